@@ -0,0 +1,109 @@
+package agents
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+func TestInsertRecordExecutorOnConflict(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "pb_data")
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DataDir:       dataDir,
+		DataDsn:       "sqlite://" + filepath.Join(dataDir, "test.db"),
+		DisableVector: true,
+	})
+
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.ResetBootstrapState()
+
+	if err := runMigrationsForTest(app); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RefreshSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(app)
+	if _, err := svc.ExecuteTool("schema.create_table", map[string]any{
+		"project": "project-1",
+		"name":    "products",
+		"fields": []any{
+			map[string]any{"name": "sku", "type": "text"},
+			map[string]any{"name": "qty", "type": "number"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ExecuteTool("schema.create_index", map[string]any{
+		"project":    "project-1",
+		"collection": "products",
+		"index":      `CREATE UNIQUE INDEX idx_products_sku ON products (sku)`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	insert := NewInsertRecordExecutor(app)
+
+	scenarios := []struct {
+		name            string
+		onConflict      string
+		data            map[string]any
+		expectError     bool
+		expectedMessage string
+		expectedQty     int
+	}{
+		{"first insert", "sku", map[string]any{"sku": "a", "qty": 1}, false, "record inserted", 1},
+		{"conflicting insert", "sku", map[string]any{"sku": "a", "qty": 2}, false, "record updated", 2},
+		{"new unique value", "sku", map[string]any{"sku": "b", "qty": 3}, false, "record inserted", 3},
+		{"non unique conflict field", "qty", map[string]any{"sku": "c", "qty": 3}, true, "", 0},
+		{"without onConflict", "", map[string]any{"sku": "a", "qty": 4}, true, "", 0},
+	}
+
+	var firstId string
+
+	for _, s := range scenarios {
+		result, err := insert(map[string]any{
+			"project":    "project-1",
+			"collection": "products",
+			"data":       s.data,
+			"onConflict": s.onConflict,
+		})
+
+		if s.expectError {
+			if err == nil {
+				t.Fatalf("[%s] expected error, got %#v", s.name, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%s] %v", s.name, err)
+		}
+
+		record, ok := result.Data.(*models.Record)
+		if !ok || result.Message != s.expectedMessage {
+			t.Fatalf("[%s] unexpected result: %#v", s.name, result)
+		}
+		if record.GetInt("qty") != s.expectedQty {
+			t.Fatalf("[%s] expected qty %d, got %d", s.name, s.expectedQty, record.GetInt("qty"))
+		}
+
+		if firstId == "" {
+			firstId = record.Id
+		} else if s.data["sku"] == "a" && record.Id != firstId {
+			t.Fatalf("[%s] expected the existing record %q to be updated, got %q", s.name, firstId, record.Id)
+		}
+	}
+
+	records, err := app.Dao().FindRecordsByExpr("products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+}
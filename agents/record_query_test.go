@@ -0,0 +1,92 @@
+package agents
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+func TestQueryAndGetRecordExecutorsFields(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "pb_data")
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DataDir:       dataDir,
+		DataDsn:       "sqlite://" + filepath.Join(dataDir, "test.db"),
+		DisableVector: true,
+	})
+
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.ResetBootstrapState()
+
+	if err := runMigrationsForTest(app); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RefreshSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(app)
+	if _, err := svc.ExecuteTool("schema.create_table", map[string]any{
+		"project": "project-1",
+		"name":    "articles",
+		"fields": []any{
+			map[string]any{"name": "title", "type": "text"},
+			map[string]any{"name": "body", "type": "json"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	inserted, err := NewInsertRecordExecutor(app)(map[string]any{
+		"project":    "project-1",
+		"collection": "articles",
+		"data":       map[string]any{"title": "test", "body": map[string]any{"a": 1, "b": 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordId := inserted.Data.(*models.Record).Id
+
+	scenarios := []struct {
+		name     string
+		tool     string
+		fields   string
+		expected string
+	}{
+		{"query all fields", "data.query", "", `"title":"test"`},
+		{"query projection", "data.query", "title, body.b", `"items":[{"body":{"b":2},"title":"test"}]`},
+		{"get all fields", "data.get", "", `"title":"test"`},
+		{"get projection", "data.get", "id,body", `{"body":{"a":1,"b":2},"id":"` + recordId + `"}`},
+	}
+
+	for _, s := range scenarios {
+		result, err := svc.ExecuteTool(s.tool, map[string]any{
+			"project":    "project-1",
+			"collection": "articles",
+			"id":         recordId,
+			"fields":     s.fields,
+		})
+		if err != nil {
+			t.Fatalf("[%s] %v", s.name, err)
+		}
+		if result.Status != "ok" {
+			t.Fatalf("[%s] unexpected result: %#v", s.name, result)
+		}
+
+		raw, err := json.Marshal(result.Data)
+		if err != nil {
+			t.Fatalf("[%s] %v", s.name, err)
+		}
+		if !strings.Contains(string(raw), s.expected) {
+			t.Fatalf("[%s] expected %s to contain %s", s.name, raw, s.expected)
+		}
+		if s.fields != "" && strings.Contains(string(raw), `"collectionId"`) {
+			t.Fatalf("[%s] expected only the projected fields, got %s", s.name, raw)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package agents
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+func TestUpdateRecordExecutorExpectedUpdated(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "pb_data")
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DataDir:       dataDir,
+		DataDsn:       "sqlite://" + filepath.Join(dataDir, "test.db"),
+		DisableVector: true,
+	})
+
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.ResetBootstrapState()
+
+	if err := runMigrationsForTest(app); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RefreshSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(app)
+	if _, err := svc.ExecuteTool("schema.create_table", map[string]any{
+		"project": "project-1",
+		"name":    "notes",
+		"fields": []any{
+			map[string]any{"name": "title", "type": "text"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	inserted, err := NewInsertRecordExecutor(app)(map[string]any{
+		"project":    "project-1",
+		"collection": "notes",
+		"data":       map[string]any{"title": "v1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, ok := inserted.Data.(*models.Record)
+	if !ok {
+		t.Fatalf("unexpected insert result: %#v", inserted)
+	}
+	version := record.GetUpdated().String()
+
+	update := NewUpdateRecordExecutor(app)
+
+	scenarios := []struct {
+		name            string
+		expectedUpdated string
+		expectedStatus  string
+		expectedTitle   string
+	}{
+		{"without precondition", "", "ok", "v2"},
+		{"stale version", version, "error", "v2"},
+	}
+
+	for _, s := range scenarios {
+		result, err := update(map[string]any{
+			"project":         "project-1",
+			"collection":      "notes",
+			"id":              record.Id,
+			"data":            map[string]any{"title": "from " + s.name},
+			"expectedUpdated": s.expectedUpdated,
+		})
+		if err != nil {
+			t.Fatalf("[%s] %v", s.name, err)
+		}
+		if result.Status != s.expectedStatus {
+			t.Fatalf("[%s] expected status %q, got %#v", s.name, s.expectedStatus, result)
+		}
+		if s.expectedStatus == "ok" {
+			version = result.Data.(*models.Record).GetUpdated().String()
+		} else if current, ok := result.Data.(*models.Record); !ok || current.GetUpdated().String() != version {
+			t.Fatalf("[%s] expected the current record version %q, got %#v", s.name, version, result.Data)
+		}
+	}
+
+	// update based on the current version
+	result, err := update(map[string]any{
+		"project":         "project-1",
+		"collection":      "notes",
+		"id":              record.Id,
+		"data":            map[string]any{"title": "v3"},
+		"expectedUpdated": version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "ok" {
+		t.Fatalf("expected ok, got %#v", result)
+	}
+}
@@ -120,6 +120,11 @@ func TestCreateTableExecutor(t *testing.T) {
 	if preview == nil || preview.Status != "ok" {
 		t.Fatalf("unexpected preview result: %#v", preview)
 	}
+	previewData, _ := preview.Data.(map[string]any)
+	exports, _ := previewData["exports"].(map[string]string)
+	if exports["csv"] != "/api/collections/posts/export?format=csv" {
+		t.Fatalf("unexpected preview exports: %#v", previewData["exports"])
+	}
 
 	indexed, err := svc.ExecuteTool("schema.create_index", map[string]any{
 		"project":    "project-1",
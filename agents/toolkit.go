@@ -16,6 +16,7 @@ import (
 	"github.com/zhenruyan/postgrebase/models/schema"
 	"github.com/zhenruyan/postgrebase/replication"
 	"github.com/zhenruyan/postgrebase/tools/dbutils"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/search"
 	"github.com/zhenruyan/postgrebase/tools/security"
 	"github.com/zhenruyan/postgrebase/tools/types"
@@ -138,10 +139,10 @@ func NewToolRegistry() *ToolRegistry {
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"project":     map[string]any{"type": "string"},
-					"name":        map[string]any{"type": "string"},
-					"displayName": map[string]any{"type": "string"},
-					"type":        map[string]any{"type": "string", "enum": []string{"base", "vector"}},
+					"project":        map[string]any{"type": "string"},
+					"name":           map[string]any{"type": "string"},
+					"displayName":    map[string]any{"type": "string"},
+					"type":           map[string]any{"type": "string", "enum": []string{"base", "vector"}},
 					"embeddingModel": map[string]any{"type": "string"},
 					"dimensions":     map[string]any{"type": "integer"},
 					"fields": map[string]any{
@@ -175,6 +176,10 @@ func NewToolRegistry() *ToolRegistry {
 					"page":       map[string]any{"type": "integer"},
 					"perPage":    map[string]any{"type": "integer"},
 					"skipTotal":  map[string]any{"type": "boolean"},
+					"fields": map[string]any{
+						"type":        "string",
+						"description": "Optional comma separated fields to return (eg. id,title). All fields are returned by default.",
+					},
 				},
 				"required": []string{"project", "collection"},
 			},
@@ -188,6 +193,10 @@ func NewToolRegistry() *ToolRegistry {
 					"project":    map[string]any{"type": "string"},
 					"collection": map[string]any{"type": "string"},
 					"data":       map[string]any{"type": "object"},
+					"onConflict": map[string]any{
+						"type":        "string",
+						"description": "Optional comma separated unique index fields (eg. sku). If a record with the same values exists, it is updated instead.",
+					},
 				},
 				"required": []string{"project", "collection", "data"},
 			},
@@ -217,6 +226,10 @@ func NewToolRegistry() *ToolRegistry {
 					"project":    map[string]any{"type": "string"},
 					"collection": map[string]any{"type": "string"},
 					"id":         map[string]any{"type": "string"},
+					"fields": map[string]any{
+						"type":        "string",
+						"description": "Optional comma separated fields to return (eg. id,title). All fields are returned by default.",
+					},
 				},
 				"required": []string{"project", "collection", "id"},
 			},
@@ -231,6 +244,10 @@ func NewToolRegistry() *ToolRegistry {
 					"collection": map[string]any{"type": "string"},
 					"id":         map[string]any{"type": "string"},
 					"data":       map[string]any{"type": "object"},
+					"expectedUpdated": map[string]any{
+						"type":        "string",
+						"description": "Optional updated date of the record version the changes are based on. The update fails if the record was changed since.",
+					},
 				},
 				"required": []string{"project", "collection", "id", "data"},
 			},
@@ -586,11 +603,13 @@ func NewQueryExecutor(app core.App) ToolExecutor {
 		records := []*models.Record{}
 		provider := search.NewProvider(search.NewSimpleFieldResolver("id", "created", "updated", "project"))
 
+		var sort []search.SortField
 		if rawFilter, ok := args["filter"]; ok && rawFilter != nil {
 			provider.AddFilter(search.FilterData(cast.ToString(rawFilter)))
 		}
 		if rawSort, ok := args["sort"]; ok && rawSort != nil {
-			provider.Sort(search.ParseSortFromString(cast.ToString(rawSort)))
+			sort = search.ParseSortFromString(cast.ToString(rawSort))
+			provider.Sort(sort)
 		}
 		if rawPage, ok := args["page"]; ok && rawPage != nil {
 			provider.Page(cast.ToInt(rawPage))
@@ -602,11 +621,21 @@ func NewQueryExecutor(app core.App) ToolExecutor {
 			provider.SkipTotal(cast.ToBool(rawSkip))
 		}
 
-		result, err := provider.Query(app.Dao().RecordQuery(collection)).Exec(&records)
+		fields := picker.ParseFields(cast.ToString(args["fields"]))
+
+		query := app.Dao().SelectRecordFields(app.Dao().RecordQuery(collection), collection, fields, nil, sort)
+
+		result, err := provider.Query(query).Exec(&records)
 		if err != nil {
 			return nil, err
 		}
 
+		if len(fields) > 0 {
+			for _, record := range records {
+				record.WithFields(fields)
+			}
+		}
+
 		return &ToolExecutionResult{
 			Status:  "ok",
 			Message: "query executed",
@@ -895,11 +924,24 @@ func NewDatasetPreviewExecutor(app core.App) ToolExecutor {
 			Message: "dataset preview generated",
 			Data: map[string]any{
 				"records": records,
+				"exports": datasetExportUrls(collection),
 			},
 		}, nil
 	}
 }
 
+// datasetExportUrls returns the relative record export api urls
+// of the collection dataset for each supported download format.
+func datasetExportUrls(collection *models.Collection) map[string]string {
+	result := map[string]string{}
+
+	for _, format := range []string{"csv", "ndjson", "xlsx"} {
+		result[format] = "/api/collections/" + collection.Name + "/export?format=" + format
+	}
+
+	return result
+}
+
 // NewInsertRecordExecutor creates a project-scoped record insert executor.
 func NewInsertRecordExecutor(app core.App) ToolExecutor {
 	return func(args map[string]any) (*ToolExecutionResult, error) {
@@ -924,14 +966,47 @@ func NewInsertRecordExecutor(app core.App) ToolExecutor {
 			return toolResult, nil
 		}
 
+		data = sanitizeRecordData(data)
+
+		var conflictFields []string
+		if onConflict := cast.ToString(args["onConflict"]); onConflict != "" {
+			if app.IsSQLiteCluster() {
+				return nil, errors.New("onConflict is not supported in SQLite cluster mode")
+			}
+
+			for _, field := range strings.Split(onConflict, ",") {
+				if field = strings.TrimSpace(field); field != "" {
+					conflictFields = append(conflictFields, field)
+				}
+			}
+
+			existing, err := app.Dao().FindRecordByConflictFields(collection, conflictFields, data)
+			if err == nil {
+				return updateConflictRecord(app, existing, data)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+		}
+
 		record := models.NewRecord(collection)
 		form := forms.NewRecordUpsert(app, record)
 		configureRecordUpsertReplication(app, form)
-		if err := form.LoadData(sanitizeRecordData(data)); err != nil {
+		form.SetConflictFields(conflictFields...)
+		if err := form.LoadData(data); err != nil {
 			return nil, err
 		}
 		if err := form.Submit(); err != nil {
-			return nil, err
+			if !errors.Is(err, daos.ErrRecordExists) {
+				return nil, err
+			}
+
+			// concurrently created in the meantime
+			existing, err := app.Dao().FindRecordByConflictFields(collection, conflictFields, data)
+			if err != nil {
+				return nil, err
+			}
+			return updateConflictRecord(app, existing, data)
 		}
 
 		return &ToolExecutionResult{
@@ -942,6 +1017,24 @@ func NewInsertRecordExecutor(app core.App) ToolExecutor {
 	}
 }
 
+// updateConflictRecord updates the existing record matching the data.insert onConflict fields.
+func updateConflictRecord(app core.App, existing *models.Record, data map[string]any) (*ToolExecutionResult, error) {
+	form := forms.NewRecordUpsert(app, existing)
+	configureRecordUpsertReplication(app, form)
+	if err := form.LoadData(data); err != nil {
+		return nil, err
+	}
+	if err := form.Submit(); err != nil {
+		return nil, err
+	}
+
+	return &ToolExecutionResult{
+		Status:  "ok",
+		Message: "record updated",
+		Data:    existing,
+	}, nil
+}
+
 // NewBulkInsertRecordExecutor creates a project-scoped record bulk insert executor.
 func NewBulkInsertRecordExecutor(app core.App) ToolExecutor {
 	return func(args map[string]any) (*ToolExecutionResult, error) {
@@ -1060,6 +1153,8 @@ func NewGetRecordExecutor(app core.App) ToolExecutor {
 			return toolResult, nil
 		}
 
+		record.WithFields(picker.ParseFields(cast.ToString(args["fields"])))
+
 		return &ToolExecutionResult{
 			Status:  "ok",
 			Message: "record fetched",
@@ -1101,7 +1196,18 @@ func NewUpdateRecordExecutor(app core.App) ToolExecutor {
 		if err := form.LoadData(sanitizeRecordData(data)); err != nil {
 			return nil, err
 		}
+		if v := cast.ToString(args["expectedUpdated"]); v != "" {
+			form.ExpectedUpdated = v
+		}
 		if err := form.Submit(); err != nil {
+			var conflictErr *forms.RecordVersionConflictError
+			if errors.As(err, &conflictErr) {
+				return &ToolExecutionResult{
+					Status:  "error",
+					Message: fmt.Sprintf("record %q was modified since %s, review the current version and retry", recordID, form.ExpectedUpdated),
+					Data:    conflictErr.Current,
+				}, nil
+			}
 			return nil, err
 		}
 
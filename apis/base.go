@@ -33,7 +33,7 @@ func InitApi(app core.App) (*echo.Echo, error) {
 	e := echo.New()
 	e.Debug = app.IsDebug()
 	e.JSONSerializer = &rest.Serializer{
-		FieldsParam: "fields",
+		FieldsParam: fieldsQueryParam,
 	}
 
 	// configure a custom router
@@ -135,6 +135,7 @@ func InitApi(app core.App) (*echo.Echo, error) {
 	bindHealthApi(app, api)
 	bindBackupApi(app, api)
 	bindMcpTokenApi(app, api)
+	bindMcpAuditApi(app, api)
 	bindAgentsApi(app, api)
 	bindAgentSessionApi(app, api)
 	bindVectorApi(app, api)
@@ -0,0 +1,66 @@
+package apis
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/migrations"
+	"github.com/zhenruyan/postgrebase/tools/migrate"
+)
+
+// newTestApp creates a new bootstrapped and migrated SQLite test app.
+func newTestApp(t *testing.T) *core.BaseApp {
+	t.Helper()
+
+	dataDir := filepath.Join(t.TempDir(), "pb_data")
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DataDir:       dataDir,
+		DataDsn:       "sqlite://" + filepath.Join(dataDir, "test.db"),
+		DisableVector: true,
+	})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+
+	runner, err := migrate.NewRunner(app.DB(), migrations.AppMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.RefreshSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	return app
+}
+
+// serveTestRequest sends a single request to the api of the provided app.
+func serveTestRequest(t *testing.T, app core.App, method string, url string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e, err := InitApi(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
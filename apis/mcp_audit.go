@@ -0,0 +1,58 @@
+package apis
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/search"
+)
+
+// bindMcpAuditApi registers the MCP tool calls audit log API endpoints.
+func bindMcpAuditApi(app core.App, rg *echo.Group) {
+	api := mcpAuditApi{app: app}
+
+	subGroup := rg.Group("/mcp-audit", RequireAdminAuth())
+	subGroup.GET("", api.list)
+	subGroup.GET("/:id", api.view)
+}
+
+type mcpAuditApi struct {
+	app core.App
+}
+
+var mcpAuditFilterFields = []string{
+	"id", "created", "token_id", "token_name", "actor", "tool", "status", "error_msg", "duration",
+}
+
+// list returns a paginated and filterable list of the MCP audit entries
+// (eg. ?filter=tool='delete_record' && status='error'&sort=-created).
+func (api *mcpAuditApi) list(c echo.Context) error {
+	fieldResolver := search.NewSimpleFieldResolver(mcpAuditFilterFields...)
+
+	result, err := search.NewProvider(fieldResolver).
+		Query(api.app.Dao().McpAuditQuery()).
+		ParseAndExec(c.QueryParams().Encode(), &[]*models.McpAuditLog{})
+
+	if err != nil {
+		return NewBadRequestError("", err)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// view returns a single MCP audit entry.
+func (api *mcpAuditApi) view(c echo.Context) error {
+	id := c.PathParam("id")
+	if id == "" {
+		return NewNotFoundError("", nil)
+	}
+
+	entry, err := api.app.Dao().FindMcpAuditLogById(id)
+	if err != nil || entry == nil {
+		return NewNotFoundError("", err)
+	}
+
+	return c.JSON(http.StatusOK, entry)
+}
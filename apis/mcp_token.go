@@ -6,6 +6,7 @@ import (
 
 	"github.com/labstack/echo/v5"
 	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/mcp"
 	"github.com/zhenruyan/postgrebase/models"
 	"github.com/zhenruyan/postgrebase/tools/security"
 )
@@ -17,6 +18,7 @@ func bindMcpTokenApi(app core.App, rg *echo.Group) {
 	subGroup := rg.Group("/mcp-tokens", ActivityLogger(app), RequireAdminAuth())
 	subGroup.GET("", api.list)
 	subGroup.POST("", api.create)
+	subGroup.PATCH("/:id", api.update)
 	subGroup.DELETE("/:id", api.delete)
 	subGroup.POST("/generate", api.generate)
 }
@@ -52,14 +54,17 @@ func (api *mcpTokenApi) list(c echo.Context) error {
 		}
 
 		item := map[string]interface{}{
-			"id":          r.Id,
-			"name":        r.GetString("name"),
-			"token":       maskedToken,
-			"description": r.GetString("description"),
-			"active":      r.GetBool("active"),
-			"expiresAt":   r.GetDateTime("expiresAt"),
-			"created":     r.Created,
-			"updated":     r.Updated,
+			"id":               r.Id,
+			"name":             r.GetString("name"),
+			"token":            maskedToken,
+			"description":      r.GetString("description"),
+			"active":           r.GetBool("active"),
+			"expiresAt":        r.GetDateTime("expiresAt"),
+			"scopes":           mcpTokenScopes(r),
+			"rateLimit":        r.GetInt("rateLimit"),
+			"destructiveLimit": r.GetInt("destructiveLimit"),
+			"created":          r.Created,
+			"updated":          r.Updated,
 		}
 		result = append(result, item)
 	}
@@ -76,9 +81,13 @@ func (api *mcpTokenApi) create(c echo.Context) error {
 
 	// Parse request body
 	var body struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		ExpiresDays int    `json:"expiresDays"` // 0 = never expires
+		Name        string           `json:"name"`
+		Description string           `json:"description"`
+		ExpiresDays int              `json:"expiresDays"` // 0 = never expires
+		Scopes      []mcp.TokenScope `json:"scopes"`      // empty = full access
+
+		RateLimit        int `json:"rateLimit"`        // tool calls per minute (0 = unlimited)
+		DestructiveLimit int `json:"destructiveLimit"` // destructive tool calls per minute (0 = unlimited)
 	}
 	if err := c.Bind(&body); err != nil {
 		return NewBadRequestError("Invalid request body", err)
@@ -88,6 +97,14 @@ func (api *mcpTokenApi) create(c echo.Context) error {
 		return NewBadRequestError("Name is required", nil)
 	}
 
+	if err := mcp.ValidateTokenScopes(body.Scopes); err != nil {
+		return NewBadRequestError("Invalid token scopes", err)
+	}
+
+	if body.RateLimit < 0 || body.DestructiveLimit < 0 {
+		return NewBadRequestError("Rate limits must be positive numbers or 0", nil)
+	}
+
 	// Generate a secure token
 	token := "mcp_" + security.RandomString(48)
 
@@ -97,6 +114,9 @@ func (api *mcpTokenApi) create(c echo.Context) error {
 	record.Set("token", token)
 	record.Set("description", body.Description)
 	record.Set("active", true)
+	record.Set("scopes", body.Scopes)
+	record.Set("rateLimit", body.RateLimit)
+	record.Set("destructiveLimit", body.DestructiveLimit)
 
 	// Set expiration if specified
 	if body.ExpiresDays > 0 {
@@ -110,14 +130,98 @@ func (api *mcpTokenApi) create(c echo.Context) error {
 
 	// Return the full token only on creation (user should copy it immediately)
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"id":          record.Id,
-		"name":        record.GetString("name"),
-		"token":       token, // Full token shown only once
-		"description": record.GetString("description"),
-		"active":      record.GetBool("active"),
-		"expiresAt":   record.GetDateTime("expiresAt"),
-		"created":     record.Created,
-		"updated":     record.Updated,
+		"id":               record.Id,
+		"name":             record.GetString("name"),
+		"token":            token, // Full token shown only once
+		"description":      record.GetString("description"),
+		"active":           record.GetBool("active"),
+		"expiresAt":        record.GetDateTime("expiresAt"),
+		"scopes":           mcpTokenScopes(record),
+		"rateLimit":        record.GetInt("rateLimit"),
+		"destructiveLimit": record.GetInt("destructiveLimit"),
+		"created":          record.Created,
+		"updated":          record.Updated,
+	})
+}
+
+// update changes the name, description, active state, scopes or rate limits of an
+// existing MCP token (the token value itself is left untouched)
+func (api *mcpTokenApi) update(c echo.Context) error {
+	id := c.PathParam("id")
+	if id == "" {
+		return NewNotFoundError("Token ID is required", nil)
+	}
+
+	collection, err := api.app.Dao().FindCollectionByNameOrId("_pb_mcp_tokens_")
+	if err != nil {
+		return NewNotFoundError("MCP tokens collection not found", err)
+	}
+
+	record, err := api.app.Dao().FindRecordById(collection.Id, id)
+	if err != nil {
+		return NewNotFoundError("Token not found", err)
+	}
+
+	// Parse request body (omitted fields are left unchanged)
+	var body struct {
+		Name        *string           `json:"name"`
+		Description *string           `json:"description"`
+		Active      *bool             `json:"active"`
+		Scopes      *[]mcp.TokenScope `json:"scopes"`
+
+		RateLimit        *int `json:"rateLimit"`
+		DestructiveLimit *int `json:"destructiveLimit"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return NewBadRequestError("Invalid request body", err)
+	}
+
+	if body.Name != nil {
+		if *body.Name == "" {
+			return NewBadRequestError("Name is required", nil)
+		}
+		record.Set("name", *body.Name)
+	}
+	if body.Description != nil {
+		record.Set("description", *body.Description)
+	}
+	if body.Active != nil {
+		record.Set("active", *body.Active)
+	}
+	if body.Scopes != nil {
+		if err := mcp.ValidateTokenScopes(*body.Scopes); err != nil {
+			return NewBadRequestError("Invalid token scopes", err)
+		}
+		record.Set("scopes", *body.Scopes)
+	}
+	if body.RateLimit != nil {
+		if *body.RateLimit < 0 {
+			return NewBadRequestError("Rate limits must be positive numbers or 0", nil)
+		}
+		record.Set("rateLimit", *body.RateLimit)
+	}
+	if body.DestructiveLimit != nil {
+		if *body.DestructiveLimit < 0 {
+			return NewBadRequestError("Rate limits must be positive numbers or 0", nil)
+		}
+		record.Set("destructiveLimit", *body.DestructiveLimit)
+	}
+
+	if err := api.app.Dao().SaveRecord(record); err != nil {
+		return NewBadRequestError("Failed to update MCP token", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":               record.Id,
+		"name":             record.GetString("name"),
+		"description":      record.GetString("description"),
+		"active":           record.GetBool("active"),
+		"expiresAt":        record.GetDateTime("expiresAt"),
+		"scopes":           mcpTokenScopes(record),
+		"rateLimit":        record.GetInt("rateLimit"),
+		"destructiveLimit": record.GetInt("destructiveLimit"),
+		"created":          record.Created,
+		"updated":          record.Updated,
 	})
 }
 
@@ -154,9 +258,13 @@ func (api *mcpTokenApi) generate(c echo.Context) error {
 
 	// Parse request body
 	var body struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		ExpiresDays int    `json:"expiresDays"`
+		Name        string           `json:"name"`
+		Description string           `json:"description"`
+		ExpiresDays int              `json:"expiresDays"`
+		Scopes      []mcp.TokenScope `json:"scopes"`
+
+		RateLimit        int `json:"rateLimit"`
+		DestructiveLimit int `json:"destructiveLimit"`
 	}
 	if err := c.Bind(&body); err != nil {
 		return NewBadRequestError("Invalid request body", err)
@@ -166,6 +274,14 @@ func (api *mcpTokenApi) generate(c echo.Context) error {
 		return NewBadRequestError("Name is required", nil)
 	}
 
+	if err := mcp.ValidateTokenScopes(body.Scopes); err != nil {
+		return NewBadRequestError("Invalid token scopes", err)
+	}
+
+	if body.RateLimit < 0 || body.DestructiveLimit < 0 {
+		return NewBadRequestError("Rate limits must be positive numbers or 0", nil)
+	}
+
 	// Generate a secure token
 	token := "mcp_" + security.RandomString(48)
 
@@ -175,6 +291,9 @@ func (api *mcpTokenApi) generate(c echo.Context) error {
 	record.Set("token", token)
 	record.Set("description", body.Description)
 	record.Set("active", true)
+	record.Set("scopes", body.Scopes)
+	record.Set("rateLimit", body.RateLimit)
+	record.Set("destructiveLimit", body.DestructiveLimit)
 
 	if body.ExpiresDays > 0 {
 		expiresAt := time.Now().Add(time.Duration(body.ExpiresDays) * 24 * time.Hour)
@@ -186,13 +305,26 @@ func (api *mcpTokenApi) generate(c echo.Context) error {
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"id":          record.Id,
-		"name":        record.GetString("name"),
-		"token":       token,
-		"description": record.GetString("description"),
-		"active":      record.GetBool("active"),
-		"expiresAt":   record.GetDateTime("expiresAt"),
-		"created":     record.Created,
-		"updated":     record.Updated,
+		"id":               record.Id,
+		"name":             record.GetString("name"),
+		"token":            token,
+		"description":      record.GetString("description"),
+		"active":           record.GetBool("active"),
+		"expiresAt":        record.GetDateTime("expiresAt"),
+		"scopes":           mcpTokenScopes(record),
+		"rateLimit":        record.GetInt("rateLimit"),
+		"destructiveLimit": record.GetInt("destructiveLimit"),
+		"created":          record.Created,
+		"updated":          record.Updated,
 	})
 }
+
+// mcpTokenScopes returns the decoded scopes of an MCP token record
+// (an empty slice means full access)
+func mcpTokenScopes(record *models.Record) []mcp.TokenScope {
+	scopes := []mcp.TokenScope{}
+	if raw := record.GetString("scopes"); raw != "" && raw != "null" {
+		_ = record.UnmarshalJSONField("scopes", &scopes)
+	}
+	return scopes
+}
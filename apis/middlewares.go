@@ -215,30 +215,12 @@ func LoadAuthContext(app core.App) echo.MiddlewareFunc {
 				return next(c)
 			}
 
-			// the schema is not required and it is only for
-			// compatibility with the defaults of some HTTP clients
-			token = strings.TrimPrefix(token, "Bearer ")
-
-			claims, _ := security.ParseUnverifiedJWT(token)
-			tokenType := cast.ToString(claims["type"])
-
-			switch tokenType {
-			case tokens.TypeAdmin:
-				admin, err := app.Dao().FindAdminByToken(
-					token,
-					app.Settings().AdminAuthToken.Secret,
-				)
-				if err == nil && admin != nil {
-					c.Set(ContextAdminKey, admin)
-				}
-			case tokens.TypeAuthRecord:
-				record, err := app.Dao().FindAuthRecordByToken(
-					token,
-					app.Settings().RecordAuthToken.Secret,
-				)
-				if err == nil && record != nil {
-					c.Set(ContextAuthRecordKey, record)
-				}
+			admin, record := findAuthByToken(app, token)
+			if admin != nil {
+				c.Set(ContextAdminKey, admin)
+			}
+			if record != nil {
+				c.Set(ContextAuthRecordKey, record)
 			}
 
 			return next(c)
@@ -246,6 +228,38 @@ func LoadAuthContext(app core.App) echo.MiddlewareFunc {
 	}
 }
 
+// findAuthByToken returns the admin or the auth record
+// of the provided auth token (both are nil if the token is invalid).
+func findAuthByToken(app core.App, token string) (*models.Admin, *models.Record) {
+	// the schema is not required and it is only for
+	// compatibility with the defaults of some HTTP clients
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	claims, _ := security.ParseUnverifiedJWT(token)
+	tokenType := cast.ToString(claims["type"])
+
+	switch tokenType {
+	case tokens.TypeAdmin:
+		admin, err := app.Dao().FindAdminByToken(
+			token,
+			app.Settings().AdminAuthToken.Secret,
+		)
+		if err == nil && admin != nil {
+			return admin, nil
+		}
+	case tokens.TypeAuthRecord:
+		record, err := app.Dao().FindAuthRecordByToken(
+			token,
+			app.Settings().RecordAuthToken.Secret,
+		)
+		if err == nil && record != nil {
+			return nil, record
+		}
+	}
+
+	return nil, nil
+}
+
 // LoadCollectionContext middleware finds the collection with related
 // path identifier and loads it into the request context.
 //
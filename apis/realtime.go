@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -15,24 +17,57 @@ import (
 	"github.com/zhenruyan/postgrebase/forms"
 	"github.com/zhenruyan/postgrebase/models"
 	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/routine"
 	"github.com/zhenruyan/postgrebase/tools/search"
+	"github.com/zhenruyan/postgrebase/tools/security"
 	"github.com/zhenruyan/postgrebase/tools/subscriptions"
 )
 
+// RealtimeIgnoreRulesKey is the realtime client context key of a bool flag
+// that makes the broker skip the collection access rules for the client
+// (eg. for the clients of the MCP tokens and local sessions).
+const RealtimeIgnoreRulesKey = "ignoreRules"
+
 // bindRealtimeApi registers the realtime api endpoints.
 func bindRealtimeApi(app core.App, rg *echo.Group) {
-	api := realtimeApi{app: app}
+	api := realtimeApi{
+		app:           app,
+		nodeId:        security.RandomString(15),
+		presence:      subscriptions.NewPresence(),
+		presenceSyncs: &presenceSyncParts{},
+	}
+
+	// with LISTEN/NOTIFY each node receives (and stores) the record changes on its own
+	// and there is no writer node to assign the shared event ids, so the replay
+	// works only for the clients reconnecting to the same node (see high-availability.md)
+	if app.RedisCache() != nil && !app.IsPgNotifyEnabled() {
+		api.events = subscriptions.NewRedisEventLog(app.RedisCache(), eventLogStream, eventLogSize)
+	} else {
+		api.events = subscriptions.NewMemoryEventLog(eventLogSize)
+	}
 
 	subGroup := rg.Group("/realtime", ActivityLogger(app))
 	subGroup.GET("", api.connect)
 	subGroup.POST("", api.setSubscriptions)
+	subGroup.GET("/ws", api.connectWS)
+	subGroup.POST("/publish", api.publish)
+	subGroup.GET("/presence", api.presenceMembers)
 
 	api.bindEvents()
+	api.startPresenceSync()
 }
 
 type realtimeApi struct {
 	app core.App
+
+	// nodeId identifies the presence members of the current app instance
+	nodeId        string
+	presence      *subscriptions.Presence
+	presenceSyncs *presenceSyncParts
+
+	// events stores the most recent record events for the Last-Event-ID replay
+	events subscriptions.EventLog
 }
 
 func (api *realtimeApi) connect(c echo.Context) error {
@@ -42,6 +77,7 @@ func (api *realtimeApi) connect(c echo.Context) error {
 
 	// register new subscription client
 	client := subscriptions.NewDefaultClient()
+	api.setClientLastEventId(client, c.Request().Header.Get("Last-Event-ID"), c.QueryParam("lastEventId"))
 	api.app.SubscriptionsBroker().Register(client)
 	defer func() {
 		disconnectEvent := &core.RealtimeDisconnectEvent{
@@ -83,7 +119,7 @@ func (api *realtimeApi) connect(c echo.Context) error {
 		Client:      client,
 		Message: &subscriptions.Message{
 			Name: "PB_CONNECT",
-			Data: []byte(`{"clientId":"` + client.Id() + `"}`),
+			Data: api.connectMessageData(client),
 		},
 	}
 	connectMsgErr := api.app.OnRealtimeBeforeMessageSend().Trigger(connectMsgEvent, func(e *core.RealtimeMessageEvent) error {
@@ -128,7 +164,9 @@ func (api *realtimeApi) connect(c echo.Context) error {
 			}
 			msgErr := api.app.OnRealtimeBeforeMessageSend().Trigger(msgEvent, func(e *core.RealtimeMessageEvent) error {
 				w := e.HttpContext.Response()
-				w.Write([]byte("id:" + e.Client.Id() + "\n"))
+				if e.Message.Id != "" {
+					w.Write([]byte("id:" + e.Message.Id + "\n"))
+				}
 				w.Write([]byte("event:" + e.Message.Name + "\n"))
 				w.Write([]byte("data:"))
 				w.Write(e.Message.Data)
@@ -182,6 +220,13 @@ func (api *realtimeApi) setSubscriptions(c echo.Context) error {
 		return NewForbiddenError("The current and the previous request authorization don't match.", nil)
 	}
 
+	// validate the subscription filters and channels (if any)
+	for _, subscription := range form.Subscriptions {
+		if err := api.checkSubscription(c, subscription); err != nil {
+			return NewBadRequestError("Invalid subscription "+subscription+".", err)
+		}
+	}
+
 	event := &core.RealtimeSubscribeEvent{
 		HttpContext:   c,
 		Client:        client,
@@ -209,6 +254,98 @@ func (api *realtimeApi) setSubscriptions(c echo.Context) error {
 	})
 }
 
+// parseSubscription splits a realtime subscription into its topic,
+// optional record filter and optional record fields projection, eg.:
+//
+//	posts/*?filter=status="published" && author=@request.auth.id
+//	posts/*?fields=id,title&filter=status="published"
+//
+// The filter takes the rest of the subscription options, so the
+// fields option should be either before it or the filter should be url
+// encoded (a literal "%" must be encoded as "%25").
+func parseSubscription(subscription string) (topic string, filter string, fields []string, err error) {
+	topic, options, hasOptions := strings.Cut(subscription, "?")
+	if !hasOptions {
+		return subscription, "", nil, nil
+	}
+
+	for options != "" {
+		switch {
+		case strings.HasPrefix(options, "fields="):
+			var rawFields string
+			rawFields, options, _ = strings.Cut(strings.TrimPrefix(options, "fields="), "&")
+			if unescaped, err := url.QueryUnescape(rawFields); err == nil {
+				rawFields = unescaped
+			}
+			fields = picker.ParseFields(rawFields)
+		case strings.HasPrefix(options, "filter="):
+			filter = strings.TrimPrefix(options, "filter=")
+			options = ""
+
+			// url encoded filter followed by other options
+			// (a raw "&&" filter operator is not a separator)
+			if i := strings.LastIndex(filter, "&fields="); i > 0 && filter[i-1] != '&' {
+				options = filter[i+1:]
+				filter = filter[:i]
+			}
+
+			if unescaped, err := url.PathUnescape(filter); err == nil {
+				filter = unescaped
+			}
+		default:
+			return "", "", nil, errors.New("only the filter and fields subscription options are supported")
+		}
+	}
+
+	return topic, strings.TrimSpace(filter), fields, nil
+}
+
+// checkSubscription checks whether the subscription filter (if any)
+// is a valid filter expression for the topic collection.
+//
+// Similar to the list api, only admins can filter by hidden fields and @collection.*.
+//
+// The custom channel subscriptions are checked against the channel subscribe rule.
+func (api *realtimeApi) checkSubscription(c echo.Context, subscription string) error {
+	topic, filter, fields, err := parseSubscription(subscription)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(topic, channelTopicPrefix) {
+		if len(fields) > 0 {
+			return errors.New("the channel subscriptions don't support fields")
+		}
+		return api.checkChannelSubscription(c, topic, filter)
+	}
+
+	if filter == "" {
+		return nil
+	}
+
+	requestInfo := RequestInfo(c)
+
+	// the same as for the list api query params
+	// (@request.auth.* is allowed since it identifies the subscriber)
+	if requestInfo.Admin == nil && strings.Contains(filter, "@collection.") {
+		return errors.New("only admins can filter by @collection")
+	}
+
+	collectionNameOrId, _, _ := strings.Cut(topic, "/")
+
+	collection, err := api.app.Dao().FindCollectionByNameOrId(collectionNameOrId)
+	if err != nil {
+		return fmt.Errorf("missing collection %q", collectionNameOrId)
+	}
+
+	resolver := resolvers.NewRecordFieldResolver(api.app.Dao(), collection, requestInfo, requestInfo.Admin != nil)
+	if _, err := search.FilterData(filter).BuildExpr(resolver); err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+
+	return nil
+}
+
 // updateClientsAuthModel updates the existing clients auth model with the new one (matched by ID).
 func (api *realtimeApi) updateClientsAuthModel(contextKey string, newModel models.Model) error {
 	for _, client := range api.app.SubscriptionsBroker().Clients() {
@@ -240,37 +377,42 @@ func (api *realtimeApi) unregisterClientsByAuthModel(contextKey string, model mo
 func (api *realtimeApi) bindEvents() {
 	// subscribe to global realtime broadcast (for HA)
 	api.app.OnRealtimeBroadcast().Add(func(e *core.RealtimeBroadcastEvent) error {
+		if e.Channel == core.PgNotifyRecordChannel {
+			return api.handleRecordChangeNotification(e.Payload)
+		}
+
 		if e.Channel != "realtime" {
 			return nil
 		}
 
-		var data struct {
-			Action       string         `json:"action"`
-			CollectionId string         `json:"collectionId"`
-			RecordData   map[string]any `json:"recordData"`
+		event := &recordEvent{}
+		if err := json.Unmarshal(e.Payload, event); err != nil {
+			return err
 		}
 
-		if err := json.Unmarshal(e.Payload, &data); err != nil {
-			return err
+		if event.Type == channelEventType {
+			return api.handleChannelEvent(e.Payload)
 		}
 
-		collection, err := api.app.Dao().FindCollectionByNameOrId(data.CollectionId)
+		record, err := api.decodeRecordEvent(event)
 		if err != nil {
 			return err
 		}
 
-		record := models.NewRecord(collection)
-		// Manually populate record from map
-		for k, v := range data.RecordData {
-			record.Set(k, v)
-		}
+		return api.localBroadcastRecord(event.EventId, event.Action, record, false)
+	})
 
-		// Ensure ID is set (though it should be in RecordData)
-		if id, ok := data.RecordData["id"].(string); ok {
-			record.SetId(id)
-		}
+	// track the presence channels members and replay the
+	// missed events of the reconnected clients
+	api.app.OnRealtimeAfterSubscribeRequest().PreAdd(func(e *core.RealtimeSubscribeEvent) error {
+		api.updateClientPresence(e.Client, false)
+		api.replayMissedEvents(e.Client)
+		return nil
+	})
 
-		return api.localBroadcastRecord(data.Action, record, false)
+	api.app.OnRealtimeDisconnectRequest().PreAdd(func(e *core.RealtimeDisconnectEvent) error {
+		api.updateClientPresence(e.Client, true)
+		return nil
 	})
 
 	// update the clients that has admin or auth record association
@@ -301,6 +443,10 @@ func (api *realtimeApi) bindEvents() {
 
 	api.app.OnModelAfterCreate().PreAdd(func(e *core.ModelEvent) error {
 		if record := api.resolveRecord(e.Model); record != nil {
+			if api.app.IsPgNotifyEnabled() {
+				return nil // captured by the collection table triggers
+			}
+
 			if err := api.publishRecord("create", record); err != nil && api.app.IsDebug() {
 				log.Println(err)
 			}
@@ -310,6 +456,10 @@ func (api *realtimeApi) bindEvents() {
 
 	api.app.OnModelAfterUpdate().PreAdd(func(e *core.ModelEvent) error {
 		if record := api.resolveRecord(e.Model); record != nil {
+			if api.app.IsPgNotifyEnabled() {
+				return nil // captured by the collection table triggers
+			}
+
 			if err := api.publishRecord("update", record); err != nil && api.app.IsDebug() {
 				log.Println(err)
 			}
@@ -324,6 +474,10 @@ func (api *realtimeApi) bindEvents() {
 
 	api.app.OnModelAfterDelete().Add(func(e *core.ModelEvent) error {
 		if record := api.resolveRecord(e.Model); record != nil {
+			if api.app.IsPgNotifyEnabled() {
+				return nil // captured by the collection table triggers
+			}
+
 			if err := api.publishRecord("delete", record); err != nil && api.app.IsDebug() {
 				log.Println(err)
 			}
@@ -358,37 +512,51 @@ func (api *realtimeApi) resolveRecordCollection(model models.Model) (collection
 	return collection
 }
 
-// canAccessRecord checks if the subscription client has access to the specified record model.
-func (api *realtimeApi) canAccessRecord(client subscriptions.Client, record *models.Record, accessRule *string) bool {
+// canAccessRecord checks if the subscription client has access to the specified record model
+// and, if filter is set, whether the record also satisfies the subscription filter.
+func (api *realtimeApi) canAccessRecord(client subscriptions.Client, record *models.Record, accessRule *string, filter string) bool {
 	admin, _ := client.Get(ContextAdminKey).(*models.Admin)
-	if admin != nil {
-		// admins can access everything
-		return true
+	ignoreRules, _ := client.Get(RealtimeIgnoreRulesKey).(bool)
+
+	// admins (and the clients with ignored rules) can access everything
+	expr := ""
+	if admin == nil && !ignoreRules {
+		if accessRule == nil {
+			// only admins can access this record
+			return false
+		}
+		expr = *accessRule
 	}
 
-	if accessRule == nil {
-		// only admins can access this record
-		return false
+	if filter != "" {
+		if expr == "" {
+			expr = filter
+		} else {
+			// evaluate the rule and the filter with a single query
+			expr = "(" + expr + ") && (" + filter + ")"
+		}
 	}
 
-	ruleFunc := func(q *dbx.SelectQuery) error {
-		if *accessRule == "" {
-			return nil // empty public rule
-		}
+	if expr == "" {
+		return true // admin or empty public rule
+	}
 
+	ruleFunc := func(q *dbx.SelectQuery) error {
 		// mock request data
 		requestInfo := &models.RequestInfo{
 			Method: "GET",
+			Admin:  admin,
 		}
 		requestInfo.AuthRecord, _ = client.Get(ContextAuthRecordKey).(*models.Record)
 
+		// the filters are checked for hidden fields on subscribe
 		resolver := resolvers.NewRecordFieldResolver(api.app.Dao(), record.Collection(), requestInfo, true)
-		expr, err := search.FilterData(*accessRule).BuildExpr(resolver)
+		whereExpr, err := search.FilterData(expr).BuildExpr(resolver)
 		if err != nil {
 			return err
 		}
 		resolver.UpdateQuery(q)
-		q.AndWhere(expr)
+		q.AndWhere(whereExpr)
 
 		return nil
 	}
@@ -412,26 +580,68 @@ func (api *realtimeApi) publishRecord(action string, record *models.Record) erro
 		return errors.New("Record collection not set.")
 	}
 
-	data := map[string]any{
-		"action":       action,
-		"collectionId": collection.Id,
-		"recordData":   record,
+	recordData, err := json.Marshal(record)
+	if err != nil {
+		return err
 	}
 
-	return api.app.Publish("realtime", data)
-}
-
-func (api *realtimeApi) localBroadcastRecord(action string, record *models.Record, dryCache bool) error {
-	collection := record.Collection()
-	if collection == nil {
-		return errors.New("Record collection not set.")
+	event := &recordEvent{
+		Type:         recordEventType,
+		Action:       action,
+		CollectionId: collection.Id,
+		RecordData:   recordData,
 	}
 
+	api.storeRecordEvent(event)
+
+	return api.app.Publish("realtime", event)
+}
+
+func (api *realtimeApi) localBroadcastRecord(eventId string, action string, record *models.Record, dryCache bool) error {
 	clients := api.app.SubscriptionsBroker().Clients()
 	if len(clients) == 0 {
 		return nil // no subscribers
 	}
 
+	event, err := api.prepareRecordEvent(eventId, action, record)
+	if err != nil {
+		return err
+	}
+
+	for _, client := range clients {
+		client := client
+
+		for _, msg := range api.clientRecordMessages(client, event) {
+			msg := msg
+
+			if dryCache {
+				client.Set(action+"/"+record.Id, msg)
+			} else {
+				routine.FireAndForget(func() {
+					client.Send(msg)
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+// preparedRecordEvent holds the client independent data of a record event.
+type preparedRecordEvent struct {
+	eventId             string
+	collection          *models.Collection
+	data                *recordData
+	dataBytes           []byte
+	subscriptionRuleMap map[string]*string
+}
+
+func (api *realtimeApi) prepareRecordEvent(eventId string, action string, record *models.Record) (*preparedRecordEvent, error) {
+	collection := record.Collection()
+	if collection == nil {
+		return nil, errors.New("Record collection not set.")
+	}
+
 	// create a clean record copy without expand and unknown fields
 	// because we don't know if the clients have permissions to view them
 	cleanRecord := record.CleanCopy()
@@ -453,51 +663,70 @@ func (api *realtimeApi) localBroadcastRecord(action string, record *models.Recor
 
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, client := range clients {
-		client := client
+	return &preparedRecordEvent{
+		eventId:             eventId,
+		collection:          collection,
+		data:                data,
+		dataBytes:           dataBytes,
+		subscriptionRuleMap: subscriptionRuleMap,
+	}, nil
+}
 
-		for subscription, rule := range subscriptionRuleMap {
-			if !client.HasSubscription(subscription) {
-				continue
-			}
+// clientRecordMessages returns the record event messages for the
+// client subscriptions (subject to the subscriptions access rules).
+func (api *realtimeApi) clientRecordMessages(client subscriptions.Client, event *preparedRecordEvent) []subscriptions.Message {
+	var messages []subscriptions.Message
 
-			if !api.canAccessRecord(client, data.Record, rule) {
-				continue
-			}
+	data := event.data
 
-			msg := subscriptions.Message{
-				Name: subscription,
-				Data: dataBytes,
-			}
+	for subscription := range client.Subscriptions() {
+		topic, filter, fields, err := parseSubscription(subscription)
+		if err != nil {
+			continue
+		}
 
-			// ignore the auth record email visibility checks for
-			// auth owner, admin or manager
-			if collection.IsAuth() {
-				authId := extractAuthIdFromGetter(client)
-				if authId == data.Record.Id ||
-					api.canAccessRecord(client, data.Record, collection.AuthOptions().ManageRule) {
-					data.Record.IgnoreEmailVisibility(true) // ignore
-					if newData, err := json.Marshal(data); err == nil {
-						msg.Data = newData
-					}
-					data.Record.IgnoreEmailVisibility(false) // restore
-				}
-			}
+		rule, ok := event.subscriptionRuleMap[topic]
+		if !ok {
+			continue
+		}
 
-			if dryCache {
-				client.Set(action+"/"+data.Record.Id, msg)
-			} else {
-				routine.FireAndForget(func() {
-					client.Send(msg)
-				})
+		if !api.canAccessRecord(client, data.Record, rule, filter) {
+			continue
+		}
+
+		msg := subscriptions.Message{
+			Name: subscription,
+			Id:   event.eventId,
+			Data: event.dataBytes,
+		}
+
+		// ignore the auth record email visibility checks for
+		// auth owner, admin or manager
+		var ignoreEmailVisibility bool
+		if event.collection.IsAuth() {
+			authId := extractAuthIdFromGetter(client)
+			ignoreEmailVisibility = authId == data.Record.Id ||
+				api.canAccessRecord(client, data.Record, event.collection.AuthOptions().ManageRule, "")
+		}
+
+		if ignoreEmailVisibility || len(fields) > 0 {
+			data.Record.IgnoreEmailVisibility(ignoreEmailVisibility)
+			data.Record.WithFields(fields)
+			if newData, err := json.Marshal(data); err == nil {
+				msg.Data = newData
 			}
+			// restore
+			data.Record.IgnoreEmailVisibility(false)
+			data.Record.WithFields(nil)
 		}
+
+		messages = append(messages, msg)
 	}
 
-	return nil
+	return messages
 }
 
 // broadcastDryCachedRecord broadcasts record if it is cached in the client context.
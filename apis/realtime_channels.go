@@ -0,0 +1,569 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/list"
+	"github.com/zhenruyan/postgrebase/tools/routine"
+	"github.com/zhenruyan/postgrebase/tools/search"
+	"github.com/zhenruyan/postgrebase/tools/security"
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+const (
+	// channelTopicPrefix is the subscription topic prefix of the custom
+	// realtime channels (eg. "channel:rooms/abc").
+	channelTopicPrefix = "channel:"
+
+	// channelEventType is the type of the channel events sent through
+	// the "realtime" app broadcast (to distinguish them from the record events).
+	channelEventType = "channel"
+
+	// channelMaxDataSize is the max size of a single published message data.
+	channelMaxDataSize = 64 << 10
+
+	// channelPgNotifyMaxDataSize is the max size of a single published
+	// message data with LISTEN/NOTIFY (the whole event must fit in
+	// a single NOTIFY payload, see [core.PgNotifyMaxPayloadSize]).
+	channelPgNotifyMaxDataSize = 6 << 10
+
+	// presenceSyncChunkSize is the approximate max size of the members
+	// of a single presence "sync" event (larger syncs are split in parts).
+	presenceSyncChunkSize = 6 << 10
+
+	// presenceSyncInterval is the interval of reporting the node presence
+	// members to the other nodes (only when Redis is enabled).
+	presenceSyncInterval = 30 * time.Second
+
+	// presenceExpireAfter is the max time without a presence report
+	// after which the node members are considered gone.
+	presenceExpireAfter = 3 * presenceSyncInterval
+
+	// clientPresenceKey is the subscription client store key
+	// with the list of the joined presence channels.
+	clientPresenceKey = "@presenceChannels"
+)
+
+// channelRuleCollection is the base collection used to resolve the channel
+// rules (the rules could reference only @request.* and @collection.* fields).
+var channelRuleCollection = &models.Collection{Name: "__channel", Type: models.CollectionTypeBase}
+
+// channelEvent is a channel event sent through the "realtime" app broadcast
+// so that it could reach the clients of all nodes.
+type channelEvent struct {
+	Type    string                                    `json:"type"`
+	Action  string                                    `json:"action"` // broadcast, join, leave or sync
+	NodeId  string                                    `json:"nodeId"`
+	Channel string                                    `json:"channel,omitempty"`
+	Member  *subscriptions.PresenceMember             `json:"member,omitempty"`
+	Data    json.RawMessage                           `json:"data,omitempty"`
+	Members map[string][]subscriptions.PresenceMember `json:"members,omitempty"`
+
+	// the "sync" events could be split in multiple parts
+	SyncId string `json:"syncId,omitempty"`
+	Part   int    `json:"part,omitempty"`
+	Parts  int    `json:"parts,omitempty"`
+}
+
+// channelMessage is the data of a channel message sent to the subscribed clients.
+type channelMessage struct {
+	Action  string                        `json:"action"` // broadcast, join or leave
+	Channel string                        `json:"channel"`
+	Sender  *subscriptions.PresenceMember `json:"sender,omitempty"`
+	Member  *subscriptions.PresenceMember `json:"member,omitempty"`
+	Data    json.RawMessage               `json:"data,omitempty"`
+}
+
+// publish sends a message to a custom realtime channel (POST /api/realtime/publish).
+func (api *realtimeApi) publish(c echo.Context) error {
+	form := struct {
+		ClientId string          `json:"clientId"`
+		Channel  string          `json:"channel"`
+		Data     json.RawMessage `json:"data"`
+	}{}
+
+	if err := c.Bind(&form); err != nil {
+		return NewBadRequestError("", err)
+	}
+
+	sender := &subscriptions.PresenceMember{AuthId: extractAuthIdFromGetter(c)}
+
+	// optional publisher client (so that the subscribers could identify it)
+	if form.ClientId != "" {
+		client, err := api.app.SubscriptionsBroker().ClientById(form.ClientId)
+		if err != nil {
+			return NewNotFoundError("Missing or invalid client id.", err)
+		}
+
+		if extractAuthIdFromGetter(client) != sender.AuthId {
+			return NewForbiddenError("The current and the client authorization don't match.", nil)
+		}
+
+		sender.ClientId = client.Id()
+	}
+
+	if err := api.publishToChannel(c, sender, form.Channel, form.Data); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// presenceMembers returns the current members of a presence
+// channel (GET /api/realtime/presence?channel=...).
+func (api *realtimeApi) presenceMembers(c echo.Context) error {
+	channel := c.QueryParam("channel")
+
+	cfg, param := api.app.Settings().Realtime.FindChannel(channel)
+	if cfg == nil || !cfg.Presence {
+		return NewNotFoundError("Missing or invalid presence channel.", nil)
+	}
+
+	if !api.canAccessChannel(c, cfg.SubscribeRule, channel, param, http.MethodGet, nil) {
+		return NewForbiddenError("You are not allowed to access the channel presence.", nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"channel": channel,
+		"members": api.presence.Members(channel),
+	})
+}
+
+// publishToChannel checks the channel publish rule and broadcasts
+// the message data to the channel subscribers of all nodes.
+func (api *realtimeApi) publishToChannel(auth getter, sender *subscriptions.PresenceMember, channel string, data json.RawMessage) error {
+	cfg, param := api.app.Settings().Realtime.FindChannel(channel)
+	if cfg == nil {
+		return NewNotFoundError("Missing or invalid channel.", nil)
+	}
+
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+
+	maxDataSize := channelMaxDataSize
+	if api.app.IsPgNotifyEnabled() {
+		maxDataSize = channelPgNotifyMaxDataSize
+	}
+
+	if len(data) > maxDataSize {
+		return NewBadRequestError(fmt.Sprintf("The message data must be less than %d bytes.", maxDataSize), nil)
+	}
+
+	if !api.canAccessChannel(auth, cfg.PublishRule, channel, param, http.MethodPost, data) {
+		return NewForbiddenError("You are not allowed to publish to the channel.", nil)
+	}
+
+	err := api.publishChannelEvent(&channelEvent{
+		Action:  "broadcast",
+		Channel: channel,
+		Member:  sender,
+		Data:    data,
+	})
+	if errors.Is(err, core.ErrPgNotifyPayloadTooLarge) {
+		return NewBadRequestError("The message is too large.", err)
+	}
+
+	return err
+}
+
+// checkChannelSubscription checks whether the current request
+// auth is allowed to subscribe to the channel topic.
+func (api *realtimeApi) checkChannelSubscription(c echo.Context, topic string, filter string) error {
+	if filter != "" {
+		return errors.New("the channel subscriptions don't support filters")
+	}
+
+	channel := strings.TrimPrefix(topic, channelTopicPrefix)
+
+	cfg, param := api.app.Settings().Realtime.FindChannel(channel)
+	if cfg == nil {
+		return fmt.Errorf("missing channel %q", channel)
+	}
+
+	if !api.canAccessChannel(c, cfg.SubscribeRule, channel, param, http.MethodGet, nil) {
+		return fmt.Errorf("not allowed to subscribe to channel %q", channel)
+	}
+
+	return nil
+}
+
+// canAccessChannel checks whether the auth state of the provided
+// request or client satisfies the channel rule.
+//
+// The rule could access the full channel name with @request.query.channel,
+// the part matched by the channel name wildcard with @request.query.param
+// and the published message data fields with @request.data.*.
+func (api *realtimeApi) canAccessChannel(auth getter, rule *string, channel string, param string, method string, data json.RawMessage) bool {
+	// admins can access everything
+	if admin, _ := auth.Get(ContextAdminKey).(*models.Admin); admin != nil {
+		return true
+	}
+
+	if rule == nil {
+		return false // admins only
+	}
+
+	if *rule == "" {
+		return true // public
+	}
+
+	requestInfo := &models.RequestInfo{
+		Method:  method,
+		Query:   map[string]any{"channel": channel, "param": param},
+		Data:    map[string]any{},
+		Headers: map[string]any{},
+	}
+	requestInfo.AuthRecord, _ = auth.Get(ContextAuthRecordKey).(*models.Record)
+	if dataMap := map[string]any{}; json.Unmarshal(data, &dataMap) == nil && dataMap != nil {
+		requestInfo.Data = dataMap // non-object data is ignored
+	}
+
+	resolver := resolvers.NewRecordFieldResolver(api.app.Dao(), channelRuleCollection, requestInfo, true)
+
+	expr, err := search.FilterData(*rule).BuildExpr(resolver)
+	if err != nil {
+		if api.app.IsDebug() {
+			log.Printf("Invalid realtime channel %q rule: %v\n", channel, err)
+		}
+		return false
+	}
+
+	query := api.app.Dao().DB().
+		Select("count(*)").
+		From(channelRuleCollection.Name).
+		AndWhere(expr)
+	resolver.UpdateQuery(query)
+
+	// the rule base "table" is a single row CTE so that
+	// it could be referenced also by the multi-match subqueries
+	built := query.Build()
+	withQuery := api.app.Dao().DB().
+		NewQuery("WITH {{" + channelRuleCollection.Name + "}} AS (SELECT '' AS [[id]]) " + built.SQL()).
+		Bind(built.Params())
+
+	var total int
+	if err := withQuery.Row(&total); err != nil {
+		if api.app.IsDebug() {
+			log.Printf("Failed to check realtime channel %q rule: %v\n", channel, err)
+		}
+		return false
+	}
+
+	return total > 0
+}
+
+// updateClientPresence publishes the presence join/leave events for the
+// changes of the client presence channel subscriptions since the last call.
+func (api *realtimeApi) updateClientPresence(client subscriptions.Client, disconnected bool) {
+	prevChannels, _ := client.Get(clientPresenceKey).([]string)
+
+	channels := []string{}
+	if !disconnected {
+		for sub := range client.Subscriptions() {
+			channel, ok := strings.CutPrefix(sub, channelTopicPrefix)
+			if !ok {
+				continue
+			}
+
+			if cfg, _ := api.app.Settings().Realtime.FindChannel(channel); cfg != nil && cfg.Presence {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	client.Set(clientPresenceKey, channels)
+
+	member := &subscriptions.PresenceMember{
+		ClientId: client.Id(),
+		AuthId:   extractAuthIdFromGetter(client),
+	}
+
+	for _, channel := range channels {
+		if !list.ExistInSlice(channel, prevChannels) {
+			api.publishPresenceEvent("join", channel, member)
+		}
+	}
+
+	for _, channel := range prevChannels {
+		if !list.ExistInSlice(channel, channels) {
+			api.publishPresenceEvent("leave", channel, member)
+		}
+	}
+}
+
+func (api *realtimeApi) publishPresenceEvent(action string, channel string, member *subscriptions.PresenceMember) {
+	err := api.publishChannelEvent(&channelEvent{
+		Action:  action,
+		Channel: channel,
+		Member:  member,
+	})
+	if err != nil && api.app.IsDebug() {
+		log.Println("Failed to publish realtime presence event:", err)
+	}
+}
+
+func (api *realtimeApi) publishChannelEvent(event *channelEvent) error {
+	event.Type = channelEventType
+	event.NodeId = api.nodeId
+
+	return api.app.Publish("realtime", event)
+}
+
+// handleChannelEvent processes a channel event received
+// from the "realtime" app broadcast.
+func (api *realtimeApi) handleChannelEvent(payload []byte) error {
+	event := &channelEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return err
+	}
+
+	switch event.Action {
+	case "broadcast":
+		api.localBroadcastChannel(event.Channel, &channelMessage{
+			Action:  "broadcast",
+			Channel: event.Channel,
+			Sender:  event.Member,
+			Data:    event.Data,
+		})
+	case "join":
+		if event.Member != nil && api.presence.Join(event.NodeId, event.Channel, *event.Member) {
+			api.localBroadcastPresence(subscriptions.PresenceChange{Channel: event.Channel, Member: *event.Member, Joined: true})
+		}
+	case "leave":
+		if event.Member == nil {
+			return nil
+		}
+		if member, ok := api.presence.Leave(event.NodeId, event.Channel, event.Member.ClientId); ok {
+			api.localBroadcastPresence(subscriptions.PresenceChange{Channel: event.Channel, Member: member})
+		}
+	case "sync":
+		members, ok := api.presenceSyncs.add(event)
+		if !ok {
+			return nil // wait for the remaining parts
+		}
+
+		for _, change := range api.presence.Sync(event.NodeId, members) {
+			api.localBroadcastPresence(change)
+		}
+	}
+
+	return nil
+}
+
+func (api *realtimeApi) localBroadcastPresence(change subscriptions.PresenceChange) {
+	action := "leave"
+	if change.Joined {
+		action = "join"
+	}
+
+	member := change.Member
+
+	api.localBroadcastChannel(change.Channel, &channelMessage{
+		Action:  action,
+		Channel: change.Channel,
+		Member:  &member,
+	})
+}
+
+// localBroadcastChannel sends the channel message to the channel
+// subscribers of the current node (subject to the channel subscribe rule).
+func (api *realtimeApi) localBroadcastChannel(channel string, msg *channelMessage) {
+	cfg, param := api.app.Settings().Realtime.FindChannel(channel)
+	if cfg == nil {
+		return // the channel was removed
+	}
+
+	dataBytes, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	topic := channelTopicPrefix + channel
+
+	for _, client := range api.app.SubscriptionsBroker().Clients() {
+		if !client.HasSubscription(topic) {
+			continue
+		}
+
+		if !api.canAccessChannel(client, cfg.SubscribeRule, channel, param, http.MethodGet, nil) {
+			continue
+		}
+
+		client := client
+
+		routine.FireAndForget(func() {
+			client.Send(subscriptions.Message{
+				Name: topic,
+				Data: dataBytes,
+			})
+		})
+	}
+}
+
+// startPresenceSync periodically reports the node presence members
+// and expires the members of the stopped nodes (only with Redis or LISTEN/NOTIFY).
+func (api *realtimeApi) startPresenceSync() {
+	done := make(chan struct{})
+
+	var stopOnce sync.Once
+	api.app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+		stopOnce.Do(func() {
+			close(done)
+		})
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(presenceSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				api.syncPresence()
+			}
+		}
+	}()
+}
+
+func (api *realtimeApi) syncPresence() {
+	if api.app.RedisCache() == nil && !api.app.IsPgNotifyEnabled() {
+		return // single node
+	}
+
+	members := map[string][]subscriptions.PresenceMember{}
+
+	for _, client := range api.app.SubscriptionsBroker().Clients() {
+		channels, _ := client.Get(clientPresenceKey).([]string)
+		for _, channel := range channels {
+			members[channel] = append(members[channel], subscriptions.PresenceMember{
+				ClientId: client.Id(),
+				AuthId:   extractAuthIdFromGetter(client),
+			})
+		}
+	}
+
+	// split the members so that each part fits in a single NOTIFY payload
+	parts := splitPresenceMembers(members, presenceSyncChunkSize)
+	syncId := security.RandomString(10)
+
+	for i, part := range parts {
+		err := api.publishChannelEvent(&channelEvent{
+			Action:  "sync",
+			Members: part,
+			SyncId:  syncId,
+			Part:    i + 1,
+			Parts:   len(parts),
+		})
+		if err != nil {
+			if api.app.IsDebug() {
+				log.Println("Failed to publish realtime presence sync:", err)
+			}
+			break
+		}
+	}
+
+	for _, change := range api.presence.Expire(time.Now().Add(-presenceExpireAfter)) {
+		api.localBroadcastPresence(change)
+	}
+}
+
+// splitPresenceMembers splits the channel members in parts with
+// approximately up to maxSize bytes of serialized members each.
+//
+// Returns at least one (possibly empty) part.
+func splitPresenceMembers(members map[string][]subscriptions.PresenceMember, maxSize int) []map[string][]subscriptions.PresenceMember {
+	channels := make([]string, 0, len(members))
+	for channel := range members {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	parts := []map[string][]subscriptions.PresenceMember{{}}
+	size := 0
+
+	for _, channel := range channels {
+		for _, member := range members[channel] {
+			// rough json size estimate of the member and its channel key
+			memberSize := len(channel) + len(member.ClientId) + len(member.AuthId) + 40
+
+			if size > 0 && size+memberSize > maxSize {
+				parts = append(parts, map[string][]subscriptions.PresenceMember{})
+				size = 0
+			}
+
+			part := parts[len(parts)-1]
+			part[channel] = append(part[channel], member)
+			size += memberSize
+		}
+	}
+
+	return parts
+}
+
+// presenceSyncParts collects the parts of the split
+// presence "sync" events of the other nodes.
+type presenceSyncParts struct {
+	mux     sync.Mutex
+	pending map[string]*presenceSyncPending // by node id
+}
+
+type presenceSyncPending struct {
+	syncId   string
+	received int
+	members  map[string][]subscriptions.PresenceMember
+}
+
+// add stores the "sync" event part and returns the merged members
+// of all parts once all of them were received.
+//
+// A part of a newer sync discards the incomplete older one.
+func (p *presenceSyncParts) add(event *channelEvent) (map[string][]subscriptions.PresenceMember, bool) {
+	if event.Parts <= 1 {
+		return event.Members, true
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.pending == nil {
+		p.pending = map[string]*presenceSyncPending{}
+	}
+
+	pending := p.pending[event.NodeId]
+	if pending == nil || pending.syncId != event.SyncId {
+		pending = &presenceSyncPending{
+			syncId:  event.SyncId,
+			members: map[string][]subscriptions.PresenceMember{},
+		}
+		p.pending[event.NodeId] = pending
+	}
+
+	for channel, members := range event.Members {
+		pending.members[channel] = append(pending.members[channel], members...)
+	}
+	pending.received++
+
+	if pending.received < event.Parts {
+		return nil, false
+	}
+
+	delete(p.pending, event.NodeId)
+
+	return pending.members, true
+}
@@ -0,0 +1,145 @@
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+func TestSplitPresenceMembers(t *testing.T) {
+	newMembers := func(channels int, perChannel int) map[string][]subscriptions.PresenceMember {
+		result := map[string][]subscriptions.PresenceMember{}
+		for i := 0; i < channels; i++ {
+			channel := fmt.Sprintf("rooms/%d", i)
+			for j := 0; j < perChannel; j++ {
+				result[channel] = append(result[channel], subscriptions.PresenceMember{
+					ClientId: fmt.Sprintf("client_%d_%d_abcdefghijklmnopqrstuvwxyz", i, j),
+					AuthId:   fmt.Sprintf("auth_%d", j),
+				})
+			}
+		}
+		return result
+	}
+
+	scenarios := []struct {
+		name        string
+		members     map[string][]subscriptions.PresenceMember
+		expectParts int
+	}{
+		{"no members", map[string][]subscriptions.PresenceMember{}, 1},
+		{"single small part", newMembers(2, 3), 1},
+		{"single large channel", newMembers(1, 500), 8},
+		{"many channels", newMembers(300, 2), 10},
+	}
+
+	for _, s := range scenarios {
+		parts := splitPresenceMembers(s.members, presenceSyncChunkSize)
+
+		if len(parts) != s.expectParts {
+			t.Errorf("[%s] Expected %d parts, got %d", s.name, s.expectParts, len(parts))
+		}
+
+		merged := map[string][]subscriptions.PresenceMember{}
+		for i, part := range parts {
+			event := &channelEvent{
+				Type:    channelEventType,
+				Action:  "sync",
+				NodeId:  "abcdefghijklmno",
+				Members: part,
+				SyncId:  "abcdefghij",
+				Part:    i + 1,
+				Parts:   len(parts),
+			}
+
+			raw, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(raw) > core.PgNotifyMaxPayloadSize {
+				t.Errorf("[%s] Expected part %d to fit in a NOTIFY payload, got %d bytes", s.name, i, len(raw))
+			}
+
+			for channel, members := range part {
+				merged[channel] = append(merged[channel], members...)
+			}
+		}
+
+		if !reflect.DeepEqual(merged, s.members) {
+			t.Errorf("[%s] Expected the merged parts to match the original members", s.name)
+		}
+	}
+}
+
+func TestPresenceSyncPartsAdd(t *testing.T) {
+	member := func(id string) []subscriptions.PresenceMember {
+		return []subscriptions.PresenceMember{{ClientId: id}}
+	}
+
+	scenarios := []struct {
+		name         string
+		events       []*channelEvent
+		expectDone   []bool
+		expectMerged map[string][]subscriptions.PresenceMember
+	}{
+		{
+			"single part",
+			[]*channelEvent{
+				{NodeId: "n1", Members: map[string][]subscriptions.PresenceMember{"a": member("1")}},
+			},
+			[]bool{true},
+			map[string][]subscriptions.PresenceMember{"a": member("1")},
+		},
+		{
+			"multiple parts",
+			[]*channelEvent{
+				{NodeId: "n1", SyncId: "s1", Part: 1, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("1")}},
+				{NodeId: "n1", SyncId: "s1", Part: 2, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("2"), "b": member("3")}},
+			},
+			[]bool{false, true},
+			map[string][]subscriptions.PresenceMember{"a": append(member("1"), member("2")...), "b": member("3")},
+		},
+		{
+			"newer sync discards the incomplete one",
+			[]*channelEvent{
+				{NodeId: "n1", SyncId: "s1", Part: 1, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("old")}},
+				{NodeId: "n1", SyncId: "s2", Part: 1, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("1")}},
+				{NodeId: "n1", SyncId: "s2", Part: 2, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"b": member("2")}},
+			},
+			[]bool{false, false, true},
+			map[string][]subscriptions.PresenceMember{"a": member("1"), "b": member("2")},
+		},
+		{
+			"parts of different nodes",
+			[]*channelEvent{
+				{NodeId: "n1", SyncId: "s1", Part: 1, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("1")}},
+				{NodeId: "n2", SyncId: "s1", Part: 1, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("other")}},
+				{NodeId: "n1", SyncId: "s1", Part: 2, Parts: 2, Members: map[string][]subscriptions.PresenceMember{"a": member("2")}},
+			},
+			[]bool{false, false, true},
+			map[string][]subscriptions.PresenceMember{"a": append(member("1"), member("2")...)},
+		},
+	}
+
+	for _, s := range scenarios {
+		parts := &presenceSyncParts{}
+
+		var merged map[string][]subscriptions.PresenceMember
+		for i, event := range s.events {
+			members, done := parts.add(event)
+			if done != s.expectDone[i] {
+				t.Fatalf("[%s] Expected event %d done %v, got %v", s.name, i, s.expectDone[i], done)
+			}
+			if done {
+				merged = members
+			}
+		}
+
+		if !reflect.DeepEqual(merged, s.expectMerged) {
+			t.Errorf("[%s] Expected merged members \n%v, \ngot \n%v", s.name, s.expectMerged, merged)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/routine"
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+const (
+	// recordEventType is the broadcast event type of the record changes.
+	recordEventType = "record"
+
+	// eventLogStream is the Redis Stream key of the realtime events log.
+	eventLogStream = "pb_realtime_events"
+
+	// eventLogSize is the max number of the stored events available for replay.
+	eventLogSize = 1000
+
+	// clientLastEventIdKey is the subscription client store key with the
+	// id of the last received event before the client has reconnected.
+	clientLastEventIdKey = "@lastEventId"
+
+	// resyncMessageName is the name of the message sent to the reconnected
+	// clients when the missed events are no longer available.
+	resyncMessageName = "PB_RESYNC"
+)
+
+// recordEvent is the broadcast payload of a single record change.
+type recordEvent struct {
+	Type         string          `json:"type"`
+	EventId      string          `json:"eventId,omitempty"`
+	Action       string          `json:"action"`
+	CollectionId string          `json:"collectionId"`
+	RecordData   json.RawMessage `json:"recordData"`
+}
+
+// decodeRecordEvent populates a new record model from the event data.
+func (api *realtimeApi) decodeRecordEvent(event *recordEvent) (*models.Record, error) {
+	collection, err := api.app.Dao().FindCollectionByNameOrId(event.CollectionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRecordFromJSON(collection, event.RecordData)
+}
+
+// newRecordFromJSON populates a new collection record model from the serialized record data.
+func newRecordFromJSON(collection *models.Collection, rawData json.RawMessage) (*models.Record, error) {
+	data := map[string]any{}
+	if err := json.Unmarshal(rawData, &data); err != nil {
+		return nil, err
+	}
+
+	record := models.NewRecord(collection)
+	// Manually populate record from map
+	for k, v := range data {
+		record.Set(k, v)
+	}
+
+	// Ensure ID is set (though it should be in RecordData)
+	if id, ok := data["id"].(string); ok {
+		record.SetId(id)
+	}
+
+	return record, nil
+}
+
+// storeRecordEvent stores the record event for the Last-Event-ID replay
+// and assigns its event id.
+func (api *realtimeApi) storeRecordEvent(event *recordEvent) {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	id, err := api.events.Append(raw)
+	if err != nil {
+		if api.app.IsDebug() {
+			log.Println("Failed to store realtime event:", err)
+		}
+		return
+	}
+
+	event.EventId = id
+}
+
+// handleRecordChangeNotification broadcasts to the local clients
+// a record change captured by the PostgreSQL collection table triggers
+// (including the changes made outside of the app).
+func (api *realtimeApi) handleRecordChangeNotification(payload []byte) error {
+	n := &core.RecordChangeNotification{}
+	if err := json.Unmarshal(payload, n); err != nil {
+		return err
+	}
+
+	collection, err := api.app.Dao().FindCollectionByNameOrId(n.Table)
+	if err != nil {
+		return nil // not a collection table
+	}
+
+	var record *models.Record
+	action := n.Action
+
+	if action == "delete" {
+		record = models.NewRecord(collection)
+		if len(n.Record) > 0 {
+			// the deleted row has the raw column values, so they are
+			// loaded through Set to be normalized as the regular records
+			if r, err := newRecordFromJSON(collection, n.Record); err == nil {
+				record = r
+			}
+		}
+		record.SetId(n.Id)
+	} else {
+		if action == "insert" {
+			action = "create"
+		}
+
+		record, err = api.app.Dao().FindRecordById(collection.Id, n.Id)
+		if err != nil {
+			return nil // eg. already deleted
+		}
+	}
+
+	recordData, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	event := &recordEvent{
+		Type:         recordEventType,
+		Action:       action,
+		CollectionId: collection.Id,
+		RecordData:   recordData,
+	}
+
+	api.storeRecordEvent(event)
+
+	return api.localBroadcastRecord(event.EventId, action, record, false)
+}
+
+// setClientLastEventId stores the first nonempty event id from the
+// provided values (eg. the Last-Event-ID header or query parameter)
+// so that the missed events could be replayed once the client
+// submits its subscriptions.
+func (api *realtimeApi) setClientLastEventId(client subscriptions.Client, values ...string) {
+	for _, v := range values {
+		// skip the old SSE client ids (the EventSource last id of a
+		// connection without record events) and other invalid values
+		if subscriptions.IsEventId(v) {
+			client.Set(clientLastEventIdKey, v)
+			return
+		}
+	}
+}
+
+// connectMessageData returns the PB_CONNECT message data
+// with the client id and the id of the most recent event.
+func (api *realtimeApi) connectMessageData(client subscriptions.Client) []byte {
+	lastEventId, err := api.events.LastId()
+	if err != nil && api.app.IsDebug() {
+		log.Println("Failed to load the last realtime event id:", err)
+	}
+
+	data, _ := json.Marshal(map[string]string{
+		"clientId":    client.Id(),
+		"lastEventId": lastEventId,
+	})
+
+	return data
+}
+
+// replayMissedEvents sends to the reconnected client the record events
+// that happened after its last received event (if any).
+//
+// The events are filtered against the current client subscriptions and
+// access rules. If some of the events are no longer available, a single
+// PB_RESYNC message is sent instead so that the client could reload its data.
+func (api *realtimeApi) replayMissedEvents(client subscriptions.Client) {
+	lastEventId, _ := client.Get(clientLastEventIdKey).(string)
+	if lastEventId == "" {
+		return
+	}
+
+	// replay only once per connection
+	client.Unset(clientLastEventIdKey)
+
+	events, err := api.events.After(lastEventId)
+	if err != nil {
+		if !errors.Is(err, subscriptions.ErrEventGap) && api.app.IsDebug() {
+			log.Println("Failed to load the missed realtime events:", err)
+		}
+
+		headId, _ := api.events.LastId()
+		data, _ := json.Marshal(map[string]string{"lastEventId": headId})
+
+		routine.FireAndForget(func() {
+			client.Send(subscriptions.Message{
+				Name: resyncMessageName,
+				Id:   headId,
+				Data: data,
+			})
+		})
+
+		return
+	}
+
+	messages := []subscriptions.Message{}
+
+	for _, e := range events {
+		event := &recordEvent{}
+		if err := json.Unmarshal(e.Data, event); err != nil {
+			continue
+		}
+
+		record, err := api.decodeRecordEvent(event)
+		if err != nil {
+			continue // eg. deleted collection
+		}
+
+		prepared, err := api.prepareRecordEvent(e.Id, event.Action, record)
+		if err != nil {
+			continue
+		}
+
+		messages = append(messages, api.clientRecordMessages(client, prepared)...)
+	}
+
+	if len(messages) == 0 {
+		return
+	}
+
+	// send sequentially to preserve the events order
+	routine.FireAndForget(func() {
+		for _, msg := range messages {
+			client.Send(msg)
+		}
+	})
+}
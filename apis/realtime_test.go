@@ -0,0 +1,101 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+func TestRealtimeCheckSubscriptionFilter(t *testing.T) {
+	app := newTestApp(t)
+
+	users, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.ListRule = types.Pointer("")
+	users.ViewRule = types.Pointer("")
+	if err := app.Dao().SaveCollection(users); err != nil {
+		t.Fatal(err)
+	}
+
+	authRecord := models.NewRecord(users)
+	authRecord.Id = "user1"
+
+	api := &realtimeApi{app: app}
+
+	scenarios := []struct {
+		name         string
+		admin        *models.Admin
+		authRecord   *models.Record
+		subscription string
+		expectError  bool
+	}{
+		{"guest without filter", nil, nil, "users", false},
+		{"guest with field filter", nil, nil, "users?filter=" + "name='a'", false},
+		{"guest with @collection filter", nil, nil, "users?filter=" + "@collection.users.email='a@example.com'", true},
+		{"auth with @request.auth filter", nil, authRecord, "users/*?filter=" + "id=@request.auth.id", false},
+		{"auth with @collection filter", nil, authRecord, "users/*?filter=" + "@collection.users.tokenKey!=''", true},
+		{"admin with @collection filter", &models.Admin{}, nil, "users?filter=" + "@collection.users.email='a@example.com'", false},
+	}
+
+	for _, s := range scenarios {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/api/realtime", nil), httptest.NewRecorder())
+		if s.admin != nil {
+			c.Set(ContextAdminKey, s.admin)
+		}
+		if s.authRecord != nil {
+			c.Set(ContextAuthRecordKey, s.authRecord)
+		}
+
+		err := api.checkSubscription(c, s.subscription)
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.name, s.expectError, hasErr, err)
+		}
+	}
+}
+
+func TestRealtimeCanAccessRecordIgnoreRules(t *testing.T) {
+	app := newTestApp(t)
+
+	users, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := models.NewRecord(users)
+	record.Id = "user1"
+
+	api := &realtimeApi{app: app}
+
+	scenarios := []struct {
+		name     string
+		values   map[string]any
+		expected bool
+	}{
+		{"guest", nil, false},
+		{"ignoreRules false", map[string]any{RealtimeIgnoreRulesKey: false}, false},
+		{"ignoreRules true", map[string]any{RealtimeIgnoreRulesKey: true}, true},
+		{"admin", map[string]any{ContextAdminKey: &models.Admin{}}, true},
+	}
+
+	for _, s := range scenarios {
+		client := subscriptions.NewDefaultClient()
+		for k, v := range s.values {
+			client.Set(k, v)
+		}
+
+		// nil rule - only admins can access
+		result := api.canAccessRecord(client, record, nil, "")
+		if result != s.expected {
+			t.Errorf("[%s] Expected %v, got %v", s.name, s.expected, result)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// realtimeWSMaxPayload is the max size of a single client message.
+	realtimeWSMaxPayload = 64 << 10
+
+	// realtimeWSWriteTimeout is the max time for writing a single server message.
+	realtimeWSWriteTimeout = 10 * time.Second
+)
+
+// realtimeWSRequest is a client message of the realtime WebSocket connection.
+//
+// Supported types:
+//
+//	auth        - sets or refreshes the connection auth state (empty token for guest)
+//	subscribe   - adds the provided subscriptions
+//	unsubscribe - removes the provided subscriptions (all if empty)
+//	publish     - sends the data to a custom channel
+//	presence    - replies with the custom channel members
+//	ping        - replies with pong
+type realtimeWSRequest struct {
+	Type          string          `json:"type"`
+	Id            any             `json:"id,omitempty"` // optional id echoed in the reply
+	Token         string          `json:"token,omitempty"`
+	Subscriptions []string        `json:"subscriptions,omitempty"`
+	Channel       string          `json:"channel,omitempty"`
+	Data          json.RawMessage `json:"data,omitempty"`
+}
+
+// realtimeWSReply is a server message of the realtime WebSocket connection.
+//
+// The broker messages (including PB_CONNECT) are sent with type "message"
+// and the replies of the client messages with type "ack", "error" or "pong".
+type realtimeWSReply struct {
+	Type    string          `json:"type"`
+	Id      any             `json:"id,omitempty"`
+	EventId string          `json:"eventId,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// connectWS handles the realtime WebSocket connection (GET /api/realtime/ws).
+//
+// It is an alternative to the SSE connection + POST subscriptions flow,
+// carrying the subscriptions management and the broker messages over
+// a single bidirectional connection.
+func (api *realtimeApi) connectWS(c echo.Context) error {
+	// cache the request info before the connection is hijacked
+	// (it is used later to validate the subscription filters)
+	RequestInfo(c)
+
+	// the Handshake is not set so that any origin is allowed
+	// (the same as for the SSE endpoint)
+	server := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			api.serveWS(c, conn)
+		},
+	}
+
+	server.ServeHTTP(c.Response(), c.Request())
+
+	return nil
+}
+
+func (api *realtimeApi) serveWS(c echo.Context, conn *websocket.Conn) {
+	defer conn.Close()
+
+	conn.MaxPayloadBytes = realtimeWSMaxPayload
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// register new subscription client
+	client := subscriptions.NewDefaultClient()
+	client.Set(ContextAdminKey, c.Get(ContextAdminKey))
+	client.Set(ContextAuthRecordKey, c.Get(ContextAuthRecordKey))
+	api.setClientLastEventId(client, c.QueryParam("lastEventId"))
+	api.app.SubscriptionsBroker().Register(client)
+	defer func() {
+		disconnectEvent := &core.RealtimeDisconnectEvent{
+			HttpContext: c,
+			Client:      client,
+		}
+
+		if err := api.app.OnRealtimeDisconnectRequest().Trigger(disconnectEvent); err != nil && api.app.IsDebug() {
+			log.Println(err)
+		}
+
+		api.app.SubscriptionsBroker().Unregister(client.Id())
+	}()
+
+	connectEvent := &core.RealtimeConnectEvent{
+		HttpContext: c,
+		Client:      client,
+		IdleTimeout: 5 * time.Minute,
+	}
+
+	if err := api.app.OnRealtimeConnectRequest().Trigger(connectEvent); err != nil {
+		writeWSReply(conn, &realtimeWSReply{Type: "error", Message: err.Error()})
+		return
+	}
+
+	if api.app.IsDebug() {
+		log.Printf("Realtime WebSocket connection established: %s\n", client.Id())
+	}
+
+	// signalize established connection (aka. fire "connect" message)
+	connectMsg := &subscriptions.Message{
+		Name: "PB_CONNECT",
+		Data: api.connectMessageData(client),
+	}
+	if err := api.sendWSMessage(c, conn, client, connectMsg); err != nil {
+		if api.app.IsDebug() {
+			log.Println("Realtime WebSocket connection closed (failed to deliver PB_CONNECT):", client.Id(), err)
+		}
+		return
+	}
+
+	// read the client messages in the background
+	// (all writes happen in the loop below)
+	requests := make(chan *realtimeWSRequest)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var raw []byte
+			if err := websocket.Message.Receive(conn, &raw); err != nil {
+				readErr <- err
+				return
+			}
+
+			req := &realtimeWSRequest{}
+			if err := json.Unmarshal(raw, req); err != nil {
+				req = &realtimeWSRequest{Type: "invalid"}
+			}
+
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// start an idle timer to keep track of inactive/forgotten connections
+	idleTimeout := connectEvent.IdleTimeout
+	idleTimer := time.NewTimer(idleTimeout)
+	defer idleTimer.Stop()
+
+	for {
+		select {
+		case <-idleTimer.C:
+			if api.app.IsDebug() {
+				log.Println("Realtime WebSocket connection closed (idle timeout):", client.Id())
+			}
+			return
+		case err := <-readErr:
+			if api.app.IsDebug() {
+				log.Println("Realtime WebSocket connection closed:", client.Id(), err)
+			}
+			return
+		case req := <-requests:
+			if err := writeWSReply(conn, api.handleWSRequest(c, client, req)); err != nil {
+				return
+			}
+		case msg, ok := <-client.Channel():
+			if !ok {
+				// channel is closed
+				if api.app.IsDebug() {
+					log.Println("Realtime WebSocket connection closed (closed channel):", client.Id())
+				}
+				return
+			}
+
+			if err := api.sendWSMessage(c, conn, client, &msg); err != nil {
+				if api.app.IsDebug() {
+					log.Println("Realtime WebSocket connection closed (failed to deliver message):", client.Id(), err)
+				}
+				return
+			}
+		}
+
+		idleTimer.Stop()
+		idleTimer.Reset(idleTimeout)
+	}
+}
+
+// handleWSRequest processes a single client message and returns its reply.
+func (api *realtimeApi) handleWSRequest(c echo.Context, client subscriptions.Client, req *realtimeWSRequest) *realtimeWSReply {
+	var err error
+	var data json.RawMessage
+
+	switch req.Type {
+	case "ping":
+		return &realtimeWSReply{Type: "pong", Id: req.Id}
+	case "publish":
+		sender := &subscriptions.PresenceMember{
+			ClientId: client.Id(),
+			AuthId:   extractAuthIdFromGetter(client),
+		}
+		err = api.publishToChannel(client, sender, req.Channel, req.Data)
+	case "presence":
+		data, err = api.channelPresenceData(client, req.Channel)
+	case "auth":
+		err = api.authenticateWSClient(c, client, req.Token)
+	case "subscribe", "unsubscribe":
+		err = api.updateWSSubscriptions(c, client, req.Type == "subscribe", req.Subscriptions)
+	case "invalid":
+		err = errors.New("Invalid JSON message.")
+	default:
+		err = errors.New("Unsupported message type " + req.Type + ".")
+	}
+
+	if err != nil {
+		return &realtimeWSReply{Type: "error", Id: req.Id, Message: err.Error()}
+	}
+
+	return &realtimeWSReply{Type: "ack", Id: req.Id, Data: data}
+}
+
+// channelPresenceData returns the serialized members of a presence channel.
+func (api *realtimeApi) channelPresenceData(client subscriptions.Client, channel string) (json.RawMessage, error) {
+	cfg, param := api.app.Settings().Realtime.FindChannel(channel)
+	if cfg == nil || !cfg.Presence {
+		return nil, errors.New("Missing or invalid presence channel.")
+	}
+
+	if !api.canAccessChannel(client, cfg.SubscribeRule, channel, param, http.MethodGet, nil) {
+		return nil, errors.New("You are not allowed to access the channel presence.")
+	}
+
+	return json.Marshal(api.presence.Members(channel))
+}
+
+// authenticateWSClient sets the connection auth state from the provided token.
+//
+// The existing subscriptions are removed if the auth identity changes
+// because they were authorized for the previous one.
+func (api *realtimeApi) authenticateWSClient(c echo.Context, client subscriptions.Client, token string) error {
+	admin, record := findAuthByToken(api.app, token)
+	if token != "" && admin == nil && record == nil {
+		return errors.New("Invalid or expired auth token.")
+	}
+
+	oldAuthId := extractAuthIdFromGetter(client)
+
+	c.Set(ContextAdminKey, admin)
+	c.Set(ContextAuthRecordKey, record)
+	client.Set(ContextAdminKey, admin)
+	client.Set(ContextAuthRecordKey, record)
+
+	if extractAuthIdFromGetter(client) != oldAuthId {
+		client.Unsubscribe()
+		api.updateClientPresence(client, false)
+	}
+
+	return nil
+}
+
+// updateWSSubscriptions adds or removes the client subscriptions.
+func (api *realtimeApi) updateWSSubscriptions(c echo.Context, client subscriptions.Client, subscribe bool, subs []string) error {
+	current := client.Subscriptions()
+
+	if subscribe {
+		for _, sub := range subs {
+			if err := api.checkSubscription(c, sub); err != nil {
+				return errors.New("Invalid subscription " + sub + ": " + err.Error())
+			}
+			current[sub] = struct{}{}
+		}
+	} else if len(subs) == 0 {
+		current = map[string]struct{}{}
+	} else {
+		for _, sub := range subs {
+			delete(current, sub)
+		}
+	}
+
+	list := make([]string, 0, len(current))
+	for sub := range current {
+		list = append(list, sub)
+	}
+
+	event := &core.RealtimeSubscribeEvent{
+		HttpContext:   c,
+		Client:        client,
+		Subscriptions: list,
+	}
+
+	return api.app.OnRealtimeBeforeSubscribeRequest().Trigger(event, func(e *core.RealtimeSubscribeEvent) error {
+		// replace the previous subscriptions
+		e.Client.Unsubscribe()
+		e.Client.Subscribe(e.Subscriptions...)
+
+		return api.app.OnRealtimeAfterSubscribeRequest().Trigger(event)
+	})
+}
+
+// sendWSMessage delivers a broker message to the WebSocket client.
+func (api *realtimeApi) sendWSMessage(c echo.Context, conn *websocket.Conn, client subscriptions.Client, msg *subscriptions.Message) error {
+	msgEvent := &core.RealtimeMessageEvent{
+		HttpContext: c,
+		Client:      client,
+		Message:     msg,
+	}
+
+	return api.app.OnRealtimeBeforeMessageSend().Trigger(msgEvent, func(e *core.RealtimeMessageEvent) error {
+		data := json.RawMessage(e.Message.Data)
+		if !json.Valid(data) {
+			// send the non-JSON data as string
+			data, _ = json.Marshal(string(e.Message.Data))
+		}
+
+		reply := &realtimeWSReply{
+			Type:    "message",
+			EventId: e.Message.Id,
+			Name:    e.Message.Name,
+			Data:    data,
+		}
+		if err := writeWSReply(conn, reply); err != nil {
+			return err
+		}
+
+		return api.app.OnRealtimeAfterMessageSend().Trigger(e)
+	})
+}
+
+// writeWSReply sends a single JSON message to the WebSocket client.
+func writeWSReply(conn *websocket.Conn, reply *realtimeWSReply) error {
+	conn.SetWriteDeadline(time.Now().Add(realtimeWSWriteTimeout))
+
+	return websocket.JSON.Send(conn, reply)
+}
@@ -0,0 +1,606 @@
+package apis
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/forms"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/search"
+)
+
+const (
+	batchActionCreate = "create"
+	batchActionUpdate = "update"
+	batchActionUpsert = "upsert"
+	batchActionDelete = "delete"
+
+	// batchMaxOperations is the max allowed number of operations in a single batch request.
+	batchMaxOperations = 100
+
+	// batchRefPrefix is the prefix of the string values that are
+	// replaced with the id of a record created earlier in the batch.
+	batchRefPrefix = "@ref."
+)
+
+type batchRequest struct {
+	Operations []*batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Action     string         `json:"action"`
+	Collection string         `json:"collection"`
+	Id         string         `json:"id"`
+	Ref        string         `json:"ref"`
+	OnConflict string         `json:"onConflict"`
+	Data       map[string]any `json:"data"`
+}
+
+type batchResult struct {
+	Action     string         `json:"action"`
+	Collection string         `json:"collection"`
+	Id         string         `json:"id"`
+	Ref        string         `json:"ref,omitempty"`
+	Record     *models.Record `json:"record,omitempty"`
+}
+
+// batchAfterFunc is an operation callback executed after the batch transaction commit.
+type batchAfterFunc func() error
+
+// batch executes the ordered list of record operations in a single transaction.
+//
+// Each operation is checked against its collection API rules and triggers
+// the same record request hooks as the regular record CRUD endpoints
+// (the "after" hooks are triggered after the transaction commit).
+func (api *recordApi) batch(c echo.Context) error {
+	if api.app.IsSQLiteCluster() {
+		return NewBadRequestError("Batch requests are not supported in SQLite cluster mode.", nil)
+	}
+
+	body := &batchRequest{}
+	if err := c.Bind(body); err != nil {
+		return NewBadRequestError("Failed to load the submitted data due to invalid formatting.", err)
+	}
+
+	if len(body.Operations) == 0 {
+		return NewBadRequestError("At least one batch operation is required.", nil)
+	}
+
+	if len(body.Operations) > batchMaxOperations {
+		return NewBadRequestError(fmt.Sprintf("The batch request can have at most %d operations.", batchMaxOperations), nil)
+	}
+
+	results := make([]*batchResult, len(body.Operations))
+	afterFuncs := make([]batchAfterFunc, 0, len(body.Operations))
+	refs := map[string]string{}
+
+	txErr := api.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		for i, op := range body.Operations {
+			result, after, err := api.batchOperation(c, txDao, op, refs)
+			if err != nil {
+				return newBatchOperationError(i, op, err)
+			}
+
+			if op.Ref != "" {
+				refs[op.Ref] = result.Id
+			}
+
+			results[i] = result
+			if after != nil {
+				afterFuncs = append(afterFuncs, after)
+			}
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		// the model "after" hooks errors don't revert the committed changes
+		// (any other error, incl. a failed commit, means that nothing was written)
+		var afterErr *daos.AfterTransactionError
+		if !errors.As(txErr, &afterErr) {
+			return txErr
+		}
+
+		if api.app.IsDebug() {
+			log.Println(txErr)
+		}
+	}
+
+	for _, after := range afterFuncs {
+		if err := after(); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range results {
+		if result.Record == nil {
+			continue
+		}
+
+		if err := EnrichRecord(c, api.app.Dao(), result.Record); err != nil && api.app.IsDebug() {
+			log.Println(err)
+		}
+
+		if vectorManager := api.app.VectorManager(); vectorManager != nil {
+			vectorManager.TriggerRecordEmbedding(result.Record)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{"results": results})
+}
+
+func (api *recordApi) batchOperation(
+	c echo.Context,
+	txDao *daos.Dao,
+	op *batchOperation,
+	refs map[string]string,
+) (*batchResult, batchAfterFunc, error) {
+	collection, err := txDao.FindCollectionByNameOrId(op.Collection)
+	if err != nil || collection == nil {
+		return nil, nil, NewNotFoundError("Missing or invalid collection.", err)
+	}
+
+	if collection.IsView() {
+		return nil, nil, NewBadRequestError("Unsupported collection type.", nil)
+	}
+
+	resolvedId, err := resolveBatchRefs(op.Id, refs)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, _ := resolvedId.(string)
+
+	data := map[string]any{}
+	for k, v := range op.Data {
+		if data[k], err = resolveBatchRefs(v, refs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	result := &batchResult{
+		Action:     op.Action,
+		Collection: collection.Name,
+		Ref:        op.Ref,
+	}
+
+	var record *models.Record
+	var after batchAfterFunc
+
+	switch op.Action {
+	case batchActionCreate:
+		record, after, err = api.batchCreate(c, txDao, collection, data)
+	case batchActionUpdate:
+		record, after, err = api.batchUpdate(c, txDao, collection, id, data)
+	case batchActionUpsert:
+		record, after, _, err = api.batchUpsert(c, txDao, collection, id, splitConflictFields(op.OnConflict), data)
+	case batchActionDelete:
+		record, after, err = api.batchDelete(c, txDao, collection, id)
+	default:
+		err = NewBadRequestError("Invalid batch action (must be create, update, upsert or delete).", nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.Id = record.Id
+	if op.Action != batchActionDelete {
+		result.Record = record
+	}
+
+	return result, after, nil
+}
+
+func (api *recordApi) batchCreate(
+	c echo.Context,
+	txDao *daos.Dao,
+	collection *models.Collection,
+	data map[string]any,
+	conflictFields ...string,
+) (*models.Record, batchAfterFunc, error) {
+	requestInfo := batchRequestInfo(c, http.MethodPost, data)
+
+	if requestInfo.Admin == nil && collection.CreateRule == nil {
+		// only admins can access if the rule is nil
+		return nil, nil, NewForbiddenError("Only admins can perform this action.", nil)
+	}
+
+	hasFullManageAccess := requestInfo.Admin != nil
+
+	// temporary save the record and check it against the create rule
+	if requestInfo.Admin == nil {
+		testRecord := models.NewRecord(collection)
+
+		// replace modifiers fields so that the resolved value is always
+		// available when accessing requestInfo.Data using just the field name
+		if requestInfo.HasModifierDataKeys() {
+			requestInfo.Data = testRecord.ReplaceModifers(requestInfo.Data)
+		}
+
+		testErr := batchDryRun(txDao, func(dryDao *daos.Dao) error {
+			testForm := forms.NewRecordUpsert(api.app, testRecord)
+			testForm.SetDao(dryDao)
+			testForm.SetFullManageAccess(true)
+			if err := testForm.LoadData(data); err != nil {
+				return err
+			}
+			if err := testForm.ValidateAndFill(); err != nil {
+				return err
+			}
+			if len(conflictFields) > 0 {
+				if err := dryDao.CreateRecordOnConflict(testRecord, conflictFields...); err != nil {
+					return err
+				}
+			} else if err := dryDao.SaveRecord(testRecord); err != nil {
+				return err
+			}
+
+			foundRecord, err := dryDao.FindRecordById(
+				collection.Id,
+				testRecord.Id,
+				batchRuleFunc(dryDao, collection, collection.CreateRule, requestInfo),
+			)
+			if err != nil {
+				return fmt.Errorf("create rule failure: %w", err)
+			}
+			hasFullManageAccess = hasAuthManageAccess(dryDao, foundRecord, requestInfo)
+
+			return nil
+		})
+		if errors.Is(testErr, daos.ErrRecordExists) {
+			return nil, nil, testErr
+		}
+		if testErr != nil {
+			return nil, nil, NewBadRequestError("Failed to create record.", testErr)
+		}
+	}
+
+	record := models.NewRecord(collection)
+	form := forms.NewRecordUpsert(api.app, record)
+	form.SetDao(txDao)
+	form.SetFullManageAccess(hasFullManageAccess)
+	form.SetConflictFields(conflictFields...)
+	if err := form.LoadData(data); err != nil {
+		return nil, nil, NewBadRequestError("Failed to load the submitted data due to invalid formatting.", err)
+	}
+
+	event := new(core.RecordCreateEvent)
+	event.HttpContext = c
+	event.Collection = collection
+	event.Record = record
+
+	submitErr := form.Submit(func(next forms.InterceptorNextFunc[*models.Record]) forms.InterceptorNextFunc[*models.Record] {
+		return func(m *models.Record) error {
+			event.Record = m
+
+			return api.app.OnRecordBeforeCreateRequest().Trigger(event, func(e *core.RecordCreateEvent) error {
+				if err := next(e.Record); err != nil {
+					if errors.Is(err, daos.ErrRecordExists) {
+						return err
+					}
+					return NewBadRequestError("Failed to create record.", err)
+				}
+				return nil
+			})
+		}
+	})
+	if submitErr != nil {
+		return nil, nil, submitErr
+	}
+
+	return event.Record, func() error {
+		return api.app.OnRecordAfterCreateRequest().Trigger(event)
+	}, nil
+}
+
+func (api *recordApi) batchUpdate(
+	c echo.Context,
+	txDao *daos.Dao,
+	collection *models.Collection,
+	recordId string,
+	data map[string]any,
+) (*models.Record, batchAfterFunc, error) {
+	requestInfo := batchRequestInfo(c, http.MethodPatch, data)
+
+	if requestInfo.Admin == nil && collection.UpdateRule == nil {
+		// only admins can access if the rule is nil
+		return nil, nil, NewForbiddenError("Only admins can perform this action.", nil)
+	}
+
+	// eager fetch the record so that the modifier field values are replaced
+	// and available when accessing requestInfo.Data using just the field name
+	if requestInfo.HasModifierDataKeys() {
+		record, err := txDao.FindRecordById(collection.Id, recordId)
+		if err != nil || record == nil {
+			return nil, nil, NewNotFoundError("", err)
+		}
+		requestInfo.Data = record.ReplaceModifers(requestInfo.Data)
+	}
+
+	record, fetchErr := txDao.FindRecordById(
+		collection.Id,
+		recordId,
+		batchRuleFunc(txDao, collection, collection.UpdateRule, requestInfo),
+	)
+	if fetchErr != nil || record == nil {
+		return nil, nil, NewNotFoundError("", fetchErr)
+	}
+
+	form := forms.NewRecordUpsert(api.app, record)
+	form.SetDao(txDao)
+	form.SetFullManageAccess(requestInfo.Admin != nil || hasAuthManageAccess(txDao, record, requestInfo))
+	if err := form.LoadData(data); err != nil {
+		return nil, nil, NewBadRequestError("Failed to load the submitted data due to invalid formatting.", err)
+	}
+
+	event := new(core.RecordUpdateEvent)
+	event.HttpContext = c
+	event.Collection = collection
+	event.Record = record
+
+	submitErr := form.Submit(func(next forms.InterceptorNextFunc[*models.Record]) forms.InterceptorNextFunc[*models.Record] {
+		return func(m *models.Record) error {
+			event.Record = m
+
+			return api.app.OnRecordBeforeUpdateRequest().Trigger(event, func(e *core.RecordUpdateEvent) error {
+				if err := next(e.Record); err != nil {
+					var conflictErr *forms.RecordVersionConflictError
+					if errors.As(err, &conflictErr) {
+						return api.versionConflictError(e.HttpContext, collection, conflictErr.Current)
+					}
+					return NewBadRequestError("Failed to update record.", err)
+				}
+				return nil
+			})
+		}
+	})
+	if submitErr != nil {
+		return nil, nil, submitErr
+	}
+
+	return event.Record, func() error {
+		return api.app.OnRecordAfterUpdateRequest().Trigger(event)
+	}, nil
+}
+
+// batchUpsert updates the record matching the conflict fields values
+// from data or creates a new one if there is no such record.
+//
+// The collection update or create rule is checked depending on
+// the executed branch (created reports whether a new record was created).
+func (api *recordApi) batchUpsert(
+	c echo.Context,
+	txDao *daos.Dao,
+	collection *models.Collection,
+	recordId string,
+	conflictFields []string,
+	data map[string]any,
+) (record *models.Record, after batchAfterFunc, created bool, err error) {
+	createData := data
+
+	if len(conflictFields) == 0 {
+		conflictFields = []string{schema.FieldNameId}
+	}
+
+	if len(conflictFields) == 1 && conflictFields[0] == schema.FieldNameId {
+		if recordId == "" {
+			recordId, _ = data[schema.FieldNameId].(string)
+		}
+		if recordId == "" {
+			return nil, nil, false, NewBadRequestError("The upsert operation requires an id.", nil)
+		}
+
+		createData = make(map[string]any, len(data)+1)
+		for k, v := range data {
+			createData[k] = v
+		}
+		createData[schema.FieldNameId] = recordId
+	}
+
+	if err := daos.ValidateConflictFields(collection, conflictFields); err != nil {
+		return nil, nil, false, NewBadRequestError("Invalid onConflict fields.", err)
+	}
+
+	update := func() (*models.Record, batchAfterFunc, bool, error) {
+		existing, err := txDao.FindRecordByConflictFields(collection, conflictFields, createData)
+		if err != nil {
+			return nil, nil, false, NewBadRequestError("Failed to load the conflicting record.", err)
+		}
+
+		// prevent concurrent changes until the end of the transaction
+		if err := txDao.LockRecordRow(existing); err != nil {
+			return nil, nil, false, NewBadRequestError("Failed to lock the conflicting record.", err)
+		}
+
+		record, after, err := api.batchUpdate(c, txDao, collection, existing.Id, data)
+
+		return record, after, false, err
+	}
+
+	_, findErr := txDao.FindRecordByConflictFields(collection, conflictFields, createData)
+	if findErr == nil {
+		return update()
+	}
+	if !errors.Is(findErr, sql.ErrNoRows) {
+		return nil, nil, false, NewBadRequestError("Failed to load the conflicting record.", findErr)
+	}
+
+	record, after, err = api.batchCreate(c, txDao, collection, createData, conflictFields...)
+	if errors.Is(err, daos.ErrRecordExists) {
+		// concurrently created in the meantime
+		return update()
+	}
+
+	return record, after, err == nil, err
+}
+
+func (api *recordApi) batchDelete(
+	c echo.Context,
+	txDao *daos.Dao,
+	collection *models.Collection,
+	recordId string,
+) (*models.Record, batchAfterFunc, error) {
+	requestInfo := batchRequestInfo(c, http.MethodDelete, map[string]any{})
+
+	if requestInfo.Admin == nil && collection.DeleteRule == nil {
+		// only admins can access if the rule is nil
+		return nil, nil, NewForbiddenError("Only admins can perform this action.", nil)
+	}
+
+	record, fetchErr := txDao.FindRecordById(
+		collection.Id,
+		recordId,
+		batchRuleFunc(txDao, collection, collection.DeleteRule, requestInfo),
+	)
+	if fetchErr != nil || record == nil {
+		return nil, nil, NewNotFoundError("", fetchErr)
+	}
+
+	event := new(core.RecordDeleteEvent)
+	event.HttpContext = c
+	event.Collection = collection
+	event.Record = record
+
+	deleteErr := api.app.OnRecordBeforeDeleteRequest().Trigger(event, func(e *core.RecordDeleteEvent) error {
+		if err := txDao.DeleteRecord(e.Record); err != nil {
+			return NewBadRequestError("Failed to delete record. Make sure that the record is not part of a required relation reference.", err)
+		}
+		return nil
+	})
+	if deleteErr != nil {
+		return nil, nil, deleteErr
+	}
+
+	return event.Record, func() error {
+		return api.app.OnRecordAfterDeleteRequest().Trigger(event)
+	}, nil
+}
+
+// batchRequestInfo returns a copy of the batch request info
+// with the method and data of a single operation.
+func batchRequestInfo(c echo.Context, method string, data map[string]any) *models.RequestInfo {
+	info := *RequestInfo(c)
+	info.Method = method
+	info.Data = data
+
+	return &info
+}
+
+// batchRuleFunc returns a record query filter for the collection rule
+// (no filter for admins and empty rules).
+func batchRuleFunc(
+	dao *daos.Dao,
+	collection *models.Collection,
+	rule *string,
+	requestInfo *models.RequestInfo,
+) func(q *dbx.SelectQuery) error {
+	return func(q *dbx.SelectQuery) error {
+		if requestInfo.Admin != nil || rule == nil || *rule == "" {
+			return nil
+		}
+
+		resolver := resolvers.NewRecordFieldResolver(dao, collection, requestInfo, true)
+		expr, err := search.FilterData(*rule).BuildExpr(resolver)
+		if err != nil {
+			return err
+		}
+		resolver.UpdateQuery(q)
+		q.AndWhere(expr)
+
+		return nil
+	}
+}
+
+// batchDryRun executes fn within a savepoint of the batch transaction and reverts it.
+//
+// Similar to [forms.RecordUpsert.DrySubmit], the dry dao doesn't trigger any model hooks.
+func batchDryRun(txDao *daos.Dao, fn func(dryDao *daos.Dao) error) error {
+	db := txDao.NonconcurrentDB()
+
+	if _, err := db.NewQuery("SAVEPOINT pb_batch_dry").Execute(); err != nil {
+		return err
+	}
+
+	fnErr := fn(daos.New(db))
+
+	if _, err := db.NewQuery("ROLLBACK TO SAVEPOINT pb_batch_dry").Execute(); err != nil {
+		return err
+	}
+	if _, err := db.NewQuery("RELEASE SAVEPOINT pb_batch_dry").Execute(); err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// resolveBatchRefs replaces the "@ref.{name}" string values (incl. the
+// nested slice items) with the ids of the earlier created batch records.
+func resolveBatchRefs(value any, refs map[string]string) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, batchRefPrefix) {
+			return v, nil
+		}
+
+		id, ok := refs[strings.TrimPrefix(v, batchRefPrefix)]
+		if !ok {
+			return nil, NewBadRequestError(fmt.Sprintf("Unknown batch reference %q.", v), nil)
+		}
+
+		return id, nil
+	case []any:
+		resolved := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if resolved[i], err = resolveBatchRefs(item, refs); err != nil {
+				return nil, err
+			}
+		}
+
+		return resolved, nil
+	default:
+		return v, nil
+	}
+}
+
+// splitConflictFields returns the trimmed non-empty
+// comma separated field names of the onConflict parameter.
+func splitConflictFields(onConflict string) []string {
+	result := []string{}
+
+	for _, field := range strings.Split(onConflict, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
+
+// newBatchOperationError wraps the failed operation error as the
+// error response of the whole (reverted) batch request.
+func newBatchOperationError(index int, op *batchOperation, err error) *ApiError {
+	var opErr *ApiError
+	if !errors.As(err, &opErr) {
+		opErr = NewBadRequestError("", err)
+	}
+
+	apiErr := NewApiError(opErr.Code, fmt.Sprintf("Batch operation %d failed: %s", index, opErr.Message), err)
+	apiErr.Data = map[string]any{
+		"operation":  index,
+		"action":     op.Action,
+		"collection": op.Collection,
+		"error":      opErr,
+	}
+
+	return apiErr
+}
@@ -0,0 +1,66 @@
+package apis
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestResolveBatchRefs(t *testing.T) {
+	refs := map[string]string{"post": "post_id", "author": "author_id"}
+
+	scenarios := []struct {
+		name        string
+		value       any
+		expectError bool
+		expected    string
+	}{
+		{"nil", nil, false, `null`},
+		{"number", 12.5, false, `12.5`},
+		{"plain string", "abc", false, `"abc"`},
+		{"ref prefix in the middle", "x@ref.post", false, `"x@ref.post"`},
+		{"known ref", "@ref.post", false, `"post_id"`},
+		{"unknown ref", "@ref.missing", true, ``},
+		{"empty ref name", "@ref.", true, ``},
+		{"list of refs", []any{"@ref.post", "abc", "@ref.author"}, false, `["post_id","abc","author_id"]`},
+		{"list with unknown ref", []any{"@ref.post", "@ref.missing"}, true, ``},
+		{"nested list", []any{[]any{"@ref.author"}}, false, `[["author_id"]]`},
+	}
+
+	for _, s := range scenarios {
+		result, err := resolveBatchRefs(s.value, refs)
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.name, s.expectError, hasErr, err)
+			continue
+		}
+		if hasErr {
+			continue
+		}
+
+		encoded, _ := json.Marshal(result)
+		if string(encoded) != s.expected {
+			t.Errorf("[%s] Expected %s, got %s", s.name, s.expected, encoded)
+		}
+	}
+}
+
+func TestSplitConflictFields(t *testing.T) {
+	scenarios := []struct {
+		onConflict string
+		expected   string
+	}{
+		{"", `[]`},
+		{" , ,", `[]`},
+		{"email", `["email"]`},
+		{" email , username ", `["email","username"]`},
+		{"a,,b,", `["a","b"]`},
+	}
+
+	for _, s := range scenarios {
+		encoded, _ := json.Marshal(splitConflictFields(s.onConflict))
+		if string(encoded) != s.expected {
+			t.Errorf("[%q] Expected %s, got %s", s.onConflict, s.expected, encoded)
+		}
+	}
+}
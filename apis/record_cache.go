@@ -0,0 +1,135 @@
+package apis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+// requestIdentifierRegex matches the `@request.*` identifiers
+// of a rule or filter expression (eg. "@request.headers.x_token").
+var requestIdentifierRegex = regexp.MustCompile(`@request\.(\w+)((?:\.[\w:]+)*)`)
+
+// recordCacheScope returns the cache key part that identifies the
+// rule context of the request, so that the cached records are served
+// only to the requests resolving the rules (and filters) the same way:
+//   - "admin" for admins (the rules are not applied)
+//   - "public" for the non-admin requests when the expressions don't depend on the requester
+//   - "guest" or "auth:{collectionId}:{recordId}" when they depend on the auth state
+//
+// identityDependent forces the auth state scope even if the expressions
+// don't reference it (eg. because of the expanded relations rules).
+//
+// The other referenced `@request.*` values (headers, data, etc.) are
+// appended as hash. The query parameters are part of the cache key already.
+func recordCacheScope(requestInfo *models.RequestInfo, identityDependent bool, exprs ...string) string {
+	if requestInfo.Admin != nil {
+		return "admin"
+	}
+
+	values := map[string]any{}
+
+	for _, expr := range exprs {
+		for _, match := range requestIdentifierRegex.FindAllStringSubmatch(expr, -1) {
+			key := strings.TrimPrefix(match[2], ".")
+			key, _, _ = strings.Cut(key, ".") // the top level field only
+			key, _, _ = strings.Cut(key, ":") // strip the modifiers
+
+			switch match[1] {
+			case "auth":
+				identityDependent = true
+			case "query":
+				// already part of the cache key
+			case "method":
+				values["method"] = requestInfo.Method
+			case "headers":
+				values["headers."+key] = requestInfo.Headers[key]
+			case "data":
+				values["data."+key] = requestInfo.Data[key]
+			default:
+				// unknown identifier -> fallback to the requester identity
+				identityDependent = true
+			}
+		}
+	}
+
+	scope := "public"
+	if identityDependent {
+		if requestInfo.AuthRecord != nil {
+			scope = "auth:" + requestInfo.AuthRecord.Collection().Id + ":" + requestInfo.AuthRecord.Id
+		} else {
+			scope = "guest"
+		}
+	}
+
+	if len(values) > 0 {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		h := sha256.New()
+		for _, k := range keys {
+			raw, _ := json.Marshal(values[k])
+			h.Write([]byte(k + "=" + string(raw) + "\n"))
+		}
+
+		scope += ":" + hex.EncodeToString(h.Sum(nil))[:16]
+	}
+
+	return scope
+}
+
+// cacheDependencies returns the ids of the collections, other than
+// the main one, that the collection records response depends on:
+//   - the collections of the expanded relations (incl. the indirect ones)
+//   - the collections loaded by the rule and filter resolver (eg. relation joins and `@collection.*`)
+//   - the source collections of the above view collections (incl. the main collection)
+func (api *recordApi) cacheDependencies(c echo.Context, collection *models.Collection, loaded ...*models.Collection) []string {
+	var expands []string
+	if param := c.QueryParam(expandQueryParam); param != "" {
+		expands = strings.Split(param, ",")
+	}
+
+	candidates := append([]*models.Collection{collection}, loaded...)
+	candidates = append(candidates, api.app.Dao().FindExpandCollections(collection, expands)...)
+
+	result := []string{}
+	found := map[string]bool{collection.Id: true}
+
+	add := func(dep *models.Collection) {
+		if !found[dep.Id] {
+			found[dep.Id] = true
+			result = append(result, dep.Id)
+		}
+	}
+
+	for _, candidate := range candidates {
+		add(candidate)
+
+		if !candidate.IsView() {
+			continue
+		}
+
+		sources, err := api.app.Dao().FindViewSourceCollections(candidate)
+		if err != nil {
+			if api.app.IsDebug() {
+				log.Println("Failed to resolve the view cache dependencies:", err)
+			}
+			continue
+		}
+		for _, source := range sources {
+			add(source)
+		}
+	}
+
+	return result
+}
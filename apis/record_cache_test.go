@@ -0,0 +1,69 @@
+package apis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+func TestRecordCacheScope(t *testing.T) {
+	users := &models.Collection{}
+	users.Id = "users_id"
+
+	authRecord := models.NewRecord(users)
+	authRecord.Id = "user1"
+
+	guest := &models.RequestInfo{Method: "GET", Headers: map[string]any{"x_token": "a"}}
+	guestOtherHeader := &models.RequestInfo{Method: "GET", Headers: map[string]any{"x_token": "b"}}
+	user := &models.RequestInfo{Method: "GET", AuthRecord: authRecord, Headers: map[string]any{"x_token": "a"}}
+	admin := &models.RequestInfo{Method: "GET", Admin: &models.Admin{}}
+
+	scenarios := []struct {
+		name              string
+		requestInfo       *models.RequestInfo
+		identityDependent bool
+		exprs             []string
+		expected          string
+	}{
+		{"admin", admin, true, []string{"@request.auth.id != ''"}, "admin"},
+		{"no rules", guest, false, nil, "public"},
+		{"static rule", user, false, []string{"status = 'active'"}, "public"},
+		{"query only", user, false, []string{"@request.query.page > 1"}, "public"},
+		{"identity dependent guest", guest, true, nil, "guest"},
+		{"identity dependent auth", user, true, nil, "auth:users_id:user1"},
+		{"auth rule guest", guest, false, []string{"author = @request.auth.id"}, "guest"},
+		{"auth rule auth", user, false, []string{"", "author = @request.auth.id"}, "auth:users_id:user1"},
+		{"auth rule with modifier", user, false, []string{"@request.auth.roles:each = 'a'"}, "auth:users_id:user1"},
+		{"unknown identifier", user, false, []string{"@request.context = 'x'"}, "auth:users_id:user1"},
+		{"headers rule", guest, false, []string{"@request.headers.x_token = 'a'"}, "public:"},
+		{"headers and auth rule", user, false, []string{"@request.headers.x_token = 'a' && @request.auth.id != ''"}, "auth:users_id:user1:"},
+	}
+
+	for _, s := range scenarios {
+		result := recordCacheScope(s.requestInfo, s.identityDependent, s.exprs...)
+
+		if strings.HasSuffix(s.expected, ":") {
+			// hashed request values
+			if !strings.HasPrefix(result, s.expected) || len(result) != len(s.expected)+16 {
+				t.Errorf("[%s] Expected %q followed by the values hash, got %q", s.name, s.expected, result)
+			}
+		} else if result != s.expected {
+			t.Errorf("[%s] Expected %q, got %q", s.name, s.expected, result)
+		}
+	}
+
+	headersRule := "@request.headers.x_token = 'a'"
+
+	if recordCacheScope(guest, false, headersRule) == recordCacheScope(guestOtherHeader, false, headersRule) {
+		t.Error("Expected different scopes for different referenced header values")
+	}
+
+	if recordCacheScope(guest, false, headersRule) != recordCacheScope(guest, false, headersRule, "@request.headers.x_token:isset = true") {
+		t.Error("Expected the same scope for the same referenced header with a modifier")
+	}
+
+	if recordCacheScope(guest, false, "@request.headers.other = ''") != recordCacheScope(guestOtherHeader, false, "@request.headers.other = ''") {
+		t.Error("Expected the same scope when the differing header is not referenced")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/zhenruyan/postgrebase/models/schema"
 	"github.com/zhenruyan/postgrebase/models/settings"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -21,11 +22,15 @@ import (
 	"github.com/zhenruyan/postgrebase/models"
 	"github.com/zhenruyan/postgrebase/replication"
 	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/search"
 	"github.com/zhenruyan/postgrebase/vector"
 )
 
-const expandQueryParam = "expand"
+const (
+	expandQueryParam = "expand"
+	fieldsQueryParam = "fields"
+)
 
 // bindRecordCrudApi registers the record crud api endpoints and
 // the corresponding handlers.
@@ -39,11 +44,17 @@ func bindRecordCrudApi(app core.App, rg *echo.Group) {
 
 	subGroup.GET("/records", api.list, LoadCollectionContext(app))
 	subGroup.GET("/records/:id", api.view, LoadCollectionContext(app))
+	subGroup.GET("/export", api.export, LoadCollectionContext(app))
+	subGroup.POST("/import", api.importRecords, LoadCollectionContext(app, models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeVector))
+	subGroup.GET("/import/:jobId", api.importStatus, LoadCollectionContext(app, models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeVector))
 	subGroup.POST("/records", api.create, LoadCollectionContext(app, models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeVector))
+	subGroup.PUT("/records", api.upsert, LoadCollectionContext(app, models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeVector))
 	subGroup.PATCH("/records/:id", api.update, LoadCollectionContext(app, models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeVector))
 	subGroup.DELETE("/records/:id", api.delete, LoadCollectionContext(app, models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeVector))
 	subGroup.POST("/vector-search", api.vectorSearch, LoadCollectionContext(app, models.CollectionTypeVector))
 	subGroup.GET("/vector-search", api.vectorSearch, LoadCollectionContext(app, models.CollectionTypeVector))
+
+	rg.POST("/batch", api.batch, ActivityLogger(app))
 }
 
 func (api *recordApi) getCacheKey(collection *models.Collection, suffix string) string {
@@ -80,7 +91,16 @@ func (api *recordApi) cacheGetJSON(c echo.Context, key string, dest any) bool {
 	return json.Unmarshal(encoded, dest) == nil
 }
 
-func (api *recordApi) cacheSet(c echo.Context, key string, value any, ttl time.Duration) {
+// cacheSet stores the cache entry and registers the collections
+// (other than the key one) whose changes should invalidate it.
+func (api *recordApi) cacheSet(c echo.Context, key string, value any, ttl time.Duration, deps ...string) {
+	if err := api.app.AddRecordCacheDependencies(key, ttl, deps...); err != nil {
+		if api.app.IsDebug() {
+			log.Println("Failed to register the cache dependencies:", err)
+		}
+		return // don't cache to avoid stale data
+	}
+
 	if api.app.RedisCache() != nil {
 		encoded, _ := json.Marshal(value)
 		api.app.RedisCache().Set(c.Request().Context(), key, encoded, ttl)
@@ -105,19 +125,6 @@ func (api *recordApi) list(c echo.Context) error {
 
 	records := []*models.Record{}
 
-	// --- Cache Read ---
-	var cacheKey string
-	canCache := (collection.ListCacheEnabled && c.QueryParams().Encode() == "") ||
-		(collection.SearchCacheEnabled && c.QueryParams().Encode() != "")
-
-	if canCache {
-		cacheKey = api.getCacheKey(collection, "list:"+c.QueryParams().Encode())
-		var cachedResult search.Result
-		if api.cacheGetJSON(c, cacheKey, &cachedResult) {
-			return c.JSON(http.StatusOK, cachedResult)
-		}
-	}
-
 	// forbid users and guests to query special filter/sort fields
 	if err := api.checkForForbiddenQueryFields(c); err != nil {
 		return err
@@ -130,6 +137,29 @@ func (api *recordApi) list(c echo.Context) error {
 		return NewForbiddenError("Only admins can perform this action.", nil)
 	}
 
+	// the expanded relations and the auth records emails
+	// visibility depend on the requester too
+	scope := recordCacheScope(
+		requestInfo,
+		c.QueryParam(expandQueryParam) != "" || collection.IsAuth(),
+		cast.ToString(collection.ListRule),
+		c.QueryParam(search.FilterQueryParam),
+		c.QueryParam(search.SortQueryParam),
+	)
+
+	// --- Cache Read ---
+	var cacheKey string
+	canCache := (collection.ListCacheEnabled && c.QueryParams().Encode() == "") ||
+		(collection.SearchCacheEnabled && c.QueryParams().Encode() != "")
+
+	if canCache {
+		cacheKey = api.getCacheKey(collection, "list:"+scope+":"+c.QueryParams().Encode())
+		var cached recordResponse
+		if api.cacheGetJSON(c, cacheKey, &cached) && len(cached.Data) > 0 {
+			return writeRecordResponse(c, collection, scope, &cached)
+		}
+	}
+
 	fieldsResolver := resolvers.NewRecordFieldResolver(
 		api.app.Dao(),
 		collection,
@@ -138,8 +168,18 @@ func (api *recordApi) list(c echo.Context) error {
 		requestInfo.Admin != nil,
 	)
 
+	// read only the columns required by the fields projection (if any)
+	query := api.app.Dao().SelectRecordFields(
+		api.app.Dao().RecordQuery(collection),
+		collection,
+		picker.ParseFields(c.QueryParam(fieldsQueryParam)),
+		strings.Split(c.QueryParam(expandQueryParam), ","),
+		search.ParseSortFromString(c.QueryParam(search.SortQueryParam)),
+	)
+
 	searchProvider := search.NewProvider(fieldsResolver).
-		Query(api.app.Dao().RecordQuery(collection))
+		Query(query).
+		CursorSecret(api.app.Settings().RecordAuthToken.Secret)
 
 	if requestInfo.Admin == nil && collection.ListRule != nil {
 		searchProvider.AddFilter(search.FilterData(*collection.ListRule))
@@ -165,13 +205,19 @@ func (api *recordApi) list(c echo.Context) error {
 			log.Println(err)
 		}
 
+		response, err := newRecordsListResponse(e.Result, picker.ParseFields(e.HttpContext.QueryParam(fieldsQueryParam)))
+		if err != nil {
+			return err
+		}
+
 		// --- Cache Write ---
 		if canCache && e.Result != nil {
 			duration := time.Duration(collection.CacheDuration) * time.Second
-			api.cacheSet(e.HttpContext, cacheKey, e.Result, duration)
+			deps := api.cacheDependencies(e.HttpContext, collection, fieldsResolver.LoadedCollections()...)
+			api.cacheSet(e.HttpContext, cacheKey, response, duration, deps...)
 		}
 
-		return e.HttpContext.JSON(http.StatusOK, e.Result)
+		return writeRecordResponse(e.HttpContext, collection, scope, response)
 	})
 }
 
@@ -186,23 +232,27 @@ func (api *recordApi) view(c echo.Context) error {
 		return NewNotFoundError("", nil)
 	}
 
+	requestInfo := RequestInfo(c)
+
+	if requestInfo.Admin == nil && collection.ViewRule == nil {
+		// only admins can access if the rule is nil
+		return NewForbiddenError("Only admins can perform this action.", nil)
+	}
+
+	scope := recordViewScope(c, collection)
+
 	// --- Cache Read ---
 	var cacheKey string
 	canCache := collection.CacheEnabled
 	if canCache {
-		cacheKey = api.getCacheKey(collection, "view:"+recordId)
-		var cachedRecord models.Record
-		if api.cacheGetJSON(c, cacheKey, &cachedRecord) {
-			return c.JSON(http.StatusOK, cachedRecord)
+		cacheKey = api.getCacheKey(collection, "view:"+scope+":"+recordId+":"+c.QueryParams().Encode())
+		var cached recordResponse
+		if api.cacheGetJSON(c, cacheKey, &cached) && len(cached.Data) > 0 {
+			return writeRecordResponse(c, collection, scope, &cached)
 		}
 	}
 
-	requestInfo := RequestInfo(c)
-
-	if requestInfo.Admin == nil && collection.ViewRule == nil {
-		// only admins can access if the rule is nil
-		return NewForbiddenError("Only admins can perform this action.", nil)
-	}
+	var ruleCollections []*models.Collection
 
 	ruleFunc := func(q *dbx.SelectQuery) error {
 		if requestInfo.Admin == nil && collection.ViewRule != nil && *collection.ViewRule != "" {
@@ -211,13 +261,25 @@ func (api *recordApi) view(c echo.Context) error {
 			if err != nil {
 				return err
 			}
+			ruleCollections = resolver.LoadedCollections()
 			resolver.UpdateQuery(q)
 			q.AndWhere(expr)
 		}
 		return nil
 	}
 
-	record, fetchErr := api.app.Dao().FindRecordById(collection.Id, recordId, ruleFunc)
+	fieldsFunc := func(q *dbx.SelectQuery) error {
+		api.app.Dao().SelectRecordFields(
+			q,
+			collection,
+			picker.ParseFields(c.QueryParam(fieldsQueryParam)),
+			strings.Split(c.QueryParam(expandQueryParam), ","),
+			nil,
+		)
+		return nil
+	}
+
+	record, fetchErr := api.app.Dao().FindRecordById(collection.Id, recordId, ruleFunc, fieldsFunc)
 	if fetchErr != nil || record == nil {
 		return NewNotFoundError("", fetchErr)
 	}
@@ -236,13 +298,19 @@ func (api *recordApi) view(c echo.Context) error {
 			log.Println(err)
 		}
 
+		response, err := newRecordViewResponse(e.Record, scope, picker.ParseFields(e.HttpContext.QueryParam(fieldsQueryParam)))
+		if err != nil {
+			return err
+		}
+
 		// --- Cache Write ---
-		if canCache && e.Record != nil {
+		if canCache {
 			duration := time.Duration(collection.CacheDuration) * time.Second
-			api.cacheSet(e.HttpContext, cacheKey, e.Record, duration)
+			deps := api.cacheDependencies(e.HttpContext, collection, ruleCollections...)
+			api.cacheSet(e.HttpContext, cacheKey, response, duration, deps...)
 		}
 
-		return e.HttpContext.JSON(http.StatusOK, e.Record)
+		return writeRecordResponse(e.HttpContext, collection, scope, response)
 	})
 }
 
@@ -354,6 +422,72 @@ func (api *recordApi) create(c echo.Context) error {
 	})
 }
 
+// upsert creates or updates (if already exists) the record matching
+// the submitted values of the "onConflict" unique fields.
+//
+// The operation is executed in a single transaction the same way
+// as a batch upsert operation (aka. file uploads are not supported).
+func (api *recordApi) upsert(c echo.Context) error {
+	collection, _ := c.Get(ContextCollectionKey).(*models.Collection)
+	if collection == nil {
+		return NewNotFoundError("", "Missing collection context.")
+	}
+
+	if api.app.IsSQLiteCluster() {
+		return NewBadRequestError("Upsert requests are not supported in SQLite cluster mode.", nil)
+	}
+
+	conflictFields := splitConflictFields(c.QueryParam("onConflict"))
+	if len(conflictFields) == 0 {
+		return NewBadRequestError("The onConflict query parameter is required.", nil)
+	}
+
+	data := make(map[string]any, len(RequestInfo(c).Data))
+	for k, v := range RequestInfo(c).Data {
+		data[k] = v
+	}
+
+	var record *models.Record
+	var after batchAfterFunc
+
+	txErr := api.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		record, after, _, err = api.batchUpsert(c, txDao, collection, "", conflictFields, data)
+
+		return err
+	})
+	if txErr != nil {
+		// the model "after" hooks errors don't revert the committed changes
+		// (any other error, incl. a failed commit, means that nothing was written)
+		var afterErr *daos.AfterTransactionError
+		if !errors.As(txErr, &afterErr) {
+			return txErr
+		}
+
+		if api.app.IsDebug() {
+			log.Println(txErr)
+		}
+	}
+
+	if err := EnrichRecord(c, api.app.Dao(), record); err != nil && api.app.IsDebug() {
+		log.Println(err)
+	}
+
+	if vectorManager := api.app.VectorManager(); vectorManager != nil {
+		vectorManager.TriggerRecordEmbedding(record)
+	}
+
+	if err := after(); err != nil {
+		return err
+	}
+
+	if c.Response().Committed {
+		return nil
+	}
+
+	return c.JSON(http.StatusOK, record)
+}
+
 func (api *recordApi) update(c echo.Context) error {
 	collection, _ := c.Get(ContextCollectionKey).(*models.Collection)
 	if collection == nil {
@@ -412,6 +546,13 @@ func (api *recordApi) update(c echo.Context) error {
 		return NewBadRequestError("Failed to load the submitted data due to invalid formatting.", err)
 	}
 
+	// optimistic concurrency precondition
+	if ifMatch := c.Request().Header.Get("If-Match"); ifMatch != "" {
+		form.SetVersionCheck(func(current *models.Record) (bool, error) {
+			return matchRecordVersion(ifMatch, current), nil
+		})
+	}
+
 	event := new(core.RecordUpdateEvent)
 	event.HttpContext = c
 	event.Collection = collection
@@ -425,6 +566,10 @@ func (api *recordApi) update(c echo.Context) error {
 
 			return api.app.OnRecordBeforeUpdateRequest().Trigger(event, func(e *core.RecordUpdateEvent) error {
 				if err := next(e.Record); err != nil {
+					var conflictErr *forms.RecordVersionConflictError
+					if errors.As(err, &conflictErr) {
+						return api.versionConflictError(e.HttpContext, collection, conflictErr.Current)
+					}
 					return NewBadRequestError("Failed to update record.", err)
 				}
 
@@ -441,6 +586,11 @@ func (api *recordApi) update(c echo.Context) error {
 						return nil
 					}
 
+					// the new version validator for the subsequent conditional updates
+					if response, err := newRecordViewResponse(e.Record, recordViewScope(e.HttpContext, collection), nil); err == nil {
+						e.HttpContext.Response().Header().Set("ETag", response.ETag)
+					}
+
 					return e.HttpContext.JSON(http.StatusOK, e.Record)
 				})
 			})
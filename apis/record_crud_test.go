@@ -0,0 +1,317 @@
+package apis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/forms"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+func TestRecordsListCursorHiddenEmailSort(t *testing.T) {
+	app := newTestApp(t)
+
+	users, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.ListRule = types.Pointer("")
+	if err := app.Dao().SaveCollection(users); err != nil {
+		t.Fatal(err)
+	}
+
+	emails := []string{"hidden_a@example.com", "hidden_b@example.com", "hidden_c@example.com"}
+	for i, email := range emails {
+		record := models.NewRecord(users)
+		record.SetUsername("user" + strconv.Itoa(i))
+		record.SetEmail(email)
+		record.SetEmailVisibility(false)
+		record.SetPassword("1234567890")
+		if err := app.Dao().SaveRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cursor := ""
+	for page := 0; page < len(emails); page++ {
+		rec := serveTestRequest(t, app, http.MethodGet, "/api/collections/users/records?sort=email&perPage=1&cursor="+url.QueryEscape(cursor), "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("(%d) Expected status 200, got %d: %s", page, rec.Code, rec.Body.String())
+		}
+
+		if strings.Contains(rec.Body.String(), "hidden_") {
+			t.Fatalf("(%d) Expected the hidden emails to not be returned, got %s", page, rec.Body.String())
+		}
+
+		result := struct {
+			Items      []map[string]any `json:"items"`
+			NextCursor string           `json:"nextCursor"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result.Items) != 1 {
+			t.Fatalf("(%d) Expected 1 item, got %d", page, len(result.Items))
+		}
+
+		if page == len(emails)-1 {
+			if result.NextCursor != "" {
+				t.Fatalf("Expected no next cursor for the last page, got %q", result.NextCursor)
+			}
+			break
+		}
+
+		raw, err := base64.RawURLEncoding.DecodeString(result.NextCursor)
+		if err != nil {
+			t.Fatalf("(%d) Failed to decode the next cursor %q: %v", page, result.NextCursor, err)
+		}
+		if strings.Contains(string(raw), "hidden_") {
+			t.Fatalf("(%d) Expected the next cursor to not contain the sort value, got %s", page, raw)
+		}
+
+		cursor = result.NextCursor
+	}
+}
+
+func TestRecordsListFieldsWithSortAndRelationFilter(t *testing.T) {
+	app := newTestApp(t)
+
+	users, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	author := models.NewRecord(users)
+	author.SetUsername("author")
+	author.SetEmail("author@example.com")
+	author.SetPassword("1234567890")
+	author.Set("name", "x")
+	if err := app.Dao().SaveRecord(author); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := &models.Collection{
+		Name:     "posts",
+		Type:     models.CollectionTypeBase,
+		ListRule: types.Pointer(""),
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+			&schema.SchemaField{Name: "body", Type: schema.FieldTypeJson},
+			&schema.SchemaField{
+				Name:    "author",
+				Type:    schema.FieldTypeRelation,
+				Options: &schema.RelationOptions{CollectionId: users.Id, MaxSelect: types.Pointer(1)},
+			},
+		),
+	}
+	if err := app.Dao().SaveCollection(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"b", "a"} {
+		record := models.NewRecord(posts)
+		record.Set("title", title)
+		record.Set("body", map[string]any{"large": true})
+		record.Set("author", author.Id)
+		if err := app.Dao().SaveRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var selectQueries []string
+	app.Dao().DB().(*dbx.DB).QueryLogFunc = func(ctx context.Context, t time.Duration, sql string, rows *sql.Rows, err error) {
+		if strings.HasPrefix(sql, "SELECT DISTINCT") && strings.Contains(sql, "posts") {
+			selectQueries = append(selectQueries, sql)
+		}
+	}
+
+	rec := serveTestRequest(t, app, http.MethodGet, "/api/collections/posts/records?fields=id&sort=title&filter="+url.QueryEscape("author.name='x'"), "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	result := struct {
+		Items []map[string]any `json:"items"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 2 || len(result.Items[0]) != 1 || result.Items[0]["id"] == nil {
+		t.Fatalf("Expected 2 items with only id, got %v", result.Items)
+	}
+
+	var found bool
+	for _, query := range selectQueries {
+		if !strings.Contains(query, "ORDER BY") {
+			continue
+		}
+
+		found = true
+
+		selectList, _, _ := strings.Cut(query, " FROM ")
+		if !strings.Contains(selectList, "title") {
+			t.Fatalf("Expected the sort column to be selected, got %s", query)
+		}
+		if strings.Contains(selectList, "body") {
+			t.Fatalf("Expected the body column to not be selected, got %s", query)
+		}
+	}
+	if !found {
+		t.Fatalf("Missing the records DISTINCT query, got %v", selectQueries)
+	}
+}
+
+func TestRecordUpdateIfMatchWithDifferentExpand(t *testing.T) {
+	app := newTestApp(t)
+
+	users, err := app.Dao().FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	author := models.NewRecord(users)
+	author.SetUsername("author")
+	author.SetEmail("author@example.com")
+	author.SetPassword("1234567890")
+	if err := app.Dao().SaveRecord(author); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := &models.Collection{
+		Name:       "posts",
+		Type:       models.CollectionTypeBase,
+		ViewRule:   types.Pointer(""),
+		UpdateRule: types.Pointer(""),
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+			&schema.SchemaField{
+				Name:    "author",
+				Type:    schema.FieldTypeRelation,
+				Options: &schema.RelationOptions{CollectionId: users.Id, MaxSelect: types.Pointer(1)},
+			},
+		),
+	}
+	if err := app.Dao().SaveCollection(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.NewRecord(posts)
+	post.Set("title", "a")
+	post.Set("author", author.Id)
+	if err := app.Dao().SaveRecord(post); err != nil {
+		t.Fatal(err)
+	}
+
+	recordUrl := "/api/collections/posts/records/" + post.Id
+
+	view := serveTestRequest(t, app, http.MethodGet, recordUrl+"?expand=author", "", nil)
+	if view.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", view.Code, view.Body.String())
+	}
+	etag := view.Header().Get("ETag")
+
+	// make sure that the update changes the updated date
+	time.Sleep(5 * time.Millisecond)
+
+	headers := map[string]string{"Content-Type": "application/json", "If-Match": etag}
+
+	update := serveTestRequest(t, app, http.MethodPatch, recordUrl, `{"title":"b"}`, headers)
+	if update.Code != http.StatusOK {
+		t.Fatalf("Expected the view ETag to match the update without expand, got %d: %s", update.Code, update.Body.String())
+	}
+
+	stale := serveTestRequest(t, app, http.MethodPatch, recordUrl, `{"title":"c"}`, headers)
+	if stale.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 for the stale ETag, got %d: %s", stale.Code, stale.Body.String())
+	}
+
+	headers["If-Match"] = stale.Header().Get("ETag")
+	retry := serveTestRequest(t, app, http.MethodPatch, recordUrl, `{"title":"c"}`, headers)
+	if retry.Code != http.StatusOK {
+		t.Fatalf("Expected the conflict ETag to match, got %d: %s", retry.Code, retry.Body.String())
+	}
+}
+
+func TestRecordUpdateIfMatchSavesInTransaction(t *testing.T) {
+	app := newTestApp(t)
+
+	posts := &models.Collection{
+		Name:       "posts",
+		Type:       models.CollectionTypeBase,
+		ViewRule:   types.Pointer(""),
+		UpdateRule: types.Pointer(""),
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+		),
+	}
+	if err := app.Dao().SaveCollection(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.NewRecord(posts)
+	post.Set("title", "a")
+	if err := app.Dao().SaveRecord(post); err != nil {
+		t.Fatal(err)
+	}
+
+	// rest api
+	var restInTx bool
+	app.OnModelBeforeUpdate("posts").Add(func(e *core.ModelEvent) error {
+		_, restInTx = e.Dao.DB().(*dbx.Tx)
+		return nil
+	})
+
+	recordUrl := "/api/collections/posts/records/" + post.Id
+
+	view := serveTestRequest(t, app, http.MethodGet, recordUrl, "", nil)
+	headers := map[string]string{"Content-Type": "application/json", "If-Match": view.Header().Get("ETag")}
+
+	rec := serveTestRequest(t, app, http.MethodPatch, recordUrl, `{"title":"b"}`, headers)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !restInTx {
+		t.Fatal("Expected the conditional update to be saved in the version check transaction")
+	}
+
+	// custom save func (eg. the SQLite cluster replication)
+	record, err := app.Dao().FindRecordById("posts", post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var savedInTx bool
+
+	form := forms.NewRecordUpsert(app, record)
+	form.SetVersionCheck(func(current *models.Record) (bool, error) {
+		return current.GetUpdated().Time().Equal(record.GetUpdated().Time()), nil
+	})
+	form.SetSaveFunc(func(txDao *daos.Dao, m *models.Record) error {
+		_, savedInTx = txDao.DB().(*dbx.Tx)
+		return txDao.SaveRecord(m)
+	})
+	if err := form.LoadData(map[string]any{"title": "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := form.Submit(); err != nil {
+		t.Fatal(err)
+	}
+	if !savedInTx {
+		t.Fatal("Expected the custom save func to run in the version check transaction")
+	}
+}
@@ -0,0 +1,258 @@
+package apis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/spf13/cast"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/picker"
+)
+
+// recordResponse is a serialized record api response
+// together with its conditional request validators.
+//
+// It is also the format of the cached list and view responses,
+// allowing to answer the conditional requests from the cache.
+type recordResponse struct {
+	ETag         string          `json:"etag"`
+	LastModified time.Time       `json:"lastModified"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// newRecordViewResponse serializes the record view response.
+//
+// The ETag has the format "{version}-{representation}", where the version
+// identifies only the record data (see recordVersion) and the representation
+// is derived from the requester cache scope (because of the auth records
+// email visibility), the fields projection and the serialized response
+// (so that it changes also with the expanded relations).
+//
+// The optional fields projection is applied to the serialized record.
+func newRecordViewResponse(record *models.Record, scope string, fields []string) (*recordResponse, error) {
+	data, err := marshalRecordResponseData(record, fields, false)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(scope + ";" + strings.Join(fields, ",") + ";"))
+	h.Write(data)
+
+	return &recordResponse{
+		ETag:         `"` + recordVersion(record) + "-" + hex.EncodeToString(h.Sum(nil))[:16] + `"`,
+		LastModified: record.GetUpdated().Time(),
+		Data:         data,
+	}, nil
+}
+
+// newRecordsListResponse serializes the records list response.
+//
+// The optional fields projection is applied to the result items.
+//
+// The ETag is derived from the serialized result page.
+//
+// Last-Modified is not set because the most recent updated date of the
+// page records doesn't change when a record is deleted or no longer matches.
+func newRecordsListResponse(result any, fields []string) (*recordResponse, error) {
+	data, err := marshalRecordResponseData(result, fields, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return &recordResponse{
+		ETag: `"` + hex.EncodeToString(sum[:])[:32] + `"`,
+		Data: data,
+	}, nil
+}
+
+// marshalRecordResponseData serializes the response data and applies the
+// fields projection the same way as the rest.Serializer (for search
+// results only the items are picked).
+//
+// The responses are written as raw JSON, so the projection has to be
+// applied here and not by the echo serializer.
+func marshalRecordResponseData(data any, fields []string, isList bool) ([]byte, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil || len(fields) == 0 {
+		return encoded, err
+	}
+
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+
+	if isList {
+		if decodedMap, ok := decoded.(map[string]any); ok {
+			picker.Pick(decodedMap["items"], fields)
+		}
+	} else {
+		decoded = picker.Pick(decoded, fields)
+	}
+
+	return json.Marshal(decoded)
+}
+
+// recordViewScope returns the cache scope of the collection record view
+// response for the current requester.
+//
+// The scope is resolved as for a GET request so that the update
+// responses and preconditions share the record view ETag.
+func recordViewScope(c echo.Context, collection *models.Collection) string {
+	info := *RequestInfo(c)
+	info.Method = http.MethodGet
+	info.Data = map[string]any{}
+
+	return recordCacheScope(
+		&info,
+		c.QueryParam(expandQueryParam) != "" || collection.IsAuth(),
+		cast.ToString(collection.ViewRule),
+	)
+}
+
+// recordVersion returns the representation independent version
+// of the record (the first part of its view ETag).
+//
+// The version is derived from the collection, id and updated date of the record.
+// View collection records and records without updated date are not guaranteed
+// to change their updated date together with the data, so for them the
+// version is derived from the record column values instead.
+func recordVersion(record *models.Record) string {
+	h := sha256.New()
+	h.Write([]byte(record.Collection().Id + "/" + record.Id + "@"))
+
+	updated := record.GetUpdated()
+	if record.Collection().IsView() || updated.IsZero() {
+		data, _ := json.Marshal(record.ColumnValueMap())
+		h.Write(data)
+	} else {
+		h.Write([]byte(updated.String()))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// writeRecordResponse sends the record response (or 304 if the client
+// copy is still valid) with the collection cache headers.
+//
+// Responses shared by all non-admin requesters (the "public" cache scope)
+// are allowed to be stored by shared caches.
+func writeRecordResponse(c echo.Context, collection *models.Collection, scope string, response *recordResponse) error {
+	header := c.Response().Header()
+
+	cacheControl := "no-cache"
+	if collection.CacheDuration > 0 {
+		visibility := "private"
+		if scope == "public" {
+			visibility = "public"
+		}
+		cacheControl = visibility + ", max-age=" + strconv.Itoa(collection.CacheDuration)
+	}
+	header.Set(echo.HeaderCacheControl, cacheControl)
+
+	header.Set("ETag", response.ETag)
+	if !response.LastModified.IsZero() {
+		header.Set(echo.HeaderLastModified, response.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if isNotModified(c.Request(), response) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSONBlob(http.StatusOK, response.Data)
+}
+
+// isNotModified checks the request conditional headers against the response validators.
+//
+// If-Modified-Since is checked only when If-None-Match is missing (RFC 9110).
+func isNotModified(r *http.Request, response *recordResponse) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, response.ETag)
+	}
+
+	if ims := r.Header.Get(echo.HeaderIfModifiedSince); ims != "" && !response.LastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+
+		// the header has seconds precision
+		return !response.LastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// matchETag checks whether the ETag is in the comma separated
+// If-None-Match header value.
+//
+// The comparison is weak, aka. the "W/" prefix of the header tags is ignored.
+func matchETag(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchRecordVersion checks the If-Match header value against the
+// current record version.
+//
+// Only the version part of the view ETags is compared, so that a tag
+// returned for any expand or requester matches the same record version.
+// Weak tags never match (strong comparison).
+func matchRecordVersion(header string, record *models.Record) bool {
+	version := recordVersion(record)
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		tag, ok := strings.CutPrefix(tag, `"`)
+		if !ok {
+			continue // weak or malformed
+		}
+
+		if v, _, _ := strings.Cut(strings.TrimSuffix(tag, `"`), "-"); v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionConflictError returns 409 error with the current record
+// (the same as the record view response) in its data.
+func (api *recordApi) versionConflictError(c echo.Context, collection *models.Collection, current *models.Record) *ApiError {
+	if err := EnrichRecord(c, api.app.Dao(), current); err != nil && api.app.IsDebug() {
+		log.Println(err)
+	}
+
+	if response, err := newRecordViewResponse(current, recordViewScope(c, collection), nil); err == nil {
+		c.Response().Header().Set("ETag", response.ETag)
+	}
+
+	apiErr := NewApiError(http.StatusConflict, "The record was modified by another request.", nil)
+	apiErr.Data = map[string]any{"record": current}
+
+	return apiErr
+}
@@ -0,0 +1,246 @@
+package apis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/search"
+)
+
+func TestMarshalRecordResponseData(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		data     any
+		fields   []string
+		isList   bool
+		expected string
+	}{
+		{
+			"no fields",
+			map[string]any{"id": "a", "title": "b"},
+			nil,
+			false,
+			`{"id":"a","title":"b"}`,
+		},
+		{
+			"view projection",
+			map[string]any{"id": "a", "title": "b", "expand": map[string]any{"author": map[string]any{"id": "c", "name": "d"}}},
+			[]string{"id", "expand.author.name"},
+			false,
+			`{"expand":{"author":{"name":"d"}},"id":"a"}`,
+		},
+		{
+			"list projection of the items only",
+			&search.Result{Page: 1, PerPage: 2, Items: []map[string]any{{"id": "a", "title": "b"}, {"id": "c", "title": "d"}}},
+			[]string{"title"},
+			true,
+			`{"items":[{"title":"b"},{"title":"d"}],"page":1,"perPage":2,"totalItems":0,"totalPages":0}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		data, err := marshalRecordResponseData(s.data, s.fields, s.isList)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error: %v", s.name, err)
+			continue
+		}
+
+		if string(data) != s.expected {
+			t.Errorf("[%s] Expected \n%s, \ngot \n%s", s.name, s.expected, data)
+		}
+	}
+}
+
+func TestMatchRecordVersion(t *testing.T) {
+	collection := &models.Collection{}
+	collection.Id = "test"
+
+	record := models.NewRecord(collection)
+	record.Id = "abc"
+	record.RefreshUpdated()
+
+	version := recordVersion(record)
+
+	scenarios := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{"any", "*", true},
+		{"view etag", `"` + version + `-0123456789abcdef"`, true},
+		{"view etag in a list", `"other-0123456789abcdef", "` + version + `-fedcba9876543210"`, true},
+		{"version only", `"` + version + `"`, true},
+		{"weak view etag", `W/"` + version + `-0123456789abcdef"`, false},
+		{"unquoted view etag", version + `-0123456789abcdef`, false},
+		{"different version", `"0123456789abcdef-0123456789abcdef"`, false},
+	}
+
+	for _, s := range scenarios {
+		result := matchRecordVersion(s.header, record)
+		if result != s.expected {
+			t.Errorf("[%s] Expected %v, got %v", s.name, s.expected, result)
+		}
+	}
+}
+
+func TestRecordVersion(t *testing.T) {
+	fields := schema.NewSchema(&schema.SchemaField{Name: "title", Type: schema.FieldTypeText})
+
+	base := &models.Collection{Type: models.CollectionTypeBase, Schema: fields}
+	base.Id = "base"
+
+	view := &models.Collection{Type: models.CollectionTypeView, Schema: fields}
+	view.Id = "view"
+
+	newRecord := func(collection *models.Collection, title string, updated bool) *models.Record {
+		record := models.NewRecord(collection)
+		record.Id = "abc"
+		record.Set("title", title)
+		if updated {
+			record.Set("updated", "2024-01-02 03:04:05.000Z")
+		}
+		return record
+	}
+
+	scenarios := []struct {
+		name     string
+		a        *models.Record
+		b        *models.Record
+		expected bool
+	}{
+		{"base with the same updated and different data", newRecord(base, "a", true), newRecord(base, "b", true), true},
+		{"base without updated and the same data", newRecord(base, "a", false), newRecord(base, "a", false), true},
+		{"base without updated and different data", newRecord(base, "a", false), newRecord(base, "b", false), false},
+		{"view with the same data", newRecord(view, "a", true), newRecord(view, "a", true), true},
+		{"view with the same updated and different data", newRecord(view, "a", true), newRecord(view, "b", true), false},
+	}
+
+	for _, s := range scenarios {
+		result := recordVersion(s.a) == recordVersion(s.b)
+		if result != s.expected {
+			t.Errorf("[%s] Expected equal versions %v, got %v", s.name, s.expected, result)
+		}
+	}
+}
+
+func TestNewRecordViewResponseETag(t *testing.T) {
+	view := &models.Collection{
+		Type:   models.CollectionTypeView,
+		Schema: schema.NewSchema(&schema.SchemaField{Name: "title", Type: schema.FieldTypeText}),
+	}
+	view.Id = "view"
+
+	newRecord := func(title string) *models.Record {
+		record := models.NewRecord(view)
+		record.Id = "abc"
+		record.Set("title", title)
+		return record
+	}
+
+	etag := func(record *models.Record, scope string, fields []string) string {
+		response, err := newRecordViewResponse(record, scope, fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.ETag
+	}
+
+	original := etag(newRecord("a"), "public", nil)
+
+	scenarios := []struct {
+		name     string
+		etag     string
+		expected bool
+	}{
+		{"same record and representation", etag(newRecord("a"), "public", nil), true},
+		{"different view data", etag(newRecord("b"), "public", nil), false},
+		{"different scope", etag(newRecord("a"), "admin", nil), false},
+		{"fields projection", etag(newRecord("a"), "public", []string{"id"}), false},
+	}
+
+	for _, s := range scenarios {
+		result := s.etag == original
+		if result != s.expected {
+			t.Errorf("[%s] Expected equal ETags %v, got %v (%s vs %s)", s.name, s.expected, result, s.etag, original)
+		}
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	etag := `"abc"`
+
+	scenarios := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{"empty", "", false},
+		{"exact", `"abc"`, true},
+		{"different", `"abd"`, false},
+		{"unquoted", `abc`, false},
+		{"weak", `W/"abc"`, true},
+		{"any", "*", true},
+		{"weak any", "W/*", true},
+		{"list", `"x", W/"abc"`, true},
+		{"list without spaces", `"x","abc"`, true},
+		{"list without match", `"x", "y"`, false},
+	}
+
+	for _, s := range scenarios {
+		result := matchETag(s.header, etag)
+		if result != s.expected {
+			t.Errorf("[%s] Expected %v, got %v", s.name, s.expected, result)
+		}
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+	viewResponse := &recordResponse{ETag: `"abc"`, LastModified: lastModified}
+	listResponse := &recordResponse{ETag: `"abc"`}
+
+	scenarios := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		response *recordResponse
+		expected bool
+	}{
+		{"no conditional headers", http.MethodGet, nil, viewResponse, false},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": `"abc"`}, viewResponse, true},
+		{"matching weak etag", http.MethodGet, map[string]string{"If-None-Match": `W/"abc"`}, viewResponse, true},
+		{"any etag", http.MethodGet, map[string]string{"If-None-Match": "*"}, viewResponse, true},
+		{"matching etag for HEAD", http.MethodHead, map[string]string{"If-None-Match": `"abc"`}, viewResponse, true},
+		{"matching etag for POST", http.MethodPost, map[string]string{"If-None-Match": `"abc"`}, viewResponse, false},
+		{"different etag", http.MethodGet, map[string]string{"If-None-Match": `"abd"`}, viewResponse, false},
+		{"same second", http.MethodGet, map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:05 GMT"}, viewResponse, true},
+		{"later date", http.MethodGet, map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:06 GMT"}, viewResponse, true},
+		{"earlier date", http.MethodGet, map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:04 GMT"}, viewResponse, false},
+		{"invalid date", http.MethodGet, map[string]string{"If-Modified-Since": "invalid"}, viewResponse, false},
+		{"date without last modified", http.MethodGet, map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:06 GMT"}, listResponse, false},
+		{
+			"different etag ignores the date",
+			http.MethodGet,
+			map[string]string{"If-None-Match": `"abd"`, "If-Modified-Since": "Tue, 02 Jan 2024 03:04:06 GMT"},
+			viewResponse,
+			false,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest(s.method, "/", nil)
+		for k, v := range s.headers {
+			req.Header.Set(k, v)
+		}
+
+		result := isNotModified(req, s.response)
+		if result != s.expected {
+			t.Errorf("[%s] Expected %v, got %v", s.name, s.expected, result)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package apis
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/search"
+	"github.com/zhenruyan/postgrebase/tools/types"
+	"github.com/zhenruyan/postgrebase/tools/xlsx"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	exportFormatXLSX   = "xlsx"
+
+	// exportChunkSize is the number of records that are expanded
+	// and written together while iterating the export rows cursor.
+	exportChunkSize = 200
+
+	// exportValuesSeparator is used to join the multiple values of
+	// a single csv/xlsx cell (eg. multiple select values or relation ids).
+	exportValuesSeparator = ", "
+)
+
+var exportContentTypes = map[string]string{
+	exportFormatCSV:    "text/csv; charset=utf-8",
+	exportFormatNDJSON: "application/x-ndjson; charset=utf-8",
+	exportFormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportColumn describes a single flattened export column.
+type exportColumn struct {
+	// name is the column header (eg. "title" or "author.name")
+	name string
+
+	// path is the expanded relations path of the column (eg. ["author"])
+	path []string
+
+	// field is the record field name (eg. "name")
+	field string
+}
+
+// export streams all collection records matching the list rule and the
+// filter and sort query parameters as csv, ndjson or xlsx file.
+//
+// The rows are fetched with a cursor and expanded in chunks, so that
+// the full result set is never loaded in memory.
+func (api *recordApi) export(c echo.Context) error {
+	collection, _ := c.Get(ContextCollectionKey).(*models.Collection)
+	if collection == nil {
+		return NewNotFoundError("", "Missing collection context.")
+	}
+
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		format = exportFormatCSV
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		return NewBadRequestError("Invalid export format (must be csv, ndjson or xlsx).", nil)
+	}
+
+	// forbid users and guests to query special filter/sort fields
+	if err := api.checkForForbiddenQueryFields(c); err != nil {
+		return err
+	}
+
+	requestInfo := RequestInfo(c)
+
+	if requestInfo.Admin == nil && collection.ListRule == nil {
+		// only admins can access if the rule is nil
+		return NewForbiddenError("Only admins can perform this action.", nil)
+	}
+
+	columns, err := exportColumns(api.app.Dao(), collection, c.QueryParam(expandQueryParam), c.QueryParam(fieldsQueryParam))
+	if err != nil {
+		return NewBadRequestError("Invalid fields or expand parameters.", err)
+	}
+
+	fieldsResolver := resolvers.NewRecordFieldResolver(
+		api.app.Dao(),
+		collection,
+		requestInfo,
+		// hidden fields are searchable only by admins
+		requestInfo.Admin != nil,
+	)
+
+	searchProvider := search.NewProvider(fieldsResolver).
+		Query(api.app.Dao().RecordQuery(collection))
+
+	if requestInfo.Admin == nil && collection.ListRule != nil {
+		searchProvider.AddFilter(search.FilterData(*collection.ListRule))
+	}
+
+	if err := searchProvider.Parse(c.QueryParams().Encode()); err != nil {
+		return NewBadRequestError("Invalid filter parameters.", err)
+	}
+
+	query, err := searchProvider.BuildQuery()
+	if err != nil {
+		return NewBadRequestError("Invalid filter parameters.", err)
+	}
+
+	rows, err := query.WithContext(c.Request().Context()).Rows()
+	if err != nil {
+		return NewBadRequestError("Invalid filter parameters.", err)
+	}
+	defer rows.Close()
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, exportContentTypes[format])
+	response.Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{
+		"filename": collection.Name + "." + format,
+	}))
+	response.Header().Set(echo.HeaderCacheControl, "no-store")
+	response.WriteHeader(http.StatusOK)
+
+	writer, err := newExportWriter(format, response, collection.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.WriteHeader(columns); err != nil {
+		return err
+	}
+
+	chunk := make([]*models.Record, 0, exportChunkSize)
+	page := 0
+
+	// each chunk is passed through the list request hooks as a result page
+	writeChunk := func() error {
+		page++
+
+		event := new(core.RecordsListEvent)
+		event.HttpContext = c
+		event.Collection = collection
+		event.Records = chunk
+		event.Result = &search.Result{Page: page, PerPage: exportChunkSize, Items: chunk}
+
+		chunk = make([]*models.Record, 0, exportChunkSize)
+
+		return api.app.OnRecordsListRequest().Trigger(event, func(e *core.RecordsListEvent) error {
+			if err := EnrichRecords(e.HttpContext, api.app.Dao(), e.Records); err != nil && api.app.IsDebug() {
+				log.Println(err)
+			}
+
+			for _, record := range e.Records {
+				if err := writer.WriteRow(exportRowValues(record, columns)); err != nil {
+					return err
+				}
+			}
+
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			response.Flush()
+
+			return nil
+		})
+	}
+
+	for rows.Next() {
+		row := dbx.NullStringMap{}
+		if err := rows.ScanMap(row); err != nil {
+			return err
+		}
+
+		chunk = append(chunk, models.NewRecordFromNullStringMap(collection, row))
+
+		if len(chunk) == exportChunkSize {
+			if err := writeChunk(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(chunk) > 0 {
+		if err := writeChunk(); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// exportColumns returns the flattened export columns of the collection
+// and its expanded relations, optionally limited to the comma separated
+// fields list (in the specified order).
+func exportColumns(
+	dao *daos.Dao,
+	collection *models.Collection,
+	expandParam string,
+	fieldsParam string,
+) ([]*exportColumn, error) {
+	columns := []*exportColumn{}
+	for _, field := range exportRecordFields(collection) {
+		columns = append(columns, &exportColumn{name: field, field: field})
+	}
+
+	// expanded relation columns
+	added := map[string]bool{}
+	for _, expand := range strings.Split(expandParam, ",") {
+		expand = strings.ReplaceAll(expand, " ", "")
+
+		current := collection
+		path := []string{}
+
+		for i, part := range strings.Split(strings.Trim(expand, "."), ".") {
+			if part == "" || i >= daos.MaxExpandDepth {
+				break
+			}
+
+			rels := dao.FindExpandCollections(current, []string{part})
+			if len(rels) == 0 {
+				break // unresolvable path
+			}
+			current = rels[0]
+			path = append(path, part)
+
+			prefix := strings.Join(path, ".")
+			if added[prefix] {
+				continue
+			}
+			added[prefix] = true
+
+			for _, field := range exportRecordFields(current) {
+				columns = append(columns, &exportColumn{
+					name:  prefix + "." + field,
+					path:  append([]string{}, path...),
+					field: field,
+				})
+			}
+		}
+	}
+
+	if strings.TrimSpace(fieldsParam) == "" {
+		return columns, nil
+	}
+
+	indexed := make(map[string]*exportColumn, len(columns))
+	for _, col := range columns {
+		indexed[col.name] = col
+	}
+
+	picked := []*exportColumn{}
+	for _, field := range strings.Split(fieldsParam, ",") {
+		field = strings.TrimPrefix(strings.TrimSpace(field), schema.FieldNameExpand+".")
+		if field == "" {
+			continue
+		}
+
+		col, ok := indexed[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown export field %q", field)
+		}
+		picked = append(picked, col)
+	}
+
+	return picked, nil
+}
+
+// exportRecordFields returns the exported field names of a collection record.
+func exportRecordFields(collection *models.Collection) []string {
+	fields := []string{schema.FieldNameId}
+
+	if collection.IsAuth() {
+		fields = append(
+			fields,
+			schema.FieldNameUsername,
+			schema.FieldNameEmail,
+			schema.FieldNameEmailVisibility,
+			schema.FieldNameVerified,
+		)
+	}
+
+	for _, field := range collection.Schema.Fields() {
+		if field.Name != schema.FieldNameId {
+			fields = append(fields, field.Name)
+		}
+	}
+
+	if !collection.IsView() {
+		fields = append(fields, schema.FieldNameCreated, schema.FieldNameUpdated)
+	}
+
+	return fields
+}
+
+// exportRowValues returns the record column values.
+//
+// The expanded multiple relations column values are returned as slice.
+func exportRowValues(record *models.Record, columns []*exportColumn) []any {
+	exports := map[*models.Record]map[string]any{}
+
+	result := make([]any, len(columns))
+
+	for i, col := range columns {
+		records := []*models.Record{record}
+		multiple := false
+
+		for _, part := range col.path {
+			next := []*models.Record{}
+			for _, r := range records {
+				switch v := r.Expand()[part].(type) {
+				case *models.Record:
+					next = append(next, v)
+				case []*models.Record:
+					next = append(next, v...)
+					multiple = true
+				}
+			}
+			records = next
+		}
+
+		values := make([]any, 0, len(records))
+		for _, r := range records {
+			export, ok := exports[r]
+			if !ok {
+				export = r.PublicExport()
+				exports[r] = export
+			}
+			values = append(values, export[col.field])
+		}
+
+		switch {
+		case multiple:
+			result[i] = values
+		case len(values) == 1:
+			result[i] = values[0]
+		}
+	}
+
+	return result
+}
+
+// exportCellValue flattens a column value into a single csv/xlsx cell value.
+//
+// The bool and number values are returned as they are, everything else as string.
+func exportCellValue(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case bool, int, int64, float64:
+		return v
+	case string:
+		return v
+	case types.DateTime:
+		return v.String()
+	case types.JsonRaw:
+		return v.String()
+	case []string:
+		return strings.Join(v, exportValuesSeparator)
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := exportCellString(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, exportValuesSeparator)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(raw)
+	}
+}
+
+// exportCellString returns the string representation of a csv/xlsx cell value.
+func exportCellString(value any) string {
+	switch v := exportCellValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// -------------------------------------------------------------------
+
+// exportWriter writes the export rows in a specific file format.
+type exportWriter interface {
+	WriteHeader(columns []*exportColumn) error
+	WriteRow(values []any) error
+	Flush() error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer, name string) (exportWriter, error) {
+	switch format {
+	case exportFormatNDJSON:
+		return &ndjsonExportWriter{w: bufio.NewWriter(w)}, nil
+	case exportFormatXLSX:
+		xw, err := xlsx.NewWriter(w, name)
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExportWriter{w: xw}, nil
+	default:
+		return &csvExportWriter{w: csv.NewWriter(w)}, nil
+	}
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvExportWriter) WriteHeader(columns []*exportColumn) error {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+
+	return cw.w.Write(header)
+}
+
+func (cw *csvExportWriter) WriteRow(values []any) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		if _, isString := exportCellValue(v).(string); isString {
+			row[i] = escapeCsvFormula(exportCellString(v))
+		} else {
+			row[i] = exportCellString(v)
+		}
+	}
+
+	return cw.w.Write(row)
+}
+
+func (cw *csvExportWriter) Flush() error {
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+func (cw *csvExportWriter) Close() error {
+	return cw.Flush()
+}
+
+// escapeCsvFormula prefixes the text values that spreadsheet
+// applications would evaluate as formula with a single quote
+// (aka. CSV injection protection).
+func escapeCsvFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+type xlsxExportWriter struct {
+	w *xlsx.Writer
+}
+
+func (xw *xlsxExportWriter) WriteHeader(columns []*exportColumn) error {
+	header := make([]any, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+
+	return xw.w.WriteRow(header)
+}
+
+func (xw *xlsxExportWriter) WriteRow(values []any) error {
+	row := make([]any, len(values))
+	for i, v := range values {
+		row[i] = exportCellValue(v)
+	}
+
+	return xw.w.WriteRow(row)
+}
+
+func (xw *xlsxExportWriter) Flush() error {
+	return xw.w.Flush()
+}
+
+func (xw *xlsxExportWriter) Close() error {
+	return xw.w.Close()
+}
+
+// ndjsonExportWriter writes each row as a separate JSON object line
+// with the column names as keys (in the columns order) and their
+// original (not flattened) values.
+type ndjsonExportWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func (nw *ndjsonExportWriter) WriteHeader(columns []*exportColumn) error {
+	nw.keys = make([][]byte, len(columns))
+	for i, col := range columns {
+		key, err := json.Marshal(col.name)
+		if err != nil {
+			return err
+		}
+		nw.keys[i] = key
+	}
+
+	return nil
+}
+
+func (nw *ndjsonExportWriter) WriteRow(values []any) error {
+	nw.w.WriteByte('{')
+
+	for i, v := range values {
+		if i > 0 {
+			nw.w.WriteByte(',')
+		}
+
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		nw.w.Write(nw.keys[i])
+		nw.w.WriteByte(':')
+		nw.w.Write(raw)
+	}
+
+	_, err := nw.w.WriteString("}\n")
+
+	return err
+}
+
+func (nw *ndjsonExportWriter) Flush() error {
+	return nw.w.Flush()
+}
+
+func (nw *ndjsonExportWriter) Close() error {
+	return nw.Flush()
+}
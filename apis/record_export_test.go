@@ -0,0 +1,110 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+func TestCsvExportWriterEscapesFormulas(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{"nil", nil, ""},
+		{"plain text", "abc", "abc"},
+		{"formula", "=1+2", "'=1+2"},
+		{"plus", "+1", "'+1"},
+		{"minus text", "-1+2", "'-1+2"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\tx", "'\tx"},
+		{"carriage return", "\rx", "'\rx"},
+		{"formula in the middle", "a=b", "a=b"},
+		{"negative number", -5, "-5"},
+		{"negative float", -1.5, "-1.5"},
+		{"multiple values", []string{"=a", "b"}, "'=a, b"},
+	}
+
+	for _, s := range scenarios {
+		var buf bytes.Buffer
+
+		writer := &csvExportWriter{w: csv.NewWriter(&buf)}
+		if err := writer.WriteRow([]any{s.value, "x"}); err != nil {
+			t.Errorf("[%s] Failed to write the row: %v", s.name, err)
+			continue
+		}
+		if err := writer.Flush(); err != nil {
+			t.Errorf("[%s] Failed to flush: %v", s.name, err)
+			continue
+		}
+
+		row, err := csv.NewReader(&buf).Read()
+		if err != nil {
+			t.Errorf("[%s] Failed to read the row: %v", s.name, err)
+			continue
+		}
+
+		if row[0] != s.expected {
+			t.Errorf("[%s] Expected %q, got %q", s.name, s.expected, row[0])
+		}
+	}
+}
+
+func TestRecordsExportTriggersListHook(t *testing.T) {
+	app := newTestApp(t)
+
+	posts := &models.Collection{
+		Name:     "posts",
+		Type:     models.CollectionTypeBase,
+		ListRule: types.Pointer(""),
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+		),
+	}
+	if err := app.Dao().SaveCollection(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"public", "secret"} {
+		record := models.NewRecord(posts)
+		record.Set("title", title)
+		if err := app.Dao().SaveRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var calls int
+	app.OnRecordsListRequest("posts").Add(func(e *core.RecordsListEvent) error {
+		calls++
+
+		filtered := make([]*models.Record, 0, len(e.Records))
+		for _, record := range e.Records {
+			if record.GetString("title") != "secret" {
+				filtered = append(filtered, record)
+			}
+		}
+		e.Records = filtered
+
+		return nil
+	})
+
+	rec := serveTestRequest(t, app, http.MethodGet, "/api/collections/posts/export?format=csv&fields=title", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if calls != 1 {
+		t.Fatalf("Expected the list hook to be called once, got %d", calls)
+	}
+
+	if body := rec.Body.String(); body != "title\npublic\n" {
+		t.Fatalf("Expected only the records left by the hook, got %q", body)
+	}
+}
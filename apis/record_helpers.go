@@ -13,6 +13,7 @@ import (
 	"github.com/zhenruyan/postgrebase/models"
 	"github.com/zhenruyan/postgrebase/resolvers"
 	"github.com/zhenruyan/postgrebase/tokens"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/rest"
 	"github.com/zhenruyan/postgrebase/tools/search"
 )
@@ -140,6 +141,7 @@ func EnrichRecord(c echo.Context, dao *daos.Dao, record *models.Record, defaultE
 //   - expands relations (if defaultExpands and/or ?expand query param is set)
 //   - ensures that the emails of the auth records and their expanded auth relations
 //     are visibe only for the current logged admin, record owner or record with manage access
+//   - limits the records serialization to the ?fields query param projection (if set)
 func EnrichRecords(c echo.Context, dao *daos.Dao, records []*models.Record, defaultExpands ...string) error {
 	requestInfo := RequestInfo(c)
 
@@ -147,6 +149,12 @@ func EnrichRecords(c echo.Context, dao *daos.Dao, records []*models.Record, defa
 		return fmt.Errorf("Failed to resolve email visibility: %w", err)
 	}
 
+	if fields := picker.ParseFields(c.QueryParam(fieldsQueryParam)); len(fields) > 0 {
+		for _, record := range records {
+			record.WithFields(fields)
+		}
+	}
+
 	expands := defaultExpands
 	if param := c.QueryParam(expandQueryParam); param != "" {
 		expands = append(expands, strings.Split(param, ",")...)
@@ -0,0 +1,1040 @@
+package apis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/labstack/echo/v5"
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/inflector"
+	"github.com/zhenruyan/postgrebase/tools/list"
+	"github.com/zhenruyan/postgrebase/tools/routine"
+	"github.com/zhenruyan/postgrebase/tools/security"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+const (
+	importModeAtomic = "atomic"
+	importModeSkip   = "skip"
+	importModeUpsert = "upsert"
+
+	importFormatCSV    = "csv"
+	importFormatNDJSON = "ndjson"
+	importFormatJSON   = "json"
+
+	importStatusRunning   = "running"
+	importStatusCompleted = "completed"
+	importStatusFailed    = "failed"
+
+	// importChunkSize is the number of rows committed together
+	// in a single transaction in the skip and upsert modes.
+	importChunkSize = 100
+
+	// importBackgroundMinSize is the min size (in bytes) of the
+	// import data that is processed in a background job.
+	importBackgroundMinSize = 1 << 20
+
+	// importMaxRowErrors is the max number of reported row errors
+	// (the failed rows are still counted).
+	importMaxRowErrors = 1000
+
+	// importMaxLookupCache is the max number of cached relation lookup values.
+	importMaxLookupCache = 10000
+
+	// importJobTTL is for how long a finished background job report is kept.
+	importJobTTL = 1 * time.Hour
+
+	importJobCacheKeyPrefix = "@importJob_"
+)
+
+// errImportRolledBack is used to revert the atomic import
+// transaction when at least one of the rows has failed.
+var errImportRolledBack = errors.New("import rolled back")
+
+type importRowError struct {
+	Row   int       `json:"row"`
+	Error *ApiError `json:"error"`
+}
+
+// importJob is the import progress report.
+type importJob struct {
+	mu sync.RWMutex
+
+	owner        string
+	collectionId string
+
+	Id         string            `json:"id"`
+	Collection string            `json:"collection"`
+	Mode       string            `json:"mode"`
+	Status     string            `json:"status"`
+	Message    string            `json:"message"`
+	Processed  int               `json:"processed"`
+	Inserted   int               `json:"inserted"`
+	Updated    int               `json:"updated"`
+	Failed     int               `json:"failed"`
+	Errors     []*importRowError `json:"errors"`
+	Started    types.DateTime    `json:"started"`
+	Finished   types.DateTime    `json:"finished"`
+}
+
+// MarshalJSON implements the [json.Marshaler] interface.
+func (job *importJob) MarshalJSON() ([]byte, error) {
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	type alias importJob
+
+	return json.Marshal((*alias)(job))
+}
+
+func (job *importJob) nextRow() int {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.Processed++
+
+	return job.Processed
+}
+
+func (job *importJob) addResult(created bool) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if created {
+		job.Inserted++
+	} else {
+		job.Updated++
+	}
+}
+
+func (job *importJob) addError(row int, err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.Failed++
+
+	if len(job.Errors) >= importMaxRowErrors {
+		return
+	}
+
+	apiErr, ok := err.(*ApiError)
+	if !ok && !errors.As(err, &apiErr) {
+		apiErr = NewBadRequestError("", err)
+	}
+
+	job.Errors = append(job.Errors, &importRowError{Row: row, Error: apiErr})
+}
+
+func (job *importJob) failedCount() int {
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	return job.Failed
+}
+
+// revertRows marks the saved rows of a failed chunk commit as failed.
+func (job *importJob) revertRows(rows []importedRow, err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	apiErr := NewBadRequestError("Failed to commit the imported row.", err)
+
+	for _, row := range rows {
+		if row.created {
+			job.Inserted--
+		} else {
+			job.Updated--
+		}
+
+		job.Failed++
+
+		if len(job.Errors) < importMaxRowErrors {
+			job.Errors = append(job.Errors, &importRowError{Row: row.row, Error: apiErr})
+		}
+	}
+}
+
+// rollback resets the saved records counters after a reverted transaction.
+func (job *importJob) rollback() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.Inserted = 0
+	job.Updated = 0
+}
+
+func (job *importJob) finish(message string) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.Status = importStatusCompleted
+	if message != "" {
+		job.Status = importStatusFailed
+		job.Message = message
+	}
+	job.Finished = types.NowDateTime()
+}
+
+// importedRow describes a single saved import row.
+type importedRow struct {
+	row     int
+	created bool
+}
+
+// importChunk holds the saved rows of a single import transaction
+// and their records with the "after" hooks to run after its commit.
+type importChunk struct {
+	rows       []importedRow
+	records    []*models.Record
+	afterFuncs []batchAfterFunc
+}
+
+// importLookup describes a relation field whose import values
+// are resolved by a field of the related collection records.
+type importLookup struct {
+	collection *models.Collection
+	field      string
+}
+
+type importOptions struct {
+	format         string
+	mode           string
+	conflictFields []string
+	mapping        map[string]string
+	lookups        map[string]*importLookup
+
+	// lookupCache stores the resolved relation lookup ids
+	lookupCache map[string]string
+}
+
+// importRecords creates (or upserts) the collection records from
+// the uploaded csv, ndjson or json array file.
+//
+// Each row is validated and checked against the collection API rules
+// the same way as the regular create requests. Large files are
+// imported in a background job (see [recordApi.importStatus]).
+func (api *recordApi) importRecords(c echo.Context) error {
+	collection, _ := c.Get(ContextCollectionKey).(*models.Collection)
+	if collection == nil {
+		return NewNotFoundError("", "Missing collection context.")
+	}
+
+	if api.app.IsSQLiteCluster() {
+		return NewBadRequestError("Import requests are not supported in SQLite cluster mode.", nil)
+	}
+
+	opts, err := api.importOptions(c, collection)
+	if err != nil {
+		return err
+	}
+
+	requestInfo := RequestInfo(c)
+
+	if requestInfo.Admin == nil &&
+		collection.CreateRule == nil &&
+		(opts.mode != importModeUpsert || collection.UpdateRule == nil) {
+		// only admins can access if the rule is nil
+		return NewForbiddenError("Only admins can perform this action.", nil)
+	}
+
+	source, name, size, err := importSource(c)
+	if err != nil {
+		return NewBadRequestError("Missing or invalid import data.", err)
+	}
+	defer source.Close()
+
+	if opts.format == "" {
+		opts.format = importFormatFromName(name, c.Request().Header.Get(echo.HeaderContentType))
+		if opts.format == "" {
+			return NewBadRequestError("Unknown import format (must be csv, ndjson or json).", nil)
+		}
+	}
+
+	job := &importJob{
+		owner:        importJobOwner(requestInfo),
+		collectionId: collection.Id,
+		Id:           security.NewUUIDString(),
+		Collection:   collection.Name,
+		Mode:         opts.mode,
+		Status:       importStatusRunning,
+		Errors:       []*importRowError{},
+		Started:      types.NowDateTime(),
+	}
+
+	background := size < 0 || size >= importBackgroundMinSize
+	switch strings.ToLower(c.FormValue("background")) {
+	case "true", "1":
+		background = true
+	case "false", "0":
+		background = false
+	}
+
+	if !background {
+		reader, err := newImportReader(opts.format, source)
+		if err != nil {
+			return NewBadRequestError("Failed to read the import data.", err)
+		}
+
+		api.runImport(c, collection, job, opts, reader)
+
+		return c.JSON(http.StatusOK, job)
+	}
+
+	// persist the import data since the uploaded
+	// files are deleted after the request completion
+	tempDir := filepath.Join(api.app.DataDir(), core.LocalTempDirName)
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return NewBadRequestError("Failed to prepare the import data.", err)
+	}
+
+	tempFile, err := os.CreateTemp(tempDir, "import_*")
+	if err != nil {
+		return NewBadRequestError("Failed to prepare the import data.", err)
+	}
+
+	cleanup := func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}
+
+	if _, err := io.Copy(tempFile, source); err != nil {
+		cleanup()
+		return NewBadRequestError("Failed to read the import data.", err)
+	}
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return NewBadRequestError("Failed to read the import data.", err)
+	}
+
+	reader, err := newImportReader(opts.format, tempFile)
+	if err != nil {
+		cleanup()
+		return NewBadRequestError("Failed to read the import data.", err)
+	}
+
+	jobKey := importJobCacheKeyPrefix + job.Id
+
+	api.app.Cache().Set(jobKey, job)
+
+	bgContext := detachedImportContext(c)
+
+	routine.FireAndForget(func() {
+		defer cleanup()
+
+		api.runImport(bgContext, collection, job, opts, reader)
+
+		time.AfterFunc(importJobTTL, func() {
+			api.app.Cache().Remove(jobKey)
+		})
+	})
+
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// importStatus returns the report of a background import job.
+//
+// Only the import requester can access the job report.
+func (api *recordApi) importStatus(c echo.Context) error {
+	collection, _ := c.Get(ContextCollectionKey).(*models.Collection)
+	if collection == nil {
+		return NewNotFoundError("", "Missing collection context.")
+	}
+
+	job, _ := api.app.Cache().Get(importJobCacheKeyPrefix + c.PathParam("jobId")).(*importJob)
+	if job == nil ||
+		job.collectionId != collection.Id ||
+		job.owner != importJobOwner(RequestInfo(c)) {
+		return NewNotFoundError("", nil)
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// importOptions loads and validates the import query or multipart form parameters.
+func (api *recordApi) importOptions(c echo.Context, collection *models.Collection) (*importOptions, error) {
+	opts := &importOptions{
+		format:      strings.ToLower(c.FormValue("format")),
+		mode:        strings.ToLower(c.FormValue("mode")),
+		mapping:     map[string]string{},
+		lookups:     map[string]*importLookup{},
+		lookupCache: map[string]string{},
+	}
+
+	switch opts.format {
+	case "", importFormatCSV, importFormatNDJSON, importFormatJSON:
+	default:
+		return nil, NewBadRequestError("Invalid import format (must be csv, ndjson or json).", nil)
+	}
+
+	switch opts.mode {
+	case "":
+		opts.mode = importModeAtomic
+	case importModeAtomic, importModeSkip, importModeUpsert:
+	default:
+		return nil, NewBadRequestError("Invalid import mode (must be atomic, skip or upsert).", nil)
+	}
+
+	if opts.mode == importModeUpsert {
+		opts.conflictFields = splitConflictFields(c.FormValue("onConflict"))
+		if len(opts.conflictFields) == 0 {
+			opts.conflictFields = []string{schema.FieldNameId}
+		}
+
+		if err := daos.ValidateConflictFields(collection, opts.conflictFields); err != nil {
+			return nil, NewBadRequestError("Invalid onConflict fields.", err)
+		}
+	}
+
+	if raw := c.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.mapping); err != nil {
+			return nil, NewBadRequestError("Invalid mapping parameter (must be a JSON object with column-to-field names).", err)
+		}
+	}
+
+	rawLookups := map[string]string{}
+	if raw := c.FormValue("lookup"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &rawLookups); err != nil {
+			return nil, NewBadRequestError("Invalid lookup parameter (must be a JSON object with relation-to-lookup field names).", err)
+		}
+	}
+
+	isAdmin := RequestInfo(c).Admin != nil
+
+	for fieldName, lookupField := range rawLookups {
+		field := collection.Schema.GetFieldByName(fieldName)
+		if field == nil || field.Type != schema.FieldTypeRelation {
+			return nil, NewBadRequestError(fmt.Sprintf("Invalid lookup parameter - %q is not a relation field.", fieldName), nil)
+		}
+
+		field.InitOptions()
+		options, _ := field.Options.(*schema.RelationOptions)
+		if options == nil {
+			return nil, NewBadRequestError(fmt.Sprintf("Invalid lookup parameter - %q is not a relation field.", fieldName), nil)
+		}
+
+		relCollection, err := api.app.Dao().FindCollectionByNameOrId(options.CollectionId)
+		if err != nil {
+			return nil, NewBadRequestError(fmt.Sprintf("Invalid lookup parameter - missing %q related collection.", fieldName), err)
+		}
+
+		if !importHasField(relCollection, lookupField) {
+			return nil, NewBadRequestError(fmt.Sprintf("Invalid lookup parameter - %q collection doesn't have %q field.", relCollection.Name, lookupField), nil)
+		}
+
+		if !isAdmin && relCollection.ViewRule == nil {
+			return nil, NewForbiddenError(fmt.Sprintf("Only admins can lookup %q records.", relCollection.Name), nil)
+		}
+
+		opts.lookups[fieldName] = &importLookup{collection: relCollection, field: lookupField}
+	}
+
+	return opts, nil
+}
+
+// runImport imports all reader rows and updates the job report.
+func (api *recordApi) runImport(
+	c echo.Context,
+	collection *models.Collection,
+	job *importJob,
+	opts *importOptions,
+	reader importReader,
+) {
+	var message string
+
+	if opts.mode == importModeAtomic {
+		message = api.importAtomic(c, collection, job, opts, reader)
+	} else {
+		message = api.importChunks(c, collection, job, opts, reader)
+	}
+
+	job.finish(message)
+}
+
+// importAtomic imports all rows in a single transaction that is
+// reverted if any of the rows fails.
+func (api *recordApi) importAtomic(
+	c echo.Context,
+	collection *models.Collection,
+	job *importJob,
+	opts *importOptions,
+	reader importReader,
+) string {
+	var readErr error
+
+	chunk := &importChunk{}
+
+	txErr := api.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		_, readErr = api.importRows(c, txDao, collection, job, opts, reader, 0, chunk)
+		if readErr != nil {
+			return readErr
+		}
+
+		if job.failedCount() > 0 {
+			return errImportRolledBack
+		}
+
+		return nil
+	})
+	if txErr != nil && !isAfterTransactionError(txErr) {
+		job.rollback()
+
+		switch {
+		case readErr != nil:
+			return "Failed to read the import data: " + readErr.Error()
+		case errors.Is(txErr, errImportRolledBack):
+			return fmt.Sprintf("The import was reverted because %d row(s) failed.", job.failedCount())
+		default:
+			return "Failed to import the records: " + txErr.Error()
+		}
+	}
+
+	api.importAfterCommit(txErr, chunk)
+
+	return ""
+}
+
+// importChunks imports the rows in multiple smaller transactions,
+// skipping the failed rows.
+func (api *recordApi) importChunks(
+	c echo.Context,
+	collection *models.Collection,
+	job *importJob,
+	opts *importOptions,
+	reader importReader,
+) string {
+	for {
+		var done bool
+		var readErr error
+
+		chunk := &importChunk{}
+
+		txErr := api.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			// the already imported chunk rows are committed even on read error
+			done, readErr = api.importRows(c, txDao, collection, job, opts, reader, importChunkSize, chunk)
+
+			return nil
+		})
+		if txErr != nil && !isAfterTransactionError(txErr) {
+			// nothing from the chunk was written (eg. failed commit)
+			job.revertRows(chunk.rows, txErr)
+		} else {
+			api.importAfterCommit(txErr, chunk)
+		}
+
+		if readErr != nil {
+			return "Failed to read the import data: " + readErr.Error()
+		}
+
+		if done {
+			return ""
+		}
+	}
+}
+
+// importRows imports up to limit reader rows (or all if limit is 0) within the txDao transaction.
+//
+// Returns done=true when all rows have been read and a non-nil
+// error if the import data couldn't be read any further.
+func (api *recordApi) importRows(
+	c echo.Context,
+	txDao *daos.Dao,
+	collection *models.Collection,
+	job *importJob,
+	opts *importOptions,
+	reader importReader,
+	limit int,
+	chunk *importChunk,
+) (bool, error) {
+	for i := 0; limit <= 0 || i < limit; i++ {
+		row, err := reader.Next()
+		if err == io.EOF {
+			return true, nil
+		}
+
+		var rowErr *importRowDataError
+		if err != nil && !errors.As(err, &rowErr) {
+			return true, err
+		}
+
+		rowNum := job.nextRow()
+
+		if rowErr != nil {
+			job.addError(rowNum, NewBadRequestError("Invalid row data. Raw error: \n"+rowErr.Error(), nil))
+			continue
+		}
+
+		var record *models.Record
+		var after batchAfterFunc
+		var created bool
+
+		err = txDao.RunInSavepoint(func(spDao *daos.Dao) error {
+			data, err := api.importRowData(c, spDao, collection, opts, row)
+			if err != nil {
+				return err
+			}
+
+			if opts.mode == importModeUpsert {
+				record, after, created, err = api.batchUpsert(c, spDao, collection, "", opts.conflictFields, data)
+			} else {
+				record, after, err = api.batchCreate(c, spDao, collection, data)
+				created = true
+			}
+
+			return err
+		})
+		if err != nil {
+			job.addError(rowNum, err)
+			continue
+		}
+
+		job.addResult(created)
+
+		chunk.rows = append(chunk.rows, importedRow{row: rowNum, created: created})
+		chunk.records = append(chunk.records, record)
+		if after != nil {
+			chunk.afterFuncs = append(chunk.afterFuncs, after)
+		}
+	}
+
+	return false, nil
+}
+
+// isAfterTransactionError reports whether txErr is a model "after" hook
+// error of an already committed transaction.
+func isAfterTransactionError(txErr error) bool {
+	var afterErr *daos.AfterTransactionError
+
+	return errors.As(txErr, &afterErr)
+}
+
+// importAfterCommit triggers the "after" request hooks
+// and the embeddings of the committed import records.
+func (api *recordApi) importAfterCommit(txErr error, chunk *importChunk) {
+	// the model "after" hooks errors don't revert the committed changes
+	if txErr != nil && api.app.IsDebug() {
+		log.Println(txErr)
+	}
+
+	for _, after := range chunk.afterFuncs {
+		if err := after(); err != nil && api.app.IsDebug() {
+			log.Println(err)
+		}
+	}
+
+	if vectorManager := api.app.VectorManager(); vectorManager != nil {
+		for _, record := range chunk.records {
+			vectorManager.TriggerRecordEmbedding(record)
+		}
+	}
+}
+
+// importRowData converts a single import row into record data
+// by applying the column mapping and the relation lookups.
+func (api *recordApi) importRowData(
+	c echo.Context,
+	txDao *daos.Dao,
+	collection *models.Collection,
+	opts *importOptions,
+	row map[string]any,
+) (map[string]any, error) {
+	data := make(map[string]any, len(row))
+
+	for column, value := range row {
+		name := column
+		if mapped, ok := opts.mapping[column]; ok {
+			name = mapped
+		}
+		if name == "" {
+			continue // ignored column
+		}
+
+		field := collection.Schema.GetFieldByName(name)
+		if field != nil {
+			if field.Type == schema.FieldTypeFile {
+				continue // file uploads are not supported
+			}
+
+			// csv multiple values are comma separated
+			if str, ok := value.(string); ok && opts.format == importFormatCSV && importIsMultiple(field) {
+				value = importSplitValues(str)
+			}
+		}
+
+		if lookup, ok := opts.lookups[name]; ok {
+			ids, err := api.importLookupIds(c, txDao, opts, lookup, value)
+			if err != nil {
+				return nil, NewBadRequestError("Failed to resolve the relation lookup.", validation.Errors{
+					name: validation.NewError("validation_lookup_failure", err.Error()),
+				})
+			}
+			value = ids
+		}
+
+		data[name] = value
+	}
+
+	return data, nil
+}
+
+// importLookupIds returns the ids of the related records whose
+// lookup field matches the provided value(s).
+func (api *recordApi) importLookupIds(
+	c echo.Context,
+	txDao *daos.Dao,
+	opts *importOptions,
+	lookup *importLookup,
+	value any,
+) ([]string, error) {
+	values := list.ToUniqueStringSlice(value)
+
+	ids := make([]string, 0, len(values))
+
+	requestInfo := batchRequestInfo(c, http.MethodGet, map[string]any{})
+
+	for _, v := range values {
+		cacheKey := lookup.collection.Id + "." + lookup.field + "." + v
+		if id, ok := opts.lookupCache[cacheKey]; ok {
+			ids = append(ids, id)
+			continue
+		}
+
+		var prepared any = v
+		if field := lookup.collection.Schema.GetFieldByName(lookup.field); field != nil {
+			prepared = field.PrepareValue(v)
+		}
+
+		records := []*models.Record{}
+
+		query := txDao.RecordQuery(lookup.collection).
+			AndWhere(dbx.HashExp{
+				lookup.collection.Name + "." + inflector.Columnify(lookup.field): prepared,
+			}).
+			Limit(2)
+
+		if err := batchRuleFunc(txDao, lookup.collection, lookup.collection.ViewRule, requestInfo)(query); err != nil {
+			return nil, err
+		}
+
+		if err := query.All(&records); err != nil {
+			return nil, err
+		}
+
+		switch len(records) {
+		case 0:
+			return nil, fmt.Errorf("missing %q record with %s %q", lookup.collection.Name, lookup.field, v)
+		case 1:
+		default:
+			return nil, fmt.Errorf("more than one %q record with %s %q", lookup.collection.Name, lookup.field, v)
+		}
+
+		if len(opts.lookupCache) >= importMaxLookupCache {
+			opts.lookupCache = map[string]string{}
+		}
+		opts.lookupCache[cacheKey] = records[0].Id
+
+		ids = append(ids, records[0].Id)
+	}
+
+	return ids, nil
+}
+
+// importSource returns the import data reader from the multipart
+// "file" field or from the raw request body (with its name and size).
+//
+// The returned size is -1 if unknown.
+func importSource(c echo.Context) (io.ReadCloser, string, int64, error) {
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		return f, fh.Filename, fh.Size, nil
+	}
+
+	if c.Request().Body == nil || c.Request().ContentLength == 0 {
+		return nil, "", 0, errors.New("empty request body")
+	}
+
+	return c.Request().Body, "", c.Request().ContentLength, nil
+}
+
+// importFormatFromName returns the import format based on the
+// uploaded file extension or on the request content type.
+func importFormatFromName(name string, contentType string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return importFormatCSV
+	case ".ndjson", ".jsonl":
+		return importFormatNDJSON
+	case ".json":
+		return importFormatJSON
+	}
+
+	if name != "" {
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return importFormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return importFormatNDJSON
+	case echo.MIMEApplicationJSON:
+		return importFormatJSON
+	}
+
+	return ""
+}
+
+// importJobOwner returns the identifier of the import requester.
+func importJobOwner(requestInfo *models.RequestInfo) string {
+	switch {
+	case requestInfo.Admin != nil:
+		return "admin:" + requestInfo.Admin.Id
+	case requestInfo.AuthRecord != nil:
+		return "record:" + requestInfo.AuthRecord.Id
+	default:
+		return ""
+	}
+}
+
+// detachedImportContext returns a new request context with the auth
+// state of c that could be used after the request completion.
+func detachedImportContext(c echo.Context) echo.Context {
+	requestInfo := RequestInfo(c)
+
+	bc := c.Echo().NewContext(c.Request().Clone(context.Background()), &importResponseWriter{header: http.Header{}})
+	bc.SetPathParams(c.PathParams())
+
+	for _, key := range []string{ContextAdminKey, ContextAuthRecordKey, ContextCollectionKey} {
+		if v := c.Get(key); v != nil {
+			bc.Set(key, v)
+		}
+	}
+	bc.Set(ContextRequestInfoKey, requestInfo)
+
+	return bc
+}
+
+// importResponseWriter is a no-op [http.ResponseWriter] used
+// by the background import request context.
+type importResponseWriter struct {
+	header http.Header
+}
+
+func (w *importResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *importResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *importResponseWriter) WriteHeader(statusCode int) {}
+
+// importHasField checks whether the collection records have the specified field.
+func importHasField(collection *models.Collection, name string) bool {
+	if collection.Schema.GetFieldByName(name) != nil {
+		return true
+	}
+
+	if list.ExistInSlice(name, schema.BaseModelFieldNames()) {
+		return true
+	}
+
+	return collection.IsAuth() && (name == schema.FieldNameUsername || name == schema.FieldNameEmail)
+}
+
+// importIsMultiple checks whether the field could have multiple values.
+func importIsMultiple(field *schema.SchemaField) bool {
+	field.InitOptions()
+
+	switch options := field.Options.(type) {
+	case *schema.SelectOptions:
+		return options.IsMultiple()
+	case *schema.RelationOptions:
+		return options.IsMultiple()
+	default:
+		return false
+	}
+}
+
+// importSplitValues returns the trimmed non-empty comma separated values.
+func importSplitValues(str string) []string {
+	result := []string{}
+
+	for _, v := range strings.Split(str, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// -------------------------------------------------------------------
+
+// importRowDataError is a non-fatal single row read error
+// (the rest of the rows could be still read).
+type importRowDataError struct {
+	err error
+}
+
+func (e *importRowDataError) Error() string {
+	return e.err.Error()
+}
+
+// importReader reads the import data rows one by one.
+type importReader interface {
+	// Next returns the next row data, io.EOF if there are no more rows
+	// or *importRowDataError if only the current row is invalid.
+	Next() (map[string]any, error)
+}
+
+func newImportReader(format string, r io.Reader) (importReader, error) {
+	switch format {
+	case importFormatCSV:
+		return newCsvImportReader(r)
+	case importFormatNDJSON:
+		return &ndjsonImportReader{r: bufio.NewReader(r)}, nil
+	case importFormatJSON:
+		return newJsonImportReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+// csvImportReader reads the csv rows with the first row as header.
+//
+// The empty cells are omitted.
+type csvImportReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCsvImportReader(r io.Reader) (*csvImportReader, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the csv header: %w", err)
+	}
+
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // utf-8 BOM
+		}
+		header[i] = strings.TrimSpace(name)
+	}
+
+	return &csvImportReader{r: cr, header: header}, nil
+}
+
+func (cr *csvImportReader) Next() (map[string]any, error) {
+	record, err := cr.r.Read()
+	if err != nil {
+		if errors.Is(err, csv.ErrFieldCount) {
+			return nil, &importRowDataError{err}
+		}
+		return nil, err
+	}
+
+	row := make(map[string]any, len(record))
+	for i, value := range record {
+		if value != "" && cr.header[i] != "" {
+			row[cr.header[i]] = value
+		}
+	}
+
+	return row, nil
+}
+
+// ndjsonImportReader reads a JSON object per line (the blank lines are skipped).
+type ndjsonImportReader struct {
+	r *bufio.Reader
+}
+
+func (nr *ndjsonImportReader) Next() (map[string]any, error) {
+	for {
+		line, err := nr.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		row := map[string]any{}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return nil, &importRowDataError{err}
+		}
+
+		return row, nil
+	}
+}
+
+// jsonImportReader reads the objects of a JSON array one by one.
+type jsonImportReader struct {
+	decoder *json.Decoder
+}
+
+func newJsonImportReader(r io.Reader) (*jsonImportReader, error) {
+	decoder := json.NewDecoder(r)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("the json import data must be an array of objects")
+	}
+
+	return &jsonImportReader{decoder: decoder}, nil
+}
+
+func (jr *jsonImportReader) Next() (map[string]any, error) {
+	if !jr.decoder.More() {
+		return nil, io.EOF
+	}
+
+	row := map[string]any{}
+	if err := jr.decoder.Decode(&row); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &importRowDataError{err}
+		}
+		return nil, err
+	}
+
+	return row, nil
+}
@@ -0,0 +1,133 @@
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestImportFormatFromName(t *testing.T) {
+	scenarios := []struct {
+		name        string
+		fileName    string
+		contentType string
+		expected    string
+	}{
+		{"csv extension", "data.csv", "", importFormatCSV},
+		{"uppercase extension", "DATA.CSV", "application/json", importFormatCSV},
+		{"ndjson extension", "data.ndjson", "", importFormatNDJSON},
+		{"jsonl extension", "data.jsonl", "", importFormatNDJSON},
+		{"json extension", "data.json", "text/csv", importFormatJSON},
+		{"unknown extension", "data.txt", "text/csv", ""},
+		{"no extension", "data", "text/csv", ""},
+		{"csv content type", "", "text/csv; charset=utf-8", importFormatCSV},
+		{"ndjson content type", "", "application/x-ndjson", importFormatNDJSON},
+		{"jsonl content type", "", "application/jsonl", importFormatNDJSON},
+		{"json content type", "", "application/json", importFormatJSON},
+		{"unknown content type", "", "text/plain", ""},
+		{"missing name and content type", "", "", ""},
+	}
+
+	for _, s := range scenarios {
+		result := importFormatFromName(s.fileName, s.contentType)
+		if result != s.expected {
+			t.Errorf("[%s] Expected %q, got %q", s.name, s.expected, result)
+		}
+	}
+}
+
+func TestImportReaders(t *testing.T) {
+	scenarios := []struct {
+		name        string
+		format      string
+		data        string
+		expectError bool
+		// the JSON encoded rows, "invalid" for a row error
+		expected []string
+	}{
+		{"unknown format", "xml", "<a></a>", true, nil},
+		{"csv without header", importFormatCSV, "", true, nil},
+		{
+			"csv",
+			importFormatCSV,
+			"\ufefftitle, count \na,1\n,2\n",
+			false,
+			[]string{`{"count":"1","title":"a"}`, `{"count":"2"}`},
+		},
+		{
+			"csv quoted separators",
+			importFormatCSV,
+			"title,tags\n\"a, b\",\"x,y\"\n\"c \"\"quoted\"\"\",\"line\nbreak\"\n",
+			false,
+			[]string{`{"tags":"x,y","title":"a, b"}`, `{"tags":"line\nbreak","title":"c \"quoted\""}`},
+		},
+		{
+			"csv invalid field count",
+			importFormatCSV,
+			"title,count\na\nb,2\n",
+			false,
+			[]string{`invalid`, `{"count":"2","title":"b"}`},
+		},
+		{
+			"ndjson",
+			importFormatNDJSON,
+			"{\"title\":\"a\"}\n\n  \n{\"title\":\"b, c\"}",
+			false,
+			[]string{`{"title":"a"}`, `{"title":"b, c"}`},
+		},
+		{
+			"ndjson invalid line",
+			importFormatNDJSON,
+			"{\"title\":\"a\"}\n[1]\n{\"title\":\"b\"}\n",
+			false,
+			[]string{`{"title":"a"}`, `invalid`, `{"title":"b"}`},
+		},
+		{"json not array", importFormatJSON, `{"title":"a"}`, true, nil},
+		{
+			"json",
+			importFormatJSON,
+			`[{"title":"a"}, 1, {"title":"b"}]`,
+			false,
+			[]string{`{"title":"a"}`, `invalid`, `{"title":"b"}`},
+		},
+	}
+
+	for _, s := range scenarios {
+		reader, err := newImportReader(s.format, strings.NewReader(s.data))
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.name, s.expectError, hasErr, err)
+			continue
+		}
+		if hasErr {
+			continue
+		}
+
+		rows := []string{}
+		for {
+			row, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			var rowErr *importRowDataError
+			if errors.As(err, &rowErr) {
+				rows = append(rows, "invalid")
+				continue
+			}
+			if err != nil {
+				t.Fatalf("[%s] Unexpected read error: %v", s.name, err)
+			}
+
+			encoded, _ := json.Marshal(row)
+			rows = append(rows, string(encoded))
+		}
+
+		if strings.Join(rows, "\n") != strings.Join(s.expected, "\n") {
+			t.Errorf("[%s] Expected rows \n%v, \ngot \n%v", s.name, s.expected, rows)
+		}
+	}
+}
@@ -69,6 +69,8 @@ func Serve(app core.App, config ServeConfig) (*http.Server, error) {
 		Skipper:      middleware.DefaultSkipper,
 		AllowOrigins: config.AllowedOrigins,
 		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		// allow the js clients to send conditional requests
+		ExposeHeaders: []string{"ETag", "Last-Modified"},
 	}))
 
 	// start http server
@@ -125,7 +127,7 @@ func Serve(app core.App, config ServeConfig) (*http.Server, error) {
 
 		regular := color.New()
 		regular.Printf("├─ REST API: %s\n", color.CyanString("%s://%s/api/", schema, server.Addr))
-		regular.Printf("├─ MCP SSE:  %s\n", color.CyanString("%s://%s/api/mcp/sse", schema, server.Addr))
+		regular.Printf("├─ MCP:      %s\n", color.CyanString("%s://%s/api/mcp/stream", schema, server.Addr))
 		regular.Printf("└─ Admin UI: %s\n", color.CyanString("%s://%s/_/", schema, server.Addr))
 	}
 
@@ -12,6 +12,7 @@ import (
 func NewMCPCommand(app core.App, version string) *cobra.Command {
 	var mcpToken string
 	var noAuth bool
+	var remote string
 
 	command := &cobra.Command{
 		Use:   "mcp",
@@ -30,8 +31,28 @@ Example Claude Desktop configuration:
       "args": ["mcp", "--dataDsn", "sqlite:///path/to/dev.db", "--mcp-no-auth"]
     }
   }
-}`,
+}
+
+With --remote the command doesn't open the database and instead forwards
+all MCP messages to the Streamable HTTP endpoint of a deployed instance:
+
+  pb mcp --remote https://api.example.com --mcp-token mcp_...`,
 		Run: func(command *cobra.Command, args []string) {
+			if remote != "" {
+				if noAuth {
+					log.Fatalf("--mcp-no-auth can't be used with --remote")
+				}
+
+				proxy, err := mcp.NewProxyTransport(remote, mcpToken)
+				if err != nil {
+					log.Fatalf("MCP proxy error: %v", err)
+				}
+				if err := proxy.Run(); err != nil {
+					log.Fatalf("MCP proxy error: %v", err)
+				}
+				return
+			}
+
 			token := mcpToken
 			if noAuth {
 				token = ""
@@ -51,6 +72,13 @@ Example Claude Desktop configuration:
 		"Admin or auth record token for MCP authentication",
 	)
 
+	command.PersistentFlags().StringVar(
+		&remote,
+		"remote",
+		"",
+		"Base url of a remote PostgreBase instance to proxy the MCP calls to (eg. https://api.example.com)",
+	)
+
 	command.PersistentFlags().BoolVar(
 		&noAuth,
 		"mcp-no-auth",
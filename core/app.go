@@ -5,6 +5,7 @@ package core
 
 import (
 	"context"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/zhenruyan/postgrebase/daos"
@@ -73,6 +74,18 @@ type App interface {
 	// RedisCache returns the app Redis client instance.
 	RedisCache() *redis.Client
 
+	// AddRecordCacheDependencies registers the collections, besides the
+	// cache key collection, whose records changes should invalidate
+	// the cache entry (eg. the expanded relations and the view sources).
+	//
+	// ttl is the cache entry ttl (zero for no expiration), after which
+	// the dependencies are no longer tracked.
+	AddRecordCacheDependencies(key string, ttl time.Duration, collectionIds ...string) error
+
+	// IsPgNotifyEnabled reports whether the realtime events and the cache
+	// invalidations are distributed with the PostgreSQL LISTEN/NOTIFY.
+	IsPgNotifyEnabled() bool
+
 	// VectorManager returns the embedded vector runtime manager.
 	VectorManager() *vector.Manager
 
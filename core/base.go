@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	"github.com/zhenruyan/postgrebase/daos"
 	"github.com/zhenruyan/postgrebase/dbx"
@@ -49,6 +50,7 @@ type BaseApp struct {
 	dataDir          string
 	dataDsn          string
 	redisDsn         string
+	pgNotify         bool
 	encryptionEnv    string
 	disableVector    bool
 	clusterPeers     []string
@@ -63,6 +65,8 @@ type BaseApp struct {
 	cache               *store.Store[any]
 	redisCache          *redis.Client
 	redisContext        context.Context
+	pgListener          *pq.Listener
+	cacheDeps           recordCacheDeps
 	settings            *settings.Settings
 	dao                 *daos.Dao
 	logsDao             *daos.Dao
@@ -192,6 +196,7 @@ type BaseAppConfig struct {
 	LogsMaxIdleConns int // default to 5
 	DataDsn          string
 	RedisDsn         string
+	PgNotify         bool
 	DisableVector    bool
 	ClusterPeers     []string
 	NodeAddr         string
@@ -207,6 +212,7 @@ func NewBaseApp(config BaseAppConfig) *BaseApp {
 		dataDir:             config.DataDir,
 		dataDsn:             config.DataDsn,
 		redisDsn:            config.RedisDsn,
+		pgNotify:            config.PgNotify,
 		isDebug:             config.IsDebug,
 		encryptionEnv:       config.EncryptionEnv,
 		disableVector:       config.DisableVector,
@@ -373,6 +379,10 @@ func (app *BaseApp) Bootstrap() error {
 		return err
 	}
 
+	if err := app.initPgNotify(); err != nil {
+		return err
+	}
+
 	if !app.disableVector {
 		if err := app.initVector(); err != nil {
 			return err
@@ -397,6 +407,11 @@ func (app *BaseApp) Bootstrap() error {
 // ResetBootstrapState takes care for releasing initialized app resources
 // (eg. closing db connections).
 func (app *BaseApp) ResetBootstrapState() error {
+	if app.pgListener != nil {
+		app.pgListener.Close()
+		app.pgListener = nil
+	}
+
 	if app.Dao() != nil {
 		if err := app.Dao().ConcurrentDB().(*dbx.DB).Close(); err != nil {
 			return err
@@ -1282,6 +1297,18 @@ func (app *BaseApp) Publish(channel string, data any) error {
 		return app.redisCache.Publish(app.redisContext, channel, payload).Err()
 	}
 
+	if app.pgListener != nil && channel == "realtime" {
+		if len(payload) > PgNotifyMaxPayloadSize {
+			return ErrPgNotifyPayloadTooLarge
+		}
+
+		_, err := app.Dao().DB().NewQuery("SELECT pg_notify({:channel}, {:payload})").Bind(map[string]any{
+			"channel": PgNotifyRealtimeChannel,
+			"payload": string(payload),
+		}).Execute()
+		return err
+	}
+
 	// Fallback to local broadcast if Redis is not enabled
 	event := &RealtimeBroadcastEvent{
 		App:     app,
@@ -1409,35 +1436,19 @@ func (app *BaseApp) registerDefaultHooks() {
 	app.OnModelAfterDelete().Add(func(e *ModelEvent) error {
 		return app.clearRecordCache(e.Model)
 	})
-}
-
-func (app *BaseApp) clearRecordCache(m models.Model) error {
-	// Only clear cache for Records
-	record, ok := m.(*models.Record)
-	if !ok {
-		return nil
-	}
-
-	collection := record.Collection()
-	if collection == nil {
-		return nil
-	}
 
-	if !collection.CacheEnabled && !collection.ListCacheEnabled && !collection.SearchCacheEnabled {
+	// install the PostgreSQL notify triggers of the collection tables
+	app.OnBeforeServe().Add(func(e *ServeEvent) error {
+		if err := app.syncPgNotifyTriggers(); err != nil && app.IsDebug() {
+			log.Println("Failed to sync the notify triggers:", err)
+		}
 		return nil
-	}
-
-	prefix := fmt.Sprintf("pb_cache:%s:", collection.Id)
-	app.cache.RemoveByPrefix(prefix)
+	})
 
-	if app.redisCache == nil {
+	app.OnModelAfterCreate().Add(func(e *ModelEvent) error {
+		if collection, ok := e.Model.(*models.Collection); ok && app.IsPgNotifyEnabled() {
+			app.watchNewCollectionTable(collection)
+		}
 		return nil
-	}
-
-	ctx := context.Background()
-	iter := app.redisCache.Scan(ctx, 0, prefix+"*", 0).Iterator()
-	for iter.Next(ctx) {
-		app.redisCache.Del(ctx, iter.Val())
-	}
-	return iter.Err()
+	})
 }
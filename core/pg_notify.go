@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/lib/pq"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/routine"
+)
+
+const (
+	// PgNotifyRealtimeChannel is the PostgreSQL NOTIFY channel used by
+	// [BaseApp.Publish] for the "realtime" messages when Redis is not configured.
+	PgNotifyRealtimeChannel = "pb_realtime"
+
+	// PgNotifyRecordChannel is the PostgreSQL NOTIFY channel of the
+	// collection tables triggers.
+	//
+	// Its notifications are forwarded to the OnRealtimeBroadcast hook
+	// with the same channel name and [RecordChangeNotification] payload.
+	PgNotifyRecordChannel = "pb_record_changes"
+
+	pgNotifyTriggerName = "pb_notify_record_change"
+
+	// PgNotifyMaxPayloadSize is the max size of a single NOTIFY payload
+	// (PostgreSQL requires it to be shorter than 8000 bytes).
+	PgNotifyMaxPayloadSize = 7999
+)
+
+// ErrPgNotifyPayloadTooLarge is returned by [BaseApp.Publish] when the
+// message doesn't fit in a single PostgreSQL NOTIFY payload.
+var ErrPgNotifyPayloadTooLarge = fmt.Errorf("the message exceeds the %d bytes PostgreSQL NOTIFY payload limit", PgNotifyMaxPayloadSize)
+
+// RecordChangeNotification is the payload of a single [PgNotifyRecordChannel] notification.
+type RecordChangeNotification struct {
+	// Table is the name of the changed collection table.
+	Table string `json:"table"`
+
+	// Action is one of "insert", "update" or "delete".
+	Action string `json:"action"`
+
+	// Id is the changed row id.
+	Id string `json:"id"`
+
+	// Record is the deleted row data (if it fits in the NOTIFY payload).
+	Record json.RawMessage `json:"record,omitempty"`
+}
+
+// the NOTIFY payload must be shorter than 8000 bytes, so the deleted
+// row data is sent only if it fits (otherwise only the row id is sent)
+const pgNotifyFunctionSQL = `
+CREATE OR REPLACE FUNCTION pb_notify_record_change() RETURNS trigger AS $$
+DECLARE
+	payload text;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		payload := json_build_object('table', TG_TABLE_NAME, 'action', 'delete', 'id', OLD.id, 'record', row_to_json(OLD))::text;
+		IF octet_length(payload) > 7900 THEN
+			payload := json_build_object('table', TG_TABLE_NAME, 'action', 'delete', 'id', OLD.id)::text;
+		END IF;
+	ELSE
+		payload := json_build_object('table', TG_TABLE_NAME, 'action', lower(TG_OP), 'id', NEW.id)::text;
+	END IF;
+
+	PERFORM pg_notify('pb_record_changes', payload);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+`
+
+// IsPgNotifyEnabled reports whether the realtime events and the cache
+// invalidations are distributed with the PostgreSQL LISTEN/NOTIFY.
+func (app *BaseApp) IsPgNotifyEnabled() bool {
+	return app.pgListener != nil
+}
+
+func (app *BaseApp) initPgNotify() error {
+	if !app.pgNotify {
+		return nil
+	}
+
+	if driver := app.Dao().DB().DriverName(); driver != "postgres" {
+		if app.IsDebug() {
+			color.Yellow("PostgreSQL LISTEN/NOTIFY is not supported by the %q driver and will be ignored", driver)
+		}
+		return nil
+	}
+
+	if _, err := app.Dao().DB().NewQuery(pgNotifyFunctionSQL).Execute(); err != nil {
+		return fmt.Errorf("failed to create the notify trigger function: %w", err)
+	}
+
+	listener := pq.NewListener(app.dataDsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil && app.IsDebug() {
+			log.Println("PostgreSQL listener error:", err)
+		}
+	})
+
+	for _, channel := range []string{PgNotifyRealtimeChannel, PgNotifyRecordChannel} {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	app.pgListener = listener
+
+	if app.IsDebug() {
+		color.Green("PostgreSQL LISTEN/NOTIFY enabled")
+	}
+
+	go func() {
+		for n := range listener.Notify {
+			if n == nil {
+				// the connection was reestablished and some of the
+				// notifications could have been missed
+				app.cache.RemoveByPrefix(RecordCachePrefix)
+				continue
+			}
+
+			channel := n.Channel
+			switch channel {
+			case PgNotifyRealtimeChannel:
+				channel = "realtime" // the same as the Redis channel
+			case PgNotifyRecordChannel:
+				app.clearNotifiedRecordCache([]byte(n.Extra))
+			}
+
+			event := &RealtimeBroadcastEvent{
+				App:     app,
+				Channel: channel,
+				Payload: []byte(n.Extra),
+			}
+			if err := app.OnRealtimeBroadcast().Trigger(event); err != nil && app.IsDebug() {
+				log.Println("Realtime broadcast error:", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// clearNotifiedRecordCache clears the cache of the notified record change collection.
+func (app *BaseApp) clearNotifiedRecordCache(payload []byte) {
+	n := &RecordChangeNotification{}
+	if err := json.Unmarshal(payload, n); err != nil {
+		return
+	}
+
+	collection, err := app.Dao().FindCollectionByNameOrId(n.Table)
+	if err != nil {
+		return
+	}
+
+	if err := app.clearCollectionRecordCache(collection); err != nil && app.IsDebug() {
+		log.Println(err)
+	}
+}
+
+// syncPgNotifyTriggers ensures that all collection tables have the notify trigger.
+func (app *BaseApp) syncPgNotifyTriggers() error {
+	if !app.IsPgNotifyEnabled() {
+		return nil
+	}
+
+	collections := []*models.Collection{}
+	if err := app.Dao().CollectionQuery().All(&collections); err != nil {
+		return err
+	}
+
+	for _, collection := range collections {
+		if _, err := app.ensurePgNotifyTrigger(collection); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensurePgNotifyTrigger creates the collection table notify trigger (if missing).
+//
+// Returns false if the collection table doesn't exist (yet).
+func (app *BaseApp) ensurePgNotifyTrigger(collection *models.Collection) (bool, error) {
+	if collection.IsView() {
+		return true, nil // no row triggers for views
+	}
+
+	var exists struct {
+		Table   bool `db:"hasTable"`
+		Trigger bool `db:"hasTrigger"`
+	}
+
+	err := app.Dao().DB().NewQuery(
+		`SELECT to_regclass({:table}) IS NOT NULL AS "hasTable",
+		EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = {:trigger} AND tgrelid = to_regclass({:table})) AS "hasTrigger"`,
+	).Bind(map[string]any{
+		"table":   `"` + collection.Name + `"`,
+		"trigger": pgNotifyTriggerName,
+	}).One(&exists)
+	if err != nil || !exists.Table || exists.Trigger {
+		return exists.Table, err
+	}
+
+	_, err = app.Dao().DB().NewQuery(fmt.Sprintf(
+		`CREATE TRIGGER %s AFTER INSERT OR UPDATE OR DELETE ON "%s" FOR EACH ROW EXECUTE FUNCTION pb_notify_record_change()`,
+		pgNotifyTriggerName,
+		collection.Name,
+	)).Execute()
+
+	return true, err
+}
+
+// watchNewCollectionTable installs the notify trigger of a newly created collection.
+//
+// The collection model is saved before its table is created,
+// so the table existence is checked a few times in the background.
+func (app *BaseApp) watchNewCollectionTable(collection *models.Collection) {
+	routine.FireAndForget(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			ok, err := app.ensurePgNotifyTrigger(collection)
+			if ok || err != nil {
+				if err != nil && app.IsDebug() {
+					log.Println("Failed to create the notify trigger:", err)
+				}
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+const (
+	// RecordCachePrefix is the key prefix of the cached record api responses
+	// (the full key format is "pb_cache:{collectionId}:{suffix}").
+	RecordCachePrefix = "pb_cache:"
+
+	// recordCacheDepsPrefix is the key prefix of the sorted sets with the
+	// cache keys that depend on a collection other than their own.
+	recordCacheDepsPrefix = "pb_cache_deps:"
+)
+
+// recordCacheDepsPruneInterval is the min interval between two
+// deletions of the expired in-memory cache dependencies.
+const recordCacheDepsPruneInterval = time.Minute
+
+// recordCacheDeps is the in-memory index of the cache keys that depend
+// on other collections (used when Redis is not configured).
+type recordCacheDeps struct {
+	mux      sync.Mutex
+	keys     map[string]map[string]time.Time // collectionId -> cache key -> expiration (zero for none)
+	prunedAt time.Time
+}
+
+// AddRecordCacheDependencies registers the collections, besides the
+// cache key collection, whose records changes should invalidate
+// the cache entry (eg. the expanded relations and the view sources).
+//
+// The dependencies are removed together with the invalidated
+// cache entry or after the cache entry ttl (zero ttl for no expiration).
+func (app *BaseApp) AddRecordCacheDependencies(key string, ttl time.Duration, collectionIds ...string) error {
+	if len(collectionIds) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	if app.redisCache != nil {
+		// the dependencies are stored as sorted sets scored by the
+		// cache entry expiration, so that the expired ones can be pruned
+		score := math.Inf(1)
+		if !expiresAt.IsZero() {
+			score = float64(expiresAt.UnixMilli())
+		}
+
+		ctx := context.Background()
+		pipe := app.redisCache.Pipeline()
+		for _, id := range collectionIds {
+			pipe.ZAdd(ctx, recordCacheDepsPrefix+id, redis.Z{Score: score, Member: key})
+			pipe.ZRemRangeByScore(ctx, recordCacheDepsPrefix+id, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+
+	app.cacheDeps.mux.Lock()
+	defer app.cacheDeps.mux.Unlock()
+
+	if app.cacheDeps.keys == nil {
+		app.cacheDeps.keys = map[string]map[string]time.Time{}
+	}
+
+	for _, id := range collectionIds {
+		if app.cacheDeps.keys[id] == nil {
+			app.cacheDeps.keys[id] = map[string]time.Time{}
+		}
+		app.cacheDeps.keys[id][key] = expiresAt
+	}
+
+	if now.Sub(app.cacheDeps.prunedAt) >= recordCacheDepsPruneInterval {
+		app.cacheDeps.prunedAt = now
+		app.pruneRecordCacheDeps(now)
+	}
+
+	return nil
+}
+
+// pruneRecordCacheDeps removes the expired in-memory cache dependencies
+// together with their cache entries.
+//
+// Note that the caller must hold the app.cacheDeps lock.
+func (app *BaseApp) pruneRecordCacheDeps(now time.Time) {
+	for id, keys := range app.cacheDeps.keys {
+		for key, expiresAt := range keys {
+			if !expiresAt.IsZero() && !expiresAt.After(now) {
+				delete(keys, key)
+				app.cache.Remove(key)
+			}
+		}
+
+		if len(keys) == 0 {
+			delete(app.cacheDeps.keys, id)
+		}
+	}
+}
+
+func (app *BaseApp) clearRecordCache(m models.Model) error {
+	// Only clear cache for Records
+	record, ok := m.(*models.Record)
+	if !ok {
+		return nil
+	}
+
+	collection := record.Collection()
+	if collection == nil {
+		return nil
+	}
+
+	return app.clearCollectionRecordCache(collection)
+}
+
+// clearCollectionRecordCache clears the cached responses of the
+// collection and the ones that depend on it.
+func (app *BaseApp) clearCollectionRecordCache(collection *models.Collection) error {
+	prefix := RecordCachePrefix + collection.Id + ":"
+	hasOwnCache := collection.CacheEnabled || collection.ListCacheEnabled || collection.SearchCacheEnabled
+
+	if hasOwnCache {
+		app.cache.RemoveByPrefix(prefix)
+	}
+
+	app.cacheDeps.mux.Lock()
+	dependents := app.cacheDeps.keys[collection.Id]
+	delete(app.cacheDeps.keys, collection.Id)
+	app.cacheDeps.mux.Unlock()
+
+	for key := range dependents {
+		app.cache.Remove(key)
+	}
+
+	if app.redisCache == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	depsKey := recordCacheDepsPrefix + collection.Id
+	redisDependents, err := app.redisCache.ZRange(ctx, depsKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	if len(redisDependents) > 0 {
+		if err := app.redisCache.Del(ctx, append(redisDependents, depsKey)...).Err(); err != nil {
+			return err
+		}
+	}
+
+	if !hasOwnCache {
+		return nil
+	}
+
+	iter := app.redisCache.Scan(ctx, 0, prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		app.redisCache.Del(ctx, iter.Val())
+	}
+	return iter.Err()
+}
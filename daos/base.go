@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/zhenruyan/postgrebase/dbx"
@@ -119,6 +120,25 @@ func (dao *Dao) FindById(m models.Model, id string) error {
 	return dao.ModelQuery(m).Where(dbx.HashExp{"id": id}).Limit(1).One(m)
 }
 
+// AfterTransactionError is returned by [Dao.RunInTransaction] when the
+// transaction was committed successfully but some of the model "after"
+// hooks calls failed (aka. the changes were not reverted).
+type AfterTransactionError struct {
+	Errors []error
+}
+
+// Error implements the [error] interface.
+func (e *AfterTransactionError) Error() string {
+	// @todo after go 1.20+ upgrade consider replacing with errors.Join()
+	var errsMsg strings.Builder
+	for _, err := range e.Errors {
+		errsMsg.WriteString(err.Error())
+		errsMsg.WriteString("; ")
+	}
+
+	return fmt.Sprintf("after transaction errors: %s", errsMsg.String())
+}
+
 type afterCallGroup struct {
 	Action   string
 	EventDao *Dao
@@ -212,13 +232,7 @@ func (dao *Dao) RunInTransaction(fn func(txDao *Dao) error) error {
 			}
 		}
 		if len(errs) > 0 {
-			// @todo after go 1.20+ upgrade consider replacing with errors.Join()
-			var errsMsg strings.Builder
-			for _, err := range errs {
-				errsMsg.WriteString(err.Error())
-				errsMsg.WriteString("; ")
-			}
-			return fmt.Errorf("after transaction errors: %s", errsMsg.String())
+			return &AfterTransactionError{Errors: errs}
 		}
 
 		return nil
@@ -227,6 +241,90 @@ func (dao *Dao) RunInTransaction(fn func(txDao *Dao) error) error {
 	return errors.New("failed to start transaction (unknown dao.NonconcurrentDB() instance)")
 }
 
+// savepointCounter is used to generate unique nested savepoint names.
+var savepointCounter uint64
+
+// RunInSavepoint executes fn within a savepoint of the current dao transaction.
+//
+// If fn returns an error, only the changes made by fn are reverted and the
+// transaction could continue. The "after" model hooks calls of the reverted
+// changes are discarded, the others are forwarded to the current dao
+// (aka. they are still executed after the transaction commit).
+//
+// Returns an error if the dao is not in a transaction.
+func (dao *Dao) RunInSavepoint(fn func(spDao *Dao) error) error {
+	tx, ok := dao.NonconcurrentDB().(*dbx.Tx)
+	if !ok {
+		return errors.New("savepoints are available only within a transaction")
+	}
+
+	name := fmt.Sprintf("pb_sp_%d", atomic.AddUint64(&savepointCounter, 1))
+
+	afterCalls := []afterCallGroup{}
+
+	spDao := New(tx)
+	spDao.MaxLockRetries = dao.MaxLockRetries
+	spDao.ModelQueryTimeout = dao.ModelQueryTimeout
+	spDao.BeforeCreateFunc = dao.BeforeCreateFunc
+	spDao.BeforeUpdateFunc = dao.BeforeUpdateFunc
+	spDao.BeforeDeleteFunc = dao.BeforeDeleteFunc
+	if dao.AfterCreateFunc != nil {
+		spDao.AfterCreateFunc = func(eventDao *Dao, m models.Model) error {
+			afterCalls = append(afterCalls, afterCallGroup{"create", eventDao, m})
+			return nil
+		}
+	}
+	if dao.AfterUpdateFunc != nil {
+		spDao.AfterUpdateFunc = func(eventDao *Dao, m models.Model) error {
+			afterCalls = append(afterCalls, afterCallGroup{"update", eventDao, m})
+			return nil
+		}
+	}
+	if dao.AfterDeleteFunc != nil {
+		spDao.AfterDeleteFunc = func(eventDao *Dao, m models.Model) error {
+			afterCalls = append(afterCalls, afterCallGroup{"delete", eventDao, m})
+			return nil
+		}
+	}
+
+	if _, err := tx.NewQuery("SAVEPOINT " + name).Execute(); err != nil {
+		return err
+	}
+
+	if fnErr := fn(spDao); fnErr != nil {
+		if _, err := tx.NewQuery("ROLLBACK TO SAVEPOINT " + name).Execute(); err != nil {
+			return err
+		}
+		if _, err := tx.NewQuery("RELEASE SAVEPOINT " + name).Execute(); err != nil {
+			return err
+		}
+
+		return fnErr
+	}
+
+	if _, err := tx.NewQuery("RELEASE SAVEPOINT " + name).Execute(); err != nil {
+		return err
+	}
+
+	for _, call := range afterCalls {
+		var err error
+		switch call.Action {
+		case "create":
+			err = dao.AfterCreateFunc(call.EventDao, call.Model)
+		case "update":
+			err = dao.AfterUpdateFunc(call.EventDao, call.Model)
+		case "delete":
+			err = dao.AfterDeleteFunc(call.EventDao, call.Model)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Delete deletes the provided model.
 func (dao *Dao) Delete(m models.Model) error {
 	if !m.HasId() {
@@ -0,0 +1,45 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+// McpAuditQuery returns a new MCP audit log select query.
+func (dao *Dao) McpAuditQuery() *dbx.SelectQuery {
+	return dao.ModelQuery(&models.McpAuditLog{})
+}
+
+// FindMcpAuditLogById finds a single MCP audit log entry by its id.
+func (dao *Dao) FindMcpAuditLogById(id string) (*models.McpAuditLog, error) {
+	model := &models.McpAuditLog{}
+
+	err := dao.McpAuditQuery().
+		AndWhere(dbx.HashExp{"id": id}).
+		Limit(1).
+		One(model)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// SaveMcpAuditLog persists the provided MCP audit log entry.
+func (dao *Dao) SaveMcpAuditLog(log *models.McpAuditLog) error {
+	return dao.Save(log)
+}
+
+// DeleteOldMcpAuditLogs deletes all MCP audit log entries created before createdBefore.
+func (dao *Dao) DeleteOldMcpAuditLogs(createdBefore time.Time) error {
+	formattedDate := createdBefore.UTC().Format(types.DefaultDateLayout)
+	expr := dbx.NewExp("[[created]] <= {:date}", dbx.Params{"date": formattedDate})
+
+	_, err := dao.NonconcurrentDB().Delete((&models.McpAuditLog{}).TableName(), expr).Execute()
+
+	return err
+}
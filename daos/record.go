@@ -13,6 +13,7 @@ import (
 	"github.com/zhenruyan/postgrebase/resolvers"
 	"github.com/zhenruyan/postgrebase/tools/inflector"
 	"github.com/zhenruyan/postgrebase/tools/list"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/search"
 	"github.com/zhenruyan/postgrebase/tools/security"
 	"github.com/zhenruyan/postgrebase/tools/types"
@@ -129,6 +130,71 @@ func (dao *Dao) RecordQuery(collectionModelOrIdentifier any) *dbx.SelectQuery {
 	})
 }
 
+// SelectRecordFields narrows the columns selected by the provided collection
+// records query to the ones required by the fields projection, the
+// relation expands and the sort, so that the unused (and usually large)
+// json, editor, vector, etc. columns are not read.
+//
+// The sort columns are required because a DISTINCT query (eg. with a
+// relation filter) could be ordered only by its selected columns in PostgreSQL.
+//
+// The base model and auth system columns are always selected.
+// The not selected record fields are loaded with their zero value.
+//
+// The query is returned unchanged if the projection is empty,
+// includes all top level fields ("*") or the collection is a view.
+func (dao *Dao) SelectRecordFields(
+	query *dbx.SelectQuery,
+	collection *models.Collection,
+	fields []string,
+	expands []string,
+	sort []search.SortField,
+) *dbx.SelectQuery {
+	if len(fields) == 0 || collection.IsView() {
+		return query
+	}
+
+	names := schema.BaseModelFieldNames()
+	if collection.IsAuth() {
+		names = append(names, schema.AuthFieldNames()...)
+	}
+
+	for _, field := range fields {
+		name, _, _ := strings.Cut(field, ".")
+		if name == picker.WildcardField {
+			return query
+		}
+		if collection.Schema.GetFieldByName(name) != nil {
+			names = append(names, name)
+		}
+	}
+
+	// the relation field values are required to expand them
+	for _, expand := range expands {
+		name, _, _ := strings.Cut(strings.TrimSpace(expand), ".")
+		if collection.Schema.GetFieldByName(name) != nil {
+			names = append(names, name)
+		}
+	}
+
+	// the own sort columns (incl. the cursor keys)
+	for _, sortField := range sort {
+		name, _, _ := strings.Cut(sortField.Name, ".")
+		if collection.Schema.GetFieldByName(name) != nil {
+			names = append(names, name)
+		}
+	}
+
+	tableName := dao.DB().QuoteSimpleColumnName(collection.Name)
+
+	columns := make([]string, 0, len(names))
+	for _, name := range list.ToUniqueStringSlice(names) {
+		columns = append(columns, tableName+"."+dao.DB().QuoteSimpleColumnName(name))
+	}
+
+	return query.Select(columns...)
+}
+
 // FindRecordById finds the Record model by its id.
 func (dao *Dao) FindRecordById(
 	collectionNameOrId string,
@@ -570,29 +636,64 @@ func (dao *Dao) CanAccessRecord(record *models.Record, requestInfo *models.Reque
 // If record.IsNew() is true, the method will perform a create, otherwise an update.
 // To explicitly mark a record for update you can use record.MarkAsNotNew().
 func (dao *Dao) SaveRecord(record *models.Record) error {
-	if record.Collection().IsAuth() {
-		if record.Username() == "" {
-			return errors.New("unable to save auth record without username")
-		}
+	if err := dao.checkAuthRecordSave(record); err != nil {
+		return err
+	}
+
+	return dao.Save(record)
+}
+
+// checkAuthRecordSave performs the auth record specific checks before persisting.
+//
+// It is no-op for non-auth records.
+func (dao *Dao) checkAuthRecordSave(record *models.Record) error {
+	if !record.Collection().IsAuth() {
+		return nil
+	}
 
-		// Cross-check that the auth record id is unique for all auth collections.
-		// This is to make sure that the filter `@request.auth.id` always returns a unique id.
-		authCollections, err := dao.FindCollectionsByType(models.CollectionTypeAuth)
-		if err != nil {
-			return fmt.Errorf("unable to fetch the auth collections for cross-id unique check: %w", err)
+	if record.Username() == "" {
+		return errors.New("unable to save auth record without username")
+	}
+
+	// Cross-check that the auth record id is unique for all auth collections.
+	// This is to make sure that the filter `@request.auth.id` always returns a unique id.
+	authCollections, err := dao.FindCollectionsByType(models.CollectionTypeAuth)
+	if err != nil {
+		return fmt.Errorf("unable to fetch the auth collections for cross-id unique check: %w", err)
+	}
+	for _, collection := range authCollections {
+		if record.Collection().Id == collection.Id {
+			continue // skip current collection (sqlite will do the check for us)
 		}
-		for _, collection := range authCollections {
-			if record.Collection().Id == collection.Id {
-				continue // skip current collection (sqlite will do the check for us)
-			}
-			isUnique := dao.IsRecordValueUnique(collection.Id, schema.FieldNameId, record.Id)
-			if !isUnique {
-				return errors.New("the auth record ID must be unique across all auth collections")
-			}
+		isUnique := dao.IsRecordValueUnique(collection.Id, schema.FieldNameId, record.Id)
+		if !isUnique {
+			return errors.New("the auth record ID must be unique across all auth collections")
 		}
 	}
 
-	return dao.Save(record)
+	return nil
+}
+
+// LockRecordRow locks the persisted record row until the end of the
+// current transaction (eg. to check the current record state before an update).
+//
+// SQLite doesn't support row locks (the write transactions are serialized),
+// so this method is no-op for it.
+func (dao *Dao) LockRecordRow(record *models.Record) error {
+	switch dao.NonconcurrentDB().DriverName() {
+	case "postgres", "mysql":
+	default:
+		return nil
+	}
+
+	q := dao.NonconcurrentDB().Select("id").
+		From(record.Collection().Name).
+		AndWhere(dbx.HashExp{"id": record.Id}).
+		Build()
+
+	_, err := dao.NonconcurrentDB().NewQuery(q.SQL() + " FOR UPDATE").Bind(q.Params()).Execute()
+
+	return err
 }
 
 // DeleteRecord deletes the provided Record model.
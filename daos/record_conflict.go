@@ -0,0 +1,219 @@
+package daos
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/dbutils"
+	"github.com/zhenruyan/postgrebase/tools/inflector"
+	"github.com/zhenruyan/postgrebase/tools/list"
+)
+
+// ErrRecordExists is returned by [Dao.CreateRecordOnConflict] when
+// a record with the same conflict field values already exists.
+var ErrRecordExists = errors.New("a record with the same unique field values already exists")
+
+// ValidateConflictFields checks whether the provided fields could be used
+// as record upsert conflict target, aka. whether they are exactly the
+// columns of a unique non-partial index of the collection (or just "id").
+func ValidateConflictFields(collection *models.Collection, fields []string) error {
+	fields = list.ToUniqueStringSlice(fields)
+
+	if len(fields) == 0 {
+		return errors.New("missing conflict fields")
+	}
+
+	if len(fields) == 1 && fields[0] == schema.FieldNameId {
+		return nil
+	}
+
+	for _, idx := range collection.Indexes {
+		parsed := dbutils.ParseIndex(idx)
+		if !parsed.Unique || parsed.Where != "" || len(parsed.Columns) != len(fields) {
+			continue
+		}
+
+		matched := 0
+		for _, col := range parsed.Columns {
+			if col.Collate != "" {
+				break
+			}
+			for _, field := range fields {
+				if strings.EqualFold(col.Name, field) {
+					matched++
+					break
+				}
+			}
+		}
+
+		if matched == len(fields) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q collection doesn't have a unique index on %s", collection.Name, strings.Join(fields, ","))
+}
+
+// FindRecordByConflictFields returns the collection record whose conflict
+// fields values match the ones from the provided data.
+//
+// The data values are normalized with the related schema field before the lookup.
+//
+// Returns sql.ErrNoRows if there is no matching record.
+func (dao *Dao) FindRecordByConflictFields(
+	collection *models.Collection,
+	fields []string,
+	data map[string]any,
+) (*models.Record, error) {
+	if err := ValidateConflictFields(collection, fields); err != nil {
+		return nil, err
+	}
+
+	exp := dbx.HashExp{}
+
+	for _, name := range list.ToUniqueStringSlice(fields) {
+		value, ok := data[name]
+		if !ok {
+			return nil, fmt.Errorf("missing %q conflict field value", name)
+		}
+
+		if field := collection.Schema.GetFieldByName(name); field != nil {
+			value = field.PrepareValue(value)
+		}
+
+		exp[inflector.Columnify(name)] = value
+	}
+
+	record := &models.Record{}
+
+	err := dao.RecordQuery(collection).
+		AndWhere(exp).
+		Limit(1).
+		One(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// CreateRecordOnConflict persists the provided new Record model in the
+// database, with the specified unique fields as conflict target.
+//
+// If a record with the same conflict field values already exists,
+// the existing row is left unchanged (but locked until the end of the
+// current transaction for the engines that support row locks) and
+// ErrRecordExists is returned.
+func (dao *Dao) CreateRecordOnConflict(record *models.Record, fields ...string) error {
+	if !record.IsNew() {
+		return errors.New("the record is expected to be new")
+	}
+
+	if err := ValidateConflictFields(record.Collection(), fields); err != nil {
+		return err
+	}
+
+	if err := dao.checkAuthRecordSave(record); err != nil {
+		return err
+	}
+
+	return dao.lockRetry(func(retryDao *Dao) error {
+		return retryDao.createOnConflict(record, fields)
+	})
+}
+
+func (dao *Dao) createOnConflict(record *models.Record, fields []string) error {
+	if !record.HasId() {
+		record.RefreshId()
+	}
+
+	record.MarkAsNew()
+
+	if record.GetCreated().IsZero() {
+		record.RefreshCreated()
+	}
+
+	if record.GetUpdated().IsZero() {
+		record.RefreshUpdated()
+	}
+
+	action := func() error {
+		dataMap := record.ColumnValueMap()
+		if _, ok := dataMap["id"]; !ok {
+			dataMap["id"] = record.GetId()
+		}
+
+		columns := make([]string, 0, len(fields))
+		for _, field := range list.ToUniqueStringSlice(fields) {
+			columns = append(columns, inflector.Columnify(field))
+		}
+
+		db := dao.NonconcurrentDB()
+
+		var q *dbx.Query
+		switch db.DriverName() {
+		case "mysql":
+			// MySQL doesn't support conditional updates of the conflicting row,
+			// so the duplicate key branch is a no-op assignment
+			insert := db.Insert(record.TableName(), dataMap)
+			q = db.NewQuery(insert.SQL() + " ON DUPLICATE KEY UPDATE " + db.QuoteSimpleColumnName("id") + "=" + db.QuoteSimpleColumnName("id")).
+				Bind(insert.Params())
+		default:
+			if err := refreshSqliteSchema(db, record.TableName()); err != nil {
+				return err
+			}
+
+			// the false condition leaves the conflicting row unchanged
+			upsert := db.Upsert(record.TableName(), dataMap, columns...)
+			q = db.NewQuery(upsert.SQL() + " WHERE false").Bind(upsert.Params())
+		}
+
+		result, err := q.Execute()
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrRecordExists
+		}
+
+		// clears the "new" model flag
+		record.MarkAsNotNew()
+
+		if dao.AfterCreateFunc != nil {
+			return dao.AfterCreateFunc(dao, record)
+		}
+
+		return nil
+	}
+
+	if dao.BeforeCreateFunc != nil {
+		return dao.BeforeCreateFunc(dao, record, action)
+	}
+
+	return action()
+}
+
+// refreshSqliteSchema makes sure that the SQLite connection schema
+// cache includes the indexes created from another connection.
+//
+// SQLite resolves the upsert conflict target with the cached schema while
+// preparing the statement (without the usual stale schema retry), while
+// the execution of any statement reloads the schema if it has changed.
+//
+// It is no-op for the other drivers.
+func refreshSqliteSchema(db dbx.Builder, tableName string) error {
+	switch db.DriverName() {
+	case "sqlite", "sqlite3":
+	default:
+		return nil
+	}
+
+	_, err := db.NewQuery("SELECT 1 FROM " + db.QuoteSimpleTableName(tableName) + " LIMIT 0").Execute()
+
+	return err
+}
@@ -244,6 +244,47 @@ func (dao *Dao) expandRecords(records []*models.Record, expandPath string, fetch
 	return nil
 }
 
+// FindExpandCollections returns the collections whose records could
+// be part of the collection records expand (the unresolvable paths are ignored).
+func (dao *Dao) FindExpandCollections(collection *models.Collection, expands []string) []*models.Collection {
+	result := []*models.Collection{}
+	found := map[string]bool{}
+
+	for _, expand := range normalizeExpands(expands) {
+		current := collection
+
+		for i, part := range strings.Split(expand, ".") {
+			if i >= MaxExpandDepth {
+				break
+			}
+
+			var relCollection *models.Collection
+
+			if matches := indirectExpandRegex.FindStringSubmatch(part); len(matches) == 3 {
+				relCollection, _ = dao.FindCollectionByNameOrId(matches[1])
+			} else if field := current.Schema.GetFieldByName(part); field != nil && field.Type == schema.FieldTypeRelation {
+				field.InitOptions()
+				if options, _ := field.Options.(*schema.RelationOptions); options != nil {
+					relCollection, _ = dao.FindCollectionByNameOrId(options.CollectionId)
+				}
+			}
+
+			if relCollection == nil {
+				break
+			}
+
+			if !found[relCollection.Id] {
+				found[relCollection.Id] = true
+				result = append(result, relCollection)
+			}
+
+			current = relCollection
+		}
+	}
+
+	return result
+}
+
 // normalizeExpands normalizes expand strings and merges self containing paths
 // (eg. ["a.b.c", "a.b", "   test  ", "  ", "test"] -> ["a.b.c", "test"]).
 func normalizeExpands(paths []string) []string {
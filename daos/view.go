@@ -404,6 +404,45 @@ func (dao *Dao) parseQueryToFields(selectQuery string) (map[string]*queryField,
 	return result, nil
 }
 
+// FindViewSourceCollections returns the collections used in the view
+// collection query, including the sources of the nested view collections.
+func (dao *Dao) FindViewSourceCollections(view *models.Collection) ([]*models.Collection, error) {
+	result := []*models.Collection{}
+	found := map[string]bool{view.Id: true}
+
+	queue := []*models.Collection{view}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		p := new(identifiersParser)
+		if err := p.parse(current.ViewOptions().Query); err != nil {
+			return nil, err
+		}
+
+		collections, err := dao.findCollectionsByIdentifiers(p.tables)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, collection := range collections {
+			if found[collection.Id] {
+				continue
+			}
+			found[collection.Id] = true
+
+			result = append(result, collection)
+
+			if collection.IsView() {
+				queue = append(queue, collection)
+			}
+		}
+	}
+
+	return result, nil
+}
+
 func (dao *Dao) findCollectionsByIdentifiers(tables []identifier) (map[string]*models.Collection, error) {
 	names := make([]any, 0, len(tables))
 
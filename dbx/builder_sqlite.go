@@ -7,6 +7,7 @@ package dbx
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -44,6 +45,42 @@ func (b *SqliteBuilder) Model(model interface{}) *ModelQuery {
 	return NewModelQuery(model, b.db.FieldMapper, b.db, b)
 }
 
+// Upsert creates a Query that represents an UPSERT SQL statement.
+// Upsert inserts a row into the table if the primary key or unique index is not found.
+// Otherwise it will update the row with the new values.
+// The keys of cols are the column names, while the values of cols are the corresponding column
+// values to be inserted.
+func (b *SqliteBuilder) Upsert(table string, cols Params, constraints ...string) *Query {
+	q := b.Insert(table, cols)
+
+	names := []string{}
+	for name := range cols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		value := cols[name]
+		name = b.db.QuoteColumnName(name)
+		if e, ok := value.(Expression); ok {
+			lines = append(lines, name+"="+e.Build(b.db, q.params))
+		} else {
+			lines = append(lines, fmt.Sprintf("%v={:p%v}", name, len(q.params)))
+			q.params[fmt.Sprintf("p%v", len(q.params))] = value
+		}
+	}
+
+	if len(constraints) > 0 {
+		c := b.quoteColumns(constraints)
+		q.sql += " ON CONFLICT (" + c + ") DO UPDATE SET " + strings.Join(lines, ", ")
+	} else {
+		q.sql += " ON CONFLICT DO UPDATE SET " + strings.Join(lines, ", ")
+	}
+
+	return b.NewQuery(q.sql).Bind(q.params)
+}
+
 // QuoteSimpleTableName quotes a simple table name.
 // A simple table name does not contain any schema prefix.
 func (b *SqliteBuilder) QuoteSimpleTableName(s string) string {
@@ -10,6 +10,19 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestSqliteBuilder_Upsert(t *testing.T) {
+	b := getSqliteBuilder()
+	q := b.Upsert("users", Params{
+		"name": "James",
+		"age":  30,
+	}, "id")
+	assert.Equal(t, q.sql, "INSERT INTO `users` (`age`, `name`) VALUES ({:p0}, {:p1}) ON CONFLICT (`id`) DO UPDATE SET `age`={:p2}, `name`={:p3}", "t1")
+	assert.Equal(t, q.Params()["p0"], 30, "t2")
+	assert.Equal(t, q.Params()["p1"], "James", "t3")
+	assert.Equal(t, q.Params()["p2"], 30, "t4")
+	assert.Equal(t, q.Params()["p3"], "James", "t5")
+}
+
 func TestSqliteBuilder_QuoteSimpleTableName(t *testing.T) {
 	b := getSqliteBuilder()
 	assert.Equal(t, b.QuoteSimpleTableName(`abc`), "`abc`", "t1")
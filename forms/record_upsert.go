@@ -22,11 +22,24 @@ import (
 	"github.com/zhenruyan/postgrebase/tools/list"
 	"github.com/zhenruyan/postgrebase/tools/rest"
 	"github.com/zhenruyan/postgrebase/tools/security"
+	"github.com/zhenruyan/postgrebase/tools/types"
 )
 
 // username value regex pattern
 var usernameRegex = regexp.MustCompile(`^[\w][\w\.]*$`)
 
+// RecordVersionConflictError is returned on update when the form
+// version preconditions don't match the current persisted record.
+type RecordVersionConflictError struct {
+	// Current is the current persisted record state.
+	Current *models.Record
+}
+
+// Error makes it compatible with the `error` interface.
+func (e *RecordVersionConflictError) Error() string {
+	return "The record was modified by another request."
+}
+
 // RecordUpsert is a [models.Record] upsert (create/update) form.
 type RecordUpsert struct {
 	app          core.App
@@ -34,6 +47,9 @@ type RecordUpsert struct {
 	manageAccess bool
 	record       *models.Record
 	saveFunc     func(*daos.Dao, *models.Record) error
+	versionCheck func(current *models.Record) (bool, error)
+
+	conflictFields []string
 
 	filesToUpload map[string][]*filesystem.File
 	filesToDelete []string // names list
@@ -41,6 +57,10 @@ type RecordUpsert struct {
 	// base model fields
 	Id string `json:"id"`
 
+	// ExpectedUpdated is an optional update precondition - the `updated`
+	// date of the record version the submitted changes are based on.
+	ExpectedUpdated string `json:"expectedUpdated"`
+
 	// auth collection fields
 	// ---
 	Username        string `json:"username"`
@@ -97,6 +117,23 @@ func (form *RecordUpsert) SetSaveFunc(saveFunc func(*daos.Dao, *models.Record) e
 	form.saveFunc = saveFunc
 }
 
+// SetVersionCheck sets an optional update precondition that is called
+// with the current persisted record state right before saving the changes.
+//
+// Submit fails with [*RecordVersionConflictError] if the check returns false.
+func (form *RecordUpsert) SetVersionCheck(check func(current *models.Record) (bool, error)) {
+	form.versionCheck = check
+}
+
+// SetConflictFields sets the unique fields used as conflict target
+// when a new record is persisted.
+//
+// Submit fails with [daos.ErrRecordExists] if a record with the same
+// conflict field values already exists (see [daos.Dao.CreateRecordOnConflict]).
+func (form *RecordUpsert) SetConflictFields(fields ...string) {
+	form.conflictFields = fields
+}
+
 func (form *RecordUpsert) loadFormDefaults() {
 	form.Id = form.record.Id
 
@@ -360,6 +397,9 @@ func (form *RecordUpsert) LoadData(requestInfo map[string]any) error {
 	if v, ok := requestInfo[schema.FieldNameId]; ok {
 		form.Id = cast.ToString(v)
 	}
+	if v, ok := requestInfo["expectedUpdated"]; ok {
+		form.ExpectedUpdated = cast.ToString(v)
+	}
 
 	// load auth system fields
 	if form.record.Collection().IsAuth() {
@@ -473,6 +513,11 @@ func (form *RecordUpsert) Validate() error {
 				validation.By(validators.UniqueId(form.dao, form.record.TableName())),
 			).Else(validation.In(form.record.Id)),
 		),
+		validation.Field(
+			&form.ExpectedUpdated,
+			validation.When(form.record.IsNew(), validation.Empty),
+			validation.By(form.checkExpectedUpdated),
+		),
 	}
 
 	// auth fields validators
@@ -615,6 +660,19 @@ func (form *RecordUpsert) checkEmailDomain(value any) error {
 	return nil
 }
 
+func (form *RecordUpsert) checkExpectedUpdated(value any) error {
+	v, _ := value.(string)
+	if v == "" {
+		return nil
+	}
+
+	if d, _ := types.ParseDateTime(v); d.IsZero() {
+		return validation.NewError("validation_invalid_date", "Must be a valid date.")
+	}
+
+	return nil
+}
+
 func (form *RecordUpsert) checkOldPassword(value any) error {
 	v, _ := value.(string)
 	if v == "" {
@@ -793,15 +851,58 @@ func (form *RecordUpsert) Submit(interceptors ...InterceptorFunc[*models.Record]
 
 		// persist the record model
 		if form.saveFunc == nil {
-			if err := dao.SaveRecord(form.record); err != nil {
-				return form.prepareError(err)
+			save := func(txDao *daos.Dao) error {
+				if err := form.checkVersion(txDao); err != nil {
+					return err
+				}
+
+				var err error
+				if form.record.IsNew() && len(form.conflictFields) > 0 {
+					err = txDao.CreateRecordOnConflict(form.record, form.conflictFields...)
+				} else {
+					err = txDao.SaveRecord(form.record)
+				}
+				if err != nil {
+					return form.prepareError(err)
+				}
+
+				return nil
+			}
+
+			var err error
+			if form.hasVersionPrecondition() {
+				// check and save in a single transaction to prevent concurrent changes in between
+				err = dao.RunInTransaction(save)
+			} else {
+				err = save(dao)
+			}
+			if err != nil {
+				return err
 			}
 		} else {
-			if err := form.processFilesToUpload(); err != nil {
-				return form.prepareError(err)
+			save := func(txDao *daos.Dao) error {
+				if err := form.checkVersion(txDao); err != nil {
+					return err
+				}
+				if err := form.processFilesToUpload(); err != nil {
+					return form.prepareError(err)
+				}
+				if err := form.saveFunc(txDao, form.record); err != nil {
+					return form.prepareError(err)
+				}
+
+				return nil
 			}
-			if err := form.saveFunc(dao, form.record); err != nil {
-				return form.prepareError(err)
+
+			var err error
+			if form.hasVersionPrecondition() {
+				// check and save in a single transaction to prevent concurrent changes in between
+				err = dao.RunInTransaction(save)
+			} else {
+				err = save(dao)
+			}
+			if err != nil {
+				return err
 			}
 		}
 
@@ -817,6 +918,46 @@ func (form *RecordUpsert) Submit(interceptors ...InterceptorFunc[*models.Record]
 	}, interceptors...)
 }
 
+func (form *RecordUpsert) hasVersionPrecondition() bool {
+	return !form.record.IsNew() && (form.ExpectedUpdated != "" || form.versionCheck != nil)
+}
+
+// checkVersion checks the update preconditions (if any) against the current persisted record.
+func (form *RecordUpsert) checkVersion(dao *daos.Dao) error {
+	if !form.hasVersionPrecondition() {
+		return nil
+	}
+
+	if err := dao.LockRecordRow(form.record); err != nil {
+		return err
+	}
+
+	current, err := dao.FindRecordById(form.record.Collection().Id, form.record.Id)
+	if err != nil {
+		return err
+	}
+
+	if form.ExpectedUpdated != "" {
+		expected, _ := types.ParseDateTime(form.ExpectedUpdated)
+
+		if !expected.Time().Equal(current.GetUpdated().Time()) {
+			return &RecordVersionConflictError{Current: current}
+		}
+	}
+
+	if form.versionCheck != nil {
+		ok, err := form.versionCheck(current)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &RecordVersionConflictError{Current: current}
+		}
+	}
+
+	return nil
+}
+
 func (form *RecordUpsert) processFilesToUpload() error {
 	if len(form.filesToUpload) == 0 {
 		return nil // no parsed file fields
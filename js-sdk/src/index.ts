@@ -34,7 +34,7 @@ import {
     OAuth2UrlCallback,
     OAuth2AuthConfig,
 } from '@/services/RecordService';
-import { UnsubscribeFunc } from '@/services/RealtimeService';
+import { UnsubscribeFunc, RealtimeTransport, PresenceMember } from '@/services/RealtimeService';
 import { BackupFileInfo } from '@/services/BackupService';
 import { HealthCheckResponse } from '@/services/HealthService';
 import {
@@ -85,6 +85,8 @@ export {
     OAuth2AuthConfig,
     OnStoreChangeFunc,
     UnsubscribeFunc,
+    RealtimeTransport,
+    PresenceMember,
     BaseQueryParams,
     ListQueryParams,
     RecordQueryParams,
@@ -8,10 +8,137 @@ interface promiseCallbacks {
 
 export type UnsubscribeFunc = () => Promise<void>;
 
+export type RealtimeTransport = 'sse' | 'websocket';
+
+export interface PresenceMember {
+    clientId: string;
+    authId:   string;
+}
+
+/**
+ * RealtimeSocket wraps the realtime WebSocket connection
+ * in an EventSource like interface (the broker messages are
+ * dispatched as named `MessageEvent`-s).
+ */
+class RealtimeSocket extends EventTarget {
+    onerror: ((e: Event) => void) | null = null;
+
+    private ws: WebSocket;
+    private lastRequestId: number = 0;
+    private requestTimeout: number = 15000;
+    private pendingRequests: { [id: number]: promiseCallbacks & { timeoutId: any } } = {};
+
+    constructor(url: string) {
+        super();
+
+        this.ws = new WebSocket(url);
+
+        this.ws.onerror = (e) => {
+            this.onerror?.(e);
+        };
+
+        this.ws.onclose = (e) => {
+            this.rejectPendingRequests(new Error("The realtime connection was closed."));
+            this.onerror?.(e);
+        };
+
+        this.ws.onmessage = (e) => {
+            this.handleMessage(e.data);
+        };
+    }
+
+    /**
+     * Sends a client message and waits for its reply.
+     */
+    send(type: string, payload: { [key: string]: any } = {}): Promise<void> {
+        if (this.ws.readyState !== WebSocket.OPEN) {
+            return Promise.reject(new Error("The realtime connection is not open."));
+        }
+
+        const id = ++this.lastRequestId;
+
+        return new Promise((resolve, reject) => {
+            const timeoutId = setTimeout(() => {
+                delete this.pendingRequests[id];
+                reject(new Error(`The realtime "${type}" request took too long.`));
+            }, this.requestTimeout);
+
+            this.pendingRequests[id] = { resolve, reject, timeoutId };
+
+            this.ws.send(JSON.stringify(Object.assign({}, payload, { type, id })));
+        });
+    }
+
+    close(): void {
+        this.ws.onerror = null;
+        this.ws.onclose = null;
+        this.ws.onmessage = null;
+        this.ws.close();
+        this.rejectPendingRequests(new Error("The realtime connection was closed."));
+    }
+
+    private handleMessage(raw: any): void {
+        let msg: any;
+        try {
+            msg = JSON.parse(raw);
+        } catch {
+            return;
+        }
+
+        if (msg?.type == 'message') {
+            this.dispatchEvent(new MessageEvent(msg.name, {
+                data:        JSON.stringify(msg.data ?? {}),
+                lastEventId: msg.name == 'PB_CONNECT' ? (msg.data?.clientId || '') : (msg.eventId || ''),
+            }));
+            return;
+        }
+
+        const pending = this.pendingRequests[msg?.id];
+        if (!pending) {
+            return;
+        }
+
+        clearTimeout(pending.timeoutId);
+        delete this.pendingRequests[msg.id];
+
+        if (msg.type == 'error') {
+            pending.reject(new Error(msg.message));
+        } else {
+            pending.resolve();
+        }
+    }
+
+    private rejectPendingRequests(err: Error): void {
+        for (let id in this.pendingRequests) {
+            clearTimeout(this.pendingRequests[id].timeoutId);
+            this.pendingRequests[id].reject(err);
+        }
+        this.pendingRequests = {};
+    }
+}
+
 export default class RealtimeService extends BaseService {
     clientId: string = "";
 
-    private eventSource: EventSource | null = null;
+    /**
+     * The id of the last received realtime event.
+     *
+     * It is sent on reconnect so that the server could replay the missed
+     * events (or send a `PB_RESYNC` message if they are no longer available).
+     */
+    lastEventId: string = "";
+
+    /**
+     * The transport of the realtime connection:
+     * - 'sse'       - EventSource connection + POST /api/realtime subscriptions (default)
+     * - 'websocket' - single bidirectional /api/realtime/ws connection
+     *
+     * Changes are applied on the next (re)connect.
+     */
+    transport: RealtimeTransport = 'sse';
+
+    private eventSource: EventSource | RealtimeSocket | null = null;
+    private authChangeUnsubscribe: (() => void) | null = null;
     private subscriptions: { [key: string]: Array<EventListener> } = {};
     private lastSentTopics: Array<string> = [];
     private connectTimeoutId: any;
@@ -45,9 +172,15 @@ export default class RealtimeService extends BaseService {
             throw new Error('topic must be set.')
         }
 
-        const listener = function (e: Event) {
+        const listener = (e: Event) => {
             const msgEvent = (e as MessageEvent);
 
+            // the SSE messages without event id keep the previous id
+            // (which initially is the client id)
+            if (msgEvent?.lastEventId && msgEvent.lastEventId != this.clientId) {
+                this.lastEventId = msgEvent.lastEventId;
+            }
+
             let data;
             try {
                 data = JSON.parse(msgEvent?.data);
@@ -192,6 +325,41 @@ export default class RealtimeService extends BaseService {
         }
     }
 
+    /**
+     * Publishes a message to a custom realtime channel
+     * (subject to the channel publish rule).
+     *
+     * The channel subscribers receive it with the `channel:NAME` topic, eg.:
+     *
+     * ```js
+     * await pb.realtime.subscribe('channel:rooms/abc', (e) => { ... });
+     * await pb.realtime.publish('rooms/abc', { typing: true });
+     * ```
+     */
+    async publish(channel: string, data?: any): Promise<void> {
+        await this.client.send('/api/realtime/publish', {
+            'method': 'POST',
+            'body': {
+                'channel':  channel,
+                'data':     data,
+                'clientId': this.clientId,
+            },
+        });
+    }
+
+    /**
+     * Returns the current members of a presence channel
+     * (subject to the channel subscribe rule).
+     */
+    async presence(channel: string): Promise<Array<PresenceMember>> {
+        return this.client.send('/api/realtime/presence', {
+            'method': 'GET',
+            'params': {
+                'channel': channel,
+            },
+        }).then((data) => data?.members || []);
+    }
+
     private hasSubscriptionListeners(topicToCheck?: string): boolean {
         this.subscriptions = this.subscriptions || {};
 
@@ -218,6 +386,10 @@ export default class RealtimeService extends BaseService {
         // optimistic update
         this.addAllSubscriptionListeners();
 
+        if (this.eventSource instanceof RealtimeSocket) {
+            return this.submitSocketSubscriptions(this.eventSource);
+        }
+
         this.lastSentTopics = this.getNonEmptySubscriptionTopics();
 
         return this.client.send('/api/realtime', {
@@ -237,6 +409,65 @@ export default class RealtimeService extends BaseService {
         });
     }
 
+    /**
+     * Sends only the subscriptions changes since the last submit.
+     */
+    private async submitSocketSubscriptions(socket: RealtimeSocket): Promise<void> {
+        const prevTopics = this.lastSentTopics;
+
+        this.lastSentTopics = this.getNonEmptySubscriptionTopics();
+
+        const removed = prevTopics.filter((t) => !this.lastSentTopics.includes(t));
+        const added = this.lastSentTopics.filter((t) => !prevTopics.includes(t));
+
+        try {
+            if (removed.length) {
+                await socket.send('unsubscribe', { 'subscriptions': removed });
+            }
+            if (added.length) {
+                await socket.send('subscribe', { 'subscriptions': added });
+            }
+        } catch (err) {
+            throw new ClientResponseError(err);
+        }
+    }
+
+    /**
+     * Sends the current auth token through the realtime WebSocket connection
+     * (no-op for the SSE connection where the token is sent with each submit).
+     */
+    private async authorizeConnection(): Promise<void> {
+        if (!(this.eventSource instanceof RealtimeSocket)) {
+            return;
+        }
+
+        // the server resets the subscriptions on auth change
+        this.lastSentTopics = [];
+
+        const token = this.client.authStore.isValid ? this.client.authStore.token : '';
+
+        try {
+            await this.eventSource.send('auth', { 'token': token });
+        } catch (err) {
+            throw new ClientResponseError(err);
+        }
+    }
+
+    private getSocketUrl(): string {
+        const url = new URL(
+            this.client.buildUrl('/api/realtime/ws'),
+            typeof window !== 'undefined' ? window.location.href : undefined,
+        );
+
+        url.protocol = url.protocol == 'https:' ? 'wss:' : 'ws:';
+
+        if (this.lastEventId) {
+            url.searchParams.set('lastEventId', this.lastEventId);
+        }
+
+        return url.toString();
+    }
+
     private getSubscriptionsCancelKey(): string {
         return "realtime_" + this.clientId;
     }
@@ -307,17 +538,53 @@ export default class RealtimeService extends BaseService {
             this.connectErrorHandler(new Error("EventSource connect took too long."));
         }, this.maxConnectTimeout);
 
-        this.eventSource = new EventSource(this.client.buildUrl('/api/realtime'))
+        if (this.transport == 'websocket') {
+            this.eventSource = new RealtimeSocket(this.getSocketUrl());
+
+            // resend the auth state on login/logout
+            this.authChangeUnsubscribe = this.client.authStore.onChange(() => {
+                if (!this.clientId) {
+                    return;
+                }
+
+                this.authorizeConnection()
+                    .then(() => this.submitSubscriptions())
+                    .catch((err) => this.connectErrorHandler(err));
+            });
+        } else {
+            let url = this.client.buildUrl('/api/realtime');
+            if (this.lastEventId) {
+                url += (url.includes('?') ? '&' : '?') + 'lastEventId=' + encodeURIComponent(this.lastEventId);
+            }
+
+            this.eventSource = new EventSource(url);
+        }
 
         this.eventSource.onerror = (_) => {
             this.connectErrorHandler(new Error("Failed to establish realtime connection."));
         };
 
+        // the missed events are no longer available -> continue from the latest one
+        this.eventSource.addEventListener('PB_RESYNC', (e) => {
+            const msgEvent = (e as MessageEvent);
+            try {
+                this.lastEventId = JSON.parse(msgEvent?.data)?.lastEventId || "";
+            } catch {}
+        });
+
         this.eventSource.addEventListener('PB_CONNECT', (e) => {
             const msgEvent = (e as MessageEvent);
             this.clientId = msgEvent?.lastEventId;
 
-            this.submitSubscriptions()
+            // start tracking the events from the current one
+            if (!this.lastEventId) {
+                try {
+                    this.lastEventId = JSON.parse(msgEvent?.data)?.lastEventId || "";
+                } catch {}
+            }
+
+            this.authorizeConnection()
+            .then(() => this.submitSubscriptions())
             .then(async () => {
                 let retries = 3;
                 while (this.hasUnsentSubscriptions() && retries > 0) {
@@ -395,10 +662,13 @@ export default class RealtimeService extends BaseService {
         this.client.cancelRequest(this.getSubscriptionsCancelKey());
         this.eventSource?.close();
         this.eventSource = null;
+        this.authChangeUnsubscribe?.();
+        this.authChangeUnsubscribe = null;
         this.clientId = "";
 
         if (!fromReconnect) {
             this.reconnectAttempts = 0;
+            this.lastEventId = "";
 
             // resolve any remaining connect promises
             //
@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/routine"
+)
+
+// audit entry statuses
+const (
+	auditStatusSuccess = "success"
+	auditStatusError   = "error"
+	auditStatusDenied  = "denied"
+)
+
+// maxAuditArgLength is the max length of a single audited string argument.
+const maxAuditArgLength = 500
+
+// auditCleanupInterval is the min interval between two deletions of the expired audit entries.
+const auditCleanupInterval = 6 * time.Hour
+
+// sensitiveArgKeys are the (lowercased) argument keys that are never audited.
+var sensitiveArgKeys = []string{"password", "token", "secret", "apikey", "authorization"}
+
+// auditToolCall persists the tool call audit entry in the background.
+func (s *Server) auditToolCall(auth *AuthInfo, tool string, args map[string]interface{}, status string, callErr error, started time.Time) {
+	if s.app == nil {
+		return
+	}
+
+	entry := &models.McpAuditLog{
+		Actor:    auth.identityKey(),
+		Tool:     tool,
+		Status:   status,
+		Duration: float64(time.Since(started).Microseconds()) / 1000,
+	}
+
+	if auth != nil && auth.IsMCPToken {
+		entry.TokenId = auth.TokenId
+		entry.TokenName = auth.TokenName
+	}
+
+	if callErr != nil {
+		entry.ErrorMsg = callErr.Error()
+	}
+
+	if redacted := redactArgs(args); redacted != nil {
+		entry.Args, _ = json.Marshal(redacted)
+	}
+
+	routine.FireAndForget(func() {
+		if err := s.app.Dao().SaveMcpAuditLog(entry); err != nil && s.app.IsDebug() {
+			log.Printf("Failed to save the MCP audit entry for %s: %v", tool, err)
+		}
+
+		s.deleteExpiredAuditLogs()
+	})
+}
+
+// deleteExpiredAuditLogs deletes the audit entries older than the app
+// logs retention period (app.Settings().Logs.MaxDays).
+//
+// The deletion runs at most once per auditCleanupInterval.
+// Zero retention period keeps the audit entries forever.
+func (s *Server) deleteExpiredAuditLogs() {
+	maxDays := s.app.Settings().Logs.MaxDays
+	if maxDays <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	last := s.auditCleanedAt.Load()
+	if now.Sub(time.Unix(0, last)) < auditCleanupInterval || !s.auditCleanedAt.CompareAndSwap(last, now.UnixNano()) {
+		return // recently cleaned or in progress
+	}
+
+	if err := s.app.Dao().DeleteOldMcpAuditLogs(now.AddDate(0, 0, -maxDays)); err != nil && s.app.IsDebug() {
+		log.Printf("Failed to delete the expired MCP audit entries: %v", err)
+	}
+}
+
+// redactArgs returns a copy of the tool arguments with the sensitive
+// values masked and the long strings truncated.
+func redactArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if isSensitiveArgKey(k) {
+			out[k] = "***"
+			continue
+		}
+		out[k] = redactValue(v)
+	}
+
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return redactArgs(val)
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = redactValue(item)
+		}
+		return items
+	case string:
+		if len(val) > maxAuditArgLength {
+			return val[:maxAuditArgLength] + "..."
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+func isSensitiveArgKey(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, sensitive := range sensitiveArgKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -16,8 +16,67 @@ import (
 type AuthInfo struct {
 	Admin      *models.Admin
 	Record     *models.Record
-	IsMCPToken bool // true if authenticated via MCP-specific token
+	IsMCPToken bool   // true if authenticated via MCP-specific token
+	TokenId    string // MCP token record id
 	TokenName  string
+	Scopes     []TokenScope // MCP token scopes (empty for full access)
+	Local      bool         // true for an unauthenticated local stdio session
+
+	RateLimit        int // max MCP token tool calls per minute (0 - unlimited)
+	DestructiveLimit int // max MCP token destructive tool calls per minute (0 - unlimited)
+}
+
+// IsAdmin reports whether the caller bypasses the collection API rules.
+//
+// MCP tokens are issued by admins and are further restricted only by their scopes.
+func (a *AuthInfo) IsAdmin() bool {
+	return a != nil && (a.Admin != nil || a.IsMCPToken || a.Local)
+}
+
+// identityKey returns a string uniquely identifying the caller
+// (empty for guests).
+func (a *AuthInfo) identityKey() string {
+	switch {
+	case a == nil:
+		return ""
+	case a.Admin != nil:
+		return "admin:" + a.Admin.Id
+	case a.Record != nil:
+		return "record:" + a.Record.Collection().Id + ":" + a.Record.Id
+	case a.IsMCPToken:
+		return "token:" + a.TokenId
+	case a.Local:
+		return "local"
+	}
+
+	return ""
+}
+
+// sameIdentity reports whether both auth infos belong to the same caller.
+func (a *AuthInfo) sameIdentity(b *AuthInfo) bool {
+	return a.identityKey() == b.identityKey()
+}
+
+// requestInfo builds the @request.* data used when resolving the
+// collection API rules for the current caller.
+func (a *AuthInfo) requestInfo(method string, data map[string]interface{}) *models.RequestInfo {
+	info := &models.RequestInfo{
+		Method:  method,
+		Query:   map[string]any{},
+		Data:    map[string]any{},
+		Headers: map[string]any{},
+	}
+
+	for k, v := range data {
+		info.Data[k] = v
+	}
+
+	if a != nil {
+		info.Admin = a.Admin
+		info.AuthRecord = a.Record
+	}
+
+	return info
 }
 
 // Authenticate validates the token and returns auth info
@@ -80,7 +139,7 @@ func (s *Server) authenticateMCPToken(token string) (*AuthInfo, error) {
 		AndWhere(dbx.HashExp{"token": token}).
 		Limit(1).
 		All(&records)
-	
+
 	if err != nil || len(records) == 0 {
 		return nil, fmt.Errorf("invalid MCP token")
 	}
@@ -100,9 +159,21 @@ func (s *Server) authenticateMCPToken(token string) (*AuthInfo, error) {
 		}
 	}
 
+	scopes := []TokenScope{}
+	if raw := record.GetString("scopes"); raw != "" && raw != "null" {
+		if err := record.UnmarshalJSONField("scopes", &scopes); err != nil {
+			return nil, fmt.Errorf("MCP token has invalid scopes: %w", err)
+		}
+	}
+
 	return &AuthInfo{
 		IsMCPToken: true,
+		TokenId:    record.Id,
 		TokenName:  record.GetString("name"),
+		Scopes:     scopes,
+
+		RateLimit:        record.GetInt("rateLimit"),
+		DestructiveLimit: record.GetInt("destructiveLimit"),
 	}, nil
 }
 
@@ -0,0 +1,455 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/forms"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/filesystem"
+)
+
+const (
+	// maxBlobResourceSize is the max size of a file that could be
+	// read as a (base64 encoded) blob resource.
+	maxBlobResourceSize = 10 << 20
+
+	// fileFetchTimeout is the max time for downloading an upload_file url.
+	fileFetchTimeout = 30 * time.Second
+
+	fileResourcePrefix = "postgrebase://files/"
+)
+
+var fileToolDefs = []Tool{
+	{
+		Name:        "upload_file",
+		Description: "Attach a file to a record file field from base64 content or a public URL",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"collection": collectionArgSchema,
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "Record ID",
+				},
+				"field": map[string]interface{}{
+					"type":        "string",
+					"description": "File field name",
+				},
+				"filename": map[string]interface{}{
+					"type":        "string",
+					"description": "Original file name with extension (required for base64, defaults to the url path name)",
+				},
+				"base64": map[string]interface{}{
+					"type":        "string",
+					"description": "Base64 encoded file content (plain or as data: URL)",
+				},
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "http(s) URL to download the file from",
+				},
+				"replace": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Remove the existing field files (multiple files fields only append by default)",
+				},
+			},
+			"required": []string{"collection", "id", "field"},
+		},
+	},
+}
+
+// fileResourceURI returns the blob resource uri of a record file.
+func fileResourceURI(record *models.Record, filename string) string {
+	return fileResourcePrefix + record.Collection().Name + "/" + record.Id + "/" + filename
+}
+
+// toolUploadFile attaches a new file to a record file field
+// (subject to the collection update rule).
+func (s *Server) toolUploadFile(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	collectionName, ok := args["collection"].(string)
+	if !ok || collectionName == "" {
+		return nil, fmt.Errorf("collection parameter is required")
+	}
+
+	recordID, ok := args["id"].(string)
+	if !ok || recordID == "" {
+		return nil, fmt.Errorf("id parameter is required")
+	}
+
+	fieldName, ok := args["field"].(string)
+	if !ok || fieldName == "" {
+		return nil, fmt.Errorf("field parameter is required")
+	}
+
+	collection, err := s.app.Dao().FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %s", collectionName)
+	}
+
+	field := collection.Schema.GetFieldByName(fieldName)
+	if field == nil || field.Type != schema.FieldTypeFile {
+		return nil, fmt.Errorf("%s is not a file field of %s", fieldName, collection.Name)
+	}
+
+	options, ok := field.Options.(*schema.FileOptions)
+	if !ok {
+		return nil, errors.New("failed to load the file field options")
+	}
+
+	if err := checkRule(auth, collection.UpdateRule); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("upload_file", collection, true)
+	if err != nil {
+		return nil, err
+	}
+
+	requestInfo := auth.requestInfo("PATCH", nil)
+	record, err := s.app.Dao().FindRecordById(
+		collection.Id,
+		recordID,
+		s.ruleFunc(s.app.Dao(), collection, collection.UpdateRule, requestInfo, auth),
+		s.scopeFilterFunc(s.app.Dao(), collection, scope),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("record not found: %s", recordID)
+	}
+
+	file, err := s.loadUploadFile(ctx, auth, args, options.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	form := forms.NewRecordUpsert(s.app, record)
+	form.SetFullManageAccess(auth.IsAdmin() || s.hasAuthManageAccess(s.app.Dao(), record, requestInfo))
+	if s.app.IsSQLiteCluster() {
+		form.SetSaveFunc(func(_ *daos.Dao, r *models.Record) error {
+			return s.saveRecord(r)
+		})
+	}
+
+	if cast.ToBool(args["replace"]) {
+		if err := form.RemoveFiles(field.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := form.AddFiles(field.Name, file); err != nil {
+		return nil, err
+	}
+
+	if err := form.Submit(); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	data, _ := json.MarshalIndent(map[string]interface{}{
+		"record": record,
+		"file":   file.Name,
+		"uri":    fileResourceURI(record, file.Name),
+	}, "", "  ")
+
+	return &ToolCallResult{
+		Content: []Content{
+			{
+				Type: "text",
+				Text: string(data),
+			},
+		},
+	}, nil
+}
+
+// loadUploadFile creates the upload_file file from its base64 or url argument.
+func (s *Server) loadUploadFile(ctx context.Context, auth *AuthInfo, args map[string]interface{}, maxSize int) (*filesystem.File, error) {
+	filename, _ := args["filename"].(string)
+	rawBase64, _ := args["base64"].(string)
+	rawURL, _ := args["url"].(string)
+
+	if (rawBase64 == "") == (rawURL == "") {
+		return nil, errors.New("either base64 or url parameter is required")
+	}
+
+	var content []byte
+
+	if rawBase64 != "" {
+		if filename == "" {
+			return nil, errors.New("filename parameter is required for base64 content")
+		}
+
+		// strip the data url prefix (eg. data:image/png;base64,)
+		if strings.HasPrefix(rawBase64, "data:") {
+			if i := strings.Index(rawBase64, ","); i > 0 {
+				rawBase64 = rawBase64[i+1:]
+			}
+		}
+
+		if base64.StdEncoding.DecodedLen(len(rawBase64)) > maxSize+3 {
+			return nil, fmt.Errorf("the file exceeds the field max size of %d bytes", maxSize)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(rawBase64))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+		content = decoded
+	} else {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid file url: %s", rawURL)
+		}
+
+		// only real admins and local stdio sessions could fetch from internal addresses
+		// (MCP tokens, including the narrowly scoped ones, are limited to public hosts)
+		publicOnly := auth == nil || (auth.Admin == nil && !auth.Local)
+
+		content, err = fetchFile(ctx, u.String(), maxSize, publicOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		if filename == "" {
+			filename = path.Base(u.Path)
+			if filename == "." || filename == "/" {
+				filename = "file"
+			}
+		}
+	}
+
+	if len(content) > maxSize {
+		return nil, fmt.Errorf("the file exceeds the field max size of %d bytes", maxSize)
+	}
+
+	return filesystem.NewFileFromBytes(content, filename)
+}
+
+// errPrivateAddress is returned when a non-admin caller tries
+// to fetch a file from a loopback or private network address.
+var errPrivateAddress = errors.New("fetching files from private network addresses is not allowed")
+
+// isPublicIP reports whether ip is a public unicast address.
+func isPublicIP(ip net.IP) bool {
+	return ip != nil &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast()
+}
+
+// checkPublicHost resolves the host of the provided url and returns
+// errPrivateAddress if any of its addresses is not public.
+func checkPublicHost(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if host == "" {
+		return errPrivateAddress
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return errPrivateAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %q: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return errPrivateAddress
+	}
+
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return errPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+// fetchFile downloads the file at rawURL (up to maxSize bytes).
+//
+// If publicOnly is set, the request target (and every redirect) must resolve
+// only to public addresses and the environment proxy settings are ignored,
+// so that the check is applied to the actual target host.
+func fetchFile(ctx context.Context, rawURL string, maxSize int, publicOnly bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, fileFetchTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	transport := &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: dialer.DialContext,
+	}
+
+	client := &http.Client{Transport: transport}
+
+	if publicOnly {
+		// a proxy would dial on our behalf and bypass the address checks
+		transport.Proxy = nil
+
+		// checked also on dial so that DNS rebinding is covered too
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(net.ParseIP(host)) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return checkPublicHost(req.Context(), req.URL)
+		}
+
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPublicHost(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the file: unexpected status %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the file: %w", err)
+	}
+
+	return content, nil
+}
+
+// resolveFileResource reads a record file as a base64 encoded blob resource,
+// e.g., postgrebase://files/posts/abc123/image_0d5ab2fnt7.png
+//
+// Similar to the files api, the protected files require view access to the record.
+func (s *Server) resolveFileResource(auth *AuthInfo, uri string) (*ResourceReadResult, error) {
+	parts := strings.Split(strings.TrimPrefix(uri, fileResourcePrefix), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid file resource uri: %s", uri)
+	}
+
+	collection, err := s.app.Dao().FindCollectionByNameOrId(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %s", parts[0])
+	}
+
+	scope, err := auth.collectionScope("get_record", collection, false)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.app.Dao().FindRecordById(
+		collection.Id,
+		parts[1],
+		s.scopeFilterFunc(s.app.Dao(), collection, scope),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("record not found: %s", parts[1])
+	}
+
+	filename := parts[2]
+
+	fileField := record.FindFileFieldByFile(filename)
+	if fileField == nil {
+		return nil, fmt.Errorf("file not found: %s", filename)
+	}
+
+	options, ok := fileField.Options.(*schema.FileOptions)
+	if !ok {
+		return nil, errors.New("failed to load the file field options")
+	}
+
+	if options.Protected && !auth.IsAdmin() {
+		canAccess, _ := s.app.Dao().CanAccessRecord(record, auth.requestInfo("GET", nil), collection.ViewRule)
+		if !canAccess {
+			return nil, errors.New("insufficient permissions to access the protected file")
+		}
+	}
+
+	baseFilesPath := record.BaseFilesPath()
+
+	// fetch the original view file field related record
+	if collection.IsView() {
+		fileRecord, err := s.app.Dao().FindRecordByViewFile(collection.Id, fileField.Name, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the view file field record: %w", err)
+		}
+		baseFilesPath = fileRecord.BaseFilesPath()
+	}
+
+	fs, err := s.app.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	filePath := baseFilesPath + "/" + filename
+
+	attrs, err := fs.Attributes(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %s", filename)
+	}
+	if attrs.Size > maxBlobResourceSize {
+		return nil, fmt.Errorf("the file is too large to be read through MCP (max %d bytes)", maxBlobResourceSize)
+	}
+
+	reader, err := fs.GetFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("file not found: %s", filename)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResourceReadResult{
+		Contents: []ResourceContent{
+			{
+				URI:      uri,
+				MimeType: attrs.ContentType,
+				Blob:     base64.StdEncoding.EncodeToString(content),
+			},
+		},
+	}, nil
+}
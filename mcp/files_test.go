@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLoadUploadFile(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer remote.Close()
+
+	s := &Server{}
+	admin := &AuthInfo{Local: true}
+
+	scenarios := []struct {
+		name        string
+		auth        *AuthInfo
+		args        map[string]interface{}
+		maxSize     int
+		expectError bool
+		expectName  string
+		expectSize  int64
+	}{
+		{"missing content", admin, map[string]interface{}{"filename": "a.txt"}, 100, true, "", 0},
+		{"both base64 and url", admin, map[string]interface{}{"filename": "a.txt", "base64": "aGVsbG8=", "url": remote.URL}, 100, true, "", 0},
+		{"base64 without filename", admin, map[string]interface{}{"base64": "aGVsbG8="}, 100, true, "", 0},
+		{"invalid base64", admin, map[string]interface{}{"filename": "a.txt", "base64": "!!!"}, 100, true, "", 0},
+		{"base64 exceeding max size", admin, map[string]interface{}{"filename": "a.txt", "base64": "aGVsbG8="}, 4, true, "", 0},
+		{"base64", admin, map[string]interface{}{"filename": "a.txt", "base64": "aGVsbG8="}, 100, false, "a.txt", 5},
+		{"data url", admin, map[string]interface{}{"filename": "a.txt", "base64": "data:text/plain;base64,aGVsbG8="}, 100, false, "a.txt", 5},
+		{"invalid url", admin, map[string]interface{}{"url": "ftp://example.com/a.txt"}, 100, true, "", 0},
+		{"url exceeding max size", admin, map[string]interface{}{"url": remote.URL + "/b.txt"}, 4, true, "", 0},
+		{"url", admin, map[string]interface{}{"url": remote.URL + "/b.txt"}, 100, false, "b.txt", 5},
+		{"private url as non-admin", &AuthInfo{}, map[string]interface{}{"url": remote.URL + "/b.txt"}, 100, true, "", 0},
+		{"private url as mcp token", &AuthInfo{IsMCPToken: true}, map[string]interface{}{"url": remote.URL + "/b.txt"}, 100, true, "", 0},
+		{"private url as scoped mcp token", &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{ReadOnly: true}}}, map[string]interface{}{"url": remote.URL + "/b.txt"}, 100, true, "", 0},
+	}
+
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			file, err := s.loadUploadFile(context.Background(), sc.auth, sc.args, sc.maxSize)
+			if (err != nil) != sc.expectError {
+				t.Fatalf("expected error %v, got %v", sc.expectError, err)
+			}
+			if err != nil {
+				return
+			}
+			if file.OriginalName != sc.expectName {
+				t.Fatalf("expected name %q, got %q", sc.expectName, file.OriginalName)
+			}
+			if file.Size != sc.expectSize {
+				t.Fatalf("expected size %d, got %d", sc.expectSize, file.Size)
+			}
+		})
+	}
+}
+
+func TestFetchFilePublicOnly(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer remote.Close()
+
+	if _, err := fetchFile(context.Background(), remote.URL, 100, true); !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("expected errPrivateAddress, got %v", err)
+	}
+
+	content, err := fetchFile(context.Background(), remote.URL, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" {
+		t.Fatalf("expected hello, got %q", content)
+	}
+}
+
+func TestCheckPublicHost(t *testing.T) {
+	scenarios := []struct {
+		url         string
+		expectError bool
+	}{
+		{"http://localhost/a.txt", true},
+		{"http://127.0.0.1:8090/a.txt", true},
+		{"http://[::1]/a.txt", true},
+		{"http://10.0.0.1/a.txt", true},
+		{"http://192.168.1.1/a.txt", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://0.0.0.0/a.txt", true},
+		{"http://[::ffff:127.0.0.1]/a.txt", true},
+		{"http://8.8.8.8/a.txt", false},
+		{"https://[2001:4860:4860::8888]/a.txt", false},
+	}
+
+	for _, s := range scenarios {
+		u, err := url.Parse(s.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = checkPublicHost(context.Background(), u)
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.url, s.expectError, hasErr, err)
+		}
+	}
+}
+
+func TestFetchFilePublicOnlyWithProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metadata"))
+	}))
+	defer proxy.Close()
+
+	// the target host is checked even if a proxy is configured
+	t.Setenv("HTTP_PROXY", proxy.URL)
+
+	if _, err := fetchFile(context.Background(), "http://169.254.169.254/latest/meta-data", 100, true); !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("expected errPrivateAddress, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// errRequestCancelled is returned for the requests cancelled by the client
+// (or interrupted because the client disconnected).
+var errRequestCancelled = errors.New("the request was cancelled")
+
+// RequestMeta holds the reserved "_meta" request params.
+type RequestMeta struct {
+	// ProgressToken is an arbitrary string or number set by the client
+	// to receive notifications/progress for the request.
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
+
+// CancelledParams are the params of a notifications/cancelled message.
+type CancelledParams struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// ProgressParams are the params of a notifications/progress message.
+type ProgressParams struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// requestKey returns the normalized form of a JSON-RPC request id
+// (so that a numeric id 1 and a string id "1" are not mixed up).
+func requestKey(id interface{}) string {
+	raw, _ := json.Marshal(id)
+
+	return string(raw)
+}
+
+// trackRequest registers a cancellable in-flight request of the session.
+//
+// The returned done func must be called once the request completes.
+func (sess *Session) trackRequest(ctx context.Context, id interface{}) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	if id == nil {
+		return ctx, cancel
+	}
+
+	key := requestKey(id)
+
+	sess.mu.Lock()
+	if sess.closed {
+		sess.mu.Unlock()
+		cancel()
+		return ctx, cancel
+	}
+	sess.inflight[key] = cancel
+	sess.mu.Unlock()
+
+	return ctx, func() {
+		sess.mu.Lock()
+		delete(sess.inflight, key)
+		sess.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest cancels an in-flight request of the session.
+//
+// It returns false if there is no such request (eg. already completed).
+func (sess *Session) cancelRequest(id interface{}) bool {
+	sess.mu.Lock()
+	cancel, ok := sess.inflight[requestKey(id)]
+	sess.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+
+	return ok
+}
+
+// handleCancelled handles a notifications/cancelled message.
+func (s *Server) handleCancelled(sess *Session, req *JSONRPCRequest) {
+	var params CancelledParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.RequestID == nil {
+		return
+	}
+
+	sess.cancelRequest(params.RequestID)
+}
+
+// -------------------------------------------------------------------
+
+type notifierCtxKey struct{}
+
+type progressCtxKey struct{}
+
+// progressReporter sends the progress notifications of a single request.
+type progressReporter struct {
+	sess  *Session
+	token interface{}
+}
+
+// withNotifier returns a copy of ctx that delivers the request
+// notifications with send instead of the session channel
+// (eg. on the response stream of a Streamable HTTP POST).
+func withNotifier(ctx context.Context, send func(data []byte) error) context.Context {
+	return context.WithValue(ctx, notifierCtxKey{}, send)
+}
+
+// withProgress returns a copy of ctx that reports the request progress
+// to the client (if it has provided a progress token).
+func withProgress(ctx context.Context, sess *Session, token interface{}) context.Context {
+	if token == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, progressCtxKey{}, &progressReporter{sess: sess, token: token})
+}
+
+// reportProgress sends a notifications/progress message for the current request.
+//
+// It is a no-op if the client hasn't asked for progress updates.
+// Set total to 0 if it is unknown.
+func reportProgress(ctx context.Context, progress, total float64, message string) {
+	reporter, _ := ctx.Value(progressCtxKey{}).(*progressReporter)
+	if reporter == nil || ctx.Err() != nil {
+		return
+	}
+
+	params := &ProgressParams{
+		ProgressToken: reporter.token,
+		Progress:      progress,
+		Total:         total,
+		Message:       message,
+	}
+
+	// progress updates are best effort
+	if send, _ := ctx.Value(notifierCtxKey{}).(func(data []byte) error); send != nil {
+		if data, err := json.Marshal(&JSONRPCNotification{
+			JSONRPC: "2.0",
+			Method:  "notifications/progress",
+			Params:  params,
+		}); err == nil {
+			_ = send(data)
+		}
+		return
+	}
+
+	_ = reporter.sess.Notify("notifications/progress", params)
+}
+
+// progressToken returns the progress token of a request (if any).
+func progressToken(req *JSONRPCRequest) interface{} {
+	if len(req.Params) == 0 {
+		return nil
+	}
+
+	var params struct {
+		Meta *RequestMeta `json:"_meta"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Meta == nil {
+		return nil
+	}
+
+	return params.Meta.ProgressToken
+}
@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressToken(t *testing.T) {
+	scenarios := []struct {
+		params   string
+		expected interface{}
+	}{
+		{``, nil},
+		{`{}`, nil},
+		{`{"name":"test"}`, nil},
+		{`{"_meta":{}}`, nil},
+		{`{"_meta":{"progressToken":"abc"}}`, "abc"},
+		{`{"_meta":{"progressToken":5}}`, float64(5)},
+		{`invalid`, nil},
+	}
+
+	for i, s := range scenarios {
+		result := progressToken(&JSONRPCRequest{Params: json.RawMessage(s.params)})
+		if result != s.expected {
+			t.Errorf("[%d] expected %v, got %v", i, s.expected, result)
+		}
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	s := &Server{}
+	sess := s.statelessSession(nil)
+
+	var sent []string
+	ctx := withNotifier(context.Background(), func(data []byte) error {
+		sent = append(sent, string(data))
+		return nil
+	})
+
+	// no progress token
+	reportProgress(ctx, 1, 2, "test")
+	if len(sent) != 0 {
+		t.Fatalf("expected no notifications, got %v", sent)
+	}
+
+	reportProgress(withProgress(ctx, sess, "abc"), 1, 2, "test")
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 notification, got %v", sent)
+	}
+
+	expected := `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":"abc","progress":1,"total":2,"message":"test"}}`
+	if sent[0] != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, sent[0])
+	}
+
+	// without a request notifier and session channel the progress is silently dropped
+	reportProgress(withProgress(context.Background(), sess, "abc"), 1, 2, "test")
+}
+
+func TestToolCallCancellation(t *testing.T) {
+	s := &Server{tools: map[string]ToolHandler{}}
+
+	started := make(chan struct{})
+	s.tools["slow"] = func(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	sess := s.statelessSession(nil)
+
+	result := make(chan *JSONRPCResponse, 1)
+	go func() {
+		result <- s.HandleRequest(context.Background(), sess, &JSONRPCRequest{
+			JSONRPC: "2.0",
+			ID:      float64(1),
+			Method:  "tools/call",
+			Params:  json.RawMessage(`{"name":"slow"}`),
+		})
+	}()
+
+	<-started
+
+	// unknown request ids are ignored
+	if response := s.HandleRequest(context.Background(), sess, &JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  json.RawMessage(`{"requestId":"1"}`),
+	}); response != nil {
+		t.Fatalf("expected no response for a notification, got %v", response)
+	}
+
+	s.HandleRequest(context.Background(), sess, &JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  json.RawMessage(`{"requestId":1,"reason":"test"}`),
+	})
+
+	select {
+	case response := <-result:
+		data, _ := json.Marshal(response.Result)
+		if !strings.Contains(string(data), errRequestCancelled.Error()) {
+			t.Fatalf("expected cancelled tool result, got %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the tool call wasn't cancelled")
+	}
+
+	if len(sess.inflight) != 0 {
+		t.Fatalf("expected no in-flight requests, got %v", sess.inflight)
+	}
+}
+
+func TestSessionCloseCancelsRequests(t *testing.T) {
+	s := &Server{}
+	sess := s.statelessSession(nil)
+
+	ctx, done := sess.trackRequest(context.Background(), "a")
+	defer done()
+
+	sess.Close()
+
+	if ctx.Err() == nil {
+		t.Fatal("expected the request context to be cancelled")
+	}
+
+	// requests started after close are cancelled immediately
+	ctx2, done2 := sess.trackRequest(context.Background(), "b")
+	defer done2()
+
+	if ctx2.Err() == nil {
+		t.Fatal("expected the late request context to be cancelled")
+	}
+}
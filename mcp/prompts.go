@@ -0,0 +1,222 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+// mcpPromptsCollection is the collection holding the admin-defined prompt templates.
+const mcpPromptsCollection = "_pb_mcp_prompts_"
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// promptDefinition is a prompt together with its message template.
+//
+// Template placeholders are in the form {{argName}}.
+type promptDefinition struct {
+	Prompt
+	Template string
+}
+
+// builtinPrompts are always available regardless of the DB-defined prompts.
+var builtinPrompts = []promptDefinition{
+	{
+		Prompt: Prompt{
+			Name:        "explore_collection",
+			Description: "Explore a collection: its schema, API rules and a sample of its records",
+			Arguments: []PromptArgument{
+				{Name: "collection", Description: "Collection name or ID", Required: true},
+			},
+		},
+		Template: "Explore the PostgreBase collection \"{{collection}}\".\n\n" +
+			"1. Read the postgrebase://collections/{{collection}} resource (or call get_collection) to learn its fields and API rules.\n" +
+			"2. Call list_records with perPage=10 to fetch a sample of its records.\n" +
+			"3. Summarize what the collection stores, the meaning of each field, the relations to other collections " +
+			"and any data quality issues you notice in the sample.",
+	},
+	{
+		Prompt: Prompt{
+			Name:        "design_schema",
+			Description: "Design a collections schema for the described application or feature",
+			Arguments: []PromptArgument{
+				{Name: "description", Description: "What the application or feature should do", Required: true},
+			},
+		},
+		Template: "Design a PostgreBase collections schema for the following requirement:\n\n{{description}}\n\n" +
+			"Call list_collections first to reuse the existing collections where it makes sense. " +
+			"For every new collection propose its name, type (base, auth or view), fields " +
+			"(text, number, bool, email, url, date, select, json, file, relation, editor) with their options, " +
+			"indexes and the list/view/create/update/delete API rules. Explain the relations between the collections.",
+	},
+	{
+		Prompt: Prompt{
+			Name:        "write_filter",
+			Description: "Write a PostgreBase filter expression for a natural language query",
+			Arguments: []PromptArgument{
+				{Name: "collection", Description: "Collection name or ID", Required: true},
+				{Name: "query", Description: "What records should be matched", Required: true},
+			},
+		},
+		Template: "Write a PostgreBase filter expression for the \"{{collection}}\" collection that matches: {{query}}\n\n" +
+			"Read the postgrebase://collections/{{collection}} resource to check the available field names. " +
+			"Supported operators: = != > >= < <= ~ (like/contains) !~ (not like) and their any-of variants " +
+			"?= ?!= ?> ?>= ?< ?<= ?~ ?!~. Combine conditions with && and ||, group them with parentheses " +
+			"and quote string values with double quotes. Reply with the expression and verify it with search_records.",
+	},
+}
+
+// listPrompts returns the built-in prompts followed by the active DB-defined ones.
+func (s *Server) listPrompts() []Prompt {
+	result := make([]Prompt, 0, len(builtinPrompts))
+	for _, p := range builtinPrompts {
+		result = append(result, p.Prompt)
+	}
+
+	for _, p := range s.dbPrompts() {
+		result = append(result, p.Prompt)
+	}
+
+	return result
+}
+
+// findPrompt looks up a prompt definition by its name.
+//
+// The built-in prompts take precedence over the DB-defined ones.
+func (s *Server) findPrompt(name string) (*promptDefinition, bool) {
+	for i := range builtinPrompts {
+		if builtinPrompts[i].Name == name {
+			return &builtinPrompts[i], true
+		}
+	}
+
+	for _, p := range s.dbPrompts() {
+		if p.Name == name {
+			return &p, true
+		}
+	}
+
+	return nil, false
+}
+
+// dbPrompts loads the active admin-defined prompt templates.
+func (s *Server) dbPrompts() []promptDefinition {
+	collection, err := s.app.Dao().FindCollectionByNameOrId(mcpPromptsCollection)
+	if err != nil {
+		return nil
+	}
+
+	records := []*models.Record{}
+	err = s.app.Dao().RecordQuery(collection).
+		AndWhere(dbx.HashExp{"active": true}).
+		OrderBy("name ASC").
+		All(&records)
+	if err != nil {
+		return nil
+	}
+
+	result := make([]promptDefinition, 0, len(records))
+	for _, r := range records {
+		p := promptDefinition{
+			Prompt: Prompt{
+				Name:        r.GetString("name"),
+				Description: r.GetString("description"),
+			},
+			Template: r.GetString("template"),
+		}
+		if raw := r.GetString("arguments"); raw != "" && raw != "null" {
+			_ = json.Unmarshal([]byte(raw), &p.Arguments)
+		}
+		result = append(result, p)
+	}
+
+	return result
+}
+
+// render replaces the {{argName}} placeholders of the prompt template.
+func (p *promptDefinition) render(args map[string]string) (string, error) {
+	for _, arg := range p.Arguments {
+		if arg.Required && strings.TrimSpace(args[arg.Name]) == "" {
+			return "", fmt.Errorf("missing required argument: %s", arg.Name)
+		}
+	}
+
+	// sort the keys to keep the replacement deterministic
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		pairs = append(pairs, "{{"+k+"}}", args[k])
+	}
+
+	return strings.NewReplacer(pairs...).Replace(p.Template), nil
+}
+
+func (s *Server) handlePromptsList(req *JSONRPCRequest) *JSONRPCResponse {
+	return s.successResponse(req.ID, map[string]interface{}{
+		"prompts": s.listPrompts(),
+	})
+}
+
+func (s *Server) handlePromptsGet(req *JSONRPCRequest) *JSONRPCResponse {
+	var params PromptGetParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return s.errorResponse(req.ID, InvalidParams, "Invalid parameters")
+	}
+
+	prompt, exists := s.findPrompt(params.Name)
+	if !exists {
+		return s.errorResponse(req.ID, InvalidParams, fmt.Sprintf("Prompt not found: %s", params.Name))
+	}
+
+	text, err := prompt.render(params.Arguments)
+	if err != nil {
+		return s.errorResponse(req.ID, InvalidParams, err.Error())
+	}
+
+	return s.successResponse(req.ID, &PromptGetResult{
+		Description: prompt.Description,
+		Messages: []PromptMessage{
+			{
+				Role: "user",
+				Content: Content{
+					Type: "text",
+					Text: text,
+				},
+			},
+		},
+	})
+}
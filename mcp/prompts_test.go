@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptDefinitionRender(t *testing.T) {
+	p := &promptDefinition{
+		Prompt: Prompt{
+			Name: "test",
+			Arguments: []PromptArgument{
+				{Name: "collection", Required: true},
+				{Name: "query"},
+			},
+		},
+		Template: "Search {{collection}} for {{query}} ({{collection}})",
+	}
+
+	text, err := p.render(map[string]string{"collection": "posts", "query": "drafts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Search posts for drafts (posts)" {
+		t.Errorf("unexpected rendered text %q", text)
+	}
+
+	if _, err := p.render(map[string]string{"query": "drafts"}); err == nil {
+		t.Error("expected missing required argument error")
+	}
+}
+
+func TestBuiltinPrompts(t *testing.T) {
+	s := &Server{}
+
+	for _, name := range []string{"explore_collection", "design_schema", "write_filter"} {
+		p, ok := s.findPrompt(name)
+		if !ok {
+			t.Errorf("expected builtin prompt %q", name)
+			continue
+		}
+
+		args := map[string]string{}
+		for _, arg := range p.Arguments {
+			args[arg.Name] = "value_" + arg.Name
+		}
+
+		text, err := p.render(args)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if strings.Contains(text, "{{") {
+			t.Errorf("%s: unresolved placeholder in %q", name, text)
+		}
+	}
+}
+
+func TestFindResourceHandler(t *testing.T) {
+	s := &Server{resources: map[string]ResourceHandler{}}
+	s.registerResources()
+
+	cases := []struct {
+		uri  string
+		want bool
+	}{
+		{"postgrebase://collections", true},
+		{"postgrebase://settings", true},
+		{"postgrebase://collections/posts", true},
+		{"postgrebase://records/posts/abc", true},
+		{"postgrebase://records/", false},
+		{"postgrebase://unknown", false},
+	}
+
+	for _, tc := range cases {
+		if _, ok := s.findResourceHandler(tc.uri); ok != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.uri, tc.want, ok)
+		}
+	}
+}
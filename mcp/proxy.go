@@ -0,0 +1,402 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyReconnectDelay is the delay before reopening
+// an interrupted remote session stream.
+const proxyReconnectDelay = 2 * time.Second
+
+// ProxyTransport is a stdio MCP server that forwards all client messages
+// to the Streamable HTTP endpoint of a remote PostgreBase instance.
+//
+// It allows the desktop AI clients to work with a deployed instance
+// through the authenticated HTTP API (without direct database access).
+type ProxyTransport struct {
+	endpoint string
+	token    string
+	client   *http.Client
+	reader   *bufio.Reader
+	writer   io.Writer
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	sessionID  string
+	stopListen context.CancelFunc
+}
+
+// NewProxyTransport creates a new stdio proxy transport for the
+// PostgreBase instance at remoteURL (eg. https://api.example.com).
+func NewProxyTransport(remoteURL, token string) (*ProxyTransport, error) {
+	u, err := url.Parse(strings.TrimSpace(remoteURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote url %q (expected http(s)://host)", remoteURL)
+	}
+
+	if token == "" {
+		return nil, errors.New("an MCP token is required for the remote mode")
+	}
+
+	return &ProxyTransport{
+		endpoint: strings.TrimRight(u.String(), "/") + "/api/mcp/stream",
+		token:    token,
+		client:   &http.Client{},
+		reader:   bufio.NewReader(os.Stdin),
+		writer:   os.Stdout,
+	}, nil
+}
+
+// Run starts the proxy loop and blocks until stdin is closed.
+func (t *ProxyTransport) Run() error {
+	log.SetOutput(os.Stderr) // Send logs to stderr to avoid interfering with stdio
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer func() {
+		// wait for the in-flight calls before terminating the remote session
+		wg.Wait()
+		t.closeSession()
+	}()
+
+	for {
+		line, err := t.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			requests, _, parseErr := parseJSONRPCMessages(line)
+			if parseErr != nil {
+				t.writeMessage(&JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error: &RPCError{
+						Code:    ParseError,
+						Message: "Parse error: " + parseErr.Error(),
+					},
+				})
+			} else if hasMethod(requests, "tools/call") {
+				// forward the tool calls concurrently so that they could be cancelled
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					t.forward(ctx, line, requests)
+				}()
+			} else {
+				t.forward(ctx, line, requests)
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read from stdin: %w", err)
+		}
+	}
+}
+
+// forward posts the raw client message to the remote endpoint
+// and writes the remote messages on stdout.
+func (t *ProxyTransport) forward(ctx context.Context, body []byte, requests []*JSONRPCRequest) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		t.writeErrors(requests, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("Authorization", t.token)
+
+	sessionID := t.session()
+	if sessionID != "" && !hasInitializeRequest(requests) {
+		req.Header.Set(sessionIdHeader, sessionID)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.writeErrors(requests, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		if resp.StatusCode == http.StatusNotFound && sessionID != "" {
+			t.resetSession(sessionID)
+		}
+		t.writeErrors(requests, remoteError(resp))
+		return
+	}
+
+	if newID := resp.Header.Get(sessionIdHeader); newID != "" && hasInitializeRequest(requests) {
+		t.startSession(ctx, newID)
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		err := readSSEEvents(resp.Body, func(id, data string) {
+			t.writeLine([]byte(data))
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("remote MCP stream error: %v", err)
+		}
+		return
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.writeErrors(requests, err)
+		return
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return // 202 Accepted (notifications only)
+	}
+
+	compacted := bytes.Buffer{}
+	if err := json.Compact(&compacted, raw); err != nil {
+		t.writeErrors(requests, fmt.Errorf("invalid remote response: %w", err))
+		return
+	}
+
+	t.writeLine(compacted.Bytes())
+}
+
+// listen relays the server-initiated messages of the remote session
+// (eg. resource updates) until ctx is cancelled.
+//
+// Interrupted streams are resumed with the last received event id.
+func (t *ProxyTransport) listen(ctx context.Context, sessionID string) {
+	var lastEventId string
+
+	for ctx.Err() == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.endpoint, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Authorization", t.token)
+		req.Header.Set(sessionIdHeader, sessionID)
+		if lastEventId != "" {
+			req.Header.Set(lastEventIdHeader, lastEventId)
+		}
+
+		resp, err := t.client.Do(req)
+		if err == nil {
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				if resp.StatusCode < 500 {
+					return // not supported or the session has expired
+				}
+			} else {
+				err = readSSEEvents(resp.Body, func(id, data string) {
+					if id != "" {
+						lastEventId = id
+					}
+					t.writeLine([]byte(data))
+				})
+				resp.Body.Close()
+			}
+		}
+
+		if err != nil && ctx.Err() == nil {
+			log.Printf("remote MCP session stream error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(proxyReconnectDelay):
+		}
+	}
+}
+
+// session returns the current remote session id (if any).
+func (t *ProxyTransport) session() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.sessionID
+}
+
+// startSession stores the new remote session id and starts listening
+// for its server-initiated messages.
+func (t *ProxyTransport) startSession(ctx context.Context, sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopListen != nil {
+		t.stopListen()
+	}
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	t.sessionID = sessionID
+	t.stopListen = cancel
+
+	go t.listen(listenCtx, sessionID)
+}
+
+// resetSession forgets the expired remote session
+// (the client has to initialize a new one).
+func (t *ProxyTransport) resetSession(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sessionID != sessionID {
+		return // already replaced
+	}
+
+	if t.stopListen != nil {
+		t.stopListen()
+		t.stopListen = nil
+	}
+	t.sessionID = ""
+}
+
+// closeSession terminates the remote session (if any).
+func (t *ProxyTransport) closeSession() {
+	sessionID := t.session()
+	if sessionID == "" {
+		return
+	}
+	t.resetSession(sessionID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.endpoint, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", t.token)
+	req.Header.Set(sessionIdHeader, sessionID)
+
+	if resp, err := t.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// writeErrors writes an error response for each of the forwarded
+// requests (the notifications are skipped).
+func (t *ProxyTransport) writeErrors(requests []*JSONRPCRequest, err error) {
+	for _, req := range requests {
+		if req.ID == nil || req.Method == "" {
+			continue
+		}
+
+		t.writeMessage(&JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &RPCError{
+				Code:    InternalError,
+				Message: "Remote MCP server error: " + err.Error(),
+			},
+		})
+	}
+}
+
+func (t *ProxyTransport) writeMessage(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	t.writeLine(data)
+}
+
+// writeLine writes a single message line (safe for concurrent use).
+func (t *ProxyTransport) writeLine(data []byte) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	if _, err := fmt.Fprintf(t.writer, "%s\n", data); err != nil {
+		log.Printf("failed to write message: %v", err)
+	}
+}
+
+// remoteError extracts the error message of a failed remote request.
+func remoteError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var body struct {
+		Error   interface{} `json:"error"`
+		Message string      `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &body); err == nil {
+		switch v := body.Error.(type) {
+		case string:
+			return fmt.Errorf("%s (status %d)", v, resp.StatusCode)
+		case map[string]interface{}:
+			if msg, ok := v["message"].(string); ok {
+				return fmt.Errorf("%s (status %d)", msg, resp.StatusCode)
+			}
+		}
+		if body.Message != "" {
+			return fmt.Errorf("%s (status %d)", body.Message, resp.StatusCode)
+		}
+	}
+
+	return fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// hasMethod reports whether any of the messages is a call of the method.
+func hasMethod(requests []*JSONRPCRequest, method string) bool {
+	for _, req := range requests {
+		if req.Method == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readSSEEvents reads the SSE stream and calls fn for each event
+// with its id (if any) and data.
+func readSSEEvents(r io.Reader, fn func(id, data string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+
+	var id string
+	var data []string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if len(data) > 0 {
+				fn(id, strings.Join(data, "\n"))
+			}
+			id = ""
+			data = data[:0]
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue // comment (eg. keepalive)
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "id":
+			id = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	return scanner.Err()
+}
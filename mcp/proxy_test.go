@@ -0,0 +1,171 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewProxyTransport(t *testing.T) {
+	scenarios := []struct {
+		url         string
+		token       string
+		expectError bool
+		endpoint    string
+	}{
+		{"", "mcp_test", true, ""},
+		{"ftp://example.com", "mcp_test", true, ""},
+		{"https://", "mcp_test", true, ""},
+		{"https://example.com", "", true, ""},
+		{"https://example.com", "mcp_test", false, "https://example.com/api/mcp/stream"},
+		{" http://example.com/base/ ", "mcp_test", false, "http://example.com/base/api/mcp/stream"},
+	}
+
+	for i, s := range scenarios {
+		proxy, err := NewProxyTransport(s.url, s.token)
+		if (err != nil) != s.expectError {
+			t.Errorf("[%d] expected error %v, got %v", i, s.expectError, err)
+			continue
+		}
+		if err == nil && proxy.endpoint != s.endpoint {
+			t.Errorf("[%d] expected endpoint %q, got %q", i, s.endpoint, proxy.endpoint)
+		}
+	}
+}
+
+func TestReadSSEEvents(t *testing.T) {
+	stream := ": keepalive\n\nid: 1\nevent: message\ndata: {\"a\":1}\n\nevent: message\ndata: line1\ndata: line2\n\nid: 2\n\n"
+
+	var result []string
+	err := readSSEEvents(strings.NewReader(stream), func(id, data string) {
+		result = append(result, id+"|"+data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`1|{"a":1}`, "|line1\nline2"}
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestProxyTransportRun(t *testing.T) {
+	var mu sync.Mutex
+	var deleted string
+
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "mcp_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Authentication required"}`))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		case http.MethodDelete:
+			mu.Lock()
+			deleted = r.Header.Get(sessionIdHeader)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		switch {
+		case strings.Contains(string(body), `"initialize"`):
+			w.Header().Set(sessionIdHeader, "s1")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{\n  \"jsonrpc\": \"2.0\",\n  \"id\": 1,\n  \"result\": {}\n}"))
+		case r.Header.Get(sessionIdHeader) != "s1":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"MCP session not found"}`))
+		case strings.Contains(string(body), `"tools/call"`):
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n"))
+			w.Write([]byte("event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":2,\"result\":{}}\n\n"))
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer remote.Close()
+
+	proxy, err := NewProxyTransport(remote.URL, "mcp_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`invalid`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"list_collections"}}`,
+	}, "\n")
+
+	output := &bytes.Buffer{}
+	proxy.reader = bufio.NewReader(strings.NewReader(input))
+	proxy.writer = output
+
+	if err := proxy.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+
+	expected := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{}}`,
+		`Parse error`,
+		`{"jsonrpc":"2.0","method":"notifications/progress"}`,
+		`{"jsonrpc":"2.0","id":2,"result":{}}`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(expected), len(lines), output.String())
+	}
+	for i, e := range expected {
+		if !strings.Contains(lines[i], e) {
+			t.Errorf("[%d] expected %s, got %s", i, e, lines[i])
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if deleted != "s1" {
+		t.Fatalf("expected the remote session to be deleted, got %q", deleted)
+	}
+}
+
+func TestProxyTransportRemoteErrors(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"Authentication required"}`))
+	}))
+	defer remote.Close()
+
+	proxy, err := NewProxyTransport(remote.URL, "mcp_invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := &bytes.Buffer{}
+	proxy.reader = bufio.NewReader(strings.NewReader(
+		`{"jsonrpc":"2.0","id":"a","method":"ping"}` + "\n" + `{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+	))
+	proxy.writer = output
+
+	if err := proxy.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"jsonrpc":"2.0","id":"a","error":{"code":-32603,"message":"Remote MCP server error: Authentication required (status 401)"}}` + "\n"
+	if output.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, output.String())
+	}
+}
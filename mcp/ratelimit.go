@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zhenruyan/postgrebase/core"
+)
+
+// rateLimitWindow is the MCP token rate limits window.
+const rateLimitWindow = time.Minute
+
+var (
+	errRateLimited        = errors.New("the MCP token tool calls rate limit is exceeded, try again later")
+	errDestructiveLimited = errors.New("the MCP token destructive operations limit is exceeded, try again later")
+)
+
+// rateLimiter counts the calls of a key within fixed time windows.
+type rateLimiter interface {
+	// Allow increments the key counter of the current window and
+	// reports whether it is still within the limit.
+	Allow(key string, limit int, window time.Duration) (bool, error)
+}
+
+// newRateLimiter returns a Redis backed limiter (shared between the app nodes)
+// if Redis is configured, otherwise an in-memory one.
+func newRateLimiter(app core.App) rateLimiter {
+	if app != nil && app.RedisCache() != nil {
+		return &redisRateLimiter{client: app.RedisCache()}
+	}
+
+	return newMemoryRateLimiter()
+}
+
+// -------------------------------------------------------------------
+
+type rateCounter struct {
+	window int64
+	count  int
+}
+
+type memoryRateLimiter struct {
+	mu       sync.Mutex
+	counters map[string]*rateCounter
+}
+
+func newMemoryRateLimiter() *memoryRateLimiter {
+	return &memoryRateLimiter{counters: map[string]*rateCounter{}}
+}
+
+func (m *memoryRateLimiter) Allow(key string, limit int, window time.Duration) (bool, error) {
+	current := time.Now().UnixNano() / int64(window)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, ok := m.counters[key]
+	if !ok || counter.window != current {
+		// drop the stale counters
+		for k, c := range m.counters {
+			if c.window != current {
+				delete(m.counters, k)
+			}
+		}
+
+		counter = &rateCounter{window: current}
+		m.counters[key] = counter
+	}
+
+	counter.count++
+
+	return counter.count <= limit, nil
+}
+
+// -------------------------------------------------------------------
+
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+func (r *redisRateLimiter) Allow(key string, limit int, window time.Duration) (bool, error) {
+	ctx := context.Background()
+
+	current := time.Now().UnixNano() / int64(window)
+	redisKey := fmt.Sprintf("pb_mcp_ratelimit:%s:%d", key, current)
+
+	count, err := r.client.Incr(ctx, redisKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if count == 1 {
+		r.client.Expire(ctx, redisKey, window)
+	}
+
+	return count <= int64(limit), nil
+}
+
+// -------------------------------------------------------------------
+
+// checkRateLimits enforces the MCP token rate limits for the tool call.
+//
+// Limiter failures (eg. Redis connection errors) don't block the call.
+func (s *Server) checkRateLimits(auth *AuthInfo, tool string) error {
+	if auth == nil || !auth.IsMCPToken || s.limiter == nil {
+		return nil
+	}
+
+	if auth.RateLimit > 0 {
+		allowed, err := s.limiter.Allow("calls:"+auth.TokenId, auth.RateLimit, rateLimitWindow)
+		if err == nil && !allowed {
+			return errRateLimited
+		}
+	}
+
+	if auth.DestructiveLimit > 0 && s.destructiveTools[tool] {
+		allowed, err := s.limiter.Allow("destructive:"+auth.TokenId, auth.DestructiveLimit, rateLimitWindow)
+		if err == nil && !allowed {
+			return errDestructiveLimited
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	limiter := newMemoryRateLimiter()
+
+	for i := 1; i <= 4; i++ {
+		allowed, err := limiter.Allow("test", 3, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i <= 3; allowed != want {
+			t.Fatalf("call %d: expected allowed %v, got %v", i, want, allowed)
+		}
+	}
+
+	// other keys have their own counters
+	if allowed, _ := limiter.Allow("other", 1, time.Hour); !allowed {
+		t.Fatal("expected the other key to be allowed")
+	}
+}
+
+func TestServerCheckRateLimits(t *testing.T) {
+	s := &Server{
+		limiter:          newMemoryRateLimiter(),
+		destructiveTools: map[string]bool{"delete_record": true},
+	}
+
+	limited := &AuthInfo{IsMCPToken: true, TokenId: "t1", RateLimit: 2, DestructiveLimit: 1}
+
+	if err := s.checkRateLimits(limited, "delete_record"); err != nil {
+		t.Fatalf("expected the first destructive call to be allowed, got %v", err)
+	}
+	if err := s.checkRateLimits(limited, "delete_record"); err != errDestructiveLimited {
+		t.Fatalf("expected errDestructiveLimited, got %v", err)
+	}
+	if err := s.checkRateLimits(limited, "list_records"); err != errRateLimited {
+		t.Fatalf("expected errRateLimited, got %v", err)
+	}
+
+	// admins and unlimited tokens are never limited
+	unlimited := &AuthInfo{IsMCPToken: true, TokenId: "t2"}
+	for i := 0; i < 10; i++ {
+		if err := s.checkRateLimits(unlimited, "delete_record"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.checkRateLimits(&AuthInfo{Local: true}, "delete_record"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	if redactArgs(nil) != nil {
+		t.Fatal("expected nil for empty args")
+	}
+
+	args := map[string]interface{}{
+		"collection": "users",
+		"data": map[string]interface{}{
+			"email":           "test@example.com",
+			"password":        "123456",
+			"passwordConfirm": "123456",
+			"bio":             strings.Repeat("a", maxAuditArgLength+10),
+		},
+		"items": []interface{}{map[string]interface{}{"apiKey": "abc"}},
+	}
+
+	redacted := redactArgs(args)
+
+	if redacted["collection"] != "users" {
+		t.Errorf("unexpected collection %v", redacted["collection"])
+	}
+
+	data := redacted["data"].(map[string]interface{})
+	if data["email"] != "test@example.com" {
+		t.Errorf("unexpected email %v", data["email"])
+	}
+	if data["password"] != "***" || data["passwordConfirm"] != "***" {
+		t.Errorf("expected the passwords to be masked, got %v", data)
+	}
+	if bio := data["bio"].(string); len(bio) != maxAuditArgLength+3 {
+		t.Errorf("expected the bio to be truncated, got length %d", len(bio))
+	}
+
+	item := redacted["items"].([]interface{})[0].(map[string]interface{})
+	if item["apiKey"] != "***" {
+		t.Errorf("expected the nested apiKey to be masked, got %v", item["apiKey"])
+	}
+
+	// the original args must remain unchanged
+	if args["data"].(map[string]interface{})["password"] != "123456" {
+		t.Error("the original args were modified")
+	}
+}
@@ -1,20 +1,81 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/zhenruyan/postgrebase/models"
 )
 
+// resourceTemplate is a parameterized resource resolved by URI prefix.
+type resourceTemplate struct {
+	ResourceTemplate
+	prefix  string
+	handler ResourceHandler
+}
+
 // registerResources registers all available resources
 func (s *Server) registerResources() {
 	s.resources["postgrebase://collections"] = s.resourceCollections
 	s.resources["postgrebase://settings"] = s.resourceSettings
+
+	s.resourceTemplates = []resourceTemplate{
+		{
+			ResourceTemplate: ResourceTemplate{
+				URITemplate: "postgrebase://collections/{name}",
+				Name:        "Collection",
+				Description: "A single collection with its schema and API rules",
+				MimeType:    "application/json",
+			},
+			prefix:  "postgrebase://collections/",
+			handler: s.resolveCollectionResource,
+		},
+		{
+			ResourceTemplate: ResourceTemplate{
+				URITemplate: "postgrebase://records/{collection}/{id}",
+				Name:        "Record",
+				Description: "A single record (subject to the collection view rule)",
+				MimeType:    "application/json",
+			},
+			prefix:  "postgrebase://records/",
+			handler: s.resolveRecordResource,
+		},
+		{
+			ResourceTemplate: ResourceTemplate{
+				URITemplate: "postgrebase://files/{collection}/{id}/{filename}",
+				Name:        "Record file",
+				Description: "A record file as a base64 encoded blob (protected files require view access)",
+			},
+			prefix:  fileResourcePrefix,
+			handler: s.resolveFileResource,
+		},
+	}
+}
+
+// findResourceHandler returns the handler of a static resource or of the
+// first resource template matching the uri.
+func (s *Server) findResourceHandler(uri string) (ResourceHandler, bool) {
+	if handler, ok := s.resources[uri]; ok {
+		return handler, true
+	}
+
+	for _, t := range s.resourceTemplates {
+		if strings.HasPrefix(uri, t.prefix) && len(uri) > len(t.prefix) {
+			return t.handler, true
+		}
+	}
+
+	return nil, false
 }
 
 // resourceCollections returns all collections with their schemas
-func (s *Server) resourceCollections(uri string) (*ResourceReadResult, error) {
+func (s *Server) resourceCollections(auth *AuthInfo, uri string) (*ResourceReadResult, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
 	collections := []*models.Collection{}
 	err := s.app.Dao().CollectionQuery().All(&collections)
 	if err != nil {
@@ -35,6 +96,10 @@ func (s *Server) resourceCollections(uri string) (*ResourceReadResult, error) {
 
 	result := make([]CollectionInfo, 0, len(collections))
 	for _, c := range collections {
+		if !auth.allowsCollection(c) {
+			continue
+		}
+
 		info := CollectionInfo{
 			ID:          c.Id,
 			Name:        c.Name,
@@ -62,7 +127,11 @@ func (s *Server) resourceCollections(uri string) (*ResourceReadResult, error) {
 }
 
 // resourceSettings returns sanitized application settings
-func (s *Server) resourceSettings(uri string) (*ResourceReadResult, error) {
+func (s *Server) resourceSettings(auth *AuthInfo, uri string) (*ResourceReadResult, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
 	settings := s.app.Settings()
 
 	// Build sanitized settings (remove sensitive data)
@@ -98,10 +167,14 @@ func (s *Server) resourceSettings(uri string) (*ResourceReadResult, error) {
 
 // resolveCollectionResource handles dynamic collection resources
 // e.g., postgrebase://collections/users
-func (s *Server) resolveCollectionResource(uri string) (*ResourceReadResult, error) {
+func (s *Server) resolveCollectionResource(auth *AuthInfo, uri string) (*ResourceReadResult, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
 	parts := strings.Split(uri, "/")
-	if len(parts) < 4 {
-		return nil, nil
+	if len(parts) != 4 || parts[3] == "" {
+		return nil, fmt.Errorf("invalid collection resource uri: %s", uri)
 	}
 
 	collectionName := parts[3]
@@ -110,6 +183,10 @@ func (s *Server) resolveCollectionResource(uri string) (*ResourceReadResult, err
 		return nil, err
 	}
 
+	if !auth.allowsCollection(collection) {
+		return nil, errScopeDenied
+	}
+
 	data, _ := json.MarshalIndent(collection, "", "  ")
 
 	return &ResourceReadResult{
@@ -122,3 +199,36 @@ func (s *Server) resolveCollectionResource(uri string) (*ResourceReadResult, err
 		},
 	}, nil
 }
+
+// resolveRecordResource handles dynamic record resources
+// e.g., postgrebase://records/posts/abc123
+func (s *Server) resolveRecordResource(auth *AuthInfo, uri string) (*ResourceReadResult, error) {
+	parts := strings.Split(uri, "/")
+	if len(parts) != 5 || parts[3] == "" || parts[4] == "" {
+		return nil, fmt.Errorf("invalid record resource uri: %s", uri)
+	}
+
+	// reuse the get_record tool so that the same rules and scopes apply
+	result, err := s.toolGetRecord(context.Background(), auth, map[string]interface{}{
+		"collection": parts[3],
+		"id":         parts[4],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text := ""
+	if len(result.Content) > 0 {
+		text = result.Content[0].Text
+	}
+
+	return &ResourceReadResult{
+		Contents: []ResourceContent{
+			{
+				URI:      uri,
+				MimeType: "application/json",
+				Text:     text,
+			},
+		},
+	}, nil
+}
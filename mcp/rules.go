@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/forms"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/search"
+)
+
+// errAdminOnly is returned when a non-admin caller hits a collection
+// action whose API rule is nil (locked to admins).
+var errAdminOnly = errors.New("only admins can perform this action")
+
+// requireAdmin rejects callers that are not admins (or admin-issued MCP tokens).
+func requireAdmin(auth *AuthInfo) error {
+	if !auth.IsAdmin() {
+		return errAdminOnly
+	}
+	return nil
+}
+
+// checkRule mirrors the REST record api guard: a nil rule means the
+// action is restricted to admins.
+func checkRule(auth *AuthInfo, rule *string) error {
+	if !auth.IsAdmin() && rule == nil {
+		return errAdminOnly
+	}
+	return nil
+}
+
+// checkForbiddenQueryFields forbids non-admins to filter or sort by the
+// special @collection.* and @request.* identifiers (same as the REST api).
+func checkForbiddenQueryFields(auth *AuthInfo, exprs ...string) error {
+	if auth.IsAdmin() {
+		return nil
+	}
+
+	joined := strings.Join(exprs, "")
+	for _, field := range []string{"@collection.", "@request."} {
+		if strings.Contains(joined, field) {
+			return errors.New("only admins can filter by @collection and @request fields")
+		}
+	}
+
+	return nil
+}
+
+// ruleFunc returns a FindRecordById filter that applies the provided
+// collection rule for non-admin callers.
+func (s *Server) ruleFunc(
+	dao *daos.Dao,
+	collection *models.Collection,
+	rule *string,
+	requestInfo *models.RequestInfo,
+	auth *AuthInfo,
+) func(q *dbx.SelectQuery) error {
+	return func(q *dbx.SelectQuery) error {
+		if auth.IsAdmin() || rule == nil || *rule == "" {
+			return nil
+		}
+
+		resolver := resolvers.NewRecordFieldResolver(dao, collection, requestInfo, true)
+		expr, err := search.FilterData(*rule).BuildExpr(resolver)
+		if err != nil {
+			return err
+		}
+		resolver.UpdateQuery(q)
+		q.AndWhere(expr)
+
+		return nil
+	}
+}
+
+// hasAuthManageAccess checks whether the caller satisfies the auth
+// collection manageRule for the provided record.
+func (s *Server) hasAuthManageAccess(dao *daos.Dao, record *models.Record, requestInfo *models.RequestInfo) bool {
+	if !record.Collection().IsAuth() {
+		return false
+	}
+
+	manageRule := record.Collection().AuthOptions().ManageRule
+	if manageRule == nil || *manageRule == "" {
+		return false // only for admins (manageRule can't be empty)
+	}
+
+	if requestInfo == nil || requestInfo.AuthRecord == nil {
+		return false // no auth record
+	}
+
+	_, err := dao.FindRecordById(
+		record.Collection().Id,
+		record.Id,
+		s.ruleFunc(dao, record.Collection(), manageRule, requestInfo, nil),
+	)
+
+	return err == nil
+}
+
+// upsertRecordAsUser validates and persists the submitted data through a
+// [forms.RecordUpsert] the same way the REST record api does for
+// non-admin callers, enforcing the collection create/update rule.
+//
+// The optional conflictFields are used as new record conflict target
+// (see [forms.RecordUpsert.SetConflictFields]).
+func (s *Server) upsertRecordAsUser(auth *AuthInfo, record *models.Record, data map[string]interface{}, conflictFields ...string) error {
+	collection := record.Collection()
+	isNew := record.IsNew()
+
+	method := "PATCH"
+	if isNew {
+		method = "POST"
+	}
+	requestInfo := auth.requestInfo(method, data)
+
+	hasFullManageAccess := false
+
+	if isNew {
+		// temporary save the record and check it against the create rule
+		testRecord := models.NewRecord(collection)
+		testForm := forms.NewRecordUpsert(s.app, testRecord)
+		testForm.SetFullManageAccess(true)
+		if err := testForm.LoadData(testRecord.ReplaceModifers(data)); err != nil {
+			return err
+		}
+
+		testErr := testForm.DrySubmit(func(txDao *daos.Dao) error {
+			foundRecord, err := txDao.FindRecordById(
+				collection.Id,
+				testRecord.Id,
+				s.ruleFunc(txDao, collection, collection.CreateRule, requestInfo, auth),
+			)
+			if err != nil {
+				return fmt.Errorf("the create rule is not satisfied: %w", err)
+			}
+			hasFullManageAccess = s.hasAuthManageAccess(txDao, foundRecord, requestInfo)
+			return nil
+		})
+		if testErr != nil {
+			return testErr
+		}
+	} else {
+		hasFullManageAccess = s.hasAuthManageAccess(s.app.Dao(), record, requestInfo)
+	}
+
+	form := forms.NewRecordUpsert(s.app, record)
+	form.SetFullManageAccess(hasFullManageAccess)
+	form.SetConflictFields(conflictFields...)
+	if s.app.IsSQLiteCluster() {
+		form.SetSaveFunc(func(_ *daos.Dao, r *models.Record) error {
+			return s.saveRecord(r)
+		})
+	}
+
+	if err := form.LoadData(data); err != nil {
+		return err
+	}
+
+	return form.Submit()
+}
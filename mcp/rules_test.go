@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+func TestAuthInfoIsAdmin(t *testing.T) {
+	cases := []struct {
+		name string
+		auth *AuthInfo
+		want bool
+	}{
+		{"guest", nil, false},
+		{"auth record", &AuthInfo{Record: &models.Record{}}, false},
+		{"admin", &AuthInfo{Admin: &models.Admin{}}, true},
+		{"mcp token", &AuthInfo{IsMCPToken: true}, true},
+		{"local stdio", &AuthInfo{Local: true}, true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.auth.IsAdmin(); got != tc.want {
+			t.Errorf("%s: IsAdmin() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckRule(t *testing.T) {
+	empty := ""
+	rule := "owner = @request.auth.id"
+	user := &AuthInfo{Record: &models.Record{}}
+	admin := &AuthInfo{Admin: &models.Admin{}}
+
+	cases := []struct {
+		name    string
+		auth    *AuthInfo
+		rule    *string
+		wantErr bool
+	}{
+		{"guest with nil rule", nil, nil, true},
+		{"user with nil rule", user, nil, true},
+		{"admin with nil rule", admin, nil, false},
+		{"guest with empty rule", nil, &empty, false},
+		{"user with rule", user, &rule, false},
+	}
+
+	for _, tc := range cases {
+		err := checkRule(tc.auth, tc.rule)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestCheckForbiddenQueryFields(t *testing.T) {
+	user := &AuthInfo{Record: &models.Record{}}
+	admin := &AuthInfo{Admin: &models.Admin{}}
+
+	if err := checkForbiddenQueryFields(user, `title ~ "a"`, "-created"); err != nil {
+		t.Errorf("expected regular filter to be allowed, got %v", err)
+	}
+	if err := checkForbiddenQueryFields(user, `@collection.users.id != ""`); err == nil {
+		t.Error("expected @collection filter to be rejected for non-admins")
+	}
+	if err := checkForbiddenQueryFields(nil, "", "@request.auth.id"); err == nil {
+		t.Error("expected @request sort to be rejected for guests")
+	}
+	if err := checkForbiddenQueryFields(admin, `@collection.users.id != ""`); err != nil {
+		t.Errorf("expected admins to filter by anything, got %v", err)
+	}
+}
+
+func TestAuthInfoRequestInfo(t *testing.T) {
+	record := &models.Record{}
+	auth := &AuthInfo{Record: record}
+
+	info := auth.requestInfo("POST", map[string]interface{}{"title": "test"})
+	if info.Method != "POST" {
+		t.Errorf("expected method POST, got %q", info.Method)
+	}
+	if info.AuthRecord != record {
+		t.Error("expected the auth record to be set")
+	}
+	if info.Data["title"] != "test" {
+		t.Errorf("expected data to be copied, got %v", info.Data)
+	}
+
+	guest := (*AuthInfo)(nil).requestInfo("GET", nil)
+	if guest.AuthRecord != nil || guest.Admin != nil {
+		t.Error("expected empty auth state for guests")
+	}
+}
@@ -0,0 +1,512 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zhenruyan/postgrebase/forms"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/replication"
+	"github.com/zhenruyan/postgrebase/tools/dbutils"
+)
+
+// schemaTools are the schema-management tools.
+//
+// MCP tokens could call them only with a scope that has "schema" enabled
+// (including the tokens without scopes).
+var schemaTools = map[string]bool{
+	"create_collection": true,
+	"update_collection": true,
+	"delete_collection": true,
+	"add_field":         true,
+	"set_rules":         true,
+	"create_index":      true,
+}
+
+// collectionRuleKeys are the tool arguments accepted by set_rules.
+var collectionRuleKeys = []string{"listRule", "viewRule", "createRule", "updateRule", "deleteRule"}
+
+var ruleArgSchema = map[string]interface{}{
+	"type":        []string{"string", "null"},
+	"description": "API rule filter expression (\"\" allows everyone, null allows only admins)",
+}
+
+var collectionArgSchema = map[string]interface{}{
+	"type":        "string",
+	"description": "Collection name or ID",
+}
+
+var schemaToolDefs = []Tool{
+	{
+		Name:        "create_collection",
+		Description: "Create a new collection with its fields, indexes and API rules",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Collection name (letters, digits and underscores)",
+				},
+				"type": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{models.CollectionTypeBase, models.CollectionTypeAuth, models.CollectionTypeView},
+					"description": "Collection type (default: base)",
+				},
+				"schema": map[string]interface{}{
+					"type":        "array",
+					"description": "Collection fields, eg. [{\"name\": \"title\", \"type\": \"text\", \"required\": true, \"options\": {}}]",
+					"items":       map[string]interface{}{"type": "object"},
+				},
+				"indexes": map[string]interface{}{
+					"type":        "array",
+					"description": "Raw CREATE INDEX statements",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+				"listRule":   ruleArgSchema,
+				"viewRule":   ruleArgSchema,
+				"createRule": ruleArgSchema,
+				"updateRule": ruleArgSchema,
+				"deleteRule": ruleArgSchema,
+				"options": map[string]interface{}{
+					"type":        "object",
+					"description": "Type specific options (eg. {\"query\": \"SELECT ...\"} for view collections)",
+				},
+				"project": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional project the collection belongs to",
+				},
+			},
+			"required": []string{"name"},
+		},
+	},
+	{
+		Name:        "update_collection",
+		Description: "Update a collection. Only the provided properties are changed; the schema, if provided, replaces all fields",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"collection": collectionArgSchema,
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "New collection name",
+				},
+				"schema": map[string]interface{}{
+					"type":        "array",
+					"description": "The full list of collection fields (keep the existing field ids to preserve their data)",
+					"items":       map[string]interface{}{"type": "object"},
+				},
+				"indexes": map[string]interface{}{
+					"type":        "array",
+					"description": "The full list of raw CREATE INDEX statements",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+				"listRule":   ruleArgSchema,
+				"viewRule":   ruleArgSchema,
+				"createRule": ruleArgSchema,
+				"updateRule": ruleArgSchema,
+				"deleteRule": ruleArgSchema,
+				"options": map[string]interface{}{
+					"type":        "object",
+					"description": "Type specific options",
+				},
+			},
+			"required": []string{"collection"},
+		},
+	},
+	{
+		Name:        "delete_collection",
+		Description: "Delete a collection together with all of its records",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"collection": collectionArgSchema,
+			},
+			"required": []string{"collection"},
+		},
+	},
+	{
+		Name:        "add_field",
+		Description: "Add a new field to a collection schema",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"collection": collectionArgSchema,
+				"field": map[string]interface{}{
+					"type":        "object",
+					"description": "Field definition, eg. {\"name\": \"status\", \"type\": \"select\", \"options\": {\"maxSelect\": 1, \"values\": [\"draft\", \"published\"]}}",
+				},
+			},
+			"required": []string{"collection", "field"},
+		},
+	},
+	{
+		Name:        "set_rules",
+		Description: "Set the API rules of a collection. Only the provided rules are changed",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"collection": collectionArgSchema,
+				"listRule":   ruleArgSchema,
+				"viewRule":   ruleArgSchema,
+				"createRule": ruleArgSchema,
+				"updateRule": ruleArgSchema,
+				"deleteRule": ruleArgSchema,
+			},
+			"required": []string{"collection"},
+		},
+	},
+	{
+		Name:        "create_index",
+		Description: "Create an index on one or more collection columns",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"collection": collectionArgSchema,
+				"columns": map[string]interface{}{
+					"type":        "array",
+					"description": "Indexed column names",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+				"unique": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Create a unique index",
+				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional index name (default: idx_{collection}_{columns})",
+				},
+			},
+			"required": []string{"collection", "columns"},
+		},
+	},
+}
+
+// registerSchemaTools registers the schema-management tools.
+func (s *Server) registerSchemaTools() {
+	s.tools["create_collection"] = s.toolCreateCollection
+	s.tools["update_collection"] = s.toolUpdateCollection
+	s.tools["delete_collection"] = s.toolDeleteCollection
+	s.tools["add_field"] = s.toolAddField
+	s.tools["set_rules"] = s.toolSetRules
+	s.tools["create_index"] = s.toolCreateIndex
+}
+
+// toolCreateCollection creates a new collection
+func (s *Server) toolCreateCollection(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
+	collection := &models.Collection{}
+	form := forms.NewCollectionUpsert(s.app, collection)
+	if err := loadCollectionForm(form, args); err != nil {
+		return nil, err
+	}
+
+	// pin the collection to the scope project (if any)
+	if form.Project == nil || *form.Project == "" {
+		for _, sc := range auth.Scopes {
+			if sc.Schema && sc.Project != "" {
+				project := sc.Project
+				form.Project = &project
+				break
+			}
+		}
+	}
+
+	candidate := &models.Collection{Project: form.Project}
+	candidate.Name = form.Name
+	if _, err := auth.collectionScope("create_collection", candidate, true); err != nil {
+		return nil, err
+	}
+
+	if err := s.submitCollectionForm(form, true); err != nil {
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	return collectionResult(collection)
+}
+
+// toolUpdateCollection updates the provided collection properties
+func (s *Server) toolUpdateCollection(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	collection, err := s.schemaToolCollection(auth, "update_collection", args)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if k != "collection" {
+			data[k] = v
+		}
+	}
+
+	form := forms.NewCollectionUpsert(s.app, collection)
+	if err := loadCollectionForm(form, data); err != nil {
+		return nil, err
+	}
+
+	// a renamed collection must remain within the token scopes
+	renamed := &models.Collection{Project: form.Project}
+	renamed.Id = collection.Id
+	renamed.Name = form.Name
+	if _, err := auth.collectionScope("update_collection", renamed, true); err != nil {
+		return nil, err
+	}
+
+	if err := s.submitCollectionForm(form, false); err != nil {
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+
+	return collectionResult(collection)
+}
+
+// toolDeleteCollection deletes a collection
+func (s *Server) toolDeleteCollection(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	collection, err := s.schemaToolCollection(auth, "delete_collection", args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.deleteCollection(collection); err != nil {
+		return nil, fmt.Errorf("failed to delete collection: %w", err)
+	}
+
+	return &ToolCallResult{
+		Content: []Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Collection %s deleted successfully", collection.Name),
+			},
+		},
+	}, nil
+}
+
+// toolAddField appends a new field to the collection schema
+func (s *Server) toolAddField(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	collection, err := s.schemaToolCollection(auth, "add_field", args)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.IsView() {
+		return nil, fmt.Errorf("the fields of view collections are generated from their query")
+	}
+
+	rawField, ok := args["field"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field parameter is required")
+	}
+
+	encoded, err := json.Marshal(rawField)
+	if err != nil {
+		return nil, err
+	}
+
+	field := &schema.SchemaField{}
+	if err := json.Unmarshal(encoded, field); err != nil {
+		return nil, fmt.Errorf("invalid field: %w", err)
+	}
+
+	if field.Name == "" {
+		return nil, fmt.Errorf("field name is required")
+	}
+
+	if collection.Schema.GetFieldByName(field.Name) != nil ||
+		(field.Id != "" && collection.Schema.GetFieldById(field.Id) != nil) {
+		return nil, fmt.Errorf("field %s already exists", field.Name)
+	}
+
+	form := forms.NewCollectionUpsert(s.app, collection)
+	form.Schema.AddField(field)
+
+	if err := s.submitCollectionForm(form, false); err != nil {
+		return nil, fmt.Errorf("failed to add field: %w", err)
+	}
+
+	return collectionResult(collection)
+}
+
+// toolSetRules changes the provided collection API rules
+func (s *Server) toolSetRules(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	collection, err := s.schemaToolCollection(auth, "set_rules", args)
+	if err != nil {
+		return nil, err
+	}
+
+	form := forms.NewCollectionUpsert(s.app, collection)
+
+	rules := map[string]**string{
+		"listRule":   &form.ListRule,
+		"viewRule":   &form.ViewRule,
+		"createRule": &form.CreateRule,
+		"updateRule": &form.UpdateRule,
+		"deleteRule": &form.DeleteRule,
+	}
+
+	var changed bool
+	for _, key := range collectionRuleKeys {
+		raw, ok := args[key]
+		if !ok {
+			continue
+		}
+
+		switch v := raw.(type) {
+		case nil:
+			*rules[key] = nil
+		case string:
+			rule := v
+			*rules[key] = &rule
+		default:
+			return nil, fmt.Errorf("%s must be a string or null", key)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil, fmt.Errorf("at least one of %s is required", strings.Join(collectionRuleKeys, ", "))
+	}
+
+	if err := s.submitCollectionForm(form, false); err != nil {
+		return nil, fmt.Errorf("failed to set rules: %w", err)
+	}
+
+	return collectionResult(collection)
+}
+
+// toolCreateIndex adds a new index to the collection
+func (s *Server) toolCreateIndex(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	collection, err := s.schemaToolCollection(auth, "create_index", args)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.IsView() {
+		return nil, fmt.Errorf("view collections can't have indexes")
+	}
+
+	rawColumns, _ := args["columns"].([]interface{})
+	columns := make([]string, 0, len(rawColumns))
+	for _, c := range rawColumns {
+		if name, ok := c.(string); ok && name != "" {
+			columns = append(columns, name)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("columns parameter is required")
+	}
+
+	index := dbutils.Index{
+		TableName: collection.Name,
+		IndexName: fmt.Sprintf("idx_%s_%s", collection.Name, strings.Join(columns, "_")),
+	}
+	if name, ok := args["name"].(string); ok && name != "" {
+		index.IndexName = name
+	}
+	if unique, ok := args["unique"].(bool); ok {
+		index.Unique = unique
+	}
+	for _, c := range columns {
+		index.Columns = append(index.Columns, dbutils.IndexColumn{Name: c})
+	}
+
+	for _, existing := range collection.Indexes {
+		if strings.EqualFold(dbutils.ParseIndex(existing).IndexName, index.IndexName) {
+			return nil, fmt.Errorf("index %s already exists", index.IndexName)
+		}
+	}
+
+	form := forms.NewCollectionUpsert(s.app, collection)
+	form.Indexes = append(form.Indexes, index.Build())
+
+	if err := s.submitCollectionForm(form, false); err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	return collectionResult(collection)
+}
+
+// schemaToolCollection loads the collection argument of a schema tool
+// and checks that the caller is allowed to change it.
+func (s *Server) schemaToolCollection(auth *AuthInfo, tool string, args map[string]interface{}) (*models.Collection, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
+	collectionName, ok := args["collection"].(string)
+	if !ok || collectionName == "" {
+		return nil, fmt.Errorf("collection parameter is required")
+	}
+
+	collection, err := s.app.Dao().FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("collection not found: %s", collectionName)
+	}
+
+	if _, err := auth.collectionScope(tool, collection, true); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// loadCollectionForm loads the tool arguments into the collection upsert form.
+func loadCollectionForm(form *forms.CollectionUpsert, data map[string]interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(encoded, form); err != nil {
+		return fmt.Errorf("invalid collection data: %w", err)
+	}
+
+	return nil
+}
+
+// submitCollectionForm validates and persists the collection form
+// (replicating the change in SQLite cluster mode).
+func (s *Server) submitCollectionForm(form *forms.CollectionUpsert, isNew bool) error {
+	return form.Submit(func(next forms.InterceptorNextFunc[*models.Collection]) forms.InterceptorNextFunc[*models.Collection] {
+		return func(collection *models.Collection) error {
+			if !s.app.IsSQLiteCluster() {
+				return next(collection)
+			}
+
+			op, err := replication.NewCollectionUpsertOperation(collection, isNew)
+			if err != nil {
+				return err
+			}
+
+			return s.proposeReplicated(op)
+		}
+	})
+}
+
+func (s *Server) deleteCollection(collection *models.Collection) error {
+	if !s.app.IsSQLiteCluster() {
+		return s.app.Dao().DeleteCollection(collection)
+	}
+
+	op, err := replication.NewCollectionDeleteOperation(collection)
+	if err != nil {
+		return err
+	}
+
+	return s.proposeReplicated(op)
+}
+
+func collectionResult(collection *models.Collection) (*ToolCallResult, error) {
+	data, _ := json.MarshalIndent(collection, "", "  ")
+	return &ToolCallResult{
+		Content: []Content{
+			{
+				Type: "text",
+				Text: string(data),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,268 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ganigeorgiev/fexpr"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/list"
+	"github.com/zhenruyan/postgrebase/tools/search"
+)
+
+// TokenScope is a single permission grant of a scoped MCP token.
+//
+// A token without scopes has full access. Otherwise a call is allowed
+// if at least one of its scopes allows the requested tool and collection.
+type TokenScope struct {
+	// Collections lists the allowed collection names or ids (empty means any).
+	Collections []string `json:"collections,omitempty"`
+
+	// Project restricts the scope to the collections of a single project.
+	Project string `json:"project,omitempty"`
+
+	// Tools lists the allowed tool names (empty means any).
+	Tools []string `json:"tools,omitempty"`
+
+	// ReadOnly disallows the write tools.
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// Filter is an optional filter expression injected into every record query.
+	Filter string `json:"filter,omitempty"`
+
+	// Schema allows the schema-management tools (create_collection, add_field, etc.).
+	Schema bool `json:"schema,omitempty"`
+}
+
+// Validate checks the scope for obvious misconfigurations.
+func (sc TokenScope) Validate() error {
+	if sc.Filter != "" {
+		if _, err := fexpr.Parse(sc.Filter); err != nil {
+			return fmt.Errorf("invalid scope filter %q: %w", sc.Filter, err)
+		}
+	}
+
+	for _, name := range sc.Tools {
+		if !isKnownToolName(name) {
+			return fmt.Errorf("unknown tool %q", name)
+		}
+	}
+
+	return nil
+}
+
+// toolInfo describes the scope related traits of a single tool.
+type toolInfo struct {
+	name string
+
+	// write indicates that the tool modifies data.
+	write bool
+
+	// schema indicates that the tool manages the collections schema
+	// (requires a scope with "schema" enabled).
+	schema bool
+
+	// unfiltered indicates that the tool accesses records without
+	// applying the scope filter (eg. the agent data tools).
+	unfiltered bool
+}
+
+// builtinTool returns the toolInfo of a built-in record or schema tool.
+func builtinTool(name string, write bool) toolInfo {
+	return toolInfo{name: name, write: write, schema: schemaTools[name]}
+}
+
+// allowsTool reports whether the scope grants access to the tool.
+func (sc *TokenScope) allowsTool(tool toolInfo) bool {
+	if tool.write && sc.ReadOnly {
+		return false
+	}
+
+	if tool.schema && !sc.Schema {
+		return false
+	}
+
+	if tool.unfiltered && sc.Filter != "" {
+		// the scope filter couldn't be enforced
+		return false
+	}
+
+	if isAgentToolName(tool.name) && len(sc.Collections) > 0 && sc.Project == "" {
+		// agent tools operate on whole projects
+		return false
+	}
+
+	return len(sc.Tools) == 0 || list.ExistInSlice(tool.name, sc.Tools)
+}
+
+// allowsCollection reports whether the scope grants access to the collection.
+func (sc *TokenScope) allowsCollection(collection *models.Collection) bool {
+	if sc.Project != "" && (collection.Project == nil || *collection.Project != sc.Project) {
+		return false
+	}
+
+	if len(sc.Collections) == 0 {
+		return true
+	}
+
+	return list.ExistInSlice(collection.Name, sc.Collections) ||
+		list.ExistInSlice(collection.Id, sc.Collections)
+}
+
+// ValidateTokenScopes validates all provided token scopes.
+func ValidateTokenScopes(scopes []TokenScope) error {
+	for i, sc := range scopes {
+		if err := sc.Validate(); err != nil {
+			return fmt.Errorf("scopes.%d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// errScopeDenied is returned when a scoped MCP token is not allowed to
+// perform the requested call.
+var errScopeDenied = errors.New("the MCP token scopes don't allow this action")
+
+// allowsTool reports whether the caller may list and call the tool.
+//
+// The schema tools require an explicit scope with "schema" enabled,
+// so MCP tokens without scopes can't call them.
+func (a *AuthInfo) allowsTool(tool toolInfo) bool {
+	if a == nil || len(a.Scopes) == 0 {
+		return !tool.schema || a == nil || !a.IsMCPToken
+	}
+
+	for i := range a.Scopes {
+		if a.Scopes[i].allowsTool(tool) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowsCollection reports whether any of the caller scopes grants
+// access to the provided collection (regardless of the tool).
+func (a *AuthInfo) allowsCollection(collection *models.Collection) bool {
+	if a == nil || len(a.Scopes) == 0 {
+		return true
+	}
+
+	for i := range a.Scopes {
+		if a.Scopes[i].allowsCollection(collection) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectionScope returns the first scope that allows the tool to
+// operate on the collection.
+//
+// It returns a nil scope (and no error) for the unscoped callers allowed to call the tool.
+func (a *AuthInfo) collectionScope(tool string, collection *models.Collection, write bool) (*TokenScope, error) {
+	info := builtinTool(tool, write)
+
+	if a == nil || len(a.Scopes) == 0 {
+		if !a.allowsTool(info) {
+			return nil, errScopeDenied
+		}
+		return nil, nil
+	}
+
+	for i := range a.Scopes {
+		sc := &a.Scopes[i]
+		if sc.allowsTool(info) && sc.allowsCollection(collection) {
+			return sc, nil
+		}
+	}
+
+	return nil, errScopeDenied
+}
+
+// projectScope returns the first scope that allows the agent tool to
+// operate on the project.
+//
+// It returns a nil scope (and no error) for the unscoped callers allowed to call the tool.
+func (a *AuthInfo) projectScope(tool toolInfo, project string) (*TokenScope, error) {
+	if a == nil || len(a.Scopes) == 0 {
+		if !a.allowsTool(tool) {
+			return nil, errScopeDenied
+		}
+		return nil, nil
+	}
+
+	for i := range a.Scopes {
+		sc := &a.Scopes[i]
+		if !sc.allowsTool(tool) {
+			continue
+		}
+		if sc.Project == "" || project == "" || sc.Project == project {
+			return sc, nil
+		}
+	}
+
+	return nil, errScopeDenied
+}
+
+// scopeFilterFunc returns a FindRecordById filter that applies the scope
+// filter (if any).
+func (s *Server) scopeFilterFunc(dao *daos.Dao, collection *models.Collection, scope *TokenScope) func(q *dbx.SelectQuery) error {
+	return func(q *dbx.SelectQuery) error {
+		if scope == nil || scope.Filter == "" {
+			return nil
+		}
+
+		resolver := resolvers.NewRecordFieldResolver(dao, collection, nil, true)
+		expr, err := search.FilterData(scope.Filter).BuildExpr(resolver)
+		if err != nil {
+			return err
+		}
+		resolver.UpdateQuery(q)
+		q.AndWhere(expr)
+
+		return nil
+	}
+}
+
+// errDryRun is used to rollback the scope filter dry-run transaction.
+var errDryRun = errors.New("dry run")
+
+// checkScopeFilter temporary saves the record within a transaction and
+// verifies that it still matches the scope filter (if any).
+func (s *Server) checkScopeFilter(record *models.Record, scope *TokenScope) error {
+	if scope == nil || scope.Filter == "" {
+		return nil
+	}
+
+	isNew := record.IsNew()
+	collection := record.Collection()
+
+	dryDao := daos.New(s.app.Dao().NonconcurrentDB())
+	err := dryDao.RunInTransaction(func(txDao *daos.Dao) error {
+		if err := txDao.SaveRecord(record); err != nil {
+			return err
+		}
+
+		if _, err := txDao.FindRecordById(collection.Id, record.Id, s.scopeFilterFunc(txDao, collection, scope)); err != nil {
+			return errors.New("the record doesn't match the MCP token scope filter")
+		}
+
+		return errDryRun
+	})
+
+	// restore record isNew state
+	if isNew {
+		record.MarkAsNew()
+	}
+
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+func TestTokenScopeValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		scope   TokenScope
+		wantErr bool
+	}{
+		{"empty", TokenScope{}, false},
+		{"valid filter", TokenScope{Filter: `tenant = "acme"`}, false},
+		{"invalid filter", TokenScope{Filter: `tenant = `}, true},
+		{"builtin tool", TokenScope{Tools: []string{"list_records"}}, false},
+		{"agent tool", TokenScope{Tools: []string{"agent_schema_list_tables"}}, false},
+		{"unknown tool", TokenScope{Tools: []string{"drop_everything"}}, true},
+	}
+
+	for _, tc := range cases {
+		err := tc.scope.Validate()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestAuthInfoAllowsTool(t *testing.T) {
+	unscoped := &AuthInfo{IsMCPToken: true}
+	readOnly := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{ReadOnly: true}}}
+	limited := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Tools: []string{"get_record"}}}}
+	collectionsOnly := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Collections: []string{"posts"}}}}
+	schemaScope := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Schema: true}}}
+	readOnlySchema := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Schema: true, ReadOnly: true}}}
+	filtered := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Project: "blog", Filter: `owner = "x"`}}}
+	mixedFilter := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Project: "blog", Filter: `owner = "x"`}, {Project: "blog"}}}
+
+	cases := []struct {
+		name       string
+		auth       *AuthInfo
+		tool       string
+		write      bool
+		unfiltered bool
+		want       bool
+	}{
+		{"guest", nil, "delete_record", true, false, true},
+		{"unscoped write", unscoped, "delete_record", true, false, true},
+		{"read-only read", readOnly, "list_records", false, false, true},
+		{"read-only write", readOnly, "create_record", true, false, false},
+		{"listed tool", limited, "get_record", false, false, true},
+		{"unlisted tool", limited, "list_records", false, false, false},
+		{"collection scope agent tool", collectionsOnly, "agent_schema_list_tables", false, false, false},
+		{"collection scope builtin tool", collectionsOnly, "list_records", false, false, true},
+		{"unscoped schema tool", unscoped, "create_collection", true, false, false},
+		{"admin schema tool", &AuthInfo{Admin: &models.Admin{}}, "create_collection", true, false, true},
+		{"local schema tool", &AuthInfo{Local: true}, "delete_collection", true, false, true},
+		{"schema tool without schema scope", collectionsOnly, "add_field", true, false, false},
+		{"schema tool with schema scope", schemaScope, "add_field", true, false, true},
+		{"schema tool with read-only schema scope", readOnlySchema, "set_rules", true, false, false},
+		{"filtered scope builtin tool", filtered, "list_records", false, false, true},
+		{"filtered scope agent data tool", filtered, "agent_data_query", false, true, false},
+		{"filtered scope agent schema tool", filtered, "agent_schema_list_tables", false, false, true},
+		{"unfiltered scope agent data tool", mixedFilter, "agent_data_update", true, true, true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.auth.allowsTool(toolInfo{name: tc.tool, write: tc.write, schema: schemaTools[tc.tool], unfiltered: tc.unfiltered}); got != tc.want {
+			t.Errorf("%s: allowsTool() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAuthInfoCollectionScope(t *testing.T) {
+	posts := &models.Collection{Name: "posts", Project: types.Pointer("blog")}
+	posts.Id = "posts_id"
+	users := &models.Collection{Name: "users"}
+	users.Id = "users_id"
+
+	auth := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{
+		{Collections: []string{"posts"}, ReadOnly: true, Filter: `tenant = "acme"`},
+		{Project: "blog", Tools: []string{"create_record"}},
+	}}
+
+	scope, err := auth.collectionScope("list_records", posts, false)
+	if err != nil || scope == nil || scope.Filter != `tenant = "acme"` {
+		t.Fatalf("expected the first scope to match, got %v (%v)", scope, err)
+	}
+
+	scope, err = auth.collectionScope("create_record", posts, true)
+	if err != nil || scope == nil || scope.Project != "blog" {
+		t.Fatalf("expected the project scope to match, got %v (%v)", scope, err)
+	}
+
+	if _, err := auth.collectionScope("delete_record", posts, true); err == nil {
+		t.Error("expected delete_record to be denied")
+	}
+
+	if _, err := auth.collectionScope("list_records", users, false); err == nil {
+		t.Error("expected the users collection to be denied")
+	}
+
+	if auth.allowsCollection(users) {
+		t.Error("expected allowsCollection to be false for users")
+	}
+
+	if scope, err := (&AuthInfo{IsMCPToken: true}).collectionScope("delete_record", users, true); err != nil || scope != nil {
+		t.Errorf("expected unscoped tokens to have full access, got %v (%v)", scope, err)
+	}
+
+	if _, err := (&AuthInfo{IsMCPToken: true}).collectionScope("delete_collection", users, true); err == nil {
+		t.Error("expected the schema tools to be denied for unscoped tokens")
+	}
+}
+
+func TestAgentToolHandlerScopeFilter(t *testing.T) {
+	s := &Server{
+		writeTools:      map[string]bool{"agent_data_update": true},
+		unfilteredTools: map[string]bool{"agent_data_query": true, "agent_data_update": true},
+	}
+
+	auth := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{
+		{Project: "blog", Filter: `owner = "x"`},
+	}}
+
+	for _, name := range []string{"agent_data_query", "agent_data_update"} {
+		if auth.allowsTool(s.toolInfo(name)) {
+			t.Errorf("%s: expected the tool to be hidden for a filtered scope", name)
+		}
+
+		handler := s.makeAgentToolHandler(name, strings.ReplaceAll(strings.TrimPrefix(name, "agent_"), "_", "."))
+
+		_, err := handler(context.Background(), auth, map[string]interface{}{"project": "blog", "collection": "posts"})
+		if !errors.Is(err, errScopeDenied) {
+			t.Errorf("%s: expected errScopeDenied, got %v", name, err)
+		}
+	}
+}
+
+func TestAgentSchemaToolsRequireSchemaScope(t *testing.T) {
+	s := &Server{
+		writeTools:  map[string]bool{"agent_schema_create_table": true, "agent_schema_create_index": true, "agent_data_insert": true},
+		schemaTools: map[string]bool{"agent_schema_create_table": true, "agent_schema_create_index": true},
+	}
+
+	projectScope := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Project: "blog"}}}
+	schemaScope := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Project: "blog", Schema: true}}}
+
+	cases := []struct {
+		name string
+		auth *AuthInfo
+		tool string
+		want bool
+	}{
+		{"create table without schema scope", projectScope, "agent_schema_create_table", false},
+		{"create index without schema scope", projectScope, "agent_schema_create_index", false},
+		{"list tables without schema scope", projectScope, "agent_schema_list_tables", true},
+		{"data insert without schema scope", projectScope, "agent_data_insert", true},
+		{"create table with schema scope", schemaScope, "agent_schema_create_table", true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.auth.allowsTool(s.toolInfo(tc.tool)); got != tc.want {
+			t.Errorf("%s: allowsTool() = %v, want %v", tc.name, got, tc.want)
+		}
+
+		_, err := tc.auth.projectScope(s.toolInfo(tc.tool), "blog")
+		if (err == nil) != tc.want {
+			t.Errorf("%s: projectScope() error %v, want allowed %v", tc.name, err, tc.want)
+		}
+	}
+}
@@ -4,10 +4,14 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/zhenruyan/postgrebase/agents"
 	"github.com/zhenruyan/postgrebase/core"
@@ -28,6 +32,12 @@ type JSONRPCResponse struct {
 	Error   *RPCError   `json:"error,omitempty"`
 }
 
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 type RPCError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -43,6 +53,31 @@ const (
 	InternalError  = -32603
 )
 
+// supportedProtocolVersions lists the MCP protocol revisions
+// understood by the server (the newest first).
+var supportedProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+// isSupportedProtocolVersion reports whether the protocol revision is supported.
+func isSupportedProtocolVersion(version string) bool {
+	for _, v := range supportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiateProtocolVersion returns the requested protocol revision
+// if supported, otherwise the latest one supported by the server.
+func negotiateProtocolVersion(requested string) string {
+	if isSupportedProtocolVersion(requested) {
+		return requested
+	}
+
+	return supportedProtocolVersions[0]
+}
+
 // MCP protocol structures
 type InitializeParams struct {
 	ProtocolVersion string      `json:"protocolVersion"`
@@ -59,6 +94,7 @@ type InitializeResult struct {
 type Capabilities struct {
 	Tools     *ToolsCapability     `json:"tools,omitempty"`
 	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *PromptsCapability   `json:"prompts,omitempty"`
 }
 
 type ToolsCapability struct {
@@ -70,6 +106,10 @@ type ResourcesCapability struct {
 	ListChanged bool `json:"listChanged"`
 }
 
+type PromptsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
 type ServerInfo struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
@@ -84,6 +124,7 @@ type Tool struct {
 type ToolCallParams struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Meta      *RequestMeta           `json:"_meta,omitempty"`
 }
 
 type ToolCallResult struct {
@@ -103,6 +144,13 @@ type Resource struct {
 	MimeType    string `json:"mimeType,omitempty"`
 }
 
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
 type ResourceReadParams struct {
 	URI string `json:"uri"`
 }
@@ -115,35 +163,55 @@ type ResourceContent struct {
 	URI      string `json:"uri"`
 	MimeType string `json:"mimeType,omitempty"`
 	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // base64 encoded binary content
 }
 
 // Server represents the MCP server
 type Server struct {
-	app            core.App
-	tools          map[string]ToolHandler
-	resources      map[string]ResourceHandler
-	mu             sync.RWMutex
-	version        string
-	agents         *agents.Service
-	agentToolDefs  []Tool
-	agentToolRoute map[string]string
+	app               core.App
+	tools             map[string]ToolHandler
+	resources         map[string]ResourceHandler
+	resourceTemplates []resourceTemplate
+	mu                sync.RWMutex
+	version           string
+	agents            *agents.Service
+	agentToolDefs     []Tool
+	agentToolRoute    map[string]string
+	writeTools        map[string]bool
+	destructiveTools  map[string]bool
+	schemaTools       map[string]bool
+	unfilteredTools   map[string]bool
+	limiter           rateLimiter
+	auditCleanedAt    atomic.Int64 // unix nano time of the last expired audit entries deletion
 }
 
-// ToolHandler is a function that handles a tool call
-type ToolHandler func(args map[string]interface{}) (*ToolCallResult, error)
+// ToolHandler is a function that handles a tool call on behalf of the
+// authenticated caller (nil for guests).
+//
+// ctx is cancelled when the client cancels the request or disconnects.
+type ToolHandler func(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error)
 
-// ResourceHandler is a function that reads a resource
-type ResourceHandler func(uri string) (*ResourceReadResult, error)
+// ResourceHandler is a function that reads a resource on behalf of the
+// authenticated caller (nil for guests)
+type ResourceHandler func(auth *AuthInfo, uri string) (*ResourceReadResult, error)
 
 // NewServer creates a new MCP server instance
 func NewServer(app core.App, version string) *Server {
 	s := &Server{
-		app:            app,
-		tools:          make(map[string]ToolHandler),
-		resources:      make(map[string]ResourceHandler),
-		version:        version,
-		agents:         agents.NewService(app),
-		agentToolRoute: make(map[string]string),
+		app:             app,
+		tools:           make(map[string]ToolHandler),
+		resources:       make(map[string]ResourceHandler),
+		version:         version,
+		agents:          agents.NewService(app),
+		agentToolRoute:  make(map[string]string),
+		writeTools:      make(map[string]bool),
+		schemaTools:     make(map[string]bool),
+		unfilteredTools: make(map[string]bool),
+		destructiveTools: map[string]bool{
+			"delete_record":     true,
+			"delete_collection": true,
+		},
+		limiter: newRateLimiter(app),
 	}
 
 	// Register tools
@@ -158,23 +226,47 @@ func NewServer(app core.App, version string) *Server {
 	return s
 }
 
-// HandleRequest processes a JSON-RPC request and returns a response
-func (s *Server) HandleRequest(req *JSONRPCRequest) *JSONRPCResponse {
+// HandleRequest processes a JSON-RPC request within the provided
+// client session and returns a response
+// (nil for notifications since they don't have responses).
+//
+// ctx should be cancelled when the client disconnects.
+func (s *Server) HandleRequest(ctx context.Context, sess *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	auth := sess.Auth
+
+	if req.ID == nil {
+		if req.Method == "notifications/cancelled" {
+			s.handleCancelled(sess, req)
+		}
+		// other notifications (eg. notifications/initialized) are ignored
+		return nil
+	}
+
 	if req.JSONRPC != "2.0" {
 		return s.errorResponse(req.ID, InvalidRequest, "Invalid JSON-RPC version")
 	}
 
 	switch req.Method {
 	case "initialize":
-		return s.handleInitialize(req)
+		return s.handleInitialize(sess, req)
 	case "tools/list":
-		return s.handleToolsList(req)
+		return s.handleToolsList(auth, req)
 	case "tools/call":
-		return s.handleToolsCall(req)
+		return s.handleToolsCall(ctx, sess, req)
 	case "resources/list":
 		return s.handleResourcesList(req)
 	case "resources/read":
-		return s.handleResourcesRead(req)
+		return s.handleResourcesRead(auth, req)
+	case "resources/subscribe":
+		return s.handleResourcesSubscribe(sess, req)
+	case "resources/unsubscribe":
+		return s.handleResourcesUnsubscribe(sess, req)
+	case "resources/templates/list":
+		return s.handleResourceTemplatesList(req)
+	case "prompts/list":
+		return s.handlePromptsList(req)
+	case "prompts/get":
+		return s.handlePromptsGet(req)
 	case "ping":
 		return s.successResponse(req.ID, map[string]interface{}{})
 	default:
@@ -182,15 +274,27 @@ func (s *Server) HandleRequest(req *JSONRPCRequest) *JSONRPCResponse {
 	}
 }
 
-func (s *Server) handleInitialize(req *JSONRPCRequest) *JSONRPCResponse {
+func (s *Server) handleInitialize(sess *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return s.errorResponse(req.ID, InvalidParams, "Invalid parameters")
+		}
+	}
+
+	sess.ProtocolVersion = negotiateProtocolVersion(params.ProtocolVersion)
+
 	result := InitializeResult{
-		ProtocolVersion: "2024-11-05",
+		ProtocolVersion: sess.ProtocolVersion,
 		Capabilities: Capabilities{
 			Tools: &ToolsCapability{
 				ListChanged: false,
 			},
 			Resources: &ResourcesCapability{
-				Subscribe:   false,
+				Subscribe:   true,
+				ListChanged: false,
+			},
+			Prompts: &PromptsCapability{
 				ListChanged: false,
 			},
 		},
@@ -203,7 +307,7 @@ func (s *Server) handleInitialize(req *JSONRPCRequest) *JSONRPCResponse {
 	return s.successResponse(req.ID, result)
 }
 
-func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
+func (s *Server) handleToolsList(auth *AuthInfo, req *JSONRPCRequest) *JSONRPCResponse {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -256,6 +360,14 @@ func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "string",
 						"description": "Sort expression (e.g., '-created', 'name')",
 					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "Keyset pagination cursor; pass an empty string for the first page and the returned nextCursor for the next ones (page is ignored)",
+					},
+					"fields": map[string]interface{}{
+						"type":        "string",
+						"description": "Comma separated fields to return (e.g., 'id,title,created'); all fields by default",
+					},
 				},
 				"required": []string{"collection"},
 			},
@@ -274,6 +386,10 @@ func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "string",
 						"description": "Record ID",
 					},
+					"fields": map[string]interface{}{
+						"type":        "string",
+						"description": "Comma separated fields to return (e.g., 'id,title,created'); all fields by default",
+					},
 				},
 				"required": []string{"collection", "id"},
 			},
@@ -292,6 +408,10 @@ func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "object",
 						"description": "Record data as JSON object",
 					},
+					"onConflict": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional comma separated unique index fields (eg. sku) - if a record with the same values exists, it is updated instead",
+					},
 				},
 				"required": []string{"collection", "data"},
 			},
@@ -314,6 +434,10 @@ func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "object",
 						"description": "Record data to update as JSON object",
 					},
+					"expectedUpdated": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional updated date of the record version the changes are based on (the update fails if the record was changed since)",
+					},
 				},
 				"required": []string{"collection", "id", "data"},
 			},
@@ -362,20 +486,36 @@ func (s *Server) handleToolsList(req *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "integer",
 						"description": "Items per page (default: 30, max: 500)",
 					},
+					"fields": map[string]interface{}{
+						"type":        "string",
+						"description": "Comma separated fields to return (e.g., 'id,title,created'); all fields by default",
+					},
 				},
 				"required": []string{"collection", "query"},
 			},
 		},
 	}
 
+	tools = append(tools, fileToolDefs...)
+	tools = append(tools, schemaToolDefs...)
 	tools = append(tools, s.agentToolDefs...)
 
+	// hide the tools that are not allowed by the token scopes
+	allowed := make([]Tool, 0, len(tools))
+	for _, tool := range tools {
+		if auth.allowsTool(s.toolInfo(tool.Name)) {
+			allowed = append(allowed, tool)
+		}
+	}
+
 	return s.successResponse(req.ID, map[string]interface{}{
-		"tools": tools,
+		"tools": allowed,
 	})
 }
 
-func (s *Server) handleToolsCall(req *JSONRPCRequest) *JSONRPCResponse {
+func (s *Server) handleToolsCall(ctx context.Context, sess *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	auth := sess.Auth
+
 	var params ToolCallParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		return s.errorResponse(req.ID, InvalidParams, "Invalid parameters")
@@ -389,8 +529,37 @@ func (s *Server) handleToolsCall(req *JSONRPCRequest) *JSONRPCResponse {
 		return s.errorResponse(req.ID, MethodNotFound, fmt.Sprintf("Tool not found: %s", params.Name))
 	}
 
-	result, err := handler(params.Arguments)
+	started := time.Now()
+
+	if !auth.allowsTool(s.toolInfo(params.Name)) {
+		message := fmt.Sprintf("Tool %s is not allowed by the MCP token scopes", params.Name)
+		s.auditToolCall(auth, params.Name, params.Arguments, auditStatusDenied, errors.New(message), started)
+		return s.errorResponse(req.ID, InvalidRequest, message)
+	}
+
+	if err := s.checkRateLimits(auth, params.Name); err != nil {
+		s.auditToolCall(auth, params.Name, params.Arguments, auditStatusDenied, err, started)
+		return s.errorResponse(req.ID, InvalidRequest, err.Error())
+	}
+
+	if params.Arguments == nil {
+		params.Arguments = map[string]interface{}{}
+	}
+
+	ctx, done := sess.trackRequest(ctx, req.ID)
+	defer done()
+
+	if params.Meta != nil {
+		ctx = withProgress(ctx, sess, params.Meta.ProgressToken)
+	}
+
+	result, err := handler(ctx, auth, params.Arguments)
+	if err != nil && ctx.Err() != nil {
+		// report the cancellation instead of the (usually misleading) query error
+		err = errRequestCancelled
+	}
 	if err != nil {
+		s.auditToolCall(auth, params.Name, params.Arguments, auditStatusError, err, started)
 		log.Printf("Tool %s error: %v", params.Name, err)
 		return s.successResponse(req.ID, &ToolCallResult{
 			Content: []Content{
@@ -403,6 +572,12 @@ func (s *Server) handleToolsCall(req *JSONRPCRequest) *JSONRPCResponse {
 		})
 	}
 
+	status := auditStatusSuccess
+	if result != nil && result.IsError {
+		status = auditStatusError
+	}
+	s.auditToolCall(auth, params.Name, params.Arguments, status, nil, started)
+
 	return s.successResponse(req.ID, result)
 }
 
@@ -427,21 +602,32 @@ func (s *Server) handleResourcesList(req *JSONRPCRequest) *JSONRPCResponse {
 	})
 }
 
-func (s *Server) handleResourcesRead(req *JSONRPCRequest) *JSONRPCResponse {
+func (s *Server) handleResourceTemplatesList(req *JSONRPCRequest) *JSONRPCResponse {
+	templates := make([]ResourceTemplate, 0, len(s.resourceTemplates))
+	for _, t := range s.resourceTemplates {
+		templates = append(templates, t.ResourceTemplate)
+	}
+
+	return s.successResponse(req.ID, map[string]interface{}{
+		"resourceTemplates": templates,
+	})
+}
+
+func (s *Server) handleResourcesRead(auth *AuthInfo, req *JSONRPCRequest) *JSONRPCResponse {
 	var params ResourceReadParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		return s.errorResponse(req.ID, InvalidParams, "Invalid parameters")
 	}
 
 	s.mu.RLock()
-	handler, exists := s.resources[params.URI]
+	handler, exists := s.findResourceHandler(params.URI)
 	s.mu.RUnlock()
 
 	if !exists {
 		return s.errorResponse(req.ID, MethodNotFound, fmt.Sprintf("Resource not found: %s", params.URI))
 	}
 
-	result, err := handler(params.URI)
+	result, err := handler(auth, params.URI)
 	if err != nil {
 		log.Printf("Resource %s error: %v", params.URI, err)
 		return s.errorResponse(req.ID, InternalError, err.Error())
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+// Session holds the per-connection state of an MCP client.
+type Session struct {
+	ID   string
+	Auth *AuthInfo
+
+	// ProtocolVersion is the protocol revision negotiated on initialize.
+	ProtocolVersion string
+
+	server *Server
+
+	// send delivers a server-initiated message to the client
+	// (nil for stateless request/response only sessions)
+	send func(data []byte) error
+
+	mu        sync.Mutex
+	closed    bool
+	done      chan struct{}
+	client    subscriptions.Client // lazily registered realtime broker client
+	resources map[string]string    // broker topic -> subscribed resource uri
+	inflight  map[string]func()    // request key -> cancel func of the in-flight tool calls
+}
+
+// NewSession creates a new MCP session for the authenticated caller.
+//
+// send is used to deliver server notifications and could be nil
+// if the transport doesn't support server-initiated messages.
+func (s *Server) NewSession(id string, auth *AuthInfo, send func(data []byte) error) *Session {
+	return &Session{
+		ID:        id,
+		Auth:      auth,
+		server:    s,
+		send:      send,
+		done:      make(chan struct{}),
+		resources: map[string]string{},
+		inflight:  map[string]func(){},
+	}
+}
+
+// statelessSession returns a session for a single request/response exchange.
+func (s *Server) statelessSession(auth *AuthInfo) *Session {
+	return s.NewSession("", auth, nil)
+}
+
+// errNotificationsUnsupported is returned when the session transport
+// can't deliver server-initiated messages.
+var errNotificationsUnsupported = errors.New("the current MCP transport doesn't support server notifications")
+
+// Notify sends a JSON-RPC notification to the client.
+func (sess *Session) Notify(method string, params interface{}) error {
+	if sess.send == nil {
+		return errNotificationsUnsupported
+	}
+
+	data, err := json.Marshal(&JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	return sess.send(data)
+}
+
+// Close releases the session resources (eg. its realtime subscriptions)
+// and cancels its in-flight requests.
+//
+// It is safe to call Close multiple times.
+func (sess *Session) Close() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.closed {
+		return
+	}
+	sess.closed = true
+	close(sess.done)
+
+	for _, cancel := range sess.inflight {
+		cancel()
+	}
+
+	if sess.client != nil {
+		sess.server.app.SubscriptionsBroker().Unregister(sess.client.Id())
+		sess.client = nil
+	}
+}
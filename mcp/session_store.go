@@ -0,0 +1,337 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// sessionTTL is the idle time after which a Streamable HTTP session expires.
+	sessionTTL = time.Hour
+
+	// maxSessionEvents is the number of the most recent server messages
+	// kept per session for Last-Event-ID replay.
+	maxSessionEvents = 100
+
+	redisSessionPrefix = "pb_mcp_session:"
+)
+
+// errSessionNotFound is returned when the session doesn't exist or has expired.
+var errSessionNotFound = errors.New("MCP session not found")
+
+// SessionInfo is the shared (node independent) state of a Streamable HTTP session.
+type SessionInfo struct {
+	ID              string    `json:"id"`
+	Identity        string    `json:"identity"`
+	ProtocolVersion string    `json:"protocolVersion"`
+	Created         time.Time `json:"created"`
+}
+
+// sessionEvent is a server-initiated message stored for resumability.
+type sessionEvent struct {
+	ID   int64           `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// sessionStore persists the Streamable HTTP sessions and their event buffers.
+type sessionStore interface {
+	// Save creates or replaces the session info and refreshes its expiration.
+	Save(info *SessionInfo) error
+
+	// Load returns the session info and refreshes its expiration.
+	Load(id string) (*SessionInfo, error)
+
+	// Exists reports whether the session is still active
+	// (without refreshing its expiration).
+	Exists(id string) (bool, error)
+
+	// Delete removes the session and its event buffer.
+	Delete(id string) error
+
+	// AppendEvent stores a new server message and returns its event id.
+	AppendEvent(id string, data []byte) (int64, error)
+
+	// EventsAfter returns the buffered events with id greater than lastEventId.
+	EventsAfter(id string, lastEventId int64) ([]sessionEvent, error)
+}
+
+// -------------------------------------------------------------------
+// in-memory store (single node)
+// -------------------------------------------------------------------
+
+type memorySession struct {
+	info     SessionInfo
+	seq      int64
+	events   []sessionEvent
+	lastSeen time.Time
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*memorySession
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: map[string]*memorySession{}}
+}
+
+// get returns the not expired session (must be called under lock).
+func (m *memorySessionStore) get(id string) (*memorySession, error) {
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, errSessionNotFound
+	}
+
+	if time.Since(session.lastSeen) > sessionTTL {
+		delete(m.sessions, id)
+		return nil, errSessionNotFound
+	}
+
+	session.lastSeen = time.Now()
+
+	return session, nil
+}
+
+func (m *memorySessionStore) Save(info *SessionInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// drop the expired sessions
+	for id, session := range m.sessions {
+		if time.Since(session.lastSeen) > sessionTTL {
+			delete(m.sessions, id)
+		}
+	}
+
+	if session, ok := m.sessions[info.ID]; ok {
+		session.info = *info
+		session.lastSeen = time.Now()
+		return nil
+	}
+
+	m.sessions[info.ID] = &memorySession{info: *info, lastSeen: time.Now()}
+
+	return nil
+}
+
+func (m *memorySessionStore) Load(id string) (*SessionInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	info := session.info
+
+	return &info, nil
+}
+
+func (m *memorySessionStore) Exists(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+
+	return ok && time.Since(session.lastSeen) <= sessionTTL, nil
+}
+
+func (m *memorySessionStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+
+	return nil
+}
+
+func (m *memorySessionStore) AppendEvent(id string, data []byte) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, err := m.get(id)
+	if err != nil {
+		return 0, err
+	}
+
+	session.seq++
+	session.events = append(session.events, sessionEvent{ID: session.seq, Data: data})
+	if len(session.events) > maxSessionEvents {
+		session.events = session.events[len(session.events)-maxSessionEvents:]
+	}
+
+	return session.seq, nil
+}
+
+func (m *memorySessionStore) EventsAfter(id string, lastEventId int64) ([]sessionEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []sessionEvent{}
+	for _, e := range session.events {
+		if e.ID > lastEventId {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+// -------------------------------------------------------------------
+// Redis store (shared between the app nodes)
+// -------------------------------------------------------------------
+
+type redisSessionStore struct {
+	client *redis.Client
+}
+
+func newRedisSessionStore(client *redis.Client) *redisSessionStore {
+	return &redisSessionStore{client: client}
+}
+
+func (r *redisSessionStore) keys(id string) (infoKey, seqKey, eventsKey string) {
+	infoKey = redisSessionPrefix + id
+	return infoKey, infoKey + ":seq", infoKey + ":events"
+}
+
+// touch refreshes the expiration of all session keys.
+func (r *redisSessionStore) touch(ctx context.Context, id string) {
+	infoKey, seqKey, eventsKey := r.keys(id)
+
+	pipe := r.client.Pipeline()
+	pipe.Expire(ctx, infoKey, sessionTTL)
+	pipe.Expire(ctx, seqKey, sessionTTL)
+	pipe.Expire(ctx, eventsKey, sessionTTL)
+	_, _ = pipe.Exec(ctx)
+}
+
+func (r *redisSessionStore) Save(info *SessionInfo) error {
+	ctx := context.Background()
+
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	infoKey, _, _ := r.keys(info.ID)
+	if err := r.client.Set(ctx, infoKey, raw, sessionTTL).Err(); err != nil {
+		return err
+	}
+
+	r.touch(ctx, info.ID)
+
+	return nil
+}
+
+func (r *redisSessionStore) Load(id string) (*SessionInfo, error) {
+	ctx := context.Background()
+
+	infoKey, _, _ := r.keys(id)
+	raw, err := r.client.Get(ctx, infoKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SessionInfo{}
+	if err := json.Unmarshal(raw, info); err != nil {
+		return nil, err
+	}
+
+	r.touch(ctx, id)
+
+	return info, nil
+}
+
+func (r *redisSessionStore) Exists(id string) (bool, error) {
+	infoKey, _, _ := r.keys(id)
+
+	n, err := r.client.Exists(context.Background(), infoKey).Result()
+
+	return n > 0, err
+}
+
+func (r *redisSessionStore) Delete(id string) error {
+	infoKey, seqKey, eventsKey := r.keys(id)
+
+	return r.client.Del(context.Background(), infoKey, seqKey, eventsKey).Err()
+}
+
+func (r *redisSessionStore) AppendEvent(id string, data []byte) (int64, error) {
+	ctx := context.Background()
+
+	if exists, err := r.Exists(id); err != nil {
+		return 0, err
+	} else if !exists {
+		return 0, errSessionNotFound
+	}
+
+	_, seqKey, eventsKey := r.keys(id)
+
+	eventId, err := r.client.Incr(ctx, seqKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := json.Marshal(sessionEvent{ID: eventId, Data: data})
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.RPush(ctx, eventsKey, raw)
+	pipe.LTrim(ctx, eventsKey, -maxSessionEvents, -1)
+	pipe.Expire(ctx, seqKey, sessionTTL)
+	pipe.Expire(ctx, eventsKey, sessionTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return eventId, nil
+}
+
+func (r *redisSessionStore) EventsAfter(id string, lastEventId int64) ([]sessionEvent, error) {
+	_, _, eventsKey := r.keys(id)
+
+	items, err := r.client.LRange(context.Background(), eventsKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []sessionEvent{}
+	for _, item := range items {
+		var e sessionEvent
+		if err := json.Unmarshal([]byte(item), &e); err != nil {
+			continue
+		}
+		if e.ID > lastEventId {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
+
+// parseEventId parses a Last-Event-ID header value (0 if empty or invalid).
+func parseEventId(raw string) int64 {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+
+	return id
+}
@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/models"
+)
+
+func TestSessionNotify(t *testing.T) {
+	s := &Server{}
+
+	if err := s.statelessSession(nil).Notify("test", nil); err != errNotificationsUnsupported {
+		t.Fatalf("expected errNotificationsUnsupported, got %v", err)
+	}
+
+	var sent []byte
+	sess := s.NewSession("test", nil, func(data []byte) error {
+		sent = data
+		return nil
+	})
+
+	if err := sess.Notify("notifications/resources/updated", map[string]string{"uri": "postgrebase://collections/posts"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg JSONRPCNotification
+	if err := json.Unmarshal(sent, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.JSONRPC != "2.0" || msg.Method != "notifications/resources/updated" {
+		t.Errorf("unexpected notification %s", sent)
+	}
+
+	if err := sess.subscribe("postgrebase://unknown"); err == nil {
+		t.Error("expected unsupported resource error")
+	}
+}
+
+func TestAuthInfoSameIdentity(t *testing.T) {
+	admin1 := &models.Admin{}
+	admin1.Id = "a1"
+	admin2 := &models.Admin{}
+	admin2.Id = "a2"
+
+	cases := []struct {
+		name string
+		a    *AuthInfo
+		b    *AuthInfo
+		want bool
+	}{
+		{"both nil", nil, nil, true},
+		{"nil and local", nil, &AuthInfo{Local: true}, false},
+		{"same admin", &AuthInfo{Admin: admin1}, &AuthInfo{Admin: admin1}, true},
+		{"different admins", &AuthInfo{Admin: admin1}, &AuthInfo{Admin: admin2}, false},
+		{"admin and token", &AuthInfo{Admin: admin1}, &AuthInfo{IsMCPToken: true, TokenId: "t"}, false},
+		{"same token", &AuthInfo{IsMCPToken: true, TokenId: "t"}, &AuthInfo{IsMCPToken: true, TokenId: "t"}, true},
+		{"different tokens", &AuthInfo{IsMCPToken: true, TokenId: "t1"}, &AuthInfo{IsMCPToken: true, TokenId: "t2"}, false},
+		{"local", &AuthInfo{Local: true}, &AuthInfo{Local: true}, true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.a.sameIdentity(tc.b); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
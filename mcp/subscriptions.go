@@ -0,0 +1,236 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+// realtime broker client context keys (the same as the apis.ContextAdminKey,
+// apis.ContextAuthRecordKey and apis.RealtimeIgnoreRulesKey).
+const (
+	realtimeAdminKey       = "admin"
+	realtimeAuthRecordKey  = "authRecord"
+	realtimeIgnoreRulesKey = "ignoreRules"
+)
+
+type ResourceSubscribeParams struct {
+	URI string `json:"uri"`
+}
+
+// resourceTopic resolves a resource uri to its realtime broker topic
+// after checking that the caller is allowed to watch it.
+//
+// Supported uris:
+//
+//	postgrebase://collections/{name}       - any record change in the collection
+//	postgrebase://records/{collection}/{id} - changes of a single record
+func (s *Server) resourceTopic(auth *AuthInfo, uri string) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, "postgrebase://collections/"):
+		name := strings.TrimPrefix(uri, "postgrebase://collections/")
+		if name == "" || strings.Contains(name, "/") {
+			return "", fmt.Errorf("invalid collection resource uri: %s", uri)
+		}
+
+		collection, err := s.app.Dao().FindCollectionByNameOrId(name)
+		if err != nil {
+			return "", fmt.Errorf("collection not found: %s", name)
+		}
+		if err := checkRule(auth, collection.ListRule); err != nil {
+			return "", err
+		}
+		if _, err := auth.collectionScope("list_records", collection, false); err != nil {
+			return "", err
+		}
+
+		return collection.Name + "/*", nil
+	case strings.HasPrefix(uri, "postgrebase://records/"):
+		parts := strings.Split(strings.TrimPrefix(uri, "postgrebase://records/"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", fmt.Errorf("invalid record resource uri: %s", uri)
+		}
+
+		collection, err := s.app.Dao().FindCollectionByNameOrId(parts[0])
+		if err != nil {
+			return "", fmt.Errorf("collection not found: %s", parts[0])
+		}
+		if err := checkRule(auth, collection.ViewRule); err != nil {
+			return "", err
+		}
+		if _, err := auth.collectionScope("get_record", collection, false); err != nil {
+			return "", err
+		}
+
+		return collection.Name + "/" + parts[1], nil
+	}
+
+	return "", fmt.Errorf("subscriptions are not supported for resource: %s", uri)
+}
+
+// subscribe starts watching the resource uri for changes.
+func (sess *Session) subscribe(uri string) error {
+	if sess.send == nil {
+		return errNotificationsUnsupported
+	}
+
+	topic, err := sess.server.resourceTopic(sess.Auth, uri)
+	if err != nil {
+		return err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.closed {
+		return fmt.Errorf("the MCP session is closed")
+	}
+
+	if sess.client == nil {
+		sess.client = sess.newRealtimeClient()
+		sess.server.app.SubscriptionsBroker().Register(sess.client)
+		go sess.forwardRealtimeMessages(sess.client)
+	}
+
+	sess.resources[topic] = uri
+	sess.client.Subscribe(topic)
+
+	return nil
+}
+
+// unsubscribe stops watching the resource uri.
+func (sess *Session) unsubscribe(uri string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	for topic, subscribed := range sess.resources {
+		if subscribed != uri {
+			continue
+		}
+		delete(sess.resources, topic)
+		if sess.client != nil {
+			sess.client.Unsubscribe(topic)
+		}
+	}
+}
+
+// newRealtimeClient creates a realtime broker client carrying the session
+// auth state, so that the broker applies the same collection rules as
+// for the /api/realtime subscribers.
+func (sess *Session) newRealtimeClient() subscriptions.Client {
+	client := subscriptions.NewDefaultClient()
+
+	if sess.Auth != nil {
+		if sess.Auth.Admin != nil {
+			client.Set(realtimeAdminKey, sess.Auth.Admin)
+		} else if sess.Auth.IsAdmin() {
+			// MCP tokens and local sessions bypass the rules like admins
+			client.Set(realtimeIgnoreRulesKey, true)
+		}
+		if sess.Auth.Record != nil {
+			client.Set(realtimeAuthRecordKey, sess.Auth.Record)
+		}
+	}
+
+	return client
+}
+
+// forwardRealtimeMessages converts the realtime record events into
+// notifications/resources/updated messages until the session is closed.
+func (sess *Session) forwardRealtimeMessages(client subscriptions.Client) {
+	for {
+		select {
+		case <-sess.done:
+			return
+		case msg := <-client.Channel():
+			sess.mu.Lock()
+			uri, ok := sess.resources[msg.Name]
+			sess.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			if !sess.allowsRealtimeMessage(msg) {
+				continue
+			}
+
+			if err := sess.Notify("notifications/resources/updated", map[string]interface{}{"uri": uri}); err != nil && sess.server.app.IsDebug() {
+				log.Printf("MCP session %s notification error: %v", sess.ID, err)
+			}
+		}
+	}
+}
+
+// allowsRealtimeMessage checks the record event against the token scopes.
+//
+// The record must also match the filter of the scope that allows the
+// subscription (if any). The filter is checked against the current
+// record state, so with filtered scopes the delete events are skipped.
+func (sess *Session) allowsRealtimeMessage(msg subscriptions.Message) bool {
+	if sess.Auth == nil || len(sess.Auth.Scopes) == 0 {
+		return true
+	}
+
+	var data struct {
+		Record struct {
+			Id           string `json:"id"`
+			CollectionId string `json:"collectionId"`
+		} `json:"record"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return false
+	}
+
+	dao := sess.server.app.Dao()
+
+	collection, err := dao.FindCollectionByNameOrId(data.Record.CollectionId)
+	if err != nil {
+		return false
+	}
+
+	// the same tool as for the subscription (see resourceTopic)
+	tool := "get_record"
+	if strings.HasSuffix(msg.Name, "/*") {
+		tool = "list_records"
+	}
+
+	scope, err := sess.Auth.collectionScope(tool, collection, false)
+	if err != nil {
+		return false
+	}
+
+	if scope == nil || scope.Filter == "" {
+		return true
+	}
+
+	_, err = dao.FindRecordById(collection.Id, data.Record.Id, sess.server.scopeFilterFunc(dao, collection, scope))
+
+	return err == nil
+}
+
+func (s *Server) handleResourcesSubscribe(sess *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	var params ResourceSubscribeParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		return s.errorResponse(req.ID, InvalidParams, "Invalid parameters")
+	}
+
+	if err := sess.subscribe(params.URI); err != nil {
+		return s.errorResponse(req.ID, InvalidRequest, err.Error())
+	}
+
+	return s.successResponse(req.ID, map[string]interface{}{})
+}
+
+func (s *Server) handleResourcesUnsubscribe(sess *Session, req *JSONRPCRequest) *JSONRPCResponse {
+	var params ResourceSubscribeParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		return s.errorResponse(req.ID, InvalidParams, "Invalid parameters")
+	}
+
+	sess.unsubscribe(params.URI)
+
+	return s.successResponse(req.ID, map[string]interface{}{})
+}
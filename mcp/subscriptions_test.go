@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/migrations"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/migrate"
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+func TestSessionNewRealtimeClient(t *testing.T) {
+	admin := &models.Admin{}
+	admin.Id = "admin1"
+
+	scenarios := []struct {
+		name              string
+		auth              *AuthInfo
+		expectAdmin       bool
+		expectIgnoreRules bool
+	}{
+		{"guest", nil, false, false},
+		{"admin", &AuthInfo{Admin: admin}, true, false},
+		{"MCP token", &AuthInfo{IsMCPToken: true}, false, true},
+		{"local session", &AuthInfo{Local: true}, false, true},
+	}
+
+	for _, s := range scenarios {
+		sess := &Session{Auth: s.auth}
+		client := sess.newRealtimeClient()
+
+		_, hasAdmin := client.Get(realtimeAdminKey).(*models.Admin)
+		if hasAdmin != s.expectAdmin {
+			t.Errorf("[%s] Expected admin %v, got %v", s.name, s.expectAdmin, hasAdmin)
+		}
+
+		ignoreRules, _ := client.Get(realtimeIgnoreRulesKey).(bool)
+		if ignoreRules != s.expectIgnoreRules {
+			t.Errorf("[%s] Expected ignoreRules %v, got %v", s.name, s.expectIgnoreRules, ignoreRules)
+		}
+	}
+}
+
+func TestSessionAllowsRealtimeMessage(t *testing.T) {
+	app := newTestApp(t)
+
+	posts := &models.Collection{
+		Name:   "posts",
+		Type:   models.CollectionTypeBase,
+		Schema: schema.NewSchema(&schema.SchemaField{Name: "title", Type: schema.FieldTypeText}),
+	}
+	if err := app.Dao().SaveCollection(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, title := range map[string]string{"public_post_1": "public", "hidden_post_1": "hidden"} {
+		record := models.NewRecord(posts)
+		record.Id = id
+		record.Set("title", title)
+		if err := app.Dao().SaveRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Server{app: app}
+
+	message := func(topic string, collectionId string, recordId string) subscriptions.Message {
+		data, _ := json.Marshal(map[string]any{
+			"action": "update",
+			"record": map[string]any{"id": recordId, "collectionId": collectionId},
+		})
+		return subscriptions.Message{Name: topic, Data: data}
+	}
+
+	filtered := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Collections: []string{"posts"}, Filter: "title='public'"}}}
+	unfiltered := &AuthInfo{IsMCPToken: true, Scopes: []TokenScope{{Collections: []string{"posts"}}}}
+	unscoped := &AuthInfo{IsMCPToken: true}
+
+	scenarios := []struct {
+		name     string
+		auth     *AuthInfo
+		msg      subscriptions.Message
+		expected bool
+	}{
+		{"unscoped token", unscoped, message("posts/*", posts.Id, "hidden_post_1"), true},
+		{"unfiltered scope", unfiltered, message("posts/*", posts.Id, "hidden_post_1"), true},
+		{"filtered scope with a matching record", filtered, message("posts/*", posts.Id, "public_post_1"), true},
+		{"filtered scope with a matching single record", filtered, message("posts/public_post_1", posts.Id, "public_post_1"), true},
+		{"filtered scope with a non-matching record", filtered, message("posts/*", posts.Id, "hidden_post_1"), false},
+		{"filtered scope with a non-matching single record", filtered, message("posts/hidden_post_1", posts.Id, "hidden_post_1"), false},
+		{"filtered scope with a deleted record", filtered, message("posts/*", posts.Id, "missing"), false},
+		{"collection outside of the scope", filtered, message("users/*", "_pb_users_auth_", "missing"), false},
+	}
+
+	for _, sc := range scenarios {
+		sess := s.NewSession("test", sc.auth, nil)
+
+		result := sess.allowsRealtimeMessage(sc.msg)
+		if result != sc.expected {
+			t.Errorf("[%s] Expected %v, got %v", sc.name, sc.expected, result)
+		}
+	}
+}
+
+// newTestApp creates a new bootstrapped and migrated SQLite test app.
+func newTestApp(t *testing.T) *core.BaseApp {
+	t.Helper()
+
+	dataDir := filepath.Join(t.TempDir(), "pb_data")
+	app := core.NewBaseApp(core.BaseAppConfig{
+		DataDir:       dataDir,
+		DataDsn:       "sqlite://" + filepath.Join(dataDir, "test.db"),
+		DisableVector: true,
+	})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+
+	runner, err := migrate.NewRunner(app.DB(), migrations.AppMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return app
+}
@@ -1,21 +1,67 @@
 package mcp
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cast"
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/forms"
 	"github.com/zhenruyan/postgrebase/models"
 	"github.com/zhenruyan/postgrebase/replication"
 	"github.com/zhenruyan/postgrebase/resolvers"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/search"
+	"github.com/zhenruyan/postgrebase/tools/types"
+	"github.com/zhenruyan/postgrebase/vector"
 )
 
+// builtinTools maps the built-in tool names to whether they modify data.
+var builtinTools = map[string]bool{
+	"list_collections": false,
+	"get_collection":   false,
+	"list_records":     false,
+	"get_record":       false,
+	"create_record":    true,
+	"update_record":    true,
+	"delete_record":    true,
+	"search_records":   false,
+	"upload_file":      true,
+
+	// schema-management tools
+	"create_collection": true,
+	"update_collection": true,
+	"delete_collection": true,
+	"add_field":         true,
+	"set_rules":         true,
+	"create_index":      true,
+}
+
+// isAgentToolName reports whether the name refers to a shared agent tool.
+func isAgentToolName(name string) bool {
+	return strings.HasPrefix(name, "agent_")
+}
+
+// isKnownToolName reports whether the name refers to a built-in or agent tool.
+func isKnownToolName(name string) bool {
+	_, ok := builtinTools[name]
+	return ok || isAgentToolName(name)
+}
+
 // registerTools registers all available tools
 func (s *Server) registerTools() {
+	for name, write := range builtinTools {
+		s.writeTools[name] = write
+		s.schemaTools[name] = schemaTools[name]
+	}
+
 	s.tools["list_collections"] = s.toolListCollections
 	s.tools["get_collection"] = s.toolGetCollection
 	s.tools["list_records"] = s.toolListRecords
@@ -24,10 +70,17 @@ func (s *Server) registerTools() {
 	s.tools["update_record"] = s.toolUpdateRecord
 	s.tools["delete_record"] = s.toolDeleteRecord
 	s.tools["search_records"] = s.toolSearchRecords
+	s.tools["upload_file"] = s.toolUploadFile
+
+	s.registerSchemaTools()
 }
 
 // toolListCollections lists all collections
-func (s *Server) toolListCollections(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolListCollections(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
 	collections := []*models.Collection{}
 	err := s.app.Dao().CollectionQuery().OrderBy("created ASC").All(&collections)
 	if err != nil {
@@ -36,6 +89,10 @@ func (s *Server) toolListCollections(args map[string]interface{}) (*ToolCallResu
 
 	result := make([]map[string]interface{}, 0, len(collections))
 	for _, c := range collections {
+		if !auth.allowsCollection(c) {
+			continue
+		}
+
 		item := map[string]interface{}{
 			"id":          c.Id,
 			"name":        c.Name,
@@ -60,7 +117,11 @@ func (s *Server) toolListCollections(args map[string]interface{}) (*ToolCallResu
 }
 
 // toolGetCollection gets detailed information about a collection
-func (s *Server) toolGetCollection(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolGetCollection(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+	if err := requireAdmin(auth); err != nil {
+		return nil, err
+	}
+
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -71,6 +132,10 @@ func (s *Server) toolGetCollection(args map[string]interface{}) (*ToolCallResult
 		return nil, fmt.Errorf("collection not found: %s", collectionName)
 	}
 
+	if _, err := auth.collectionScope("get_collection", collection, false); err != nil {
+		return nil, err
+	}
+
 	data, _ := json.MarshalIndent(collection, "", "  ")
 	return &ToolCallResult{
 		Content: []Content{
@@ -83,7 +148,7 @@ func (s *Server) toolGetCollection(args map[string]interface{}) (*ToolCallResult
 }
 
 // toolListRecords lists records from a collection
-func (s *Server) toolListRecords(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolListRecords(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -125,6 +190,19 @@ func (s *Server) toolListRecords(args map[string]interface{}) (*ToolCallResult,
 		sort = v
 	}
 
+	if err := checkRule(auth, collection.ListRule); err != nil {
+		return nil, err
+	}
+
+	if err := checkForbiddenQueryFields(auth, filter, sort); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("list_records", collection, false)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build URL-encoded query string (ParseAndExec uses url.ParseQuery internally)
 	params := url.Values{}
 	params.Set("page", strconv.Itoa(page))
@@ -135,25 +213,25 @@ func (s *Server) toolListRecords(args map[string]interface{}) (*ToolCallResult,
 	if sort != "" {
 		params.Set("sort", sort)
 	}
+	if v, ok := args["cursor"].(string); ok {
+		params.Set("cursor", v)
+	}
+	if v, ok := args["fields"].(string); ok && v != "" {
+		params.Set("fields", v)
+	}
 	queryStr := params.Encode()
 
 	records := []*models.Record{}
 
-	fieldsResolver := resolvers.NewRecordFieldResolver(
-		s.app.Dao(),
-		collection,
-		nil, // requestInfo - nil for MCP (admin-like access)
-		true,
-	)
-
-	searchProvider := search.NewProvider(fieldsResolver).
-		Query(s.app.Dao().RecordQuery(collection))
+	reportProgress(ctx, 0, 1, "Querying "+collection.Name+" records")
 
-	result, err := searchProvider.ParseAndExec(queryStr, &records)
+	result, err := s.searchRecords(ctx, auth, scope, collection, queryStr, &records)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query records: %w", err)
 	}
 
+	reportProgress(ctx, 1, 1, fmt.Sprintf("Fetched %d records", len(records)))
+
 	data, _ := json.MarshalIndent(result, "", "  ")
 	return &ToolCallResult{
 		Content: []Content{
@@ -166,7 +244,7 @@ func (s *Server) toolListRecords(args map[string]interface{}) (*ToolCallResult,
 }
 
 // toolGetRecord gets a single record by ID
-func (s *Server) toolGetRecord(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolGetRecord(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -182,11 +260,31 @@ func (s *Server) toolGetRecord(args map[string]interface{}) (*ToolCallResult, er
 		return nil, fmt.Errorf("collection not found: %s", collectionName)
 	}
 
-	record, err := s.app.Dao().FindRecordById(collection.Id, recordID)
+	if err := checkRule(auth, collection.ViewRule); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("get_record", collection, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, _ := args["fields"].(string)
+
+	requestInfo := auth.requestInfo("GET", nil)
+	record, err := s.app.Dao().FindRecordById(
+		collection.Id,
+		recordID,
+		s.ruleFunc(s.app.Dao(), collection, collection.ViewRule, requestInfo, auth),
+		s.scopeFilterFunc(s.app.Dao(), collection, scope),
+		s.fieldsFunc(collection, picker.ParseFields(fields)),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("record not found: %s", recordID)
 	}
 
+	record.WithFields(picker.ParseFields(fields))
+
 	data, _ := json.MarshalIndent(record, "", "  ")
 	return &ToolCallResult{
 		Content: []Content{
@@ -199,7 +297,7 @@ func (s *Server) toolGetRecord(args map[string]interface{}) (*ToolCallResult, er
 }
 
 // toolCreateRecord creates a new record
-func (s *Server) toolCreateRecord(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolCreateRecord(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -215,15 +313,67 @@ func (s *Server) toolCreateRecord(args map[string]interface{}) (*ToolCallResult,
 		return nil, fmt.Errorf("collection not found: %s", collectionName)
 	}
 
+	var conflictFields []string
+	if onConflict, _ := args["onConflict"].(string); onConflict != "" {
+		if s.app.IsSQLiteCluster() {
+			return nil, fmt.Errorf("onConflict is not supported in SQLite cluster mode")
+		}
+
+		for _, field := range strings.Split(onConflict, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				conflictFields = append(conflictFields, field)
+			}
+		}
+
+		existing, err := s.app.Dao().FindRecordByConflictFields(collection, conflictFields, dataArg)
+		if err == nil {
+			return s.toolUpdateRecord(ctx, auth, conflictUpdateArgs(existing, dataArg))
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invalid onConflict: %w", err)
+		}
+	}
+
+	if err := checkRule(auth, collection.CreateRule); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("create_record", collection, true)
+	if err != nil {
+		return nil, err
+	}
+
 	record := models.NewRecord(collection)
 
-	// Set the data fields
-	for key, value := range dataArg {
-		record.Set(key, value)
+	if !auth.IsAdmin() {
+		err = s.upsertRecordAsUser(auth, record, dataArg, conflictFields...)
+	} else {
+		// Set the data fields
+		for key, value := range dataArg {
+			record.Set(key, value)
+		}
+
+		if err := s.checkScopeFilter(record, scope); err != nil {
+			return nil, fmt.Errorf("failed to create record: %w", err)
+		}
+
+		// Save the record
+		if len(conflictFields) > 0 {
+			err = s.app.Dao().CreateRecordOnConflict(record, conflictFields...)
+		} else {
+			err = s.saveRecord(record)
+		}
 	}
 
-	// Save the record
-	if err := s.saveRecord(record); err != nil {
+	if errors.Is(err, daos.ErrRecordExists) {
+		// concurrently created in the meantime
+		existing, findErr := s.app.Dao().FindRecordByConflictFields(collection, conflictFields, dataArg)
+		if findErr != nil {
+			return nil, fmt.Errorf("failed to create record: %w", findErr)
+		}
+		return s.toolUpdateRecord(ctx, auth, conflictUpdateArgs(existing, dataArg))
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to create record: %w", err)
 	}
 
@@ -238,8 +388,18 @@ func (s *Server) toolCreateRecord(args map[string]interface{}) (*ToolCallResult,
 	}, nil
 }
 
+// conflictUpdateArgs returns the update_record arguments
+// for the existing record matching the create_record onConflict fields.
+func conflictUpdateArgs(existing *models.Record, data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"collection": existing.Collection().Id,
+		"id":         existing.Id,
+		"data":       data,
+	}
+}
+
 // toolUpdateRecord updates an existing record
-func (s *Server) toolUpdateRecord(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolUpdateRecord(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -255,23 +415,78 @@ func (s *Server) toolUpdateRecord(args map[string]interface{}) (*ToolCallResult,
 		return nil, fmt.Errorf("data parameter is required and must be an object")
 	}
 
+	expectedUpdated, _ := args["expectedUpdated"].(string)
+
 	collection, err := s.app.Dao().FindCollectionByNameOrId(collectionName)
 	if err != nil {
 		return nil, fmt.Errorf("collection not found: %s", collectionName)
 	}
 
-	record, err := s.app.Dao().FindRecordById(collection.Id, recordID)
+	if err := checkRule(auth, collection.UpdateRule); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("update_record", collection, true)
+	if err != nil {
+		return nil, err
+	}
+
+	requestInfo := auth.requestInfo("PATCH", dataArg)
+
+	// eager fetch the record so that the modifier field values are replaced
+	// and available when accessing @request.data using just the field name
+	if !auth.IsAdmin() {
+		original, err := s.app.Dao().FindRecordById(collection.Id, recordID)
+		if err != nil {
+			return nil, fmt.Errorf("record not found: %s", recordID)
+		}
+		requestInfo.Data = original.ReplaceModifers(requestInfo.Data)
+	}
+
+	record, err := s.app.Dao().FindRecordById(
+		collection.Id,
+		recordID,
+		s.ruleFunc(s.app.Dao(), collection, collection.UpdateRule, requestInfo, auth),
+		s.scopeFilterFunc(s.app.Dao(), collection, scope),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("record not found: %s", recordID)
 	}
 
-	// Update the data fields
-	for key, value := range dataArg {
-		record.Set(key, value)
+	if !auth.IsAdmin() {
+		if expectedUpdated != "" {
+			dataArg["expectedUpdated"] = expectedUpdated
+		}
+
+		err = s.upsertRecordAsUser(auth, record, dataArg)
+	} else {
+		// Update the data fields
+		for key, value := range dataArg {
+			record.Set(key, value)
+		}
+
+		if err := s.checkScopeFilter(record, scope); err != nil {
+			return nil, fmt.Errorf("failed to update record: %w", err)
+		}
+
+		// Save the record
+		err = s.saveRecordVersion(record, expectedUpdated)
 	}
 
-	// Save the record
-	if err := s.saveRecord(record); err != nil {
+	var conflictErr *forms.RecordVersionConflictError
+	if errors.As(err, &conflictErr) {
+		current, _ := json.MarshalIndent(conflictErr.Current, "", "  ")
+		return &ToolCallResult{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: fmt.Sprintf("Error: %v Current version:\n%s", err, current),
+				},
+			},
+			IsError: true,
+		}, nil
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to update record: %w", err)
 	}
 
@@ -287,7 +502,7 @@ func (s *Server) toolUpdateRecord(args map[string]interface{}) (*ToolCallResult,
 }
 
 // toolDeleteRecord deletes a record
-func (s *Server) toolDeleteRecord(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolDeleteRecord(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -303,7 +518,22 @@ func (s *Server) toolDeleteRecord(args map[string]interface{}) (*ToolCallResult,
 		return nil, fmt.Errorf("collection not found: %s", collectionName)
 	}
 
-	record, err := s.app.Dao().FindRecordById(collection.Id, recordID)
+	if err := checkRule(auth, collection.DeleteRule); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("delete_record", collection, true)
+	if err != nil {
+		return nil, err
+	}
+
+	requestInfo := auth.requestInfo("DELETE", nil)
+	record, err := s.app.Dao().FindRecordById(
+		collection.Id,
+		recordID,
+		s.ruleFunc(s.app.Dao(), collection, collection.DeleteRule, requestInfo, auth),
+		s.scopeFilterFunc(s.app.Dao(), collection, scope),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("record not found: %s", recordID)
 	}
@@ -322,6 +552,69 @@ func (s *Server) toolDeleteRecord(args map[string]interface{}) (*ToolCallResult,
 	}, nil
 }
 
+// searchRecords executes the list query, applying the collection ListRule
+// for non-admin callers, the token scope filter (if any) and the
+// "fields" projection (if any).
+//
+// The query is aborted when ctx is cancelled.
+func (s *Server) searchRecords(ctx context.Context, auth *AuthInfo, scope *TokenScope, collection *models.Collection, queryStr string, records *[]*models.Record) (*search.Result, error) {
+	params, err := url.ParseQuery(queryStr)
+	if err != nil {
+		return nil, err
+	}
+	fields := picker.ParseFields(params.Get("fields"))
+
+	fieldsResolver := resolvers.NewRecordFieldResolver(
+		s.app.Dao(),
+		collection,
+		auth.requestInfo("GET", nil),
+		// hidden fields are searchable only by admins
+		auth.IsAdmin(),
+	)
+
+	query := s.app.Dao().SelectRecordFields(
+		s.app.Dao().RecordQuery(collection).WithContext(ctx),
+		collection,
+		fields,
+		nil,
+		search.ParseSortFromString(params.Get(search.SortQueryParam)),
+	)
+
+	searchProvider := search.NewProvider(fieldsResolver).
+		Query(query).
+		CursorSecret(s.app.Settings().RecordAuthToken.Secret)
+
+	if !auth.IsAdmin() && collection.ListRule != nil {
+		searchProvider.AddFilter(search.FilterData(*collection.ListRule))
+	}
+
+	if scope != nil && scope.Filter != "" {
+		searchProvider.AddFilter(search.FilterData(scope.Filter))
+	}
+
+	result, err := searchProvider.ParseAndExec(queryStr, records)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 0 {
+		for _, record := range *records {
+			record.WithFields(fields)
+		}
+	}
+
+	return result, nil
+}
+
+// fieldsFunc returns a record query filter that selects only the
+// columns required by the provided fields projection (if any).
+func (s *Server) fieldsFunc(collection *models.Collection, fields []string) func(q *dbx.SelectQuery) error {
+	return func(q *dbx.SelectQuery) error {
+		s.app.Dao().SelectRecordFields(q, collection, fields, nil, nil)
+		return nil
+	}
+}
+
 func (s *Server) saveRecord(record *models.Record) error {
 	if !s.app.IsSQLiteCluster() {
 		return s.app.Dao().SaveRecord(record)
@@ -331,17 +624,59 @@ func (s *Server) saveRecord(record *models.Record) error {
 	if err != nil {
 		return err
 	}
-	manager := s.app.VectorManager()
-	if manager == nil || manager.Coordinator() == nil {
-		return fmt.Errorf("SQLite cluster coordinator is not enabled")
-	}
-	_, err = manager.Coordinator().ProposeReplicated(op)
+	err = s.proposeReplicated(op)
 	if err == nil {
 		record.MarkAsNotNew()
 	}
 	return err
 }
 
+// saveRecordVersion saves the record if its persisted `updated`
+// date matches the expected one (if not empty).
+//
+// Returns [*forms.RecordVersionConflictError] on mismatch.
+func (s *Server) saveRecordVersion(record *models.Record, expectedUpdated string) error {
+	if expectedUpdated == "" {
+		return s.saveRecord(record)
+	}
+
+	expected, _ := types.ParseDateTime(expectedUpdated)
+	if expected.IsZero() {
+		return fmt.Errorf("invalid expectedUpdated date: %s", expectedUpdated)
+	}
+
+	check := func(dao *daos.Dao) error {
+		if err := dao.LockRecordRow(record); err != nil {
+			return err
+		}
+
+		current, err := dao.FindRecordById(record.Collection().Id, record.Id)
+		if err != nil {
+			return err
+		}
+
+		if !current.GetUpdated().Time().Equal(expected.Time()) {
+			return &forms.RecordVersionConflictError{Current: current}
+		}
+
+		return nil
+	}
+
+	if s.app.IsSQLiteCluster() {
+		if err := check(s.app.Dao()); err != nil {
+			return err
+		}
+		return s.saveRecord(record)
+	}
+
+	return s.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		if err := check(txDao); err != nil {
+			return err
+		}
+		return txDao.SaveRecord(record)
+	})
+}
+
 func (s *Server) deleteRecord(record *models.Record) error {
 	if !s.app.IsSQLiteCluster() {
 		return s.app.Dao().DeleteRecord(record)
@@ -351,16 +686,21 @@ func (s *Server) deleteRecord(record *models.Record) error {
 	if err != nil {
 		return err
 	}
+	return s.proposeReplicated(op)
+}
+
+// proposeReplicated applies the operation through the SQLite cluster coordinator.
+func (s *Server) proposeReplicated(op vector.ReplicatedOperation) error {
 	manager := s.app.VectorManager()
 	if manager == nil || manager.Coordinator() == nil {
 		return fmt.Errorf("SQLite cluster coordinator is not enabled")
 	}
-	_, err = manager.Coordinator().ProposeReplicated(op)
+	_, err := manager.Coordinator().ProposeReplicated(op)
 	return err
 }
 
 // toolSearchRecords searches records using filter expressions
-func (s *Server) toolSearchRecords(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) toolSearchRecords(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
 	collectionName, ok := args["collection"].(string)
 	if !ok || collectionName == "" {
 		return nil, fmt.Errorf("collection parameter is required")
@@ -403,30 +743,40 @@ func (s *Server) toolSearchRecords(args map[string]interface{}) (*ToolCallResult
 		filter = fmt.Sprintf("(%s) && (%s)", query, v)
 	}
 
+	if err := checkRule(auth, collection.ListRule); err != nil {
+		return nil, err
+	}
+
+	if err := checkForbiddenQueryFields(auth, filter); err != nil {
+		return nil, err
+	}
+
+	scope, err := auth.collectionScope("search_records", collection, false)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build URL-encoded query string (ParseAndExec uses url.ParseQuery internally)
 	params := url.Values{}
 	params.Set("page", strconv.Itoa(page))
 	params.Set("perPage", strconv.Itoa(perPage))
 	params.Set("filter", filter)
+	if v, ok := args["fields"].(string); ok && v != "" {
+		params.Set("fields", v)
+	}
 	queryStr := params.Encode()
 
 	records := []*models.Record{}
 
-	fieldsResolver := resolvers.NewRecordFieldResolver(
-		s.app.Dao(),
-		collection,
-		nil,
-		true,
-	)
+	reportProgress(ctx, 0, 1, "Searching "+collection.Name+" records")
 
-	searchProvider := search.NewProvider(fieldsResolver).
-		Query(s.app.Dao().RecordQuery(collection))
-
-	result, err := searchProvider.ParseAndExec(queryStr, &records)
+	result, err := s.searchRecords(ctx, auth, scope, collection, queryStr, &records)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search records: %w", err)
 	}
 
+	reportProgress(ctx, 1, 1, fmt.Sprintf("Found %d records", len(records)))
+
 	data, _ := json.MarshalIndent(result, "", "  ")
 	return &ToolCallResult{
 		Content: []Content{
@@ -450,7 +800,14 @@ func (s *Server) registerAgentTools() {
 	for _, spec := range s.agents.Tools() {
 		mcpName := "agent_" + strings.ReplaceAll(spec.Name, ".", "_")
 		s.agentToolRoute[mcpName] = spec.Name
-		s.tools[mcpName] = s.makeAgentToolHandler(spec.Name)
+		s.tools[mcpName] = s.makeAgentToolHandler(mcpName, spec.Name)
+		s.writeTools[mcpName] = spec.Category == "write"
+		s.destructiveTools[mcpName] = spec.Risk == "high"
+		// the agent executors don't support the scope record filter
+		s.unfilteredTools[mcpName] = spec.AuditCategory != "schema"
+		// the non-data write tools (schema.create_table, schema.create_index, etc.)
+		// require the schema scope the same as the built-in schema tools
+		s.schemaTools[mcpName] = spec.Category == "write" && spec.AuditCategory != "data"
 
 		description := spec.Description
 		if spec.Category == "write" {
@@ -464,9 +821,38 @@ func (s *Server) registerAgentTools() {
 	}
 }
 
+// toolInfo returns the scope related traits of the named tool.
+func (s *Server) toolInfo(name string) toolInfo {
+	return toolInfo{
+		name:       name,
+		write:      s.writeTools[name],
+		schema:     s.schemaTools[name],
+		unfiltered: s.unfilteredTools[name],
+	}
+}
+
 // makeAgentToolHandler routes an MCP tool call to the shared agent executor.
-func (s *Server) makeAgentToolHandler(dottedName string) ToolHandler {
-	return func(args map[string]interface{}) (*ToolCallResult, error) {
+func (s *Server) makeAgentToolHandler(mcpName, dottedName string) ToolHandler {
+	return func(ctx context.Context, auth *AuthInfo, args map[string]interface{}) (*ToolCallResult, error) {
+		// the agent executors run with full dao access
+		if err := requireAdmin(auth); err != nil {
+			return nil, err
+		}
+
+		project, _ := args["project"].(string)
+		scope, err := auth.projectScope(s.toolInfo(mcpName), project)
+		if err != nil {
+			return nil, err
+		}
+		if scope != nil && scope.Project != "" {
+			// pin the call to the scope project
+			args["project"] = scope.Project
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		result, err := s.agents.ExecuteTool(dottedName, args)
 		if err != nil {
 			return nil, err
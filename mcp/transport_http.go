@@ -0,0 +1,646 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/zhenruyan/postgrebase/tools/security"
+)
+
+const (
+	sessionIdHeader       = "Mcp-Session-Id"
+	protocolVersionHeader = "Mcp-Protocol-Version"
+	lastEventIdHeader     = "Last-Event-ID"
+
+	// sessionSignalsChannel is the Redis channel used to forward the
+	// session messages and state changes between the app nodes.
+	sessionSignalsChannel = "mcp_sessions"
+)
+
+// sessionSignal is a session state change shared between the app nodes.
+type sessionSignal struct {
+	Type      string        `json:"type"` // event, unsubscribe, cancel or close
+	Session   string        `json:"session"`
+	Event     *sessionEvent `json:"event,omitempty"`
+	URI       string        `json:"uri,omitempty"`
+	RequestID interface{}   `json:"requestId,omitempty"`
+}
+
+// StreamableHTTPTransport implements the MCP Streamable HTTP transport
+// on a single endpoint:
+//
+//	POST   - JSON-RPC requests, notifications and batches
+//	GET    - SSE stream for the server-initiated messages (resumable with Last-Event-ID)
+//	DELETE - terminates the session
+//
+// When Redis is configured the sessions and their event buffers are shared
+// between the app nodes, so the requests of a session could be served by any node.
+type StreamableHTTPTransport struct {
+	server *Server
+	store  sessionStore
+	redis  *redis.Client
+
+	mu       sync.Mutex
+	sessions map[string]*Session           // sessions used on this node
+	streams  map[string]chan *sessionEvent // GET streams attached to this node
+
+	stop chan struct{}
+}
+
+// NewStreamableHTTPTransport creates a new Streamable HTTP transport for the MCP server.
+func NewStreamableHTTPTransport(server *Server) *StreamableHTTPTransport {
+	t := &StreamableHTTPTransport{
+		server:   server,
+		sessions: map[string]*Session{},
+		streams:  map[string]chan *sessionEvent{},
+		stop:     make(chan struct{}),
+	}
+
+	if client := server.app.RedisCache(); client != nil {
+		t.redis = client
+		t.store = newRedisSessionStore(client)
+	} else {
+		t.store = newMemorySessionStore()
+	}
+
+	return t
+}
+
+// Start starts the background routines of the transport
+// (the cross-node signals listener and the expired sessions cleanup).
+func (t *StreamableHTTPTransport) Start() {
+	if t.redis != nil {
+		pubsub := t.redis.Subscribe(context.Background(), sessionSignalsChannel)
+		go func() {
+			<-t.stop
+			pubsub.Close()
+		}()
+		go func() {
+			for msg := range pubsub.Channel() {
+				signal := &sessionSignal{}
+				if err := json.Unmarshal([]byte(msg.Payload), signal); err != nil {
+					continue
+				}
+				t.dispatch(signal)
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.closeExpiredSessions()
+			}
+		}
+	}()
+}
+
+// Stop stops the background routines and closes the sessions of the current node.
+func (t *StreamableHTTPTransport) Stop() {
+	close(t.stop)
+
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.sessions))
+	for id := range t.sessions {
+		ids = append(ids, id)
+	}
+	t.mu.Unlock()
+
+	for _, id := range ids {
+		t.closeLocal(id)
+	}
+}
+
+// HandlePost handles the client JSON-RPC messages (POST /api/mcp/stream).
+func (t *StreamableHTTPTransport) HandlePost(c echo.Context) error {
+	auth, err := t.server.Authenticate(requestToken(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Authentication required: " + err.Error(),
+		})
+	}
+
+	if version := c.Request().Header.Get(protocolVersionHeader); version != "" && !isSupportedProtocolVersion(version) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Unsupported MCP protocol version: " + version,
+		})
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, t.server.errorResponse(nil, ParseError, "Failed to read the request body"))
+	}
+
+	requests, isBatch, err := parseJSONRPCMessages(body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, t.server.errorResponse(nil, ParseError, "Invalid JSON-RPC message: "+err.Error()))
+	}
+
+	var session *Session
+	var newSession bool
+
+	if sessionID := c.Request().Header.Get(sessionIdHeader); sessionID != "" {
+		var status int
+		session, status, err = t.session(sessionID, auth)
+		if err != nil {
+			return c.JSON(status, map[string]string{"error": err.Error()})
+		}
+	} else if hasInitializeRequest(requests) {
+		session = t.newSession(auth)
+		newSession = true
+	} else {
+		// stateless request/response exchange (eg. simple clients and curl)
+		session = t.server.statelessSession(auth)
+	}
+
+	// stream the progress notifications of the request (if the client asked
+	// for them) on the POST response, followed by the request response
+	if !newSession && hasProgressToken(requests) && strings.Contains(c.Request().Header.Get("Accept"), "text/event-stream") {
+		return t.streamResponses(c, session, requests, isBatch)
+	}
+
+	responses := t.handleMessages(c.Request().Context(), session, requests)
+
+	if newSession {
+		info := &SessionInfo{
+			ID:              session.ID,
+			Identity:        auth.identityKey(),
+			ProtocolVersion: session.ProtocolVersion,
+			Created:         time.Now(),
+		}
+		if err := t.store.Save(info); err != nil {
+			t.closeLocal(session.ID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to create the MCP session: " + err.Error(),
+			})
+		}
+		c.Response().Header().Set(sessionIdHeader, session.ID)
+	}
+
+	if len(responses) == 0 {
+		return c.NoContent(http.StatusAccepted)
+	}
+
+	if isBatch {
+		return c.JSON(http.StatusOK, responses)
+	}
+
+	return c.JSON(http.StatusOK, responses[0])
+}
+
+// handleMessages processes the client messages and returns the responses
+// of the requests (the notifications and the client responses don't have any).
+func (t *StreamableHTTPTransport) handleMessages(ctx context.Context, session *Session, requests []*JSONRPCRequest) []*JSONRPCResponse {
+	responses := make([]*JSONRPCResponse, 0, len(requests))
+
+	for _, req := range requests {
+		// ignore the client responses since the server doesn't send requests
+		if req.Method == "" {
+			continue
+		}
+
+		response := t.server.HandleRequest(ctx, session, req)
+
+		if session.ID != "" {
+			switch req.Method {
+			case "resources/unsubscribe":
+				var params ResourceSubscribeParams
+				if response != nil && response.Error == nil && json.Unmarshal(req.Params, &params) == nil {
+					// the subscription could be held by another node
+					t.signal(&sessionSignal{Type: "unsubscribe", Session: session.ID, URI: params.URI})
+				}
+			case "notifications/cancelled":
+				var params CancelledParams
+				if json.Unmarshal(req.Params, &params) == nil && params.RequestID != nil {
+					// the request could be processed by another node
+					t.signal(&sessionSignal{Type: "cancel", Session: session.ID, RequestID: params.RequestID})
+				}
+			}
+		}
+
+		if response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	return responses
+}
+
+// streamResponses processes the client messages and writes the request
+// notifications (eg. progress) and the responses as an SSE stream.
+func (t *StreamableHTTPTransport) streamResponses(c echo.Context, session *Session, requests []*JSONRPCRequest, isBatch bool) error {
+	flusher, ok := c.Response().Writer.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming not supported")
+	}
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("X-Accel-Buffering", "no")
+	c.Response().WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	write := func(data []byte) error {
+		if _, err := fmt.Fprintf(c.Response(), "event: message\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	responses := t.handleMessages(withNotifier(c.Request().Context(), write), session, requests)
+	if len(responses) == 0 {
+		return nil
+	}
+
+	var data []byte
+	if isBatch {
+		data, _ = json.Marshal(responses)
+	} else {
+		data, _ = json.Marshal(responses[0])
+	}
+
+	return write(data)
+}
+
+// HandleGet opens an SSE stream for the server-initiated messages
+// of a session (GET /api/mcp/stream).
+//
+// The messages sent after the Last-Event-ID header value are replayed
+// first, so a client could resume an interrupted stream.
+func (t *StreamableHTTPTransport) HandleGet(c echo.Context) error {
+	auth, err := t.server.Authenticate(requestToken(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Authentication required: " + err.Error(),
+		})
+	}
+
+	if !strings.Contains(c.Request().Header.Get("Accept"), "text/event-stream") {
+		return c.JSON(http.StatusNotAcceptable, map[string]string{
+			"error": "The client must accept text/event-stream",
+		})
+	}
+
+	sessionID := c.Request().Header.Get(sessionIdHeader)
+	if sessionID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing " + sessionIdHeader + " header",
+		})
+	}
+
+	if _, status, err := t.session(sessionID, auth); err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	flusher, ok := c.Response().Writer.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming not supported")
+	}
+
+	stream := t.attachStream(sessionID)
+	defer t.detachStream(sessionID, stream)
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().Header().Set("X-Accel-Buffering", "no")
+	c.Response().WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// replay the missed messages
+	lastSent := parseEventId(c.Request().Header.Get(lastEventIdHeader))
+	if lastSent > 0 {
+		events, err := t.store.EventsAfter(sessionID, lastSent)
+		if err != nil && t.server.app.IsDebug() {
+			log.Printf("MCP session %s replay error: %v", sessionID, err)
+		}
+		for i := range events {
+			writeSessionEvent(c.Response(), &events[i])
+			lastSent = events[i].ID
+		}
+		flusher.Flush()
+	}
+
+	ctx := c.Request().Context()
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-stream:
+			if !ok {
+				return nil // replaced by a newer stream or the session was closed
+			}
+			if e.ID <= lastSent {
+				continue // already replayed
+			}
+			writeSessionEvent(c.Response(), e)
+			lastSent = e.ID
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprintf(c.Response(), ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// HandleDelete terminates a session (DELETE /api/mcp/stream).
+func (t *StreamableHTTPTransport) HandleDelete(c echo.Context) error {
+	auth, err := t.server.Authenticate(requestToken(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Authentication required: " + err.Error(),
+		})
+	}
+
+	sessionID := c.Request().Header.Get(sessionIdHeader)
+	if sessionID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing " + sessionIdHeader + " header",
+		})
+	}
+
+	if _, status, err := t.session(sessionID, auth); err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	if err := t.store.Delete(sessionID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete the MCP session: " + err.Error(),
+		})
+	}
+
+	t.signal(&sessionSignal{Type: "close", Session: sessionID})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// newSession creates a new session on the current node.
+func (t *StreamableHTTPTransport) newSession(auth *AuthInfo) *Session {
+	id := security.RandomString(40)
+
+	session := t.server.NewSession(id, auth, func(data []byte) error {
+		return t.emit(id, data)
+	})
+
+	t.mu.Lock()
+	t.sessions[id] = session
+	t.mu.Unlock()
+
+	return session
+}
+
+// session returns the node local instance of an existing session after
+// checking that it belongs to the caller.
+//
+// On error it also returns the related HTTP status code.
+func (t *StreamableHTTPTransport) session(id string, auth *AuthInfo) (*Session, int, error) {
+	info, err := t.store.Load(id)
+	if errors.Is(err, errSessionNotFound) {
+		return nil, http.StatusNotFound, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if info.Identity != auth.identityKey() {
+		return nil, http.StatusForbidden, errors.New("the MCP session belongs to another identity")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session, ok := t.sessions[id]
+	if !ok {
+		// the session was created or used so far only on other nodes
+		session = t.server.NewSession(id, auth, func(data []byte) error {
+			return t.emit(id, data)
+		})
+		session.ProtocolVersion = info.ProtocolVersion
+		t.sessions[id] = session
+	}
+
+	return session, 0, nil
+}
+
+// emit buffers a server message of the session and forwards it to
+// the node with the attached GET stream.
+func (t *StreamableHTTPTransport) emit(sessionID string, data []byte) error {
+	eventId, err := t.store.AppendEvent(sessionID, data)
+	if err != nil {
+		return err
+	}
+
+	t.signal(&sessionSignal{
+		Type:    "event",
+		Session: sessionID,
+		Event:   &sessionEvent{ID: eventId, Data: data},
+	})
+
+	return nil
+}
+
+// signal notifies all app nodes (or only the current one if Redis is not enabled).
+func (t *StreamableHTTPTransport) signal(signal *sessionSignal) {
+	if t.redis == nil {
+		t.dispatch(signal)
+		return
+	}
+
+	raw, err := json.Marshal(signal)
+	if err != nil {
+		return
+	}
+
+	if err := t.redis.Publish(context.Background(), sessionSignalsChannel, raw).Err(); err != nil {
+		if t.server.app.IsDebug() {
+			log.Printf("MCP session %s signal error: %v", signal.Session, err)
+		}
+		// at least apply it locally
+		t.dispatch(signal)
+	}
+}
+
+// dispatch applies a session signal to the current node.
+func (t *StreamableHTTPTransport) dispatch(signal *sessionSignal) {
+	switch signal.Type {
+	case "event":
+		if signal.Event == nil {
+			return
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if stream, ok := t.streams[signal.Session]; ok {
+			select {
+			case stream <- signal.Event:
+			default:
+				// the client could still resume with Last-Event-ID
+			}
+		}
+	case "unsubscribe":
+		t.mu.Lock()
+		session, ok := t.sessions[signal.Session]
+		t.mu.Unlock()
+
+		if ok {
+			session.unsubscribe(signal.URI)
+		}
+	case "cancel":
+		t.mu.Lock()
+		session, ok := t.sessions[signal.Session]
+		t.mu.Unlock()
+
+		if ok {
+			session.cancelRequest(signal.RequestID)
+		}
+	case "close":
+		t.closeLocal(signal.Session)
+	}
+}
+
+// attachStream registers a new GET stream for the session,
+// replacing the previous one (if any).
+func (t *StreamableHTTPTransport) attachStream(sessionID string) chan *sessionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if old, ok := t.streams[sessionID]; ok {
+		close(old)
+	}
+
+	stream := make(chan *sessionEvent, 100)
+	t.streams[sessionID] = stream
+
+	return stream
+}
+
+// detachStream unregisters the GET stream (if it wasn't already replaced).
+func (t *StreamableHTTPTransport) detachStream(sessionID string, stream chan *sessionEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.streams[sessionID] == stream {
+		delete(t.streams, sessionID)
+		close(stream)
+	}
+}
+
+// closeLocal releases the node resources of the session.
+func (t *StreamableHTTPTransport) closeLocal(sessionID string) {
+	t.mu.Lock()
+	session := t.sessions[sessionID]
+	delete(t.sessions, sessionID)
+	if stream, ok := t.streams[sessionID]; ok {
+		delete(t.streams, sessionID)
+		close(stream)
+	}
+	t.mu.Unlock()
+
+	if session != nil {
+		session.Close()
+	}
+}
+
+// closeExpiredSessions releases the node resources of the expired sessions.
+func (t *StreamableHTTPTransport) closeExpiredSessions() {
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.sessions))
+	for id := range t.sessions {
+		ids = append(ids, id)
+	}
+	t.mu.Unlock()
+
+	for _, id := range ids {
+		if exists, err := t.store.Exists(id); err == nil && !exists {
+			t.closeLocal(id)
+		}
+	}
+}
+
+// parseJSONRPCMessages parses a single JSON-RPC message or a batch of messages.
+func parseJSONRPCMessages(body []byte) ([]*JSONRPCRequest, bool, error) {
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		requests := []*JSONRPCRequest{}
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, true, err
+		}
+		if len(requests) == 0 {
+			return nil, true, errors.New("empty batch")
+		}
+		for _, req := range requests {
+			if req == nil {
+				return nil, true, errors.New("invalid batch item")
+			}
+		}
+		return requests, true, nil
+	}
+
+	req := &JSONRPCRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, false, err
+	}
+
+	return []*JSONRPCRequest{req}, false, nil
+}
+
+// hasInitializeRequest reports whether the messages contain an initialize request.
+func hasInitializeRequest(requests []*JSONRPCRequest) bool {
+	for _, req := range requests {
+		if req.Method == "initialize" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasProgressToken reports whether any of the requests asks for progress notifications.
+func hasProgressToken(requests []*JSONRPCRequest) bool {
+	for _, req := range requests {
+		if req.ID != nil && progressToken(req) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeSessionEvent writes a single SSE message with its event id.
+func writeSessionEvent(w io.Writer, e *sessionEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", e.ID, e.Data)
+}
+
+// requestToken extracts the auth token from the Authorization header
+// or the token query parameter.
+func requestToken(c echo.Context) string {
+	token := c.Request().Header.Get("Authorization")
+	if token == "" {
+		token = c.QueryParam("token")
+	}
+
+	return token
+}
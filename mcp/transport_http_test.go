@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseJSONRPCMessages(t *testing.T) {
+	cases := []struct {
+		body      string
+		count     int
+		batch     bool
+		expectErr bool
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"ping"}`, 1, false, false},
+		{`  [{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`, 2, true, false},
+		{`[]`, 0, true, true},
+		{`[null]`, 0, true, true},
+		{`{invalid`, 0, false, true},
+	}
+
+	for i, tc := range cases {
+		requests, batch, err := parseJSONRPCMessages([]byte(tc.body))
+		if (err != nil) != tc.expectErr {
+			t.Errorf("(%d) expected error %v, got %v", i, tc.expectErr, err)
+			continue
+		}
+		if batch != tc.batch {
+			t.Errorf("(%d) expected batch %v, got %v", i, tc.batch, batch)
+		}
+		if len(requests) != tc.count {
+			t.Errorf("(%d) expected %d messages, got %d", i, tc.count, len(requests))
+		}
+	}
+}
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	cases := []struct {
+		requested string
+		expected  string
+	}{
+		{"2024-11-05", "2024-11-05"},
+		{"2025-03-26", "2025-03-26"},
+		{"", supportedProtocolVersions[0]},
+		{"1999-01-01", supportedProtocolVersions[0]},
+	}
+
+	for _, tc := range cases {
+		if v := negotiateProtocolVersion(tc.requested); v != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.requested, tc.expected, v)
+		}
+	}
+}
+
+func TestMemorySessionStoreEvents(t *testing.T) {
+	store := newMemorySessionStore()
+
+	if _, err := store.AppendEvent("missing", []byte(`{}`)); err != errSessionNotFound {
+		t.Fatalf("expected errSessionNotFound, got %v", err)
+	}
+
+	if err := store.Save(&SessionInfo{ID: "test", Identity: "local"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= maxSessionEvents+10; i++ {
+		id, err := store.AppendEvent("test", []byte(fmt.Sprintf(`{"n":%d}`, i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int64(i) {
+			t.Fatalf("expected event id %d, got %d", i, id)
+		}
+	}
+
+	all, err := store.EventsAfter("test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != maxSessionEvents || all[0].ID != 11 {
+		t.Fatalf("expected the last %d events starting from 11, got %d starting from %d", maxSessionEvents, len(all), all[0].ID)
+	}
+
+	after, err := store.EventsAfter("test", int64(maxSessionEvents+8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(after))
+	}
+
+	if err := store.Delete("test"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := store.Exists("test"); exists {
+		t.Fatal("expected the session to be deleted")
+	}
+}
+
+func TestParseEventId(t *testing.T) {
+	cases := map[string]int64{"": 0, "abc": 0, "-5": 0, "42": 42}
+
+	for raw, expected := range cases {
+		if id := parseEventId(raw); id != expected {
+			t.Errorf("%q: expected %d, got %d", raw, expected, id)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package mcp
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -11,6 +10,7 @@ import (
 
 	"github.com/labstack/echo/v5"
 	"github.com/zhenruyan/postgrebase/core"
+	"github.com/zhenruyan/postgrebase/tools/security"
 )
 
 // SSEClient represents a connected SSE client
@@ -18,6 +18,7 @@ type SSEClient struct {
 	ID       string
 	Messages chan []byte
 	Done     chan bool
+	Session  *Session
 }
 
 // SSETransport handles SSE-based MCP transport over HTTP
@@ -43,7 +44,7 @@ func (t *SSETransport) HandleSSE(c echo.Context) error {
 		token = c.QueryParam("token")
 	}
 
-	_, err := t.server.Authenticate(token)
+	auth, err := t.server.Authenticate(token)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": "Authentication required: " + err.Error(),
@@ -57,22 +58,27 @@ func (t *SSETransport) HandleSSE(c echo.Context) error {
 	c.Response().Header().Set("X-Accel-Buffering", "no")
 
 	// Create client
-	clientID := fmt.Sprintf("client-%d", time.Now().UnixNano())
+	clientID := "client-" + security.RandomString(32)
 	client := &SSEClient{
 		ID:       clientID,
 		Messages: make(chan []byte, 100),
 		Done:     make(chan bool),
 	}
+	client.Session = t.server.NewSession(clientID, auth, func(data []byte) error {
+		return t.deliver(clientID, data)
+	})
 
 	t.mu.Lock()
 	t.clients[clientID] = client
 	t.mu.Unlock()
 
 	defer func() {
+		client.Session.Close()
+
 		t.mu.Lock()
 		delete(t.clients, clientID)
-		t.mu.Unlock()
 		close(client.Messages)
+		t.mu.Unlock()
 	}()
 
 	// Send endpoint information
@@ -115,7 +121,7 @@ func (t *SSETransport) HandleMessage(c echo.Context) error {
 		token = c.QueryParam("token")
 	}
 
-	_, err := t.server.Authenticate(token)
+	auth, err := t.server.Authenticate(token)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": "Authentication required: " + err.Error(),
@@ -138,20 +144,32 @@ func (t *SSETransport) HandleMessage(c echo.Context) error {
 		})
 	}
 
-	// Handle request
-	response := t.server.HandleRequest(&req)
-
-	// Send response via SSE if client exists
+	// Handle request within the SSE client session (if exists)
 	t.mu.RLock()
 	client, exists := t.clients[clientID]
 	t.mu.RUnlock()
 
+	session := t.server.statelessSession(auth)
+	if exists {
+		if !client.Session.Auth.sameIdentity(auth) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": "The client session belongs to another identity",
+			})
+		}
+		session = client.Session
+	}
+
+	response := t.server.HandleRequest(c.Request().Context(), session, &req)
+	if response == nil {
+		// notifications don't have responses
+		return c.NoContent(http.StatusAccepted)
+	}
+
+	// Send response via SSE if client exists
 	if exists {
 		data, _ := json.Marshal(response)
-		select {
-		case client.Messages <- data:
-		default:
-			log.Printf("Client %s message queue full", clientID)
+		if err := t.deliver(clientID, data); err != nil {
+			log.Printf("Client %s: %v", clientID, err)
 		}
 	}
 
@@ -159,51 +177,45 @@ func (t *SSETransport) HandleMessage(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-// HandleStreamableHTTP handles the streamable HTTP transport (POST /api/mcp/stream)
-// This is a simplified version that combines request/response in a single HTTP call
-func (t *SSETransport) HandleStreamableHTTP(c echo.Context) error {
-	// Authenticate
-	token := c.Request().Header.Get("Authorization")
-	if token == "" {
-		token = c.QueryParam("token")
-	}
+// deliver queues a message for the SSE stream of the specified client.
+func (t *SSETransport) deliver(clientID string, data []byte) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	_, err := t.server.Authenticate(token)
-	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Authentication required: " + err.Error(),
-		})
+	client, exists := t.clients[clientID]
+	if !exists {
+		return fmt.Errorf("client %s is not connected", clientID)
 	}
 
-	// Parse request
-	var req JSONRPCRequest
-	scanner := bufio.NewScanner(c.Request().Body)
-	if scanner.Scan() {
-		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": "Invalid JSON-RPC request: " + err.Error(),
-			})
-		}
+	select {
+	case client.Messages <- data:
+		return nil
+	default:
+		return fmt.Errorf("client %s message queue full", clientID)
 	}
-
-	// Handle request
-	response := t.server.HandleRequest(&req)
-
-	return c.JSON(http.StatusOK, response)
 }
 
 // BindMCPRoutes registers MCP routes on the echo instance
 func BindMCPRoutes(app core.App, e *echo.Echo, version string) {
 	transport := NewSSETransport(app, version)
 
+	streamable := NewStreamableHTTPTransport(transport.server)
+	streamable.Start()
+	app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+		streamable.Stop()
+		return nil
+	})
+
 	mcp := e.Group("/api/mcp")
 
-	// SSE endpoint for establishing connection
+	// SSE endpoint for establishing connection (deprecated HTTP+SSE transport)
 	mcp.GET("/sse", transport.HandleSSE)
 
-	// Message endpoint for sending JSON-RPC requests
+	// Message endpoint for sending JSON-RPC requests (deprecated HTTP+SSE transport)
 	mcp.POST("/message", transport.HandleMessage)
 
-	// Streamable HTTP endpoint (simpler alternative)
-	mcp.POST("/stream", transport.HandleStreamableHTTP)
+	// Streamable HTTP endpoint
+	mcp.POST("/stream", streamable.HandlePost)
+	mcp.GET("/stream", streamable.HandleGet)
+	mcp.DELETE("/stream", streamable.HandleDelete)
 }
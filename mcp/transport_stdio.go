@@ -2,11 +2,13 @@ package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sync"
 
 	"github.com/zhenruyan/postgrebase/core"
 )
@@ -16,6 +18,8 @@ type StdioTransport struct {
 	server *Server
 	reader *bufio.Reader
 	writer io.Writer
+
+	writeMu sync.Mutex
 }
 
 // NewStdioTransport creates a new stdio transport
@@ -29,9 +33,12 @@ func NewStdioTransport(app core.App, version string) *StdioTransport {
 
 // Run starts the stdio transport loop
 func (t *StdioTransport) Run(token string) error {
-	// Authenticate if token provided
+	// Authenticate if token provided, otherwise treat the session as a
+	// trusted local process (it already has direct access to the database)
+	auth := &AuthInfo{Local: true}
 	if token != "" {
-		_, err := t.server.Authenticate(token)
+		var err error
+		auth, err = t.server.Authenticate(token)
 		if err != nil {
 			return fmt.Errorf("authentication failed: %w", err)
 		}
@@ -39,6 +46,18 @@ func (t *StdioTransport) Run(token string) error {
 
 	log.SetOutput(os.Stderr) // Send logs to stderr to avoid interfering with stdio
 
+	// the stdio transport is request/response only
+	// (the realtime record events are not bound in this mode)
+	session := t.server.statelessSession(auth)
+	defer session.Close()
+
+	// the progress notifications are written on stdout next to the responses
+	ctx := withNotifier(context.Background(), t.writeLine)
+
+	// wait for the in-flight tool calls before exit
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
 	for {
 		// Read line from stdin
 		line, err := t.reader.ReadBytes('\n')
@@ -63,8 +82,21 @@ func (t *StdioTransport) Run(token string) error {
 			continue
 		}
 
+		// Run the tool calls concurrently so that they could be cancelled
+		// with a notifications/cancelled message
+		if req.Method == "tools/call" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := t.writeResponse(t.server.HandleRequest(ctx, session, &req)); err != nil {
+					log.Printf("failed to write response: %v", err)
+				}
+			}()
+			continue
+		}
+
 		// Handle request
-		response := t.server.HandleRequest(&req)
+		response := t.server.HandleRequest(ctx, session, &req)
 
 		// Write response
 		if err := t.writeResponse(response); err != nil {
@@ -74,11 +106,23 @@ func (t *StdioTransport) Run(token string) error {
 }
 
 func (t *StdioTransport) writeResponse(resp *JSONRPCResponse) error {
+	if resp == nil {
+		return nil // notifications don't have responses
+	}
+
 	data, err := json.Marshal(resp)
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintf(t.writer, "%s\n", data)
+	return t.writeLine(data)
+}
+
+// writeLine writes a single message line (safe for concurrent use).
+func (t *StdioTransport) writeLine(data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	_, err := fmt.Fprintf(t.writer, "%s\n", data)
 	return err
 }
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models/schema"
+)
+
+func init() {
+	AppMigrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, _ := dao.FindCollectionByNameOrId("_pb_mcp_tokens_")
+		if collection == nil || collection.Schema.GetFieldByName("scopes") != nil {
+			return nil
+		}
+
+		// per-collection, per-tool and read-only token permissions
+		collection.Schema.AddField(&schema.SchemaField{
+			Id:      "mcp_token_scopes",
+			Type:    schema.FieldTypeJson,
+			Name:    "scopes",
+			Options: &schema.JsonOptions{},
+		})
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, _ := dao.FindCollectionByNameOrId("_pb_mcp_tokens_")
+		if collection == nil || collection.Schema.GetFieldByName("scopes") == nil {
+			return nil
+		}
+
+		collection.Schema.RemoveField("mcp_token_scopes")
+
+		return dao.SaveCollection(collection)
+	})
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+func init() {
+	AppMigrations.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		// Check if collection already exists
+		existing, _ := dao.FindCollectionByNameOrId("_pb_mcp_prompts_")
+		if existing != nil {
+			return nil
+		}
+
+		// Create the admin-defined MCP prompt templates collection
+		// (nil rules - manageable only by admins)
+		mcpPromptsCollection := &models.Collection{}
+		mcpPromptsCollection.MarkAsNew()
+		mcpPromptsCollection.Id = "_pb_mcp_prompts_"
+		mcpPromptsCollection.Name = "mcp_prompts"
+		mcpPromptsCollection.Type = models.CollectionTypeBase
+		mcpPromptsCollection.System = false
+
+		mcpPromptsCollection.Schema = schema.NewSchema(
+			&schema.SchemaField{
+				Id:       "mcp_prompt_name",
+				Type:     schema.FieldTypeText,
+				Name:     "name",
+				Required: true,
+				Options: &schema.TextOptions{
+					Min:     types.Pointer(1),
+					Max:     types.Pointer(100),
+					Pattern: `^[a-zA-Z0-9_\-]+$`,
+				},
+			},
+			&schema.SchemaField{
+				Id:   "mcp_prompt_description",
+				Type: schema.FieldTypeText,
+				Name: "description",
+				Options: &schema.TextOptions{
+					Max: types.Pointer(1000),
+				},
+			},
+			&schema.SchemaField{
+				// [{"name": "...", "description": "...", "required": true}]
+				Id:      "mcp_prompt_arguments",
+				Type:    schema.FieldTypeJson,
+				Name:    "arguments",
+				Options: &schema.JsonOptions{},
+			},
+			&schema.SchemaField{
+				// message text with {{argName}} placeholders
+				Id:       "mcp_prompt_template",
+				Type:     schema.FieldTypeText,
+				Name:     "template",
+				Required: true,
+				Options:  &schema.TextOptions{},
+			},
+			&schema.SchemaField{
+				Id:      "mcp_prompt_active",
+				Type:    schema.FieldTypeBool,
+				Name:    "active",
+				Options: &schema.BoolOptions{},
+			},
+		)
+
+		return dao.SaveCollection(mcpPromptsCollection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, _ := dao.FindCollectionByNameOrId("_pb_mcp_prompts_")
+		if collection != nil {
+			return dao.DeleteCollection(collection)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"github.com/zhenruyan/postgrebase/daos"
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/models/schema"
+	"github.com/zhenruyan/postgrebase/tools/types"
+)
+
+func init() {
+	AppMigrations.Register(func(db dbx.Builder) error {
+		driver := db.DriverName()
+
+		stmts := []string{
+			`CREATE TABLE IF NOT EXISTS {{_pb_mcp_audit_}} (
+				[[id]]         ` + agentIdType(driver) + ` NOT NULL PRIMARY KEY,
+				[[token_id]]   ` + agentTextType(driver) + ` NOT NULL DEFAULT '',
+				[[token_name]] ` + agentTextType(driver) + ` NOT NULL DEFAULT '',
+				[[actor]]      ` + agentTextType(driver) + ` NOT NULL DEFAULT '',
+				[[tool]]       ` + agentTextType(driver) + ` NOT NULL,
+				[[args]]       ` + agentJsonType(driver) + `,
+				[[status]]     ` + agentTextType(driver) + ` NOT NULL DEFAULT '',
+				[[error_msg]]  ` + agentTextType(driver) + ` NOT NULL DEFAULT '',
+				[[duration]]   ` + mcpAuditDurationType(driver) + ` NOT NULL DEFAULT 0,
+				[[created]]    ` + agentTsType(driver) + ` NOT NULL,
+				[[updated]]    ` + agentTsType(driver) + ` NOT NULL
+			);`,
+			"CREATE INDEX IF NOT EXISTS [[idx_mcp_audit_token]] ON {{_pb_mcp_audit_}} ([[token_id]])",
+			"CREATE INDEX IF NOT EXISTS [[idx_mcp_audit_created]] ON {{_pb_mcp_audit_}} ([[created]])",
+		}
+
+		for _, stmt := range stmts {
+			if _, err := db.NewQuery(stmt).Execute(); err != nil {
+				return err
+			}
+		}
+
+		// per-token rate limits
+		dao := daos.New(db)
+
+		collection, _ := dao.FindCollectionByNameOrId("_pb_mcp_tokens_")
+		if collection == nil || collection.Schema.GetFieldByName("rateLimit") != nil {
+			return nil
+		}
+
+		collection.Schema.AddField(&schema.SchemaField{
+			// max tool calls per minute (0 - unlimited)
+			Id:      "mcp_token_rate_limit",
+			Type:    schema.FieldTypeNumber,
+			Name:    "rateLimit",
+			Options: &schema.NumberOptions{Min: types.Pointer(0.0)},
+		})
+		collection.Schema.AddField(&schema.SchemaField{
+			// max destructive tool calls per minute (0 - unlimited)
+			Id:      "mcp_token_destructive_limit",
+			Type:    schema.FieldTypeNumber,
+			Name:    "destructiveLimit",
+			Options: &schema.NumberOptions{Min: types.Pointer(0.0)},
+		})
+
+		return dao.SaveCollection(collection)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, _ := dao.FindCollectionByNameOrId("_pb_mcp_tokens_")
+		if collection != nil && collection.Schema.GetFieldByName("rateLimit") != nil {
+			collection.Schema.RemoveField("mcp_token_rate_limit")
+			collection.Schema.RemoveField("mcp_token_destructive_limit")
+			if err := dao.SaveCollection(collection); err != nil {
+				return err
+			}
+		}
+
+		_, err := db.NewQuery("DROP TABLE IF EXISTS {{_pb_mcp_audit_}}").Execute()
+
+		return err
+	})
+}
+
+func mcpAuditDurationType(driver string) string {
+	switch driver {
+	case "mysql":
+		return "DOUBLE"
+	case "sqlite", "sqlite3":
+		return "REAL"
+	default:
+		return "double precision"
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "github.com/zhenruyan/postgrebase/tools/types"
+
+var _ Model = (*McpAuditLog)(nil)
+
+// McpAuditLog stores a single MCP tool call audit entry.
+type McpAuditLog struct {
+	BaseModel
+
+	TokenId   string        `db:"token_id" json:"tokenId"`
+	TokenName string        `db:"token_name" json:"tokenName"`
+	Actor     string        `db:"actor" json:"actor"`
+	Tool      string        `db:"tool" json:"tool"`
+	Args      types.JsonRaw `db:"args" json:"args"`
+	Status    string        `db:"status" json:"status"`
+	ErrorMsg  string        `db:"error_msg" json:"error"`
+	Duration  float64       `db:"duration" json:"duration"` // in milliseconds
+}
+
+// TableName returns the MCP audit log SQL table name.
+func (m *McpAuditLog) TableName() string {
+	return "_pb_mcp_audit_"
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/zhenruyan/postgrebase/dbx"
 	"github.com/zhenruyan/postgrebase/models/schema"
 	"github.com/zhenruyan/postgrebase/tools/list"
+	"github.com/zhenruyan/postgrebase/tools/picker"
 	"github.com/zhenruyan/postgrebase/tools/security"
 	"github.com/zhenruyan/postgrebase/tools/store"
 	"github.com/zhenruyan/postgrebase/tools/types"
@@ -29,8 +30,9 @@ type Record struct {
 
 	collection *Collection
 
-	exportUnknown         bool // whether to export unknown fields
-	ignoreEmailVisibility bool // whether to ignore the emailVisibility flag for auth collections
+	exportUnknown         bool     // whether to export unknown fields
+	exportFields          []string // the field paths to export (all if empty)
+	ignoreEmailVisibility bool     // whether to ignore the emailVisibility flag for auth collections
 	loaded                bool
 	originalData          map[string]any    // the original (aka. first loaded) model data
 	expand                *store.Store[any] // expanded relations
@@ -285,6 +287,15 @@ func (m *Record) WithUnknownData(state bool) {
 	m.exportUnknown = state
 }
 
+// WithFields limits the export/serialization of the record to the provided
+// field paths, including nested expand ones (eg. "id", "title", "expand.author.name").
+//
+// Nil or empty fields resets the limit and exports all fields.
+// See [picker.Pick] for more details about the field paths format.
+func (m *Record) WithFields(fields []string) {
+	m.exportFields = fields
+}
+
 // Set sets the provided key-value data pair for the current Record model.
 //
 // If the record collection has field with name matching the provided "key",
@@ -515,6 +526,8 @@ func (m *Record) ColumnValueMap() map[string]any {
 //
 // For auth records, to force the export of the email field you need to set
 // `m.IgnoreEmailVisibility(true)`.
+//
+// To export only some of the fields you can set `m.WithFields(fields)`.
 func (m *Record) PublicExport() map[string]any {
 	result := make(map[string]any, len(m.collection.Schema.Fields())+5)
 
@@ -558,6 +571,11 @@ func (m *Record) PublicExport() map[string]any {
 		result[schema.FieldNameExpand] = m.expand.GetAll()
 	}
 
+	// pick only the specified fields (if any)
+	if len(m.exportFields) > 0 {
+		picker.Pick(result, m.exportFields)
+	}
+
 	return result
 }
 
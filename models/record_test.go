@@ -1462,6 +1462,83 @@ func TestRecordPublicExportAndMarshalJSON(t *testing.T) {
 	}
 }
 
+func TestRecordWithFields(t *testing.T) {
+	authors := &models.Collection{
+		Name: "authors",
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "name", Type: schema.FieldTypeText},
+			&schema.SchemaField{Name: "bio", Type: schema.FieldTypeText},
+		),
+	}
+	authors.Id = "authors_id"
+
+	posts := &models.Collection{
+		Name: "posts",
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "title", Type: schema.FieldTypeText},
+			&schema.SchemaField{Name: "meta", Type: schema.FieldTypeJson},
+			&schema.SchemaField{Name: "author", Type: schema.FieldTypeRelation},
+		),
+	}
+	posts.Id = "posts_id"
+
+	author := models.NewRecord(authors)
+	author.Id = "a1"
+	author.Set("name", "test_name")
+	author.Set("bio", "test_bio")
+
+	scenarios := []struct {
+		fields   []string
+		expected string
+	}{
+		{
+			nil,
+			`{"author":["a1"],"collectionId":"posts_id","collectionName":"posts","created":"","expand":{"author":{"bio":"test_bio","collectionId":"authors_id","collectionName":"authors","created":"","id":"a1","name":"test_name","updated":""}},"id":"p1","meta":{"a":1,"b":2},"title":"test_title","updated":""}`,
+		},
+		{
+			[]string{"id", "title", "missing"},
+			`{"id":"p1","title":"test_title"}`,
+		},
+		{
+			[]string{"id", "meta.b", "expand.author.name"},
+			`{"expand":{"author":{"name":"test_name"}},"id":"p1","meta":{"b":2}}`,
+		},
+		{
+			[]string{"title", "expand"},
+			`{"expand":{"author":{"bio":"test_bio","collectionId":"authors_id","collectionName":"authors","created":"","id":"a1","name":"test_name","updated":""}},"title":"test_title"}`,
+		},
+		{
+			[]string{"*", "expand.author.id"},
+			`{"author":["a1"],"collectionId":"posts_id","collectionName":"posts","created":"","expand":{"author":{"id":"a1"}},"id":"p1","meta":{"a":1,"b":2},"title":"test_title","updated":""}`,
+		},
+	}
+
+	for i, s := range scenarios {
+		m := models.NewRecord(posts)
+		m.Id = "p1"
+		m.Set("title", "test_title")
+		m.Set("meta", `{"a":1,"b":2}`)
+		m.Set("author", "a1")
+		m.SetExpand(map[string]any{"author": author})
+		m.WithFields(s.fields)
+
+		encoded, err := json.Marshal(m)
+		if err != nil {
+			t.Errorf("(%d) Unexpected error %v", i, err)
+			continue
+		}
+
+		if string(encoded) != s.expected {
+			t.Errorf("(%d) Expected \n%s \ngot \n%s", i, s.expected, encoded)
+		}
+
+		// the expanded record should remain unchanged
+		if v := author.Get("bio"); v != "test_bio" {
+			t.Errorf("(%d) Expected the expanded record to be unchanged, got bio %v", i, v)
+		}
+	}
+}
+
 func TestRecordUnmarshalJSON(t *testing.T) {
 	collection := &models.Collection{
 		Schema: schema.NewSchema(
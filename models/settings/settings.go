@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -32,6 +33,8 @@ type Settings struct {
 	Backups BackupsConfig `form:"backups" json:"backups"`
 	Agents  AgentConfig   `form:"agents" json:"agents"`
 
+	Realtime RealtimeConfig `form:"realtime" json:"realtime"`
+
 	AdminAuthToken           TokenConfig `form:"adminAuthToken" json:"adminAuthToken"`
 	AdminPasswordResetToken  TokenConfig `form:"adminPasswordResetToken" json:"adminPasswordResetToken"`
 	AdminFileToken           TokenConfig `form:"adminFileToken" json:"adminFileToken"`
@@ -211,6 +214,7 @@ func (s *Settings) Validate() error {
 		validation.Field(&s.WebDAV),
 		validation.Field(&s.Backups),
 		validation.Field(&s.Agents),
+		validation.Field(&s.Realtime),
 		validation.Field(&s.GoogleAuth),
 		validation.Field(&s.FacebookAuth),
 		validation.Field(&s.GithubAuth),
@@ -694,6 +698,89 @@ func (c AgentProviderModel) Validate() error {
 
 // -------------------------------------------------------------------
 
+type RealtimeConfig struct {
+	Channels []RealtimeChannelConfig `form:"channels" json:"channels"`
+}
+
+// Validate makes RealtimeConfig validatable by implementing [validation.Validatable] interface.
+func (c RealtimeConfig) Validate() error {
+	names := make(map[string]struct{}, len(c.Channels))
+
+	for i := range c.Channels {
+		if err := c.Channels[i].Validate(); err != nil {
+			return validation.Errors{"channels": validation.Errors{fmt.Sprint(i): err}}
+		}
+
+		if _, ok := names[c.Channels[i].Name]; ok {
+			return validation.Errors{"channels": validation.Errors{fmt.Sprint(i): validation.Errors{
+				"name": validation.NewError("validation_duplicated_channel", "The channel name must be unique."),
+			}}}
+		}
+		names[c.Channels[i].Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// FindChannel returns the first channel config matching the provided
+// channel name and the part of the name matched by the config wildcard (if any).
+func (c RealtimeConfig) FindChannel(name string) (*RealtimeChannelConfig, string) {
+	for i := range c.Channels {
+		if param, ok := c.Channels[i].Match(name); ok {
+			return &c.Channels[i], param
+		}
+	}
+
+	return nil, ""
+}
+
+// RealtimeChannelConfig defines a custom realtime broadcast channel.
+//
+// The rules use the filter syntax and, similar to the collection
+// API rules, nil means admins only and empty string - everyone.
+type RealtimeChannelConfig struct {
+	// Name is the channel name or a name prefix ending with "/*" (eg. "rooms/*").
+	Name string `form:"name" json:"name"`
+
+	// PublishRule restricts who can send messages to the channel.
+	PublishRule *string `form:"publishRule" json:"publishRule"`
+
+	// SubscribeRule restricts who can receive the channel messages
+	// (and its presence events).
+	SubscribeRule *string `form:"subscribeRule" json:"subscribeRule"`
+
+	// Presence enables the channel join/leave events and members list.
+	Presence bool `form:"presence" json:"presence"`
+}
+
+var (
+	realtimeChannelNameRegex  = regexp.MustCompile(`^[\w\-\.:]+(/[\w\-\.:]+)*(/\*)?$`)
+	realtimeChannelParamRegex = regexp.MustCompile(`^[\w\-\.:]+$`)
+)
+
+// Validate makes RealtimeChannelConfig validatable by implementing [validation.Validatable] interface.
+func (c RealtimeChannelConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 255), validation.Match(realtimeChannelNameRegex)),
+	)
+}
+
+// Match reports whether name matches the channel config name
+// and returns the part of the name matched by the wildcard (if any).
+func (c RealtimeChannelConfig) Match(name string) (string, bool) {
+	if prefix, ok := strings.CutSuffix(c.Name, "*"); ok {
+		param := strings.TrimPrefix(name, prefix)
+		if len(param) == len(name) || !realtimeChannelParamRegex.MatchString(param) {
+			return "", false
+		}
+		return param, true
+	}
+
+	return "", name == c.Name
+}
+
+// -------------------------------------------------------------------
+
 type MetaConfig struct {
 	AppName                    string        `form:"appName" json:"appName"`
 	AppUrl                     string        `form:"appUrl" json:"appUrl"`
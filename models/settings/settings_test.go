@@ -1190,3 +1190,93 @@ func TestAgentEmbeddingProviderApiValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestRealtimeConfigValidate(t *testing.T) {
+	scenarios := []struct {
+		config      settings.RealtimeConfig
+		expectError bool
+	}{
+		// zero values
+		{
+			settings.RealtimeConfig{},
+			false,
+		},
+		// missing name
+		{
+			settings.RealtimeConfig{Channels: []settings.RealtimeChannelConfig{{}}},
+			true,
+		},
+		// invalid names
+		{
+			settings.RealtimeConfig{Channels: []settings.RealtimeChannelConfig{{Name: "*"}}},
+			true,
+		},
+		{
+			settings.RealtimeConfig{Channels: []settings.RealtimeChannelConfig{{Name: "rooms/*/typing"}}},
+			true,
+		},
+		{
+			settings.RealtimeConfig{Channels: []settings.RealtimeChannelConfig{{Name: "rooms?x"}}},
+			true,
+		},
+		// duplicated names
+		{
+			settings.RealtimeConfig{Channels: []settings.RealtimeChannelConfig{{Name: "chat"}, {Name: "chat"}}},
+			true,
+		},
+		// valid data
+		{
+			settings.RealtimeConfig{Channels: []settings.RealtimeChannelConfig{{Name: "chat"}, {Name: "rooms/*", Presence: true}}},
+			false,
+		},
+	}
+
+	for i, scenario := range scenarios {
+		result := scenario.config.Validate()
+
+		if result != nil && !scenario.expectError {
+			t.Errorf("(%d) Didn't expect error, got %v", i, result)
+		}
+
+		if result == nil && scenario.expectError {
+			t.Errorf("(%d) Expected error, got nil", i)
+		}
+	}
+}
+
+func TestRealtimeConfigFindChannel(t *testing.T) {
+	config := settings.RealtimeConfig{
+		Channels: []settings.RealtimeChannelConfig{
+			{Name: "chat"},
+			{Name: "rooms/*"},
+		},
+	}
+
+	scenarios := []struct {
+		name          string
+		expectChannel string
+		expectParam   string
+	}{
+		{"", "", ""},
+		{"missing", "", ""},
+		{"chat", "chat", ""},
+		{"chat/abc", "", ""},
+		{"rooms", "", ""},
+		{"rooms/", "", ""},
+		{"rooms/abc", "rooms/*", "abc"},
+		{"rooms/abc/def", "", ""},
+	}
+
+	for _, s := range scenarios {
+		channel, param := config.FindChannel(s.name)
+
+		var channelName string
+		if channel != nil {
+			channelName = channel.Name
+		}
+
+		if channelName != s.expectChannel || param != s.expectParam {
+			t.Errorf("[%s] Expected (%q, %q), got (%q, %q)", s.name, s.expectChannel, s.expectParam, channelName, param)
+		}
+	}
+}
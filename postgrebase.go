@@ -38,6 +38,7 @@ type PostgreBase struct {
 	dataDataFlag      string
 	redisFlag         string
 	encryptionEnvFlag string
+	pgNotifyFlag      bool
 	peersFlag         []string
 	nodeAddrFlag      string
 	nodeIDFlag        string
@@ -54,6 +55,7 @@ type Config struct {
 	DefaultDataDir       string // if not set, it will fallback to "./pb_data"
 	DefaultDataDsn       string // if not set, it will fallback to "sqlite://pb_data/data.db"
 	RedisDsn             string //redis://<user>:<pass>@localhost:6379/<db>
+	PgNotify             bool   // distribute the realtime events with the PostgreSQL LISTEN/NOTIFY
 	DefaultEncryptionEnv string
 	DisableVector        bool
 	ClusterPeers         []string
@@ -128,6 +130,7 @@ func NewWithConfig(config Config) *PostgreBase {
 		redisFlag:         config.RedisDsn,
 		dataDataFlag:      config.DefaultDataDsn,
 		encryptionEnvFlag: config.DefaultEncryptionEnv,
+		pgNotifyFlag:      config.PgNotify,
 		hideStartBanner:   config.HideStartBanner,
 	}
 
@@ -140,6 +143,7 @@ func NewWithConfig(config Config) *PostgreBase {
 		DataDir:          pb.dataDirFlag,
 		DataDsn:          pb.dataDataFlag,
 		RedisDsn:         pb.redisFlag,
+		PgNotify:         pb.pgNotifyFlag,
 		EncryptionEnv:    pb.encryptionEnvFlag,
 		IsDebug:          pb.debugFlag,
 		DisableVector:    config.DisableVector,
@@ -235,6 +239,13 @@ func (pb *PostgreBase) eagerParseFlags(config *Config) error {
 		"Cache data Redis dsn(default  redis://<user>:<pass>@localhost:6379/<db>  redis://localhost:6379/0)",
 	)
 
+	pb.RootCmd.PersistentFlags().BoolVar(
+		&pb.pgNotifyFlag,
+		"pgNotify",
+		config.PgNotify,
+		"distribute the realtime events and cache invalidations with the PostgreSQL LISTEN/NOTIFY\n(captures also the collection table writes from other services)",
+	)
+
 	pb.RootCmd.PersistentFlags().StringVar(
 		&pb.encryptionEnvFlag,
 		"encryptionEnv",
@@ -274,6 +285,7 @@ func (pb *PostgreBase) eagerParseFlags(config *Config) error {
 		"dir":           true,
 		"dataDsn":       true,
 		"redisDsn":      true,
+		"pgNotify":      true,
 		"encryptionEnv": true,
 		"debug":         true,
 		"peers":         true,
@@ -281,7 +293,8 @@ func (pb *PostgreBase) eagerParseFlags(config *Config) error {
 		"node-id":       true,
 	}
 	boolFlags := map[string]bool{
-		"debug": true,
+		"debug":    true,
+		"pgNotify": true,
 	}
 
 	if err := pb.RootCmd.ParseFlags(filterEagerFlagArgs(os.Args[1:], knownFlags, boolFlags)); err != nil {
@@ -371,6 +384,7 @@ func nodeAddrFromHTTPAddr(httpAddr string) string {
 // - is unknown command
 // - is the default help command
 // - is the default version command
+// - is the mcp command in remote (proxy) mode
 //
 // https://github.com/pocketbase/pocketbase/issues/404
 // https://github.com/pocketbase/pocketbase/discussions/1267
@@ -391,6 +405,14 @@ func (pb *PostgreBase) skipBootstrap() bool {
 		return true // unknown command
 	}
 
+	if cmd.Name() == "mcp" {
+		for _, arg := range os.Args {
+			if arg == "--remote" || strings.HasPrefix(arg, "--remote=") {
+				return true // the proxy mode doesn't need a local app
+			}
+		}
+	}
+
 	for _, arg := range os.Args {
 		if !list.ExistInSlice(arg, flags) {
 			continue
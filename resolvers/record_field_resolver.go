@@ -114,6 +114,12 @@ func NewRecordFieldResolver(
 	return r
 }
 
+// LoadedCollections returns the base collection and all other
+// collections loaded while resolving the fields (eg. relations and `@collection.*`).
+func (r *RecordFieldResolver) LoadedCollections() []*models.Collection {
+	return r.loadedCollections
+}
+
 // UpdateQuery implements `search.FieldResolver` interface.
 //
 // Conditionally updates the provided search query based on the
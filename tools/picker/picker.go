@@ -0,0 +1,119 @@
+// Package picker implements a generic fields picker (aka. projection)
+// for the JSON-like response data.
+package picker
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// WildcardField matches all fields of the current nesting level.
+const WildcardField string = "*"
+
+// ParseFields splits the provided comma separated fields
+// expression into a list of trimmed, non-empty field paths.
+//
+// Example:
+//
+//	fields := picker.ParseFields("id, title,expand.author.name")
+//	// []string{"id", "title", "expand.author.name"}
+func ParseFields(raw string) []string {
+	result := []string{}
+
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f != "" {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// Pick filters the provided data keeping only the matching field paths
+// (eg. "a", "b.c", "*", "b.*") and returns the picked result.
+//
+// Maps (and slices of maps) are modified in place. Other non-scalar values
+// that need nested picking (eg. structs with custom JSON serialization)
+// are converted to their generic JSON representation first.
+//
+// A more specific field path takes precedence over the wildcard, meaning
+// that "*,b.c" will return all fields, but only "c" from the nested "b".
+func Pick(data any, fields []string) any {
+	if len(fields) == 0 {
+		return data // nothing to pick
+	}
+
+	switch v := data.(type) {
+	case nil:
+		return v
+	case map[string]any:
+		pickMap(v, fields)
+		return v
+	case []map[string]any:
+		for _, item := range v {
+			pickMap(item, fields)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = Pick(item, fields)
+		}
+		return v
+	}
+
+	switch reflect.ValueOf(data).Kind() {
+	case reflect.Struct, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		// convert to generic json value
+	default:
+		return data // scalar
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return data
+	}
+
+	switch decoded.(type) {
+	case map[string]any, []any:
+		return Pick(decoded, fields)
+	}
+
+	return data
+}
+
+func pickMap(data map[string]any, fields []string) {
+	for k, v := range data {
+		var isFull bool
+		var hasWildcard bool
+		var nested []string
+
+		for _, f := range fields {
+			switch {
+			case f == k:
+				isFull = true
+			case f == WildcardField:
+				hasWildcard = true
+			case strings.HasPrefix(f, k+"."):
+				nested = append(nested, strings.TrimPrefix(f, k+"."))
+			}
+		}
+
+		switch {
+		case isFull:
+			// keep the entire value
+		case len(nested) > 0:
+			data[k] = Pick(v, nested)
+		case hasWildcard:
+			// keep the entire value
+		default:
+			delete(data, k)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package picker_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/tools/picker"
+)
+
+type testStruct struct {
+	A int            `json:"a"`
+	B map[string]int `json:"b"`
+}
+
+func TestParseFields(t *testing.T) {
+	scenarios := []struct {
+		raw      string
+		expected string
+	}{
+		{"", `[]`},
+		{" , ,", `[]`},
+		{"a", `["a"]`},
+		{" a, b.c ,*,", `["a","b.c","*"]`},
+	}
+
+	for i, s := range scenarios {
+		encoded, _ := json.Marshal(picker.ParseFields(s.raw))
+		if string(encoded) != s.expected {
+			t.Errorf("(%d) Expected %s, got %s", i, s.expected, encoded)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		data     any
+		fields   []string
+		expected string
+	}{
+		{
+			"no fields",
+			map[string]any{"a": 1, "b": 2},
+			nil,
+			`{"a":1,"b":2}`,
+		},
+		{
+			"scalar",
+			"test",
+			[]string{"a"},
+			`"test"`,
+		},
+		{
+			"map with existing and missing fields",
+			map[string]any{"a": 1, "b": 2, "c": 3},
+			[]string{"a", "c", "missing"},
+			`{"a":1,"c":3}`,
+		},
+		{
+			"slice of maps",
+			[]any{map[string]any{"a": 1, "b": 2}, "test", map[string]any{"b": 3}},
+			[]string{"a"},
+			`[{"a":1},"test",{}]`,
+		},
+		{
+			"nested fields",
+			map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}, "e": []map[string]any{{"f": 4, "g": 5}}},
+			[]string{"b.c", "e.g"},
+			`{"b":{"c":2},"e":[{"g":5}]}`,
+		},
+		{
+			"wildcard",
+			map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}},
+			[]string{"*"},
+			`{"a":1,"b":{"c":2,"d":3}}`,
+		},
+		{
+			"wildcard with more specific nested field",
+			map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}},
+			[]string{"*", "b.c"},
+			`{"a":1,"b":{"c":2}}`,
+		},
+		{
+			"nested wildcard",
+			map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}},
+			[]string{"b.*"},
+			`{"b":{"c":2,"d":3}}`,
+		},
+		{
+			"full field with nested field",
+			map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}},
+			[]string{"b", "b.c"},
+			`{"b":{"c":2,"d":3}}`,
+		},
+		{
+			"nested struct",
+			map[string]any{"a": 1, "b": &testStruct{A: 2, B: map[string]int{"c": 3, "d": 4}}},
+			[]string{"b.b.d"},
+			`{"b":{"b":{"d":4}}}`,
+		},
+		{
+			"nested raw json",
+			map[string]any{"a": json.RawMessage(`{"b":1,"c":2}`)},
+			[]string{"a.c"},
+			`{"a":{"c":2}}`,
+		},
+	}
+
+	for _, s := range scenarios {
+		encoded, err := json.Marshal(picker.Pick(s.data, s.fields))
+		if err != nil {
+			t.Errorf("[%s] Failed to encode the result: %v", s.name, err)
+			continue
+		}
+
+		if string(encoded) != s.expected {
+			t.Errorf("[%s] Expected \n%s, \ngot \n%s", s.name, s.expected, encoded)
+		}
+	}
+}
@@ -136,6 +136,13 @@ func TestSerialize(t *testing.T) {
 			"fields=a, c, anySlice.A, mapSlice.C, mapSlice.D.DA, anySlice.D,fullMap",
 			`{"a":1,"anySlice":[{"A":[1,2,3],"D":{"DA":1,"DB":2}},{"A":"test"}],"c":"test","fullMap":[{"A":[1,2,3],"B":["1","2",3],"C":"test"},{"B":["1","2",3],"D":[{"DA":2},{"DA":3}]}],"mapSlice":[{"C":"test","D":[{"DA":1}]},{"D":[{"DA":2},{"DA":3},{}]}]}`,
 		},
+		{
+			"wildcard with nested fields",
+			rest.Serializer{},
+			map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}},
+			"fields=*,b.c",
+			`{"a":1,"b":{"c":2}}`,
+		},
 		{
 			"SearchResult",
 			rest.Serializer{},
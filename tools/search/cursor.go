@@ -0,0 +1,395 @@
+package search
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhenruyan/postgrebase/dbx"
+	"github.com/zhenruyan/postgrebase/tools/security"
+)
+
+// cursor value types
+const (
+	cursorTypeNull   string = "n"
+	cursorTypeBool   string = "b"
+	cursorTypeInt    string = "i"
+	cursorTypeFloat  string = "f"
+	cursorTypeString string = "s"
+	cursorTypeTime   string = "t"
+)
+
+// cursorKey defines a single resolved keyset pagination sort key.
+type cursorKey struct {
+	name       string
+	identifier string
+	desc       bool
+}
+
+// cursorValue defines a single typed and serialized cursor sort key value.
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+// cursorData defines the decoded opaque cursor structure.
+type cursorData struct {
+	Sort   string        `json:"s"`
+	Values []cursorValue `json:"v"`
+}
+
+// cursorSortSignature returns a short fingerprint of the provided sort
+// keys used to invalidate cursors generated for a different sort.
+func cursorSortSignature(keys []cursorKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if key.desc {
+			parts[i] = "-" + key.name
+		} else {
+			parts[i] = "+" + key.name
+		}
+	}
+
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(strings.Join(parts, ",")))), 36)
+}
+
+// cursorEncryptionKey derives the cursor AES key from the provided secret.
+func cursorEncryptionKey(secret string) string {
+	sum := sha256.Sum256([]byte("cursor:" + secret))
+
+	return string(sum[:])
+}
+
+// encodeCursor serializes the sort key values of a single row into an opaque cursor string.
+//
+// If secret is not empty, the cursor is encrypted with a key derived from it,
+// so that the sort values can't be read (eg. hidden emails) or forged.
+func encodeCursor(keys []cursorKey, values []any, secret string) (string, error) {
+	if len(keys) != len(values) {
+		return "", errors.New("cursor keys and values mismatch")
+	}
+
+	data := cursorData{
+		Sort:   cursorSortSignature(keys),
+		Values: make([]cursorValue, len(values)),
+	}
+
+	for i, v := range values {
+		data.Values[i] = newCursorValue(v)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	if secret != "" {
+		encrypted, err := security.Encrypt(raw, cursorEncryptionKey(secret))
+		if err != nil {
+			return "", err
+		}
+
+		if raw, err = base64.StdEncoding.DecodeString(encrypted); err != nil {
+			return "", err
+		}
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor parses the provided opaque cursor string and returns
+// its sort key values.
+//
+// Returns an error if the cursor is malformed, wasn't encrypted with
+// the provided secret or was generated for a different sort.
+func decodeCursor(cursor string, keys []cursorKey, secret string) ([]any, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	if secret != "" {
+		raw, err = security.Decrypt(base64.StdEncoding.EncodeToString(raw), cursorEncryptionKey(secret))
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	data := cursorData{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	if data.Sort != cursorSortSignature(keys) || len(data.Values) != len(keys) {
+		return nil, errors.New("the cursor doesn't match the current sort")
+	}
+
+	values := make([]any, len(data.Values))
+	for i, v := range data.Values {
+		values[i], err = v.decode()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// newCursorValue converts a scanned db value into a typed cursorValue.
+func newCursorValue(v any) cursorValue {
+	switch val := v.(type) {
+	case nil:
+		return cursorValue{Type: cursorTypeNull}
+	case bool:
+		return cursorValue{Type: cursorTypeBool, Value: strconv.FormatBool(val)}
+	case int64:
+		return cursorValue{Type: cursorTypeInt, Value: strconv.FormatInt(val, 10)}
+	case float64:
+		return cursorValue{Type: cursorTypeFloat, Value: strconv.FormatFloat(val, 'g', -1, 64)}
+	case string:
+		return cursorValue{Type: cursorTypeString, Value: val}
+	case []byte:
+		return cursorValue{Type: cursorTypeString, Value: string(val)}
+	case time.Time:
+		return cursorValue{Type: cursorTypeTime, Value: val.Format(time.RFC3339Nano)}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return cursorValue{Type: cursorTypeInt, Value: strconv.FormatInt(rv.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cursorValue{Type: cursorTypeInt, Value: strconv.FormatUint(rv.Uint(), 10)}
+	case reflect.Float32:
+		return cursorValue{Type: cursorTypeFloat, Value: strconv.FormatFloat(rv.Float(), 'g', -1, 64)}
+	}
+
+	return cursorValue{Type: cursorTypeString, Value: fmt.Sprint(v)}
+}
+
+// decode converts the cursorValue back to its typed Go value.
+func (v cursorValue) decode() (any, error) {
+	switch v.Type {
+	case cursorTypeNull:
+		return nil, nil
+	case cursorTypeBool:
+		return strconv.ParseBool(v.Value)
+	case cursorTypeInt:
+		return strconv.ParseInt(v.Value, 10, 64)
+	case cursorTypeFloat:
+		return strconv.ParseFloat(v.Value, 64)
+	case cursorTypeString:
+		return v.Value, nil
+	case cursorTypeTime:
+		return time.Parse(time.RFC3339Nano, v.Value)
+	}
+
+	return nil, fmt.Errorf("invalid cursor value type %q", v.Type)
+}
+
+// cursorItemKey extracts the unique key (eg. "id") of the last
+// element in the provided items slice pointer.
+//
+// Supported elements are models with GetId() method, maps
+// (including dbx.NullStringMap) and structs with matching "db" tag.
+func cursorItemKey(items any, col string) (any, error) {
+	rv := reflect.ValueOf(items)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Slice || rv.Len() == 0 {
+		return nil, errors.New("missing cursor items")
+	}
+
+	item := rv.Index(rv.Len() - 1)
+
+	if col == "id" && item.CanInterface() {
+		if m, ok := item.Interface().(interface{ GetId() string }); ok {
+			return m.GetId(), nil
+		}
+	}
+
+	for item.Kind() == reflect.Pointer || item.Kind() == reflect.Interface {
+		item = item.Elem()
+	}
+
+	switch item.Kind() {
+	case reflect.Map:
+		v := item.MapIndex(reflect.ValueOf(col))
+		if v.IsValid() {
+			if ns, ok := v.Interface().(sql.NullString); ok {
+				return ns.String, nil
+			}
+			return v.Interface(), nil
+		}
+	case reflect.Struct:
+		t := item.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if strings.Split(t.Field(i).Tag.Get("db"), ",")[0] == col {
+				return item.Field(i).Interface(), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unable to extract the cursor key %q from the last item", col)
+}
+
+// trimItems shrinks the provided items slice pointer to the specified length.
+//
+// Returns false if the slice wasn't longer than the specified length.
+func trimItems(items any, length int) bool {
+	rv := reflect.ValueOf(items)
+	if rv.Kind() != reflect.Pointer {
+		return false
+	}
+
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Slice || rv.Len() <= length {
+		return false
+	}
+
+	rv.Set(rv.Slice(0, length))
+
+	return true
+}
+
+// keysetExpr is a [dbx.Expression] that matches the rows positioned
+// after the provided sort key values (aka. keyset pagination).
+type keysetExpr struct {
+	keys   []cursorKey
+	values []any
+}
+
+// Build implements [dbx.Expression.Build] interface method.
+//
+// The generated condition respects the default NULL ordering of the
+// db driver (NULLs are considered largest in PostgreSQL and smallest
+// in SQLite and MySQL).
+func (e *keysetExpr) Build(db *dbx.DB, params dbx.Params) string {
+	if len(e.keys) == 0 || len(e.keys) != len(e.values) {
+		return "0=1"
+	}
+
+	nullsLargest := db.DriverName() == "postgres" || db.DriverName() == "pgx"
+
+	placeholders := make([]string, len(e.values))
+	for i, v := range e.values {
+		if v != nil {
+			name := fmt.Sprintf("pbCursor%d", i)
+			params[name] = v
+			placeholders[i] = "{:" + name + "}"
+		}
+	}
+
+	ors := make([]string, 0, len(e.keys))
+	for i, key := range e.keys {
+		parts := make([]string, 0, i+1)
+
+		// all previous keys are equal...
+		for j := 0; j < i; j++ {
+			if e.values[j] == nil {
+				parts = append(parts, e.keys[j].identifier+" IS NULL")
+			} else {
+				parts = append(parts, e.keys[j].identifier+" = "+placeholders[j])
+			}
+		}
+
+		// ...and the current one is positioned after the cursor value
+		nullsAfter := key.desc != nullsLargest
+		if e.values[i] == nil {
+			if nullsAfter {
+				parts = append(parts, "0=1")
+			} else {
+				parts = append(parts, key.identifier+" IS NOT NULL")
+			}
+		} else {
+			op := " > "
+			if key.desc {
+				op = " < "
+			}
+			if nullsAfter {
+				parts = append(parts, "("+key.identifier+op+placeholders[i]+" OR "+key.identifier+" IS NULL)")
+			} else {
+				parts = append(parts, key.identifier+op+placeholders[i])
+			}
+		}
+
+		ors = append(ors, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return strings.Join(ors, " OR ")
+}
+
+// estimateCount returns the PostgreSQL planner row estimate for the provided
+// count query, using the table statistics (reltuples) for unfiltered
+// single table queries and EXPLAIN for everything else.
+//
+// The returned bool is false if the query db driver doesn't support estimations.
+func estimateCount(query dbx.SelectQuery, countCol string) (int, bool, error) {
+	info := query.Info()
+	if info.Builder == nil {
+		return 0, false, nil
+	}
+
+	driver := info.Builder.DriverName()
+	if driver != "postgres" && driver != "pgx" {
+		return 0, false, nil
+	}
+
+	if info.Where == nil &&
+		info.Having == nil &&
+		len(info.Join) == 0 &&
+		len(info.GroupBy) == 0 &&
+		len(info.Union) == 0 &&
+		len(info.From) == 1 &&
+		!strings.ContainsAny(info.From[0], " ({") {
+		var total int64
+		err := info.Builder.NewQuery("SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass({:table})").
+			Bind(dbx.Params{"table": info.Builder.QuoteSimpleTableName(info.From[0])}).
+			WithContext(info.Context).
+			Row(&total)
+
+		// negative reltuples means that the table was never analyzed
+		if err == nil && total >= 0 {
+			return int(total), true, nil
+		}
+	}
+
+	// note: query is a shallow copy and in-place slice/map modifications should be avoided
+	q := query.Distinct(false).
+		Select("[[" + countCol + "]]").
+		OrderBy( /* reset */ ).
+		Build()
+
+	var plan string
+	err := info.Builder.NewQuery("EXPLAIN (FORMAT JSON) " + q.SQL()).
+		Bind(q.Params()).
+		WithContext(info.Context).
+		Row(&plan)
+	if err != nil {
+		return 0, true, err
+	}
+
+	parsed := []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}{}
+	if err := json.Unmarshal([]byte(plan), &parsed); err != nil {
+		return 0, true, err
+	}
+	if len(parsed) == 0 {
+		return 0, true, errors.New("missing query plan")
+	}
+
+	return int(parsed[0].Plan.Rows), true, nil
+}
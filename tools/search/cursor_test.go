@@ -0,0 +1,434 @@
+package search
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhenruyan/postgrebase/dbx"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	keys := []cursorKey{
+		{name: "a", identifier: "[[a]]"},
+		{name: "b", identifier: "[[b]]", desc: true},
+		{name: "id", identifier: "[[id]]"},
+	}
+
+	date := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	scenarios := []struct {
+		name   string
+		values []any
+		expect []any
+	}{
+		{"nil", []any{nil, nil, "x"}, []any{nil, nil, "x"}},
+		{"numbers", []any{int64(-12), 1.5, "x"}, []any{int64(-12), 1.5, "x"}},
+		{"int kinds", []any{int32(7), uint8(8), "x"}, []any{int64(7), int64(8), "x"}},
+		{"bool and bytes", []any{true, []byte("abc"), "x"}, []any{true, "abc", "x"}},
+		{"time", []any{date, "test", "x"}, []any{date, "test", "x"}},
+	}
+
+	for _, secret := range []string{"", "test_secret"} {
+		for _, s := range scenarios {
+			name := s.name + " (secret " + secret + ")"
+
+			cursor, err := encodeCursor(keys, s.values, secret)
+			if err != nil {
+				t.Errorf("[%s] Failed to encode cursor: %v", name, err)
+				continue
+			}
+
+			values, err := decodeCursor(cursor, keys, secret)
+			if err != nil {
+				t.Errorf("[%s] Failed to decode cursor: %v", name, err)
+				continue
+			}
+
+			if len(values) != len(s.expect) {
+				t.Errorf("[%s] Expected %d values, got %d", name, len(s.expect), len(values))
+				continue
+			}
+
+			for i, v := range values {
+				if tv, ok := v.(time.Time); ok {
+					if !tv.Equal(s.expect[i].(time.Time)) {
+						t.Errorf("[%s] Expected value %d to be %v, got %v", name, i, s.expect[i], v)
+					}
+				} else if v != s.expect[i] {
+					t.Errorf("[%s] Expected value %d to be %#v, got %#v", name, i, s.expect[i], v)
+				}
+			}
+		}
+	}
+
+	if _, err := encodeCursor(keys, []any{"x"}, ""); err == nil {
+		t.Fatal("Expected keys and values mismatch error, got nil")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	keys := []cursorKey{{name: "a", identifier: "[[a]]"}, {name: "id", identifier: "[[id]]"}}
+
+	valid, err := encodeCursor(keys, []any{"1", "2"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encodeCursor(keys, []any{"1", "2"}, "test_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name   string
+		cursor string
+		keys   []cursorKey
+		secret string
+	}{
+		{"non base64", "!!!", keys, ""},
+		{"non json", "YWJj", keys, ""},
+		{"different sort direction", valid, []cursorKey{{name: "a", desc: true}, {name: "id"}}, ""},
+		{"different sort fields", valid, []cursorKey{{name: "b"}, {name: "id"}}, ""},
+		{"invalid value type", rawCursor(`{"s":"` + cursorSortSignature(nil) + `","v":[{"t":"x"}]}`), []cursorKey{}, ""},
+		{"unencrypted cursor with secret", valid, keys, "test_secret"},
+		{"different secret", encrypted, keys, "other_secret"},
+		{"encrypted cursor without secret", encrypted, keys, ""},
+	}
+
+	for _, s := range scenarios {
+		if _, err := decodeCursor(s.cursor, s.keys, s.secret); err == nil {
+			t.Errorf("[%s] Expected error, got nil", s.name)
+		}
+	}
+}
+
+func rawCursor(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+func TestKeysetExprBuild(t *testing.T) {
+	keys := []cursorKey{
+		{name: "a", identifier: "a"},
+		{name: "b", identifier: "b", desc: true},
+	}
+
+	scenarios := []struct {
+		driver string
+		values []any
+		expect string
+	}{
+		{
+			"sqlite",
+			[]any{1, 2},
+			"(a > {:pbCursor0}) OR (a = {:pbCursor0} AND (b < {:pbCursor1} OR b IS NULL))",
+		},
+		{
+			"sqlite",
+			[]any{nil, nil},
+			"(a IS NOT NULL) OR (a IS NULL AND 0=1)",
+		},
+		{
+			"postgres",
+			[]any{1, 2},
+			"((a > {:pbCursor0} OR a IS NULL)) OR (a = {:pbCursor0} AND b < {:pbCursor1})",
+		},
+		{
+			"pgx",
+			[]any{nil, nil},
+			"(0=1) OR (a IS NULL AND b IS NOT NULL)",
+		},
+		{
+			"sqlite",
+			[]any{1},
+			"0=1",
+		},
+	}
+
+	for i, s := range scenarios {
+		params := dbx.Params{}
+
+		expr := &keysetExpr{keys: keys, values: s.values}
+
+		result := expr.Build(dbx.NewFromDB(nil, s.driver), params)
+		if result != s.expect {
+			t.Errorf("(%d) Expected \n%s, \ngot \n%s", i, s.expect, result)
+		}
+	}
+}
+
+func TestCursorItemKey(t *testing.T) {
+	type testItem struct {
+		Key string `db:"id,omitempty"`
+	}
+
+	scenarios := []struct {
+		name        string
+		items       any
+		expectError bool
+		expectKey   any
+	}{
+		{"non slice", &testItem{}, true, nil},
+		{"empty slice", &[]testItem{}, true, nil},
+		{"struct db tag", &[]testItem{{"a"}, {"b"}}, false, "b"},
+		{"struct pointers", &[]*testItem{{"a"}, {"c"}}, false, "c"},
+		{"struct without tag", &[]testTableStruct{{}}, true, nil},
+		{"NullStringMap", &[]dbx.NullStringMap{{"id": sql.NullString{String: "d", Valid: true}}}, false, "d"},
+		{"map", &[]map[string]any{{"id": 5}}, false, 5},
+	}
+
+	for _, s := range scenarios {
+		key, err := cursorItemKey(s.items, "id")
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.name, s.expectError, hasErr, err)
+			continue
+		}
+
+		if key != s.expectKey {
+			t.Errorf("[%s] Expected key %v, got %v", s.name, s.expectKey, key)
+		}
+	}
+}
+
+func TestProviderExecCursor(t *testing.T) {
+	testDB, err := createTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+
+	testDB.CreateTable("cursor_items", map[string]string{"id": "text primary key", "num": "int null"}).Execute()
+	for _, item := range []struct {
+		id  string
+		num any
+	}{{"a", 1}, {"b", nil}, {"c", 2}, {"d", 1}, {"e", nil}, {"f", 3}} {
+		testDB.Insert("cursor_items", dbx.Params{"id": item.id, "num": item.num}).Execute()
+	}
+
+	type cursorItem struct {
+		Id  string        `db:"id"`
+		Num sql.NullInt64 `db:"num"`
+	}
+
+	scenarios := []struct {
+		name    string
+		sort    []SortField
+		perPage int
+		expect  string
+	}{
+		{"default tiebreaker", nil, 4, "a,b,c,d|e,f"},
+		{"asc with nulls", []SortField{{"num", SortAsc}}, 2, "b,e|a,d|c,f"},
+		{"desc with nulls", []SortField{{"num", SortDesc}}, 4, "f,c,a,d|b,e"},
+		{"desc with nulls (split)", []SortField{{"num", SortDesc}}, 3, "f,c,a|d,b,e"},
+		{"explicit tiebreaker", []SortField{{"id", SortDesc}}, 5, "f,e,d,c,b|a"},
+		{"single page", []SortField{{"num", SortAsc}}, 10, "b,e,a,d,c,f"},
+	}
+
+	for _, s := range scenarios {
+		pages := []string{}
+		cursor := ""
+		for i := 0; i < 10; i++ {
+			items := []cursorItem{}
+
+			result, err := NewProvider(&testFieldResolver{}).
+				Query(testDB.Select("*").From("cursor_items").OrderBy("num DESC")).
+				Sort(s.sort).
+				PerPage(s.perPage).
+				Page(2). // ignored
+				Cursor(cursor).
+				Exec(&items)
+			if err != nil {
+				t.Fatalf("[%s] Failed to execute page %d: %v", s.name, i, err)
+			}
+
+			if result.Page != 1 || result.TotalItems != 6 {
+				t.Errorf("[%s] Expected page 1 and 6 total items, got %d and %d", s.name, result.Page, result.TotalItems)
+			}
+
+			ids := make([]string, len(items))
+			for j, item := range items {
+				ids[j] = item.Id
+			}
+			pages = append(pages, strings.Join(ids, ","))
+
+			if result.NextCursor == "" {
+				break
+			}
+			cursor = result.NextCursor
+		}
+
+		if v := strings.Join(pages, "|"); v != s.expect {
+			t.Errorf("[%s] Expected pages %q, got %q", s.name, s.expect, v)
+		}
+	}
+}
+
+func TestProviderExecCursorErrors(t *testing.T) {
+	testDB, err := createTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+
+	validCursor, err := encodeCursor([]cursorKey{{name: "test1"}, {name: "id"}}, []any{int64(1), int64(1)}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedCursor, err := encodeCursor([]cursorKey{{name: "test1"}, {name: "id"}}, []any{int64(1), int64(1)}, "test_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name        string
+		sort        []SortField
+		cursor      string
+		secret      string
+		expectError bool
+	}{
+		{"random sort", []SortField{{randomSortKey, SortAsc}}, "", "", true},
+		{"invalid sort field", []SortField{{"unknown", SortAsc}}, "", "", true},
+		{"invalid cursor", []SortField{{"test1", SortAsc}}, "invalid", "", true},
+		{"cursor for a different sort", []SortField{{"test1", SortDesc}}, validCursor, "", true},
+		{"valid cursor", []SortField{{"test1", SortAsc}}, validCursor, "", false},
+		{"unencrypted cursor with secret", []SortField{{"test1", SortAsc}}, validCursor, "test_secret", true},
+		{"encrypted cursor with wrong secret", []SortField{{"test1", SortAsc}}, encryptedCursor, "other_secret", true},
+		{"valid encrypted cursor", []SortField{{"test1", SortAsc}}, encryptedCursor, "test_secret", false},
+	}
+
+	for _, s := range scenarios {
+		_, err := NewProvider(&testFieldResolver{}).
+			Query(testDB.Select("*").From("test")).
+			Sort(s.sort).
+			CursorSecret(s.secret).
+			Cursor(s.cursor).
+			Exec(&[]testTableStruct{})
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.name, s.expectError, hasErr, err)
+		}
+	}
+}
+
+func TestProviderExecCursorHidesSortValues(t *testing.T) {
+	testDB, err := createTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+
+	result, err := NewProvider(&testFieldResolver{}).
+		Query(testDB.Select("*").From("test")).
+		Sort([]SortField{{"test2", SortAsc}}).
+		CursorSecret("test_secret").
+		PerPage(1).
+		Cursor("").
+		Exec(&[]dbx.NullStringMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.NextCursor == "" {
+		t.Fatal("Expected non-empty next cursor")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(result.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(raw), "test2.") {
+		t.Fatalf("Expected the cursor to not contain the sort values, got %s", raw)
+	}
+}
+
+func TestProviderExecCursorChangedBoundaryItem(t *testing.T) {
+	scenarios := []struct {
+		name   string
+		change func(db *testDB) error
+		expect string
+	}{
+		{
+			"deleted boundary item",
+			func(db *testDB) error {
+				_, err := db.Delete("cursor_boundary", dbx.HashExp{"id": "b"}).Execute()
+				return err
+			},
+			"c,d",
+		},
+		{
+			"boundary item moved forward",
+			func(db *testDB) error {
+				_, err := db.Update("cursor_boundary", dbx.Params{"num": 10}, dbx.HashExp{"id": "b"}).Execute()
+				return err
+			},
+			"c,d",
+		},
+		{
+			"boundary item moved backward",
+			func(db *testDB) error {
+				_, err := db.Update("cursor_boundary", dbx.Params{"num": 0}, dbx.HashExp{"id": "b"}).Execute()
+				return err
+			},
+			"c,d",
+		},
+	}
+
+	for _, s := range scenarios {
+		func() {
+			testDB, err := createTestDB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer testDB.Close()
+
+			testDB.DropTable("cursor_boundary").Execute()
+			testDB.CreateTable("cursor_boundary", map[string]string{"id": "text primary key", "num": "int null"}).Execute()
+			for i, id := range []string{"a", "b", "c", "d"} {
+				testDB.Insert("cursor_boundary", dbx.Params{"id": id, "num": i + 1}).Execute()
+			}
+
+			exec := func(cursor string) ([]string, string) {
+				items := []dbx.NullStringMap{}
+
+				result, err := NewProvider(&testFieldResolver{}).
+					Query(testDB.Select("*").From("cursor_boundary")).
+					Sort([]SortField{{"num", SortAsc}}).
+					CursorSecret("test_secret").
+					PerPage(2).
+					Cursor(cursor).
+					Exec(&items)
+				if err != nil {
+					t.Fatalf("[%s] Failed to execute: %v", s.name, err)
+				}
+
+				ids := make([]string, len(items))
+				for i, item := range items {
+					ids[i] = item["id"].String
+				}
+
+				return ids, result.NextCursor
+			}
+
+			firstPage, cursor := exec("")
+			if v := strings.Join(firstPage, ","); v != "a,b" {
+				t.Fatalf("[%s] Expected first page a,b, got %s", s.name, v)
+			}
+
+			if err := s.change(testDB); err != nil {
+				t.Fatalf("[%s] Failed to change the boundary item: %v", s.name, err)
+			}
+
+			secondPage, _ := exec(cursor)
+			if v := strings.Join(secondPage, ","); v != s.expect {
+				t.Errorf("[%s] Expected second page %s, got %s", s.name, s.expect, v)
+			}
+		}()
+	}
+}
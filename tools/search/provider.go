@@ -23,6 +23,9 @@ const (
 	SortQueryParam      string = "sort"
 	FilterQueryParam    string = "filter"
 	SkipTotalQueryParam string = "skipTotal"
+
+	CursorQueryParam        string = "cursor"
+	EstimateTotalQueryParam string = "estimateTotal"
 )
 
 // Result defines the returned search result structure.
@@ -32,6 +35,15 @@ type Result struct {
 	TotalItems int `json:"totalItems"`
 	TotalPages int `json:"totalPages"`
 	Items      any `json:"items"`
+
+	// NextCursor is the opaque cursor of the next page (cursor pagination only).
+	//
+	// It is empty if there are no more items.
+	NextCursor string `json:"nextCursor,omitempty"`
+
+	// EstimatedTotal indicates that TotalItems and TotalPages
+	// are based on the db planner estimate and not on exact count.
+	EstimatedTotal bool `json:"estimatedTotal,omitempty"`
 }
 
 // Provider represents a single configured search provider instance.
@@ -39,6 +51,10 @@ type Provider struct {
 	fieldResolver FieldResolver
 	query         *dbx.SelectQuery
 	skipTotal     bool
+	estimateTotal bool
+	cursorMode    bool
+	cursor        string
+	cursorSecret  string
 	countCol      string
 	page          int
 	perPage       int
@@ -80,6 +96,42 @@ func (s *Provider) SkipTotal(skipTotal bool) *Provider {
 	return s
 }
 
+// EstimateTotal changes the `estimateTotal` field of the current search provider.
+//
+// When enabled and the db driver is PostgreSQL, the total items count
+// is estimated from the table statistics or the query plan instead of
+// running an exact COUNT query (other drivers fallback to the exact count).
+//
+// This field is ignored if skipTotal is true.
+func (s *Provider) EstimateTotal(estimateTotal bool) *Provider {
+	s.estimateTotal = estimateTotal
+	return s
+}
+
+// Cursor enables keyset (aka. cursor) pagination and sets the
+// opaque cursor returned as `nextCursor` from a previous search result.
+//
+// An empty cursor returns the first page.
+//
+// In cursor mode the `page` field is ignored, the base query ordering
+// is replaced with the provider's sort fields and the countCol is
+// appended as final sort tiebreaker.
+func (s *Provider) Cursor(cursor string) *Provider {
+	s.cursorMode = true
+	s.cursor = cursor
+	return s
+}
+
+// CursorSecret sets the secret used to encrypt the cursor sort values.
+//
+// Without a secret the cursor values are only base64 encoded, so it
+// should be set whenever the sort could include values that the
+// requester is not allowed to read (eg. hidden fields or emails).
+func (s *Provider) CursorSecret(secret string) *Provider {
+	s.cursorSecret = secret
+	return s
+}
+
 // CountCol allows changing the default column (id) that is used
 // to generated the COUNT SQL query statement.
 //
@@ -151,6 +203,18 @@ func (s *Provider) Parse(urlQuery string) error {
 		s.SkipTotal(v)
 	}
 
+	if raw := params.Get(EstimateTotalQueryParam); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		s.EstimateTotal(v)
+	}
+
+	if params.Has(CursorQueryParam) {
+		s.Cursor(params.Get(CursorQueryParam))
+	}
+
 	if raw := params.Get(PageQueryParam); raw != "" {
 		v, err := strconv.Atoi(raw)
 		if err != nil {
@@ -180,11 +244,21 @@ func (s *Provider) Parse(urlQuery string) error {
 	return nil
 }
 
-// Exec executes the search provider and fills/scans
-// the provided `items` slice with the found models.
-func (s *Provider) Exec(items any) (*Result, error) {
+// BuildQuery returns a copy of the provider's base query with the
+// filters, sorting and field resolver modifications applied (without pagination).
+//
+// It could be used to iterate over all matching items, eg. with a rows cursor.
+func (s *Provider) BuildQuery() (*dbx.SelectQuery, error) {
+	query, _, err := s.buildQuery()
+
+	return query, err
+}
+
+// buildQuery builds the provider's search query and returns it
+// together with the resolved keyset sort keys (in cursor mode).
+func (s *Provider) buildQuery() (*dbx.SelectQuery, []cursorKey, error) {
 	if s.query == nil {
-		return nil, errors.New("query is not set")
+		return nil, nil, errors.New("query is not set")
 	}
 
 	// shallow clone the provider's query
@@ -194,7 +268,7 @@ func (s *Provider) Exec(items any) (*Result, error) {
 	for _, f := range s.filter {
 		expr, err := f.BuildExpr(s.fieldResolver)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if expr != nil {
 			modelsQuery.AndWhere(expr)
@@ -202,26 +276,113 @@ func (s *Provider) Exec(items any) (*Result, error) {
 	}
 
 	// apply sorting
-	for _, sortField := range s.sort {
-		expr, err := sortField.BuildExpr(s.fieldResolver)
+	var keys []cursorKey
+	if s.cursorMode {
+		var err error
+		keys, err = s.cursorKeys()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if expr != "" {
-			modelsQuery.AndOrderBy(expr)
+
+		// the keyset condition relies on the exact rows order
+		modelsQuery.OrderBy( /* reset */ )
+		for _, key := range keys {
+			if key.desc {
+				modelsQuery.AndOrderBy(key.identifier + " " + SortDesc)
+			} else {
+				modelsQuery.AndOrderBy(key.identifier + " " + SortAsc)
+			}
+		}
+	} else {
+		for _, sortField := range s.sort {
+			expr, err := sortField.BuildExpr(s.fieldResolver)
+			if err != nil {
+				return nil, nil, err
+			}
+			if expr != "" {
+				modelsQuery.AndOrderBy(expr)
+			}
 		}
 	}
 
 	// apply field resolver query modifications (if any)
 	if err := s.fieldResolver.UpdateQuery(&modelsQuery); err != nil {
+		return nil, nil, err
+	}
+
+	return &modelsQuery, keys, nil
+}
+
+// cursorKeys resolves the provider's sort fields into keyset sort
+// keys, appending the countCol as unique tiebreaker.
+func (s *Provider) cursorKeys() ([]cursorKey, error) {
+	keys := make([]cursorKey, 0, len(s.sort)+1)
+
+	var hasTiebreaker bool
+	for _, sortField := range s.sort {
+		if sortField.Name == randomSortKey {
+			return nil, errors.New("random sort is not supported with cursor pagination")
+		}
+
+		identifier, err := sortField.resolveIdentifier(s.fieldResolver)
+		if err != nil {
+			return nil, err
+		}
+
+		if sortField.Name == s.countCol {
+			hasTiebreaker = true
+		}
+
+		keys = append(keys, cursorKey{
+			name:       sortField.Name,
+			identifier: identifier,
+			desc:       sortField.Direction == SortDesc,
+		})
+	}
+
+	if !hasTiebreaker {
+		keys = append(keys, cursorKey{
+			name:       s.countCol,
+			identifier: "[[" + s.qualifiedCountCol() + "]]",
+		})
+	}
+
+	return keys, nil
+}
+
+// qualifiedCountCol returns the countCol prefixed with the base query table (if any).
+func (s *Provider) qualifiedCountCol() string {
+	if s.query != nil {
+		if from := s.query.Info().From; len(from) > 0 {
+			return from[0] + "." + s.countCol
+		}
+	}
+
+	return s.countCol
+}
+
+// Exec executes the search provider and fills/scans
+// the provided `items` slice with the found models.
+func (s *Provider) Exec(items any) (*Result, error) {
+	query, keys, err := s.buildQuery()
+	if err != nil {
 		return nil, err
 	}
+	modelsQuery := *query
 
 	// normalize page
-	if s.page <= 0 {
+	if s.page <= 0 || s.cursorMode {
 		s.page = 1
 	}
 
+	var cursorValues []any
+	if s.cursorMode && s.cursor != "" {
+		cursorValues, err = decodeCursor(s.cursor, keys, s.cursorSecret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// normalize perPage
 	if s.perPage <= 0 {
 		s.perPage = DefaultPerPage
@@ -232,6 +393,8 @@ func (s *Provider) Exec(items any) (*Result, error) {
 	// negative value to differentiate from the zero default
 	totalCount := -1
 	totalPages := -1
+	var estimated bool
+	var nextCursor string
 
 	// prepare a count query from the base one
 	countQuery := modelsQuery // shallow clone
@@ -242,6 +405,19 @@ func (s *Provider) Exec(items any) (*Result, error) {
 			countCol = queryInfo.From[0] + "." + countCol
 		}
 
+		if s.estimateTotal {
+			total, ok, err := estimateCount(countQuery, countCol)
+			if err != nil {
+				return err
+			}
+			if ok {
+				estimated = true
+				totalCount = total
+				totalPages = int(math.Ceil(float64(totalCount) / float64(s.perPage)))
+				return nil
+			}
+		}
+
 		// note: countQuery is shallow cloned and slice/map in-place modifications should be avoided
 		err := countQuery.Distinct(false).
 			Select("COUNT(DISTINCT [[" + countCol + "]])").
@@ -258,10 +434,32 @@ func (s *Provider) Exec(items any) (*Result, error) {
 
 	// apply pagination to the original query and fetch the models
 	modelsExec := func() error {
-		modelsQuery.Limit(int64(s.perPage))
-		modelsQuery.Offset(int64(s.perPage * (s.page - 1)))
+		if !s.cursorMode {
+			modelsQuery.Limit(int64(s.perPage))
+			modelsQuery.Offset(int64(s.perPage * (s.page - 1)))
+
+			return modelsQuery.All(items)
+		}
+
+		if cursorValues != nil {
+			modelsQuery.AndWhere(&keysetExpr{keys: keys, values: cursorValues})
+		}
+
+		// fetch 1 extra item to check whether there is a next page
+		modelsQuery.Limit(int64(s.perPage + 1))
+
+		if err := modelsQuery.All(items); err != nil {
+			return err
+		}
+
+		if !trimItems(items, s.perPage) {
+			return nil // no more items
+		}
 
-		return modelsQuery.All(items)
+		var err error
+		nextCursor, err = s.nextCursor(*query, keys, items)
+
+		return err
 	}
 
 	if !s.skipTotal {
@@ -280,16 +478,65 @@ func (s *Provider) Exec(items any) (*Result, error) {
 	}
 
 	result := &Result{
-		Page:       s.page,
-		PerPage:    s.perPage,
-		TotalItems: totalCount,
-		TotalPages: totalPages,
-		Items:      items,
+		Page:           s.page,
+		PerPage:        s.perPage,
+		TotalItems:     totalCount,
+		TotalPages:     totalPages,
+		Items:          items,
+		NextCursor:     nextCursor,
+		EstimatedTotal: estimated,
 	}
 
 	return result, nil
 }
 
+// nextCursor loads the sort key values of the last fetched item
+// and encodes them into an opaque cursor string.
+//
+// The next page continues from these values, so it is not affected
+// if the item is later deleted or changed.
+func (s *Provider) nextCursor(query dbx.SelectQuery, keys []cursorKey, items any) (string, error) {
+	key, err := cursorItemKey(items, s.countCol)
+	if err != nil {
+		return "", err
+	}
+
+	identifiers := make([]string, len(keys))
+	for i, k := range keys {
+		identifiers[i] = k.identifier
+	}
+
+	// note: query is shallow cloned and slice/map in-place modifications should be avoided
+	rows, err := query.
+		Select(identifiers...).
+		AndWhere(dbx.NewExp("[["+s.qualifiedCountCol()+"]] = {:pbCursorKey}", dbx.Params{"pbCursorKey": key})).
+		OrderBy( /* reset */ ).
+		Limit(1).
+		Rows()
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", errors.New("failed to load the cursor item")
+	}
+
+	values := make([]any, len(keys))
+	pointers := make([]any, len(keys))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return "", err
+	}
+
+	return encodeCursor(keys, values, s.cursorSecret)
+}
+
 // ParseAndExec is a short convenient method to trigger both
 // `Parse()` and `Exec()` in a single call.
 func (s *Provider) ParseAndExec(urlQuery string, modelsSlice any) (*Result, error) {
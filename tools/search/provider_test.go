@@ -215,6 +215,86 @@ func TestProviderParse(t *testing.T) {
 	}
 }
 
+func TestProviderBuildQuery(t *testing.T) {
+	testDB, err := createTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Close()
+
+	query := testDB.Select("*").From("test")
+
+	scenarios := []struct {
+		name        string
+		query       *dbx.SelectQuery
+		sort        []SortField
+		filter      []FilterData
+		expectError bool
+		expectSQL   string
+	}{
+		{
+			"missing query",
+			nil,
+			[]SortField{},
+			[]FilterData{},
+			true,
+			"",
+		},
+		{
+			"invalid filter",
+			query,
+			[]SortField{},
+			[]FilterData{"invalid"},
+			true,
+			"",
+		},
+		{
+			"filter and sort without pagination",
+			query,
+			[]SortField{{"test2", SortDesc}},
+			[]FilterData{"test1 >= 2"},
+			false,
+			"^SELECT \\* FROM `test` WHERE test1 >= \\{:\\w+\\} ORDER BY `test2` DESC$",
+		},
+	}
+
+	for _, s := range scenarios {
+		testResolver := &testFieldResolver{}
+		p := NewProvider(testResolver).
+			Query(s.query).
+			Page(2).
+			PerPage(10).
+			Sort(s.sort).
+			Filter(s.filter)
+
+		result, err := p.BuildQuery()
+
+		hasErr := err != nil
+		if hasErr != s.expectError {
+			t.Errorf("[%s] Expected hasErr %v, got %v (%v)", s.name, s.expectError, hasErr, err)
+			continue
+		}
+
+		if hasErr {
+			continue
+		}
+
+		if testResolver.UpdateQueryCalls != 1 {
+			t.Errorf("[%s] Expected resolver.Update to be called %d, got %d", s.name, 1, testResolver.UpdateQueryCalls)
+		}
+
+		rawSQL := result.Build().SQL()
+		if !list.ExistInSliceWithRegex(rawSQL, []string{s.expectSQL}) {
+			t.Errorf("[%s] Expected query \n%v, got \n%v", s.name, s.expectSQL, rawSQL)
+		}
+
+		// the base query must remain unchanged
+		if baseSQL := s.query.Build().SQL(); baseSQL != "SELECT * FROM `test`" {
+			t.Errorf("[%s] Expected the base query to be unchanged, got \n%v", s.name, baseSQL)
+		}
+	}
+}
+
 func TestProviderExecEmptyQuery(t *testing.T) {
 	p := NewProvider(&testFieldResolver{}).
 		Query(nil)
@@ -505,6 +585,30 @@ func TestProviderParseAndExec(t *testing.T) {
 			false,
 			`{"page":1,"perPage":500,"totalItems":-1,"totalPages":-1,"items":[{"test1":2,"test2":"test2.2","test3":""}]}`,
 		},
+		{
+			"invalid estimateTotal",
+			"estimateTotal=a",
+			true,
+			"",
+		},
+		{
+			"estimateTotal with non-postgres driver (fallback to exact count)",
+			"page=1&estimateTotal=1",
+			false,
+			`{"page":1,"perPage":123,"totalItems":2,"totalPages":1,"items":[{"test1":1,"test2":"test2.1","test3":""},{"test1":2,"test2":"test2.2","test3":""}]}`,
+		},
+		{
+			"invalid cursor",
+			"cursor=invalid",
+			true,
+			"",
+		},
+		{
+			"empty cursor (first page)",
+			"page=2&cursor=&sort=-test1",
+			false,
+			`{"page":1,"perPage":123,"totalItems":2,"totalPages":1,"items":[{"test1":1,"test2":"test2.1","test3":""},{"test1":2,"test2":"test2.2","test3":""}]}`,
+		},
 	}
 
 	for _, s := range scenarios {
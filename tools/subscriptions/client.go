@@ -10,6 +10,9 @@ import (
 type Message struct {
 	Name string
 	Data []byte
+
+	// Id is the optional durable event id (used for the Last-Event-ID replay).
+	Id string
 }
 
 // Client is an interface for a generic subscription client.
@@ -20,7 +23,7 @@ type Client interface {
 	// Channel returns the client's communication channel.
 	Channel() chan Message
 
-	// Subscriptions returns all subscriptions to which the client has subscribed to.
+	// Subscriptions returns a copy of all subscriptions to which the client has subscribed to.
 	Subscriptions() map[string]struct{}
 
 	// Subscribe subscribes the client to the provided subscriptions list.
@@ -99,7 +102,14 @@ func (c *DefaultClient) Subscriptions() map[string]struct{} {
 	c.mux.RLock()
 	defer c.mux.RUnlock()
 
-	return c.subscriptions
+	// return a copy so that it is safe to iterate while the client
+	// subscriptions are changed concurrently
+	result := make(map[string]struct{}, len(c.subscriptions))
+	for s := range c.subscriptions {
+		result[s] = struct{}{}
+	}
+
+	return result
 }
 
 // Subscribe implements the [Client.Subscribe] interface method.
@@ -67,6 +67,18 @@ func TestSubscriptions(t *testing.T) {
 	if len(c.Subscriptions()) != 3 {
 		t.Errorf("Expected 3 subscriptions, got %v", c.Subscriptions())
 	}
+
+	// changing the returned map shouldn't affect the client subscriptions
+	subs := c.Subscriptions()
+	delete(subs, "sub1")
+	c.Unsubscribe("sub2")
+
+	if !c.HasSubscription("sub1") {
+		t.Errorf("Expected sub1 to be still subscribed")
+	}
+	if _, ok := subs["sub3"]; !ok || len(subs) != 2 {
+		t.Errorf("Expected the copy to be unaffected by Unsubscribe, got %v", subs)
+	}
 }
 
 func TestSubscribe(t *testing.T) {
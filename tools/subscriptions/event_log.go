@@ -0,0 +1,243 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrEventGap is returned when some of the events after the requested
+// one are no longer available (or the requested event id is unknown).
+var ErrEventGap = errors.New("the requested events are no longer available")
+
+// LoggedEvent defines a single EventLog event.
+type LoggedEvent struct {
+	Id   string
+	Data []byte
+}
+
+// EventLog defines a bounded log of the most recent realtime events
+// that allows the reconnected clients to receive the missed ones.
+//
+// The event ids are in the "{ms}-{seq}" format of the Redis Stream ids.
+type EventLog interface {
+	// Append stores a new event and returns its id.
+	Append(data []byte) (string, error)
+
+	// LastId returns the id of the most recent event (empty string if there are none).
+	LastId() (string, error)
+
+	// After returns the stored events following the one with the provided id.
+	//
+	// Returns ErrEventGap if some of the following events were already
+	// removed from the log or the id is not a known event id.
+	After(id string) ([]LoggedEvent, error)
+}
+
+// -------------------------------------------------------------------
+// in-memory log (single node)
+// -------------------------------------------------------------------
+
+// ensures that MemoryEventLog satisfies the EventLog interface
+var _ EventLog = (*MemoryEventLog)(nil)
+
+// MemoryEventLog is an EventLog that keeps the events in memory.
+//
+// The ids are prefixed with the log creation time so that the ids
+// from a previous app process are reported as unknown.
+type MemoryEventLog struct {
+	mux    sync.RWMutex
+	epoch  uint64
+	seq    uint64
+	max    int
+	events []LoggedEvent
+}
+
+// NewMemoryEventLog creates a new in-memory log with up to max events.
+func NewMemoryEventLog(max int) *MemoryEventLog {
+	return &MemoryEventLog{
+		epoch: uint64(time.Now().UnixMilli()),
+		max:   max,
+	}
+}
+
+// Append implements [EventLog.Append].
+func (l *MemoryEventLog) Append(data []byte) (string, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.seq++
+
+	id := formatEventId(l.epoch, l.seq)
+
+	l.events = append(l.events, LoggedEvent{Id: id, Data: data})
+	if len(l.events) > l.max {
+		l.events = l.events[len(l.events)-l.max:]
+	}
+
+	return id, nil
+}
+
+// LastId implements [EventLog.LastId].
+func (l *MemoryEventLog) LastId() (string, error) {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	if l.seq == 0 {
+		return "", nil
+	}
+
+	return formatEventId(l.epoch, l.seq), nil
+}
+
+// After implements [EventLog.After].
+func (l *MemoryEventLog) After(id string) ([]LoggedEvent, error) {
+	epoch, seq, err := parseEventId(id)
+	if err != nil {
+		return nil, ErrEventGap
+	}
+
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	// the seq of the last removed event
+	removedSeq := l.seq - uint64(len(l.events))
+
+	if epoch != l.epoch || seq > l.seq || seq < removedSeq {
+		return nil, ErrEventGap
+	}
+
+	result := make([]LoggedEvent, 0, l.seq-seq)
+	result = append(result, l.events[seq-removedSeq:]...)
+
+	return result, nil
+}
+
+// -------------------------------------------------------------------
+// Redis Streams log (shared between the app nodes)
+// -------------------------------------------------------------------
+
+// ensures that RedisEventLog satisfies the EventLog interface
+var _ EventLog = (*RedisEventLog)(nil)
+
+// RedisEventLog is an EventLog that keeps the events in a capped Redis Stream.
+type RedisEventLog struct {
+	client *redis.Client
+	stream string
+	max    int64
+}
+
+// NewRedisEventLog creates a new Redis Stream log with approximately up to max events.
+func NewRedisEventLog(client *redis.Client, stream string, max int64) *RedisEventLog {
+	return &RedisEventLog{
+		client: client,
+		stream: stream,
+		max:    max,
+	}
+}
+
+// Append implements [EventLog.Append].
+func (l *RedisEventLog) Append(data []byte) (string, error) {
+	return l.client.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: l.stream,
+		MaxLen: l.max,
+		Approx: true,
+		Values: map[string]any{"data": data},
+	}).Result()
+}
+
+// LastId implements [EventLog.LastId].
+func (l *RedisEventLog) LastId() (string, error) {
+	entries, err := l.client.XRevRangeN(context.Background(), l.stream, "+", "-", 1).Result()
+	if err != nil || len(entries) == 0 {
+		return "", err
+	}
+
+	return entries[0].ID, nil
+}
+
+// After implements [EventLog.After].
+func (l *RedisEventLog) After(id string) ([]LoggedEvent, error) {
+	if _, _, err := parseEventId(id); err != nil {
+		return nil, ErrEventGap
+	}
+
+	ctx := context.Background()
+
+	// the requested event must be still in the stream
+	// (otherwise some of the following could be trimmed)
+	first, err := l.client.XRangeN(ctx, l.stream, "-", "+", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(first) == 0 || compareEventIds(id, first[0].ID) < 0 {
+		return nil, ErrEventGap
+	}
+
+	entries, err := l.client.XRange(ctx, l.stream, id, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 || entries[0].ID != id {
+		return nil, ErrEventGap // unknown id
+	}
+
+	result := make([]LoggedEvent, 0, len(entries)-1)
+	for _, entry := range entries[1:] {
+		data, _ := entry.Values["data"].(string)
+		result = append(result, LoggedEvent{Id: entry.ID, Data: []byte(data)})
+	}
+
+	return result, nil
+}
+
+// -------------------------------------------------------------------
+
+// IsEventId checks whether the provided string is in the EventLog ids format.
+func IsEventId(id string) bool {
+	_, _, err := parseEventId(id)
+	return err == nil
+}
+
+func formatEventId(ms uint64, seq uint64) string {
+	return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq, 10)
+}
+
+func parseEventId(id string) (ms uint64, seq uint64, err error) {
+	rawMs, rawSeq, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid event id %q", id)
+	}
+
+	if ms, err = strconv.ParseUint(rawMs, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid event id %q", id)
+	}
+
+	if seq, err = strconv.ParseUint(rawSeq, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid event id %q", id)
+	}
+
+	return ms, seq, nil
+}
+
+// compareEventIds returns -1, 0 or 1 if a is respectively
+// before, the same or after b (invalid ids are treated as zero).
+func compareEventIds(a, b string) int {
+	aMs, aSeq, _ := parseEventId(a)
+	bMs, bSeq, _ := parseEventId(b)
+
+	switch {
+	case aMs < bMs || (aMs == bMs && aSeq < bSeq):
+		return -1
+	case aMs > bMs || (aMs == bMs && aSeq > bSeq):
+		return 1
+	default:
+		return 0
+	}
+}
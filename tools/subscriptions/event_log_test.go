@@ -0,0 +1,67 @@
+package subscriptions_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+func TestMemoryEventLog(t *testing.T) {
+	log := subscriptions.NewMemoryEventLog(3)
+
+	lastId, err := log.LastId()
+	if err != nil || lastId != "" {
+		t.Fatalf("Expected empty last id, got %q (%v)", lastId, err)
+	}
+
+	ids := []string{}
+	for _, data := range []string{"a", "b", "c", "d", "e"} {
+		id, err := log.Append([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	lastId, _ = log.LastId()
+	if lastId != ids[4] {
+		t.Fatalf("Expected last id %q, got %q", ids[4], lastId)
+	}
+
+	epoch, _, _ := strings.Cut(ids[0], "-")
+
+	scenarios := []struct {
+		name        string
+		id          string
+		expectGap   bool
+		expectItems string
+	}{
+		{"invalid id", "abc", true, ""},
+		{"other epoch", "1-1", true, ""},
+		{"unknown future id", epoch + "-6", true, ""},
+		{"removed events", ids[0], true, ""},
+		{"oldest available", ids[1], false, "cde"},
+		{"middle", ids[2], false, "de"},
+		{"latest", ids[4], false, ""},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			events, err := log.After(s.id)
+
+			if hasGap := errors.Is(err, subscriptions.ErrEventGap); hasGap != s.expectGap {
+				t.Fatalf("Expected gap %v, got %v", s.expectGap, err)
+			}
+
+			items := ""
+			for _, e := range events {
+				items += string(e.Data)
+			}
+			if items != s.expectItems {
+				t.Fatalf("Expected events %q, got %q", s.expectItems, items)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package subscriptions
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PresenceMember defines a single member of a presence channel.
+type PresenceMember struct {
+	ClientId string `json:"clientId"`
+	AuthId   string `json:"authId"`
+}
+
+// PresenceChange defines a single presence channel join or leave.
+type PresenceChange struct {
+	Channel string
+	Member  PresenceMember
+	Joined  bool
+}
+
+type presenceEntry struct {
+	nodeId string
+	member PresenceMember
+}
+
+// Presence keeps track of the presence channels members of all app nodes.
+//
+// Each node owns the members of its own clients and is expected to
+// periodically report them with Sync so that the missed join/leave
+// events and the members of the stopped nodes could be reconciled.
+type Presence struct {
+	mux      sync.RWMutex
+	channels map[string]map[string]presenceEntry // channel -> client id -> entry
+	nodes    map[string]time.Time                // node id -> last activity
+}
+
+// NewPresence initializes and returns a new Presence instance.
+func NewPresence() *Presence {
+	return &Presence{
+		channels: map[string]map[string]presenceEntry{},
+		nodes:    map[string]time.Time{},
+	}
+}
+
+// Join adds the node member to the channel and reports whether it is a new member.
+func (p *Presence) Join(nodeId string, channel string, member PresenceMember) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.nodes[nodeId] = time.Now()
+
+	return p.join(nodeId, channel, member)
+}
+
+// Leave removes the client from the channel and reports whether it was a member.
+func (p *Presence) Leave(nodeId string, channel string, clientId string) (PresenceMember, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.nodes[nodeId] = time.Now()
+
+	return p.leave(channel, clientId)
+}
+
+// Members returns the channel members sorted by their client id.
+func (p *Presence) Members(channel string) []PresenceMember {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+
+	result := make([]PresenceMember, 0, len(p.channels[channel]))
+	for _, entry := range p.channels[channel] {
+		result = append(result, entry.member)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ClientId < result[j].ClientId
+	})
+
+	return result
+}
+
+// Sync replaces all members of the node with the provided ones
+// (indexed by their channel) and returns the resulting changes.
+func (p *Presence) Sync(nodeId string, channels map[string][]PresenceMember) []PresenceChange {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.nodes[nodeId] = time.Now()
+
+	changes := []PresenceChange{}
+
+	// remove the members that are no longer reported
+	for channel, entries := range p.channels {
+		for clientId, entry := range entries {
+			if entry.nodeId != nodeId || hasPresenceMember(channels[channel], clientId) {
+				continue
+			}
+
+			if member, ok := p.leave(channel, clientId); ok {
+				changes = append(changes, PresenceChange{Channel: channel, Member: member})
+			}
+		}
+	}
+
+	for channel, members := range channels {
+		for _, member := range members {
+			if p.join(nodeId, channel, member) {
+				changes = append(changes, PresenceChange{Channel: channel, Member: member, Joined: true})
+			}
+		}
+	}
+
+	sortPresenceChanges(changes)
+
+	return changes
+}
+
+// Expire removes the members of the nodes that weren't active
+// since the provided time and returns the resulting changes.
+func (p *Presence) Expire(since time.Time) []PresenceChange {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	changes := []PresenceChange{}
+
+	for nodeId, lastActive := range p.nodes {
+		if !lastActive.Before(since) {
+			continue
+		}
+
+		delete(p.nodes, nodeId)
+
+		for channel, entries := range p.channels {
+			for clientId, entry := range entries {
+				if entry.nodeId != nodeId {
+					continue
+				}
+
+				if member, ok := p.leave(channel, clientId); ok {
+					changes = append(changes, PresenceChange{Channel: channel, Member: member})
+				}
+			}
+		}
+	}
+
+	sortPresenceChanges(changes)
+
+	return changes
+}
+
+func (p *Presence) join(nodeId string, channel string, member PresenceMember) bool {
+	entries, ok := p.channels[channel]
+	if !ok {
+		entries = map[string]presenceEntry{}
+		p.channels[channel] = entries
+	}
+
+	_, exists := entries[member.ClientId]
+
+	entries[member.ClientId] = presenceEntry{nodeId: nodeId, member: member}
+
+	return !exists
+}
+
+func (p *Presence) leave(channel string, clientId string) (PresenceMember, bool) {
+	entry, ok := p.channels[channel][clientId]
+	if !ok {
+		return PresenceMember{}, false
+	}
+
+	delete(p.channels[channel], clientId)
+	if len(p.channels[channel]) == 0 {
+		delete(p.channels, channel)
+	}
+
+	return entry.member, true
+}
+
+func hasPresenceMember(members []PresenceMember, clientId string) bool {
+	for _, m := range members {
+		if m.ClientId == clientId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortPresenceChanges(changes []PresenceChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Channel != changes[j].Channel {
+			return changes[i].Channel < changes[j].Channel
+		}
+		return changes[i].Member.ClientId < changes[j].Member.ClientId
+	})
+}
@@ -0,0 +1,97 @@
+package subscriptions_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/zhenruyan/postgrebase/tools/subscriptions"
+)
+
+func TestPresenceJoinLeave(t *testing.T) {
+	p := subscriptions.NewPresence()
+
+	if !p.Join("n1", "room", subscriptions.PresenceMember{ClientId: "c2", AuthId: "a2"}) {
+		t.Fatal("Expected c2 to be a new member")
+	}
+	if !p.Join("n2", "room", subscriptions.PresenceMember{ClientId: "c1"}) {
+		t.Fatal("Expected c1 to be a new member")
+	}
+	if p.Join("n1", "room", subscriptions.PresenceMember{ClientId: "c2", AuthId: "a2"}) {
+		t.Fatal("Expected c2 to be an existing member")
+	}
+
+	raw, _ := json.Marshal(p.Members("room"))
+	expected := `[{"clientId":"c1","authId":""},{"clientId":"c2","authId":"a2"}]`
+	if string(raw) != expected {
+		t.Fatalf("Expected members %s, got %s", expected, raw)
+	}
+
+	if _, ok := p.Leave("n1", "room", "missing"); ok {
+		t.Fatal("Expected missing client leave to be no-op")
+	}
+
+	member, ok := p.Leave("n1", "room", "c2")
+	if !ok || member.AuthId != "a2" {
+		t.Fatalf("Expected c2 to leave, got %v %v", member, ok)
+	}
+
+	if total := len(p.Members("room")); total != 1 {
+		t.Fatalf("Expected 1 member, got %d", total)
+	}
+
+	if total := len(p.Members("other")); total != 0 {
+		t.Fatalf("Expected no members, got %d", total)
+	}
+}
+
+func TestPresenceSync(t *testing.T) {
+	p := subscriptions.NewPresence()
+
+	p.Join("n1", "a", subscriptions.PresenceMember{ClientId: "c1"})
+	p.Join("n1", "b", subscriptions.PresenceMember{ClientId: "c2"})
+	p.Join("n2", "a", subscriptions.PresenceMember{ClientId: "c3"})
+
+	changes := p.Sync("n1", map[string][]subscriptions.PresenceMember{
+		"a": {{ClientId: "c1"}, {ClientId: "c4"}},
+	})
+
+	expected := []subscriptions.PresenceChange{
+		{Channel: "a", Member: subscriptions.PresenceMember{ClientId: "c4"}, Joined: true},
+		{Channel: "b", Member: subscriptions.PresenceMember{ClientId: "c2"}, Joined: false},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %v", len(expected), changes)
+	}
+	for i, c := range expected {
+		if changes[i] != c {
+			t.Errorf("(%d) Expected change %v, got %v", i, c, changes[i])
+		}
+	}
+
+	// the other nodes members are not affected
+	if total := len(p.Members("a")); total != 3 {
+		t.Fatalf("Expected 3 members, got %d", total)
+	}
+}
+
+func TestPresenceExpire(t *testing.T) {
+	p := subscriptions.NewPresence()
+
+	p.Join("n1", "a", subscriptions.PresenceMember{ClientId: "c1"})
+
+	if changes := p.Expire(time.Now().Add(-time.Minute)); len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %v", changes)
+	}
+
+	p.Join("n2", "a", subscriptions.PresenceMember{ClientId: "c2"})
+
+	changes := p.Expire(time.Now().Add(time.Minute))
+	if len(changes) != 2 || changes[0].Member.ClientId != "c1" || changes[1].Member.ClientId != "c2" || changes[0].Joined {
+		t.Fatalf("Expected c1 and c2 leave changes, got %v", changes)
+	}
+
+	if total := len(p.Members("a")); total != 0 {
+		t.Fatalf("Expected no members, got %d", total)
+	}
+}
@@ -0,0 +1,253 @@
+// Package xlsx implements a minimal streaming writer of single
+// worksheet Office Open XML spreadsheets (aka. .xlsx files).
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxCellLength is the max number of characters that a spreadsheet cell can contain.
+//
+// Longer string values are truncated.
+const MaxCellLength = 32767
+
+// DefaultSheetName is the worksheet name used when an empty one is provided.
+const DefaultSheetName = "Sheet1"
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+var staticParts = []struct {
+	name    string
+	content string
+}{
+	{
+		"[Content_Types].xml",
+		xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		"_rels/.rels",
+		xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		"xl/_rels/workbook.xml.rels",
+		xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+}
+
+// Writer writes the spreadsheet rows one by one directly to the
+// underlying io.Writer, without keeping them in memory.
+//
+// The caller must call Close to complete the spreadsheet.
+type Writer struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	rows   int
+	closed bool
+}
+
+// NewWriter creates a new spreadsheet Writer with a single worksheet.
+//
+// The sheetName is normalized to the spreadsheet worksheet name restrictions.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	for _, part := range staticParts {
+		if err := writePart(zw, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	workbook := xmlHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + escape(normalizeSheetName(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+	if err := writePart(zw, "xl/workbook.xml", workbook); err != nil {
+		return nil, err
+	}
+
+	// the worksheet is the last opened zip entry so that the rows could be streamed
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Writer{
+		zw:    zw,
+		sheet: bufio.NewWriter(sheet),
+	}
+
+	if _, err := result.sheet.WriteString(xmlHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// WriteRow appends a single row to the worksheet.
+//
+// Bool and number values are written as such, nil values as empty
+// cells and all other values are written as strings.
+func (w *Writer) WriteRow(values []any) error {
+	if w.closed {
+		return errors.New("the spreadsheet writer is already closed")
+	}
+
+	w.rows++
+
+	var row strings.Builder
+
+	row.WriteString(`<row r="`)
+	row.WriteString(strconv.Itoa(w.rows))
+	row.WriteString(`">`)
+
+	for i, value := range values {
+		ref := ColumnName(i) + strconv.Itoa(w.rows)
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			row.WriteString(`<c r="` + ref + `" t="b"><v>` + b + `</v></c>`)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			row.WriteString(`<c r="` + ref + `"><v>` + fmt.Sprint(v) + `</v></c>`)
+		case float32:
+			writeFloat(&row, ref, float64(v))
+		case float64:
+			writeFloat(&row, ref, v)
+		default:
+			writeString(&row, ref, fmt.Sprint(v))
+		}
+	}
+
+	row.WriteString(`</row>`)
+
+	_, err := w.sheet.WriteString(row.String())
+
+	return err
+}
+
+// Flush writes the buffered rows to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+
+	return w.zw.Flush()
+}
+
+// Close completes the spreadsheet.
+//
+// It doesn't close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if _, err := w.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+
+	return w.zw.Close()
+}
+
+// ColumnName returns the spreadsheet column name of the
+// zero based column index (eg. 0 -> "A", 26 -> "AA").
+func ColumnName(index int) string {
+	name := ""
+
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+
+	return name
+}
+
+func writeFloat(row *strings.Builder, ref string, v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		writeString(row, ref, strconv.FormatFloat(v, 'g', -1, 64))
+		return
+	}
+
+	row.WriteString(`<c r="` + ref + `"><v>` + strconv.FormatFloat(v, 'g', -1, 64) + `</v></c>`)
+}
+
+func writeString(row *strings.Builder, ref string, v string) {
+	if utf8.RuneCountInString(v) > MaxCellLength {
+		v = string([]rune(v)[:MaxCellLength])
+	}
+
+	row.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+	row.WriteString(escape(v))
+	row.WriteString(`</t></is></c>`)
+}
+
+func writePart(zw *zip.Writer, name string, content string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, content)
+
+	return err
+}
+
+// escape returns the XML escaped string
+// (the invalid XML characters are replaced with U+FFFD).
+func escape(v string) string {
+	var b strings.Builder
+
+	xml.EscapeText(&b, []byte(v))
+
+	return b.String()
+}
+
+// normalizeSheetName strips the characters that are not allowed
+// in a worksheet name and limits its length to 31 characters.
+func normalizeSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	name = strings.Trim(strings.TrimSpace(name), "'")
+
+	if utf8.RuneCountInString(name) > 31 {
+		name = string([]rune(name)[:31])
+	}
+
+	if name == "" {
+		return DefaultSheetName
+	}
+
+	return name
+}
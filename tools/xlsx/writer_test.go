@@ -0,0 +1,130 @@
+package xlsx_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zhenruyan/postgrebase/tools/xlsx"
+)
+
+func TestColumnName(t *testing.T) {
+	scenarios := []struct {
+		index    int
+		expected string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+
+	for _, s := range scenarios {
+		if v := xlsx.ColumnName(s.index); v != s.expected {
+			t.Errorf("[%d] Expected %q, got %q", s.index, s.expected, v)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	w, err := xlsx.NewWriter(buf, "test/sheet:[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]any{
+		{"id", "title", "count", "active", "empty"},
+		{"a1", "<b>&\"test\"</b>", 10, true, nil},
+		{"a2", strings.Repeat("x", xlsx.MaxCellLength+10), 1.5, false, "\x00"},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.WriteRow([]any{"closed"}); err == nil {
+		t.Fatal("Expected WriteRow to fail after Close")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(content)
+
+		// all parts must be well-formed xml
+		decoder := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				if err != io.EOF {
+					t.Fatalf("Invalid %s xml: %v", f.Name, err)
+				}
+				break
+			}
+		}
+	}
+
+	expectedParts := []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/_rels/workbook.xml.rels",
+		"xl/workbook.xml",
+		"xl/worksheets/sheet1.xml",
+	}
+	if len(parts) != len(expectedParts) {
+		t.Fatalf("Expected %d parts, got %d", len(expectedParts), len(parts))
+	}
+	for _, name := range expectedParts {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("Missing %s part", name)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="testsheet1" sheetId="1" r:id="rId1"/>`) {
+		t.Fatalf("Expected normalized sheet name, got \n%s", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+
+	expectedCells := []string{
+		`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">&lt;b&gt;&amp;&#34;test&#34;&lt;/b&gt;</t></is></c>`,
+		`<c r="C2"><v>10</v></c><c r="D2" t="b"><v>1</v></c></row>`,
+		`<c r="C3"><v>1.5</v></c><c r="D3" t="b"><v>0</v></c><c r="E3" t="inlineStr"><is><t xml:space="preserve">` + "�" + `</t></is></c>`,
+		`<t xml:space="preserve">` + strings.Repeat("x", xlsx.MaxCellLength) + `</t>`,
+	}
+	for _, cell := range expectedCells {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("Missing cell %s", cell)
+		}
+	}
+
+	if strings.Contains(sheet, strings.Repeat("x", xlsx.MaxCellLength+1)) {
+		t.Errorf("Expected the long cell value to be truncated")
+	}
+}
@@ -284,5 +284,6 @@ export default {
     "OAuth login": "OAuth 登录",
     "If this window doesn't close automatically, close it manually.": "如果窗口没能自动关闭，请手动关掉它。",
     "Field Remark": "字段备注",
-    "Optional field remark/comment": "可选的字段备注/说明"
+    "Optional field remark/comment": "可选的字段备注/说明",
+    "Export records": "导出记录"
 };
@@ -284,5 +284,6 @@ export default {
     "OAuth login": "OAuth login",
     "If this window doesn't close automatically, close it manually.": "If this window doesn't close automatically, close it manually.",
     "Field Remark": "Field Remark",
-    "Optional field remark/comment": "Optional field remark/comment"
+    "Optional field remark/comment": "Optional field remark/comment",
+    "Export records": "Export"
 };
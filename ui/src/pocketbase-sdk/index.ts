@@ -36,7 +36,7 @@ import type {
     OAuth2UrlCallback,
     OAuth2AuthConfig,
 } from '@sdk/services/RecordService';
-import type { UnsubscribeFunc } from '@sdk/services/RealtimeService';
+import type { UnsubscribeFunc, RealtimeTransport, PresenceMember } from '@sdk/services/RealtimeService';
 import type { BackupFileInfo } from '@sdk/services/BackupService';
 import type { HealthCheckResponse } from '@sdk/services/HealthService';
 import type {
@@ -88,6 +88,8 @@ export type {
     OAuth2AuthConfig,
     OnStoreChangeFunc,
     UnsubscribeFunc,
+    RealtimeTransport,
+    PresenceMember,
     BaseQueryParams,
     ListQueryParams,
     RecordQueryParams,